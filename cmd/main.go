@@ -1,36 +1,63 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"strings"
 	"time"
+	_ "time/tzdata" // user timezones on images without zoneinfo
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/teamcutter/subscriptions-service-task/internal/auth"
+	"github.com/teamcutter/subscriptions-service-task/internal/authz"
+	"github.com/teamcutter/subscriptions-service-task/internal/codec"
+	schema "github.com/teamcutter/subscriptions-service-task/internal/db"
+	"github.com/teamcutter/subscriptions-service-task/internal/gql"
+	"github.com/teamcutter/subscriptions-service-task/internal/grpcapi"
 	"github.com/teamcutter/subscriptions-service-task/internal/handler"
+	"github.com/teamcutter/subscriptions-service-task/internal/middleware"
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+	"github.com/teamcutter/subscriptions-service-task/internal/notify"
+	"github.com/teamcutter/subscriptions-service-task/internal/openapi"
+	"github.com/teamcutter/subscriptions-service-task/internal/outbox"
+	"github.com/teamcutter/subscriptions-service-task/internal/privacy"
+	"github.com/teamcutter/subscriptions-service-task/internal/reminder"
 	"github.com/teamcutter/subscriptions-service-task/internal/repo"
+	"github.com/teamcutter/subscriptions-service-task/internal/router"
+	"github.com/teamcutter/subscriptions-service-task/internal/stream"
+	"github.com/teamcutter/subscriptions-service-task/internal/webhook"
+	subscriptionsv1 "github.com/teamcutter/subscriptions-service-task/pkg/api/subscriptions/v1"
 	"github.com/teamcutter/subscriptions-service-task/pkg/database"
 
 	"github.com/joho/godotenv"
+	"github.com/nats-io/nats.go"
 	"github.com/labstack/echo/v4"
 	echoSwagger "github.com/swaggo/echo-swagger"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
 
-	_ "github.com/teamcutter/subscriptions-service-task/docs"
+	"github.com/teamcutter/subscriptions-service-task/docs"
 )
 
 var (
 	httpRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
 		Name: "http_requests_total",
 		Help: "Total number of HTTP requests",
-	}, []string{"method", "endpoint", "status"})
+	}, []string{"method", "endpoint", "status", "tenant"})
 
 	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
 		Name:    "http_request_duration_seconds",
 		Help:    "HTTP request duration in seconds",
 		Buckets: prometheus.DefBuckets,
-	}, []string{"method", "endpoint"})
+	}, []string{"method", "endpoint", "tenant"})
 )
 
 func metricsMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
@@ -45,13 +72,173 @@ func metricsMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
 		duration := time.Since(start).Seconds()
 		status := c.Response().Status
 
-		httpRequestsTotal.WithLabelValues(method, endpoint, http.StatusText(status)).Inc()
-		httpRequestDuration.WithLabelValues(method, endpoint).Observe(duration)
+		// tenant is resolved by middlewares running after this one,
+		// unauthenticated requests belong to no tenant
+		tenant := "none"
+		if _, ok := auth.PrincipalFromContext(c.Request().Context()); ok {
+			tenant = auth.TenantFromContext(c.Request().Context())
+		}
+
+		httpRequestsTotal.WithLabelValues(method, endpoint, http.StatusText(status), tenant).Inc()
+		httpRequestDuration.WithLabelValues(method, endpoint, tenant).Observe(duration)
 
 		return err
 	}
 }
 
+// unversionedDeprecatedAt is when routes without version prefix were
+// deprecated in favor of /v1
+var unversionedDeprecatedAt = time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+
+// unversionedSunset is when routes without version prefix are removed
+func unversionedSunset(logger *slog.Logger) time.Time {
+	sunset, err := time.Parse("2006-01-02", os.Getenv("UNVERSIONED_API_SUNSET"))
+	if err != nil {
+		logger.Warn("UNVERSIONED_API_SUNSET is not set or invalid, using default", "default", "2027-04-19")
+		return time.Date(2027, 4, 19, 0, 0, 0, 0, time.UTC)
+	}
+	return sunset
+}
+
+// idempotencyTTL is how long stored responses for Idempotency-Key are kept
+func idempotencyTTL(logger *slog.Logger) time.Duration {
+	ttl, err := time.ParseDuration(os.Getenv("IDEMPOTENCY_TTL"))
+	if err != nil || ttl <= 0 {
+		logger.Warn("IDEMPOTENCY_TTL is not set or invalid, using default", "default", "24h")
+		return 24 * time.Hour
+	}
+	return ttl
+}
+
+// notifiers returns notifiers of channels configured in env
+func notifiers(logger *slog.Logger) map[string]notify.Notifier {
+	notifiers := map[string]notify.Notifier{}
+
+	if addr := os.Getenv("SMTP_ADDR"); addr != "" {
+		smtpNotifier, err := notify.NewSMTPNotifier(notify.SMTPConfig{
+			Addr: addr,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From: os.Getenv("SMTP_FROM"),
+		})
+		if err != nil {
+			logger.Error("email notifications disabled", "error", err)
+		} else {
+			notifiers[model.ChannelEmail] = smtpNotifier
+		}
+	}
+
+	if url := os.Getenv("NOTIFY_WEBHOOK_URL"); url != "" {
+		notifiers[model.ChannelWebhook] = notify.NewWebhookNotifier(url)
+	}
+	return notifiers
+}
+
+// broker returns broker publishing outbox events to consumers of this
+// process and to OUTBOX_BROKER: nats, log or none when empty
+func broker(local *outbox.InProcessBroker, logger *slog.Logger) (outbox.Broker, error) {
+	switch kind := os.Getenv("OUTBOX_BROKER"); kind {
+	case "":
+		return local, nil
+	case "log":
+		return outbox.Fanout{local, outbox.NewLogBroker(logger)}, nil
+	case "nats":
+		nc, err := nats.Connect(os.Getenv("NATS_URL"), nats.MaxReconnects(-1))
+		if err != nil {
+			return nil, err
+		}
+		stream, prefix := os.Getenv("NATS_STREAM"), os.Getenv("NATS_SUBJECT")
+		if stream == "" {
+			stream = "SUBSCRIPTIONS"
+		}
+		if prefix == "" {
+			prefix = "subscriptions"
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		nb, err := outbox.NewNATSBroker(ctx, nc, stream, prefix)
+		if err != nil {
+			nc.Close()
+			return nil, err
+		}
+		return outbox.Fanout{local, nb}, nil
+	default:
+		return nil, fmt.Errorf("unknown OUTBOX_BROKER %q", kind)
+	}
+}
+
+// validationMode is what happens to requests not conforming to the spec
+func validationMode(logger *slog.Logger) middleware.ValidationMode {
+	mode, err := middleware.ParseValidationMode(os.Getenv("OPENAPI_VALIDATION"))
+	if err != nil {
+		logger.Warn("OPENAPI_VALIDATION is not set or invalid, using default", "default", middleware.ValidationLenient)
+		return middleware.ValidationLenient
+	}
+	return mode
+}
+
+// rateLimit reads limit like "600/m" from env
+func rateLimit(env, fallback string, logger *slog.Logger) middleware.Limit {
+	limit, err := middleware.ParseLimit(os.Getenv(env))
+	if err != nil {
+		logger.Warn(env+" is not set or invalid, using default", "default", fallback)
+		limit, _ = middleware.ParseLimit(fallback)
+	}
+	return limit
+}
+
+// routes with limiters of their own, named as in RATE_LIMIT_ROUTES
+const (
+	routeTotal = "GET /subscriptions/total"
+	routeSettle = "GET /households/:id/settle"
+	routeTotalV2 = "GET /v2/subscriptions/total"
+)
+
+// routeLimiters returns a limiter per route, RATE_LIMIT_ROUTES sets their
+// limits and routes missing there get fallback
+func routeLimiters(fallback middleware.Limit, logger *slog.Logger) map[string]*middleware.RateLimiter {
+	limits, err := middleware.ParseRouteLimits(os.Getenv("RATE_LIMIT_ROUTES"))
+	if err != nil {
+		logger.Error("invalid RATE_LIMIT_ROUTES", "error", err)
+		os.Exit(1)
+	}
+
+	limiters := map[string]*middleware.RateLimiter{}
+	for _, route := range []string{routeTotal, routeSettle, routeTotalV2} {
+		limit, ok := limits[route]
+		if !ok {
+			limit = fallback
+		}
+		delete(limits, route)
+		limiters[route] = middleware.NewRateLimiter(route, limit)
+	}
+	for route := range limits {
+		logger.Warn("RATE_LIMIT_ROUTES names a route without own limiter", "route", route)
+	}
+	return limiters
+}
+
+// ipExtractor reads client address of requests. Forwarded headers are
+// only trusted from proxies listed in TRUSTED_PROXIES as CIDRs, otherwise
+// clients could pick any address to escape per-address rate limits
+func ipExtractor(logger *slog.Logger) echo.IPExtractor {
+	proxies := os.Getenv("TRUSTED_PROXIES")
+	if proxies == "" {
+		return echo.ExtractIPDirect()
+	}
+
+	options := []echo.TrustOption{echo.TrustLoopback(false), echo.TrustLinkLocal(false), echo.TrustPrivateNet(false)}
+	for _, cidr := range strings.Split(proxies, ",") {
+		_, ipNet, err := net.ParseCIDR(strings.TrimSpace(cidr))
+		if err != nil {
+			logger.Error("invalid TRUSTED_PROXIES", "cidr", cidr, "error", err)
+			os.Exit(1)
+		}
+		options = append(options, echo.TrustIPRange(ipNet))
+	}
+	return echo.ExtractIPFromXFFHeader(options...)
+}
+
 func setupLogger() *slog.Logger {
 	return slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
 		Level: slog.LevelInfo,
@@ -62,7 +249,16 @@ func setupLogger() *slog.Logger {
 // @version 1.0
 // @description REST API for manage users' subscriptions
 // @host localhost:8080
+// @schemes http
 // @BasePath /
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+// @description JWT as "Bearer <token>", subject is user ID
+// @securityDefinitions.apikey APIKeyAuth
+// @in header
+// @name X-API-Key
+// @description API key for service-to-service calls
 func main() {
 	logger := setupLogger()
 
@@ -79,19 +275,185 @@ func main() {
 
 	logger.Info("database connected successfully")
 
-	repo := repo.NewSubscriptionRepo(db)
-	h := handler.NewHandler(repo, logger)
+	// databases of earlier versions get new tables and columns before
+	// anything queries them
+	if err := schema.Migrate(db); err != nil {
+		logger.Error("database migration failed", "error", err)
+		return
+	}
+
+	if err := checkSecrets(); err != nil {
+		logger.Error("secrets check failed", "error", err)
+		return
+	}
+
+	verifier, err := auth.NewVerifier(auth.ConfigFromEnv())
+	if err != nil {
+		logger.Error("auth setup failed", "error", err)
+		return
+	}
+
+	roles := authz.DefaultRoles
+	if path := os.Getenv("RBAC_ROLES_FILE"); path != "" {
+		roles, err = authz.LoadRoles(path)
+		if err != nil {
+			logger.Error("roles loading failed", "error", err)
+			return
+		}
+	}
+	policy := authz.NewPolicy(roles, repo.NewAuditRepo(db), logger)
+
+	userRepo := repo.NewUserRepo(db)
+	// in permissive mode, the default, unknown users are created with default
+	// preferences, strict mode rejects subscriptions of users that were never saved
+	permissive := os.Getenv("USERS_MODE") != "strict"
+	webhookRepo := repo.NewWebhookRepo(db)
+	subscriptionRepo := repo.NewUserCheckRepo(repo.NewSubscriptionRepo(db), userRepo, permissive)
+	idempotencyRepo := repo.NewIdempotencyRepo(db)
+	h := handler.NewHandler(subscriptionRepo, userRepo, codec.Default(), policy, logger)
+	hV2 := handler.NewHandlerV2(subscriptionRepo, userRepo, policy, logger)
+	importHandler := handler.NewImportHandler(subscriptionRepo, repo.NewImportJobRepo(db), policy, logger)
+	receiptKey := os.Getenv("ERASURE_RECEIPT_KEY")
+	if receiptKey == "" {
+		logger.Error("ERASURE_RECEIPT_KEY is not set")
+		return
+	}
+	// webhook URLs come from tenants, loopback and private networks are
+	// reachable only with WEBHOOK_ALLOW_PRIVATE=true, for local development
+	webhookTargets := webhook.Targets{AllowPrivate: os.Getenv("WEBHOOK_ALLOW_PRIVATE") == "true"}
+	webhookHandler := handler.NewWebhookHandler(webhookRepo, webhookTargets, policy, logger)
+	// the replay buffer holds events clients may resume after
+	hub := stream.NewHub(1000)
+	streamHandler := handler.NewStreamHandler(hub, policy, logger)
+	userHandler := handler.NewUserHandler(userRepo, repo.NewUserDataRepo(db), privacy.NewSigner([]byte(receiptKey)), policy, logger)
+	apiKeyRepo := repo.NewAPIKeyRepo(db)
+	apiKeyHandler := handler.NewAPIKeyHandler(apiKeyRepo, policy, logger)
+	tenantRepo := repo.NewTenantRepo(db)
+	householdHandler := handler.NewHouseholdHandler(repo.NewHouseholdRepo(db), subscriptionRepo, policy, logger)
+	tenantHandler := handler.NewTenantHandler(tenantRepo, policy, logger)
+	schema, err := gql.NewSchema(policy)
+	if err != nil {
+		logger.Error("graphql schema failed", "error", err)
+		return
+	}
+	graphqlHandler := handler.NewGraphQLHandler(schema, subscriptionRepo, userRepo, policy, logger)
+
+	if key := os.Getenv("BOOTSTRAP_ADMIN_API_KEY"); key != "" {
+		if err := apiKeyRepo.Bootstrap("bootstrap-admin", key[:min(len(key), 11)], auth.HashAPIKey(key)); err != nil {
+			logger.Error("bootstrap admin api key failed", "error", err)
+			return
+		}
+	}
+
+	// addresses are limited before authentication so that floods of bad
+	// credentials do not reach the DB, callers are limited after it. gRPC
+	// calls take tokens from the same limiters
+	ipLimiter := middleware.NewRateLimiter("ip", rateLimit("RATE_LIMIT_IP", "1200/m", logger))
+	callerLimiter := middleware.NewRateLimiter("caller", rateLimit("RATE_LIMIT", "600/m", logger))
+	ipLimit := middleware.RateLimit(ipLimiter, middleware.KeyByIP, logger)
+	callerLimit := middleware.RateLimit(callerLimiter, middleware.KeyByPrincipal, logger)
+	// expensive routes also have limiters of their own, shared by /v1 and
+	// unversioned paths of a route
+	routeLimiters := routeLimiters(rateLimit("RATE_LIMIT_TOTAL", "30/m", logger), logger)
+	totalLimit := middleware.RateLimit(routeLimiters[routeTotal], middleware.KeyByPrincipal, logger)
+	settleLimit := middleware.RateLimit(routeLimiters[routeSettle], middleware.KeyByPrincipal, logger)
+	totalV2Limit := middleware.RateLimit(routeLimiters[routeTotalV2], middleware.KeyByPrincipal, logger)
+
+	idempotency := middleware.Idempotency(idempotencyRepo, idempotencyTTL(logger), logger)
+
+	validator, err := openapi.NewValidator(docs.OpenAPI)
+	if err != nil {
+		logger.Error("openapi spec loading failed", "error", err)
+		return
+	}
+	mode := validationMode(logger)
 
 	e := echo.New()
-	
-	subscriptionsGroup := e.Group("/subscriptions")
-	subscriptionsGroup.Use(metricsMiddleware)
-	subscriptionsGroup.POST("", h.Create)
-	subscriptionsGroup.GET("", h.GetAll)
-	subscriptionsGroup.DELETE("/:id", h.Delete)
-	subscriptionsGroup.GET("/total", h.TotalCost)
-
-	e.GET("/swagger/*", echoSwagger.WrapHandler)
+	e.IPExtractor = ipExtractor(logger)
+
+	// authChain authenticates callers of API groups, checks their scope and
+	// validates requests, see router.Middlewares
+	authChain := func(specPrefix string, scope echo.MiddlewareFunc) []echo.MiddlewareFunc {
+		chain := []echo.MiddlewareFunc{
+			metricsMiddleware,
+			ipLimit,
+			middleware.APIKey(apiKeyRepo, logger),
+			middleware.JWT(verifier, logger),
+			middleware.Tenant(tenantRepo, logger),
+			callerLimit,
+		}
+		if scope != nil {
+			chain = append(chain, scope)
+		}
+		return append(chain, middleware.ValidateRequests(validator, specPrefix, mode, logger))
+	}
+
+	router.Mount(e, router.Handlers{
+		Subscriptions: h,
+		SubscriptionsV2: hV2,
+		Import: importHandler,
+		Stream: streamHandler,
+		Households: householdHandler,
+		Users: userHandler,
+		GraphQL: graphqlHandler,
+		Webhooks: webhookHandler,
+		APIKeys: apiKeyHandler,
+		Tenants: tenantHandler,
+	}, router.Middlewares{
+		Auth: authChain,
+		Unversioned: []echo.MiddlewareFunc{middleware.Deprecated(unversionedDeprecatedAt, unversionedSunset(logger), "/v1")},
+		Idempotency: idempotency,
+		TotalLimit: totalLimit,
+		SettleLimit: settleLimit,
+		TotalV2Limit: totalV2Limit,
+	})
+
+	e.GET("/openapi.json", func(c echo.Context) error {
+		return c.Blob(http.StatusOK, echo.MIMEApplicationJSON, docs.OpenAPI)
+	})
+	e.GET("/swagger/*", echoSwagger.EchoWrapHandler(echoSwagger.URL("/openapi.json")))
+
+	// webhook deliveries are created from outbox events
+	local := outbox.NewInProcessBroker()
+	local.Subscribe(func(_ context.Context, event model.Event) error {
+		return webhookRepo.ForTenant(event.TenantID).Enqueue(event)
+	})
+	events, err := broker(local, logger)
+	if err != nil {
+		logger.Error("outbox broker failed", "error", err)
+		return
+	}
+	go outbox.NewRelay(repo.NewOutboxRepo(db), events, logger).Run(context.Background(), time.Second)
+	go webhook.NewDispatcher(webhookRepo, webhookTargets, logger).Run(context.Background(), 5*time.Second)
+
+	go func() {
+		if err := stream.Listen(context.Background(), database.DSN(), hub, logger); err != nil {
+			logger.Error("events listener failed, streams get no events", "error", err)
+		}
+	}()
+
+	if channels := notifiers(logger); len(channels) > 0 {
+		interval, err := time.ParseDuration(os.Getenv("REMINDERS_INTERVAL"))
+		if err != nil || interval <= 0 {
+			logger.Warn("REMINDERS_INTERVAL is not set or invalid, using default", "default", "1m")
+			interval = time.Minute
+		}
+		scheduler := reminder.NewScheduler(repo.NewNotificationRepo(db), channels, logger)
+		go scheduler.Run(context.Background(), interval)
+	} else {
+		logger.Info("reminders disabled, no notification channel configured")
+	}
+
+	go func() {
+		for range time.Tick(time.Hour) {
+			deleted, err := idempotencyRepo.DeleteExpired()
+			if err != nil {
+				logger.Error("idempotency keys cleanup failed", "error", err)
+				continue
+			}
+			logger.Info("expired idempotency keys deleted", "count", deleted)
+		}
+	}()
 	
 	go func() {
 		http.Handle("/metrics", promhttp.Handler())
@@ -103,6 +465,55 @@ func main() {
 		logger.Info("metrics run on port 2112")
 	}()
 
+	grpcServer := grpc.NewServer(grpc.ChainUnaryInterceptor(
+		grpcapi.Metrics(httpRequestsTotal, httpRequestDuration),
+		grpcapi.Logging(logger),
+		grpcapi.RateLimit(ipLimiter, logger),
+		grpcapi.Auth(apiKeyRepo, verifier, tenantRepo, logger),
+		grpcapi.RateLimit(callerLimiter, logger),
+		grpcapi.RateLimit(routeLimiters[routeTotal], logger, subscriptionsv1.SubscriptionService_GetTotalCost_FullMethodName),
+	))
+	subscriptionsv1.RegisterSubscriptionServiceServer(grpcServer, grpcapi.NewServer(subscriptionRepo, userRepo, policy, logger))
+	healthpb.RegisterHealthServer(grpcServer, health.NewServer())
+	reflection.Register(grpcServer)
+
+	go func() {
+		grpcPort := os.Getenv("GRPC_PORT")
+		if grpcPort == "" {
+			grpcPort = "9091"
+		}
+		lis, err := net.Listen("tcp", ":"+grpcPort)
+		if err != nil {
+			logger.Error("grpc failed", "error", err)
+			return
+		}
+		logger.Info("grpc runs on port " + grpcPort)
+		if err := grpcServer.Serve(lis); err != nil {
+			logger.Error("grpc failed", "error", err)
+		}
+	}()
+
 	port := os.Getenv("APP_PORT")
 	e.Logger.Fatal(e.Start(":" + port))
 }
+
+// placeholderSecrets are the public values of .env.example
+var placeholderSecrets = map[string]string{
+	"JWT_HS256_SECRET": "dev-secret-change-me",
+	"BOOTSTRAP_ADMIN_API_KEY": "sk_dev-admin-key-change-me",
+	"ERASURE_RECEIPT_KEY": "dev-receipt-key-change-me",
+}
+
+// checkSecrets refuses placeholder secrets unless APP_ENV is dev, anyone
+// knowing them would have admin access
+func checkSecrets() error {
+	if os.Getenv("APP_ENV") == "dev" {
+		return nil
+	}
+	for name, placeholder := range placeholderSecrets {
+		if os.Getenv(name) == placeholder {
+			return fmt.Errorf("%s is the placeholder from .env.example, set a secret or APP_ENV=dev", name)
+		}
+	}
+	return nil
+}
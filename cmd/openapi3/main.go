@@ -0,0 +1,60 @@
+// Command openapi3 converts docs/swagger.json generated by swag to
+// OpenAPI 3 docs/openapi.json and docs/openapi.yaml, the spec requests
+// are validated against
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/getkin/kin-openapi/openapi2"
+	"github.com/getkin/kin-openapi/openapi2conv"
+	"gopkg.in/yaml.v3"
+)
+
+func main() {
+	dir := flag.String("dir", "docs", "directory with swagger.json, output is written there")
+	flag.Parse()
+
+	data, err := os.ReadFile(filepath.Join(*dir, "swagger.json"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var doc2 openapi2.T
+	if err := json.Unmarshal(data, &doc2); err != nil {
+		log.Fatal(err)
+	}
+	doc3, err := openapi2conv.ToV3(&doc2)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := doc3.Validate(context.Background()); err != nil {
+		log.Fatal("converted spec is invalid: ", err)
+	}
+
+	out, err := json.MarshalIndent(doc3, "", "    ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(*dir, "openapi.json"), out, 0o644); err != nil {
+		log.Fatal(err)
+	}
+
+	// YAML is written from JSON so that it has the same fields
+	var tree any
+	if err := json.Unmarshal(out, &tree); err != nil {
+		log.Fatal(err)
+	}
+	out, err = yaml.Marshal(tree)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(*dir, "openapi.yaml"), out, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
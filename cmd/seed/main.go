@@ -0,0 +1,87 @@
+// Command seed fills the database with generated users and subscriptions
+// for demos and load tests. The same -seed and -until always give the same
+// data. With -fixtures the data is written as JSON instead, like
+// internal/repo/testdata/seed.json used by repo tests.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+	"github.com/teamcutter/subscriptions-service-task/internal/repo"
+	"github.com/teamcutter/subscriptions-service-task/internal/seed"
+	"github.com/teamcutter/subscriptions-service-task/pkg/database"
+)
+
+func main() {
+	_ = godotenv.Load()
+
+	seedValue := flag.Uint64("seed", 1, "seed of generated data")
+	users := flag.Int("users", 100, "number of users")
+	until := flag.String("until", time.Now().Format("01-2006"), "last month of generated dates, MM-YYYY")
+	history := flag.Int("history", 36, "subscriptions start up to this many months before -until")
+	tenant := flag.String("tenant", model.DefaultTenant, "tenant of inserted data")
+	fixtures := flag.String("fixtures", "", "write data as JSON to this file instead of inserting, - is stdout")
+	flag.Parse()
+
+	untilMonth, err := time.Parse("01-2006", *until)
+	if err != nil {
+		log.Fatalf("invalid -until %q, expected MM-YYYY", *until)
+	}
+	data := seed.Generate(seed.Options{Seed: *seedValue, Users: *users, Until: untilMonth, History: *history})
+
+	if *fixtures != "" {
+		if err := writeFixtures(*fixtures, data); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	conn, err := database.Connect()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer conn.Close()
+
+	if err := repo.NewUserRepo(conn).ForTenant(*tenant).SaveMany(data.Users); err != nil {
+		log.Fatal("insert users: ", err)
+	}
+	subs := repo.NewSubscriptionRepo(conn).ForTenant(*tenant)
+	for start := 0; start < len(data.Subscriptions); start += repo.MaxBatchSize {
+		chunk := data.Subscriptions[start:min(start+repo.MaxBatchSize, len(data.Subscriptions))]
+		ops := make([]model.BatchOperation, len(chunk))
+		for i := range chunk {
+			ops[i] = model.BatchOperation{Op: model.BatchOpCreate, Subscription: &chunk[i]}
+		}
+
+		results, err := subs.Batch(ops, true)
+		if err != nil {
+			log.Fatalf("insert subscriptions, %d inserted before: %v", start, err)
+		}
+		for _, result := range results {
+			if result.Err != nil {
+				log.Fatalf("insert subscriptions, %d inserted before: %v", start, result.Err)
+			}
+		}
+	}
+	fmt.Fprintf(os.Stderr, "inserted %d users and %d subscriptions to tenant %s\n", len(data.Users), len(data.Subscriptions), *tenant)
+}
+
+func writeFixtures(path string, data seed.Data) error {
+	out, err := json.MarshalIndent(data, "", "    ")
+	if err != nil {
+		return err
+	}
+	out = append(out, '\n')
+	if path == "-" {
+		_, err = os.Stdout.Write(out)
+		return err
+	}
+	return os.WriteFile(path, out, 0o644)
+}
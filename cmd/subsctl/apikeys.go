@@ -0,0 +1,143 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/teamcutter/subscriptions-service-task/internal/auth"
+	"github.com/teamcutter/subscriptions-service-task/internal/authz"
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+	"github.com/teamcutter/subscriptions-service-task/internal/repo"
+)
+
+var apiKeyHeader = []string{"ID", "TENANT", "NAME", "PREFIX", "SCOPES", "EXPIRES", "LAST USED", "REVOKED", "CREATED"}
+
+func apiKeyRow(key model.APIKey) []string {
+	return []string{
+		strconv.FormatInt(key.ID, 10),
+		cell(key.TenantID),
+		key.Name,
+		key.Prefix,
+		strings.Join(key.Scopes, ","),
+		timeCell(key.ExpiresAt),
+		timeCell(key.LastUsedAt),
+		timeCell(key.RevokedAt),
+		key.CreatedAt.Format(time.DateTime),
+	}
+}
+
+// printNewAPIKey shows the key, it can not be read again
+func printNewAPIKey(a *app, key model.NewAPIKey) error {
+	fmt.Fprintln(os.Stderr, "store the key now, it is not shown again")
+	return a.out.print(key, append(slices.Clone(apiKeyHeader), "KEY"), [][]string{append(apiKeyRow(key.APIKey), key.Key)})
+}
+
+// keyTenantFlag selects tenant of keys, platform keys have none
+func keyTenantFlag(fs *flag.FlagSet, usage string) *string {
+	return fs.String("tenant", "", usage)
+}
+
+func keyIDFlag(fs *flag.FlagSet) *int64 {
+	return fs.Int64("id", 0, "API key ID (required)")
+}
+
+func runAPIKeysList(a *app, args []string) error {
+	fs := flag.NewFlagSet("api-keys list", flag.ContinueOnError)
+	tenant := keyTenantFlag(fs, "only keys of tenant, keys of every tenant when empty")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	keys, err := repo.NewAPIKeyRepo(a.db).List(*tenant)
+	if err != nil {
+		return err
+	}
+	rows := make([][]string, len(keys))
+	for i, key := range keys {
+		rows[i] = apiKeyRow(key)
+	}
+	return a.out.print(keys, apiKeyHeader, rows)
+}
+
+func runAPIKeysCreate(a *app, args []string) error {
+	fs := flag.NewFlagSet("api-keys create", flag.ContinueOnError)
+	tenant := keyTenantFlag(fs, "tenant of key, platform key when empty")
+	name := fs.String("name", "", "name of key (required)")
+	scopes := fs.String("scopes", auth.ScopeRead, "comma separated scopes: "+strings.Join(auth.Scopes, ", "))
+	expires := fs.Duration("expires", 0, "key expires after this duration, never when 0")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *name == "" {
+		return errors.New("-name is required")
+	}
+
+	apiKey := model.APIKey{TenantID: *tenant, Name: *name, Scopes: strings.Split(*scopes, ",")}
+	for _, scope := range apiKey.Scopes {
+		if !slices.Contains(auth.Scopes, scope) {
+			return fmt.Errorf("unknown scope %q", scope)
+		}
+	}
+	if *expires > 0 {
+		expiresAt := time.Now().Add(*expires)
+		apiKey.ExpiresAt = &expiresAt
+	}
+
+	key, prefix, hash, err := auth.GenerateAPIKey()
+	if err != nil {
+		return err
+	}
+	apiKey.Prefix = prefix
+	if err := repo.NewAPIKeyRepo(a.db).Create(&apiKey, hash); err != nil {
+		return err
+	}
+	a.audit(*tenant, authz.ManageAPIKeys, fmt.Sprintf("subsctl create api key %d", apiKey.ID))
+	return printNewAPIKey(a, model.NewAPIKey{APIKey: apiKey, Key: key})
+}
+
+func runAPIKeysRotate(a *app, args []string) error {
+	fs := flag.NewFlagSet("api-keys rotate", flag.ContinueOnError)
+	tenant := keyTenantFlag(fs, "tenant the key must belong to, any when empty")
+	id := keyIDFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *id <= 0 {
+		return errors.New("-id is required")
+	}
+
+	key, prefix, hash, err := auth.GenerateAPIKey()
+	if err != nil {
+		return err
+	}
+	apiKey, err := repo.NewAPIKeyRepo(a.db).Rotate(*tenant, *id, prefix, hash)
+	if err != nil {
+		return err
+	}
+	a.audit(apiKey.TenantID, authz.ManageAPIKeys, fmt.Sprintf("subsctl rotate api key %d", apiKey.ID))
+	return printNewAPIKey(a, model.NewAPIKey{APIKey: *apiKey, Key: key})
+}
+
+func runAPIKeysRevoke(a *app, args []string) error {
+	fs := flag.NewFlagSet("api-keys revoke", flag.ContinueOnError)
+	tenant := keyTenantFlag(fs, "tenant the key must belong to, any when empty")
+	id := keyIDFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *id <= 0 {
+		return errors.New("-id is required")
+	}
+
+	if err := repo.NewAPIKeyRepo(a.db).Revoke(*tenant, *id); err != nil {
+		return err
+	}
+	a.audit(*tenant, authz.ManageAPIKeys, fmt.Sprintf("subsctl revoke api key %d", *id))
+	return a.out.print(map[string]int64{"revoked": *id}, []string{"REVOKED"}, [][]string{{strconv.FormatInt(*id, 10)}})
+}
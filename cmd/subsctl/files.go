@@ -0,0 +1,165 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/teamcutter/subscriptions-service-task/internal/authz"
+	"github.com/teamcutter/subscriptions-service-task/internal/codec"
+	"github.com/teamcutter/subscriptions-service-task/internal/importer"
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+)
+
+// fileFormat is format flag or the one of file extension, stdin and
+// stdout are CSV unless the flag is set
+func fileFormat(format, path string) (string, error) {
+	if format == "" {
+		switch filepath.Ext(path) {
+		case ".csv", "":
+			format = importer.FormatCSV
+		case ".ndjson", ".jsonl":
+			format = importer.FormatNDJSON
+		default:
+			return "", fmt.Errorf("unknown format of %s, set -format", path)
+		}
+	}
+	if format != importer.FormatCSV && format != importer.FormatNDJSON {
+		return "", fmt.Errorf("format must be csv or ndjson, got %q", format)
+	}
+	return format, nil
+}
+
+func runImport(a *app, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	tenant := tenantFlag(fs)
+	file := fs.String("file", "", "CSV or NDJSON file, - for stdin (required)")
+	format := fs.String("format", "", "csv or ndjson, defaults to extension of file")
+	columns := fs.String("columns", "", "CSV column mapping like price=amount,service_name=service")
+	dryRun := fs.Bool("dry-run", false, "only validate rows")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *file == "" {
+		return errors.New("-file is required")
+	}
+	f, err := fileFormat(*format, *file)
+	if err != nil {
+		return err
+	}
+	mapping, err := importer.ParseMapping(*columns)
+	if err != nil {
+		return err
+	}
+
+	var in io.Reader = os.Stdin
+	if *file != "-" {
+		file, err := os.Open(*file)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		in = file
+	}
+
+	var reader importer.Reader
+	if f == importer.FormatCSV {
+		reader, err = importer.NewCSVReader(in, mapping)
+		if err != nil {
+			return err
+		}
+	} else {
+		reader = importer.NewNDJSONReader(in)
+	}
+
+	report, err := importer.Import(reader, a.subscriptions(*tenant), *dryRun)
+	if err != nil {
+		return err
+	}
+	if !*dryRun {
+		a.audit(*tenant, authz.WriteAny, fmt.Sprintf("subsctl import %d subscriptions", report.Imported))
+	}
+
+	if a.out.json {
+		return a.out.print(report, nil, nil)
+	}
+	if err := a.out.print(report, []string{"ROWS", "IMPORTED", "FAILED", "DRY RUN"}, [][]string{{
+		strconv.Itoa(report.TotalRows), strconv.Itoa(report.Imported), strconv.Itoa(report.Failed), strconv.FormatBool(report.DryRun),
+	}}); err != nil || len(report.Errors) == 0 {
+		return err
+	}
+
+	fmt.Fprintln(a.out.w)
+	rows := make([][]string, len(report.Errors))
+	for i, rowErr := range report.Errors {
+		rows[i] = []string{strconv.Itoa(rowErr.Line), rowErr.Error}
+	}
+	return a.out.print(report.Errors, []string{"LINE", "ERROR"}, rows)
+}
+
+func runExport(a *app, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	tenant := tenantFlag(fs)
+	file := fs.String("file", "-", "output file, - for stdout")
+	format := fs.String("format", "", "csv or ndjson, defaults to extension of file")
+	user := fs.String("user", "", "only subscriptions of user ID")
+	service := fs.String("service", "", "only subscriptions of service")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	f, err := fileFormat(*format, *file)
+	if err != nil {
+		return err
+	}
+	userID, err := parseUser(*user)
+	if err != nil {
+		return err
+	}
+
+	var out io.Writer = os.Stdout
+	if *file != "-" {
+		file, err := os.Create(*file)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+
+	// rows are written by the same encoders as the export of the API
+	var write func(model.Subscription) error
+	flush := func() error { return nil }
+	if f == importer.FormatCSV {
+		w := codec.NewCSVWriter(out)
+		if err := w.WriteHeader(model.Subscription{}); err != nil {
+			return err
+		}
+		write = func(sub model.Subscription) error {
+			return w.Write(sub)
+		}
+		flush = w.Flush
+	} else {
+		write = func(sub model.Subscription) error {
+			return codec.JSON{}.Encode(out, sub)
+		}
+	}
+
+	rows := 0
+	err = a.subscriptions(*tenant).Stream(model.SubscriptionFilter{UserID: userID, ServiceName: *service}, func(sub model.Subscription) error {
+		rows++
+		return write(sub)
+	})
+	if err == nil {
+		err = flush()
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "%d subscriptions exported\n", rows)
+	return nil
+}
@@ -0,0 +1,143 @@
+// Command subsctl inspects and fixes data of the subscriptions service
+// directly in its database. It reads DB_* and the other env vars of the
+// service, from .env when it is present.
+//
+//	subsctl [-o table|json] <command> [flags]
+//
+// Changes are recorded to audit_log with actor operator:<OS user>.
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/user"
+	"strings"
+
+	"github.com/joho/godotenv"
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+	"github.com/teamcutter/subscriptions-service-task/internal/repo"
+	"github.com/teamcutter/subscriptions-service-task/pkg/database"
+)
+
+type command struct {
+	name string
+	summary string
+	run func(a *app, args []string) error
+}
+
+var commands = []command{
+	{"list", "list subscriptions, filtered by user and service", runList},
+	{"create", "create subscription", runCreate},
+	{"cancel", "end subscription with a month", runCancel},
+	{"delete", "delete subscription", runDelete},
+	{"total", "total cost of subscriptions of user", runTotal},
+	{"import", "import subscriptions from CSV or NDJSON file", runImport},
+	{"export", "export subscriptions to CSV or NDJSON file", runExport},
+	{"migrate", "create missing tables and indexes", runMigrate},
+	{"api-keys list", "list API keys", runAPIKeysList},
+	{"api-keys create", "create API key", runAPIKeysCreate},
+	{"api-keys rotate", "replace secret of API key", runAPIKeysRotate},
+	{"api-keys revoke", "revoke API key", runAPIKeysRevoke},
+}
+
+// app is what commands share
+type app struct {
+	db *sql.DB
+	out output
+	// actor of audit entries
+	actor string
+}
+
+// subscriptions returns repository of tenant checking users the same way
+// as the service does, see USERS_MODE
+func (a *app) subscriptions(tenantID string) repo.Repo {
+	permissive := os.Getenv("USERS_MODE") != "strict"
+	return repo.NewUserCheckRepo(repo.NewSubscriptionRepo(a.db), repo.NewUserRepo(a.db), permissive).ForTenant(tenantID)
+}
+
+// audit records change made by the operator, failure is only reported
+// because the change is already done
+func (a *app) audit(tenantID, action, resource string) {
+	err := repo.NewAuditRepo(a.db).Record(&model.AuditEntry{
+		TenantID: tenantID,
+		Actor: a.actor,
+		Action: action,
+		Resource: resource,
+		Outcome: model.AuditAllowed,
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "subsctl: audit record failed:", err)
+	}
+}
+
+func operator() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return "operator:" + u.Username
+	}
+	if name := os.Getenv("USER"); name != "" {
+		return "operator:" + name
+	}
+	return "operator:unknown"
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: subsctl [-o table|json] <command> [flags]\n\nCommands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-16s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(os.Stderr, "\nRun subsctl <command> -h for flags of command.")
+}
+
+// find returns command named by the first one or two args and the rest
+// of args
+func find(args []string) (command, []string, bool) {
+	for _, cmd := range commands {
+		words := strings.Fields(cmd.name)
+		if len(args) >= len(words) && strings.Join(args[:len(words)], " ") == cmd.name {
+			return cmd, args[len(words):], true
+		}
+	}
+	return command{}, nil, false
+}
+
+func main() {
+	_ = godotenv.Load()
+
+	format := flag.String("o", "table", "output format, table or json")
+	flag.Usage = usage
+	flag.Parse()
+
+	cmd, args, ok := find(flag.Args())
+	if !ok {
+		usage()
+		os.Exit(2)
+	}
+	out, err := newOutput(*format, os.Stdout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "subsctl:", err)
+		os.Exit(2)
+	}
+
+	conn, err := database.Connect()
+	if err == nil {
+		err = conn.Ping()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "subsctl: database connection failed:", err)
+		os.Exit(1)
+	}
+	defer conn.Close()
+
+	err = cmd.run(&app{db: conn, out: out, actor: operator()}, args)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "subsctl %s: %v\n", cmd.name, err)
+		conn.Close()
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/teamcutter/subscriptions-service-task/internal/db"
+)
+
+func runMigrate(a *app, args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if err := db.Migrate(a.db); err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stderr, "schema is up to date")
+	return nil
+}
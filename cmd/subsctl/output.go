@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// output writes results as aligned table or as JSON
+type output struct {
+	w io.Writer
+	json bool
+}
+
+func newOutput(format string, w io.Writer) (output, error) {
+	switch format {
+	case "table":
+		return output{w: w}, nil
+	case "json":
+		return output{w: w, json: true}, nil
+	}
+	return output{}, fmt.Errorf("unknown output format %q, expected table or json", format)
+}
+
+// print writes v in JSON mode and header with rows in table mode
+func (o output) print(v any, header []string, rows [][]string) error {
+	if o.json {
+		enc := json.NewEncoder(o.w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+
+	tw := tabwriter.NewWriter(o.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+// timeCell formats optional time of table, "-" when it is not set
+func timeCell(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Format(time.DateTime)
+}
+
+// cell replaces empty value of table with "-"
+func cell(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
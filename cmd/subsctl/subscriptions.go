@@ -0,0 +1,212 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/teamcutter/subscriptions-service-task/internal/authz"
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+	"github.com/teamcutter/subscriptions-service-task/internal/repo"
+)
+
+const monthDate = "01-2006"
+
+// subscriptionView shows id and version hidden from JSON of the v1 API
+type subscriptionView struct {
+	ID int64 `json:"id"`
+	model.Subscription
+	Version int `json:"version"`
+}
+
+var subscriptionHeader = []string{"ID", "SERVICE", "PRICE", "USER", "START", "END", "TRIAL END", "VERSION"}
+
+func printSubscriptions(a *app, subs []model.Subscription) error {
+	views := make([]subscriptionView, len(subs))
+	rows := make([][]string, len(subs))
+	for i, sub := range subs {
+		views[i] = subscriptionView{ID: sub.ID, Subscription: sub, Version: sub.Version}
+		rows[i] = []string{
+			strconv.FormatInt(sub.ID, 10),
+			sub.ServiceName,
+			strconv.Itoa(sub.Price),
+			sub.UserID.String(),
+			sub.StartDate,
+			cell(sub.EndDate),
+			cell(sub.TrialEndDate),
+			strconv.Itoa(sub.Version),
+		}
+	}
+	return a.out.print(views, subscriptionHeader, rows)
+}
+
+func tenantFlag(fs *flag.FlagSet) *string {
+	return fs.String("tenant", "default", "tenant of subscriptions")
+}
+
+func parseUser(s string) (uuid.NullUUID, error) {
+	if s == "" {
+		return uuid.NullUUID{}, nil
+	}
+	id, err := uuid.Parse(s)
+	if err != nil {
+		return uuid.NullUUID{}, fmt.Errorf("invalid user %q: %w", s, err)
+	}
+	return uuid.NullUUID{UUID: id, Valid: true}, nil
+}
+
+func runList(a *app, args []string) error {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	tenant := tenantFlag(fs)
+	user := fs.String("user", "", "only subscriptions of user ID")
+	service := fs.String("service", "", "only subscriptions of service")
+	limit := fs.Int("limit", 0, "at most this many subscriptions, 0 for all")
+	after := fs.Int64("after", 0, "only subscriptions with greater ID, pages with -limit")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	userID, err := parseUser(*user)
+	if err != nil {
+		return err
+	}
+	subs, err := a.subscriptions(*tenant).GetAll(model.SubscriptionFilter{
+		UserID: userID,
+		ServiceName: *service,
+		AfterID: *after,
+		Limit: *limit,
+	})
+	if err != nil {
+		return err
+	}
+	return printSubscriptions(a, subs)
+}
+
+func runCreate(a *app, args []string) error {
+	fs := flag.NewFlagSet("create", flag.ContinueOnError)
+	tenant := tenantFlag(fs)
+	user := fs.String("user", "", "user ID (required)")
+	service := fs.String("service", "", "service name (required)")
+	price := fs.Int("price", 0, "monthly price")
+	start := fs.String("start", "", "first month, MM-YYYY (required)")
+	end := fs.String("end", "", "last month, MM-YYYY")
+	trialEnd := fs.String("trial-end", "", "first paid month, MM-YYYY")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	userID, err := parseUser(*user)
+	if err != nil {
+		return err
+	}
+	if !userID.Valid || *service == "" || *start == "" {
+		return errors.New("-user, -service and -start are required")
+	}
+
+	sub := model.Subscription{
+		ServiceName: *service,
+		Price: *price,
+		UserID: userID.UUID,
+		StartDate: *start,
+		EndDate: *end,
+		TrialEndDate: *trialEnd,
+	}
+	if err := a.subscriptions(*tenant).Create(&sub); err != nil {
+		return err
+	}
+	a.audit(*tenant, authz.WriteAny, fmt.Sprintf("subsctl create subscription %d", sub.ID))
+	return printSubscriptions(a, []model.Subscription{sub})
+}
+
+func runCancel(a *app, args []string) error {
+	fs := flag.NewFlagSet("cancel", flag.ContinueOnError)
+	tenant := tenantFlag(fs)
+	id := fs.Int("id", 0, "subscription ID (required)")
+	end := fs.String("end", time.Now().Format(monthDate), "last paid month, MM-YYYY")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *id <= 0 {
+		return errors.New("-id is required")
+	}
+
+	r := a.subscriptions(*tenant)
+	sub, err := r.GetByID(*id)
+	if err != nil {
+		return err
+	}
+	sub.EndDate = *end
+	// fails when the subscription was changed since it was read
+	if err := r.Update(sub, sub.Version); err != nil {
+		return err
+	}
+	a.audit(*tenant, authz.WriteAny, fmt.Sprintf("subsctl cancel subscription %d", sub.ID))
+	return printSubscriptions(a, []model.Subscription{*sub})
+}
+
+func runDelete(a *app, args []string) error {
+	fs := flag.NewFlagSet("delete", flag.ContinueOnError)
+	tenant := tenantFlag(fs)
+	id := fs.Int("id", 0, "subscription ID (required)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *id <= 0 {
+		return errors.New("-id is required")
+	}
+
+	if err := a.subscriptions(*tenant).Delete(*id, 0); err != nil {
+		return err
+	}
+	a.audit(*tenant, authz.WriteAny, fmt.Sprintf("subsctl delete subscription %d", *id))
+	return a.out.print(map[string]int{"deleted": *id}, []string{"DELETED"}, [][]string{{strconv.Itoa(*id)}})
+}
+
+func runTotal(a *app, args []string) error {
+	fs := flag.NewFlagSet("total", flag.ContinueOnError)
+	tenant := tenantFlag(fs)
+	user := fs.String("user", "", "user ID (required)")
+	service := fs.String("service", "", "only subscriptions of service")
+	end := fs.String("end", time.Now().Format(monthDate), "last month, MM-YYYY")
+	start := fs.String("start", "", "first month, MM-YYYY, defaults to -end")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	userID, err := parseUser(*user)
+	if err != nil {
+		return err
+	}
+	if !userID.Valid {
+		return errors.New("-user is required")
+	}
+	if *start == "" {
+		*start = *end
+	}
+	for _, month := range []string{*start, *end} {
+		if _, err := time.Parse(monthDate, month); err != nil {
+			return fmt.Errorf("invalid month %q, expected MM-YYYY", month)
+		}
+	}
+
+	total, err := a.subscriptions(*tenant).TotalCost(userID.UUID.String(), *service, *start, *end)
+	if err != nil {
+		return err
+	}
+
+	currency := model.DefaultCurrency
+	profile, err := repo.NewUserRepo(a.db).ForTenant(*tenant).Get(userID.UUID)
+	if err != nil && !errors.Is(err, repo.ErrUserNotFound) {
+		return err
+	}
+	if profile != nil {
+		currency = profile.Currency
+	}
+
+	return a.out.print(model.Total{Total: total, Currency: currency},
+		[]string{"USER", "START", "END", "TOTAL", "CURRENCY"},
+		[][]string{{userID.UUID.String(), *start, *end, strconv.Itoa(total), currency}})
+}
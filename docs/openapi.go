@@ -0,0 +1,8 @@
+package docs
+
+import _ "embed"
+
+// OpenAPI is the OpenAPI 3 spec converted from swagger.json by cmd/openapi3
+//
+//go:embed openapi.json
+var OpenAPI []byte
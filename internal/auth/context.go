@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/google/uuid"
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+)
+
+const (
+	ScopeRead = "read"
+	ScopeWrite = "write"
+	ScopeAdmin = "admin"
+)
+
+var Scopes = []string{ScopeRead, ScopeWrite, ScopeAdmin}
+
+const (
+	RoleUser = "user"
+	RoleSupport = "support"
+	RoleAdmin = "admin"
+)
+
+// Principal is the authenticated caller, either a user with a token
+// or a service with an API key (UserID is not valid then).
+// TenantID is empty for platform API keys not bound to a tenant
+type Principal struct {
+	UserID uuid.NullUUID
+	TenantID string
+	Role string
+	APIKeyID int64
+	Scopes []string
+	// System is set only by WithSystem for callers inside the service,
+	// tokens and API keys can not grant it
+	System bool
+}
+
+// String identifies principal in logs and audit records
+func (p Principal) String() string {
+	if p.System {
+		return "system"
+	}
+	if p.APIKeyID != 0 {
+		return fmt.Sprintf("api_key:%d", p.APIKeyID)
+	}
+	if !p.UserID.Valid && p.Role == "" {
+		return "anonymous"
+	}
+	return "user:" + p.UserID.UUID.String()
+}
+
+func (p Principal) HasScope(scope string) bool {
+	return slices.Contains(p.Scopes, scope)
+}
+
+type principalKey struct{}
+
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
+
+// WithUserID authenticates user having user role with read and write scopes
+func WithUserID(ctx context.Context, userID uuid.UUID) context.Context {
+	return WithPrincipal(ctx, Principal{
+		UserID: uuid.NullUUID{UUID: userID, Valid: true},
+		TenantID: model.DefaultTenant,
+		Role: RoleUser,
+		Scopes: []string{ScopeRead, ScopeWrite},
+	})
+}
+
+// WithSystem authenticates the service itself, for jobs and other internal
+// callers running code that checks permissions
+func WithSystem(ctx context.Context) context.Context {
+	return WithPrincipal(ctx, Principal{System: true})
+}
+
+// UserIDFromContext returns id of authenticated user, ok is false
+// for requests without a user
+func UserIDFromContext(ctx context.Context) (uuid.UUID, bool) {
+	p, ok := PrincipalFromContext(ctx)
+	if !ok || !p.UserID.Valid {
+		return uuid.Nil, false
+	}
+	return p.UserID.UUID, true
+}
+
+type tenantKey struct{}
+
+// WithTenant sets the tenant the request works with
+func WithTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenantID)
+}
+
+// TenantFromContext returns tenant of the request, the default tenant
+// when it was not resolved
+func TenantFromContext(ctx context.Context) string {
+	if tenantID, ok := ctx.Value(tenantKey{}).(string); ok && tenantID != "" {
+		return tenantID
+	}
+	return model.DefaultTenant
+}
\ No newline at end of file
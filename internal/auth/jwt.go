@@ -0,0 +1,183 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+)
+
+var ErrNoKeys = errors.New("neither HS256 secret nor JWKS file is configured")
+
+type Config struct {
+	// HS256Secret verifies HS256 tokens
+	HS256Secret string
+	// JWKSFile is a path to JSON Web Key Set with RSA keys verifying RS256 tokens
+	JWKSFile string
+	// Issuer and Audience are checked only when set
+	Issuer string
+	Audience string
+}
+
+func ConfigFromEnv() Config {
+	return Config{
+		HS256Secret: os.Getenv("JWT_HS256_SECRET"),
+		JWKSFile: os.Getenv("JWT_JWKS_FILE"),
+		Issuer: os.Getenv("JWT_ISSUER"),
+		Audience: os.Getenv("JWT_AUDIENCE"),
+	}
+}
+
+type Verifier struct {
+	secret []byte
+	rsaKeys map[string]*rsa.PublicKey
+	options []jwt.ParserOption
+}
+
+func NewVerifier(cfg Config) (*Verifier, error) {
+	v := &Verifier{
+		secret: []byte(cfg.HS256Secret),
+		rsaKeys: map[string]*rsa.PublicKey{},
+	}
+
+	if cfg.JWKSFile != "" {
+		keys, err := LoadJWKS(cfg.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		v.rsaKeys = keys
+	}
+
+	if len(v.secret) == 0 && len(v.rsaKeys) == 0 {
+		return nil, ErrNoKeys
+	}
+
+	v.options = []jwt.ParserOption{
+		jwt.WithValidMethods([]string{"HS256", "RS256"}),
+		jwt.WithExpirationRequired(),
+	}
+	if cfg.Issuer != "" {
+		v.options = append(v.options, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		v.options = append(v.options, jwt.WithAudience(cfg.Audience))
+	}
+	return v, nil
+}
+
+func (v *Verifier) key(token *jwt.Token) (any, error) {
+	switch token.Method.Alg() {
+	case "HS256":
+		if len(v.secret) == 0 {
+			return nil, errors.New("HS256 tokens are not accepted")
+		}
+		return v.secret, nil
+	case "RS256":
+		kid, _ := token.Header["kid"].(string)
+		if key, ok := v.rsaKeys[kid]; ok {
+			return key, nil
+		}
+		// a single key may be used by tokens without kid
+		if kid == "" && len(v.rsaKeys) == 1 {
+			for _, key := range v.rsaKeys {
+				return key, nil
+			}
+		}
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+}
+
+// Verify checks token and returns user principal with id taken from
+// its subject, role from role claim and tenant from tenant claim,
+// user role and the default tenant are used when claims are absent
+func (v *Verifier) Verify(tokenString string) (Principal, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, v.key, v.options...)
+	if err != nil {
+		return Principal{}, err
+	}
+
+	subject, err := claims.GetSubject()
+	if err != nil {
+		return Principal{}, err
+	}
+
+	userID, err := uuid.Parse(subject)
+	if err != nil {
+		return Principal{}, fmt.Errorf("token subject is not a user id: %w", err)
+	}
+
+	role := RoleUser
+	if claim, ok := claims["role"]; ok {
+		if role, ok = claim.(string); !ok || role == "" {
+			return Principal{}, errors.New("token role claim must be a string")
+		}
+	}
+
+	tenantID := model.DefaultTenant
+	if claim, ok := claims["tenant"]; ok {
+		if tenantID, ok = claim.(string); !ok || tenantID == "" {
+			return Principal{}, errors.New("token tenant claim must be a string")
+		}
+	}
+
+	return Principal{
+		UserID: uuid.NullUUID{UUID: userID, Valid: true},
+		TenantID: tenantID,
+		Role: role,
+		Scopes: []string{ScopeRead, ScopeWrite},
+	}, nil
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N string `json:"n"`
+	E string `json:"e"`
+}
+
+// LoadJWKS reads RSA public keys from JWKS file, keys of other types are skipped
+func LoadJWKS(path string) (map[string]*rsa.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("parse JWKS: %w", err)
+	}
+
+	keys := map[string]*rsa.PublicKey{}
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("parse JWKS key %q: %w", k.Kid, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("parse JWKS key %q: %w", k.Kid, err)
+		}
+
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	return keys, nil
+}
\ No newline at end of file
@@ -0,0 +1,118 @@
+package auth_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/teamcutter/subscriptions-service-task/internal/auth"
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+)
+
+var mockUUID uuid.UUID = uuid.New()
+
+func claims(subject string) jwt.RegisteredClaims {
+	return jwt.RegisteredClaims{
+		Subject:   subject,
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}
+}
+
+func writeJWKS(t *testing.T, kid string, key *rsa.PublicKey) string {
+	n := base64.RawURLEncoding.EncodeToString(key.N.Bytes())
+	e := base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes())
+	jwks := fmt.Sprintf(`{"keys":[{"kty":"RSA","kid":%q,"use":"sig","n":%q,"e":%q}]}`, kid, n, e)
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, []byte(jwks), 0o600))
+	return path
+}
+
+func TestVerifyHS256(t *testing.T) {
+	verifier, err := auth.NewVerifier(auth.Config{HS256Secret: "secret"})
+	require.NoError(t, err)
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims(mockUUID.String())).SignedString([]byte("secret"))
+	require.NoError(t, err)
+
+	principal, err := verifier.Verify(token)
+	require.NoError(t, err)
+	assert.Equal(t, mockUUID, principal.UserID.UUID)
+	assert.Equal(t, auth.RoleUser, principal.Role)
+	assert.Equal(t, model.DefaultTenant, principal.TenantID)
+
+	forged, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims(mockUUID.String())).SignedString([]byte("other"))
+	require.NoError(t, err)
+	_, err = verifier.Verify(forged)
+	assert.Error(t, err)
+}
+
+func TestVerifyRS256WithJWKS(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	verifier, err := auth.NewVerifier(auth.Config{JWKSFile: writeJWKS(t, "key-1", &key.PublicKey)})
+	require.NoError(t, err)
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims(mockUUID.String()))
+	token.Header["kid"] = "key-1"
+	signed, err := token.SignedString(key)
+	require.NoError(t, err)
+
+	principal, err := verifier.Verify(signed)
+	require.NoError(t, err)
+	assert.Equal(t, mockUUID, principal.UserID.UUID)
+
+	// HS256 is rejected when only JWKS is configured
+	hs, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims(mockUUID.String())).SignedString([]byte(""))
+	require.NoError(t, err)
+	_, err = verifier.Verify(hs)
+	assert.Error(t, err)
+}
+
+func TestVerifyRoleClaim(t *testing.T) {
+	verifier, err := auth.NewVerifier(auth.Config{HS256Secret: "secret"})
+	require.NoError(t, err)
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub":  mockUUID.String(),
+		"exp":  time.Now().Add(time.Hour).Unix(),
+		"role": auth.RoleSupport,
+		"tenant": "acme",
+	}).SignedString([]byte("secret"))
+	require.NoError(t, err)
+
+	principal, err := verifier.Verify(token)
+	require.NoError(t, err)
+	assert.Equal(t, auth.RoleSupport, principal.Role)
+	assert.Equal(t, "acme", principal.TenantID)
+}
+
+func TestVerifyRejectsBadClaims(t *testing.T) {
+	verifier, err := auth.NewVerifier(auth.Config{HS256Secret: "secret"})
+	require.NoError(t, err)
+
+	notUUID, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims("alice")).SignedString([]byte("secret"))
+	_, err = verifier.Verify(notUUID)
+	assert.Error(t, err)
+
+	noExpiry, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{Subject: mockUUID.String()}).SignedString([]byte("secret"))
+	_, err = verifier.Verify(noExpiry)
+	assert.Error(t, err)
+}
+
+func TestNewVerifierWithoutKeys(t *testing.T) {
+	_, err := auth.NewVerifier(auth.Config{})
+	assert.ErrorIs(t, err, auth.ErrNoKeys)
+}
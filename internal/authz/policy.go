@@ -0,0 +1,170 @@
+package authz
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/teamcutter/subscriptions-service-task/internal/auth"
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+)
+
+const (
+	ReadOwn = "subscriptions:read:own"
+	WriteOwn = "subscriptions:write:own"
+	ReadAny = "subscriptions:read:any"
+	WriteAny = "subscriptions:write:any"
+	ManageAPIKeys = "api_keys:manage"
+	// ManageTenants is only effective for platform principals not bound to a tenant
+	ManageTenants = "tenants:manage"
+	ManageWebhooks = "webhooks:manage"
+)
+
+var Permissions = []string{ReadOwn, WriteOwn, ReadAny, WriteAny, ManageAPIKeys, ManageTenants, ManageWebhooks}
+
+var ErrForbidden = errors.New("forbidden")
+
+// DefaultRoles is used when no policy file is configured
+var DefaultRoles = map[string][]string{
+	auth.RoleUser: {ReadOwn, WriteOwn},
+	auth.RoleSupport: {ReadOwn, WriteOwn, ReadAny},
+	auth.RoleAdmin: Permissions,
+}
+
+// scopePermissions grants permissions to API keys, which have scopes instead of roles
+var scopePermissions = map[string][]string{
+	auth.ScopeRead: {ReadOwn, ReadAny},
+	auth.ScopeWrite: {WriteOwn, WriteAny},
+	auth.ScopeAdmin: Permissions,
+}
+
+var denialsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "authz_denials_total",
+	Help: "Total number of requests denied by access policy",
+}, []string{"role", "permission"})
+
+type Recorder interface {
+	Record(*model.AuditEntry) error
+}
+
+type Policy struct {
+	roles map[string][]string
+	recorder Recorder
+	logger *slog.Logger
+}
+
+func NewPolicy(roles map[string][]string, recorder Recorder, logger *slog.Logger) *Policy {
+	return &Policy{
+		roles: roles,
+		recorder: recorder,
+		logger: logger,
+	}
+}
+
+// LoadRoles reads role to permissions mapping from JSON file
+// like {"user": ["subscriptions:read:own"]}
+func LoadRoles(path string) (map[string][]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var roles map[string][]string
+	if err := json.Unmarshal(data, &roles); err != nil {
+		return nil, fmt.Errorf("parse roles: %w", err)
+	}
+
+	for role, permissions := range roles {
+		for _, permission := range permissions {
+			if !slices.Contains(Permissions, permission) {
+				return nil, fmt.Errorf("role %s: unknown permission %q", role, permission)
+			}
+		}
+	}
+	return roles, nil
+}
+
+func (p *Policy) Allowed(principal auth.Principal, permission string) bool {
+	if principal.System {
+		return true
+	}
+	if principal.APIKeyID != 0 {
+		for _, scope := range principal.Scopes {
+			if slices.Contains(scopePermissions[scope], permission) {
+				return true
+			}
+		}
+		return false
+	}
+	return slices.Contains(p.roles[principal.Role], permission)
+}
+
+// Authorize checks permission of the request principal, denials are
+// logged, counted and recorded to audit log. Requests without principal
+// are denied, internal callers use auth.WithSystem
+func (p *Policy) Authorize(ctx context.Context, permission, resource string) error {
+	principal, ok := auth.PrincipalFromContext(ctx)
+	if ok && p.Allowed(principal, permission) {
+		return nil
+	}
+
+	role := principal.Role
+	switch {
+	case !ok:
+		role = "anonymous"
+	case principal.APIKeyID != 0:
+		role = "api_key"
+	}
+	denialsTotal.WithLabelValues(role, permission).Inc()
+	p.logger.Warn("access denied",
+		"principal", principal.String(),
+		"tenant_id", principal.TenantID,
+		"role", role,
+		"permission", permission,
+		"resource", resource,
+	)
+
+	if p.recorder != nil {
+		err := p.recorder.Record(&model.AuditEntry{
+			TenantID: principal.TenantID,
+			Actor: principal.String(),
+			Action: permission,
+			Resource: resource,
+			Outcome: model.AuditDenied,
+		})
+		if err != nil {
+			p.logger.Error("audit record error", "error", err)
+		}
+	}
+	return ErrForbidden
+}
+
+// AuthorizeOwner checks permission of the request to read or write data
+// of owner, own data when owner is not set. scoped is true when the caller
+// may only access own data
+func (p *Policy) AuthorizeOwner(ctx context.Context, resource string, write bool, owner uuid.NullUUID) (scoped bool, err error) {
+	ownPermission, anyPermission := ReadOwn, ReadAny
+	if write {
+		ownPermission, anyPermission = WriteOwn, WriteAny
+	}
+	principal, ok := auth.PrincipalFromContext(ctx)
+	if !ok {
+		return false, p.Authorize(ctx, anyPermission, resource)
+	}
+	if p.Allowed(principal, anyPermission) {
+		return false, nil
+	}
+
+	foreign := owner.Valid && owner.UUID != principal.UserID.UUID
+	if !principal.UserID.Valid || foreign {
+		return false, p.Authorize(ctx, anyPermission, resource)
+	}
+	return true, p.Authorize(ctx, ownPermission, resource)
+}
\ No newline at end of file
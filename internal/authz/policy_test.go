@@ -0,0 +1,98 @@
+package authz_test
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/teamcutter/subscriptions-service-task/internal/auth"
+	"github.com/teamcutter/subscriptions-service-task/internal/authz"
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+)
+
+type memoryRecorder struct {
+	entries []model.AuditEntry
+}
+
+func (r *memoryRecorder) Record(entry *model.AuditEntry) error {
+	r.entries = append(r.entries, *entry)
+	return nil
+}
+
+func user(role string) auth.Principal {
+	return auth.Principal{UserID: uuid.NullUUID{UUID: uuid.New(), Valid: true}, Role: role}
+}
+
+func TestDefaultRoles(t *testing.T) {
+	policy := authz.NewPolicy(authz.DefaultRoles, nil, slog.Default())
+
+	assert.True(t, policy.Allowed(user(auth.RoleUser), authz.WriteOwn))
+	assert.False(t, policy.Allowed(user(auth.RoleUser), authz.ReadAny))
+	assert.True(t, policy.Allowed(user(auth.RoleSupport), authz.ReadAny))
+	assert.False(t, policy.Allowed(user(auth.RoleSupport), authz.WriteAny))
+	assert.True(t, policy.Allowed(user(auth.RoleAdmin), authz.ManageAPIKeys))
+	assert.False(t, policy.Allowed(user("unknown"), authz.ReadOwn))
+}
+
+func TestAPIKeyScopes(t *testing.T) {
+	policy := authz.NewPolicy(authz.DefaultRoles, nil, slog.Default())
+	reader := auth.Principal{APIKeyID: 1, Scopes: []string{auth.ScopeRead}}
+
+	assert.True(t, policy.Allowed(reader, authz.ReadAny))
+	assert.False(t, policy.Allowed(reader, authz.WriteAny))
+	assert.False(t, policy.Allowed(reader, authz.ManageAPIKeys))
+}
+
+func TestAuthorizeRecordsDenial(t *testing.T) {
+	recorder := &memoryRecorder{}
+	policy := authz.NewPolicy(authz.DefaultRoles, recorder, slog.Default())
+
+	principal := user(auth.RoleUser)
+	ctx := auth.WithPrincipal(context.Background(), principal)
+
+	assert.NoError(t, policy.Authorize(ctx, authz.ReadOwn, "GET /subscriptions"))
+	assert.ErrorIs(t, policy.Authorize(ctx, authz.ManageAPIKeys, "GET /admin/api-keys"), authz.ErrForbidden)
+
+	require.Len(t, recorder.entries, 1)
+	assert.Equal(t, principal.String(), recorder.entries[0].Actor)
+	assert.Equal(t, authz.ManageAPIKeys, recorder.entries[0].Action)
+	assert.Equal(t, model.AuditDenied, recorder.entries[0].Outcome)
+}
+
+func TestAuthorizeWithoutPrincipal(t *testing.T) {
+	recorder := &memoryRecorder{}
+	policy := authz.NewPolicy(authz.DefaultRoles, recorder, slog.Default())
+
+	assert.ErrorIs(t, policy.Authorize(context.Background(), authz.ReadOwn, "GET /subscriptions"), authz.ErrForbidden)
+	_, err := policy.AuthorizeOwner(context.Background(), "GET /subscriptions", false, uuid.NullUUID{})
+	assert.ErrorIs(t, err, authz.ErrForbidden)
+	require.Len(t, recorder.entries, 2)
+	assert.Equal(t, "anonymous", recorder.entries[0].Actor)
+
+	system := auth.WithSystem(context.Background())
+	assert.NoError(t, policy.Authorize(system, authz.ManageTenants, "reminder scheduler"))
+	scoped, err := policy.AuthorizeOwner(system, "reminder scheduler", true, uuid.NullUUID{})
+	assert.NoError(t, err)
+	assert.False(t, scoped)
+}
+
+func TestLoadRoles(t *testing.T) {
+	dir := t.TempDir()
+
+	valid := filepath.Join(dir, "roles.json")
+	require.NoError(t, os.WriteFile(valid, []byte(`{"viewer": ["subscriptions:read:own"]}`), 0o600))
+	roles, err := authz.LoadRoles(valid)
+	require.NoError(t, err)
+	assert.Equal(t, []string{authz.ReadOwn}, roles["viewer"])
+
+	invalid := filepath.Join(dir, "invalid.json")
+	require.NoError(t, os.WriteFile(invalid, []byte(`{"viewer": ["subscriptions:everything"]}`), 0o600))
+	_, err = authz.LoadRoles(invalid)
+	assert.Error(t, err)
+}
@@ -0,0 +1,144 @@
+package codec
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ErrNotAcceptable is returned when no encoder matches Accept header
+var ErrNotAcceptable = errors.New("not acceptable")
+
+// Encoder writes values in a media type
+type Encoder interface {
+	// ContentType is the media type of encoded values
+	ContentType() string
+	Encode(w io.Writer, v any) error
+}
+
+type entry struct {
+	encoder Encoder
+	mediaTypes []string
+}
+
+// Registry picks encoders of responses by Accept header, encoders
+// registered first are preferred when client accepts several equally
+type Registry struct {
+	entries []entry
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Default returns registry of JSON, CSV, XML and MessagePack encoders,
+// JSON is used when Accept is missing
+func Default() *Registry {
+	r := NewRegistry()
+	r.Register(JSON{})
+	r.Register(CSV{})
+	r.Register(XML{}, "text/xml")
+	r.Register(MessagePack{}, "application/vnd.msgpack", "application/x-msgpack")
+	return r
+}
+
+// Register adds encoder of its content type and of aliases
+func (r *Registry) Register(encoder Encoder, aliases ...string) {
+	mediaTypes := append([]string{encoder.ContentType()}, aliases...)
+	for i, mediaType := range mediaTypes {
+		mediaTypes[i] = strings.ToLower(mediaType)
+	}
+	r.entries = append(r.entries, entry{encoder: encoder, mediaTypes: mediaTypes})
+}
+
+// ContentTypes lists content types of registered encoders
+func (r *Registry) ContentTypes() []string {
+	types := make([]string, len(r.entries))
+	for i, e := range r.entries {
+		types[i] = e.encoder.ContentType()
+	}
+	return types
+}
+
+// Negotiate returns encoder of the media type client prefers, the first
+// registered one when Accept is empty
+func (r *Registry) Negotiate(accept string) (Encoder, error) {
+	if len(r.entries) == 0 {
+		return nil, ErrNotAcceptable
+	}
+	if strings.TrimSpace(accept) == "" {
+		return r.entries[0].encoder, nil
+	}
+
+	ranges := parseAccept(accept)
+	var best Encoder
+	bestQ := 0.0
+	for _, e := range r.entries {
+		for _, mediaType := range e.mediaTypes {
+			if q := quality(ranges, mediaType); q > bestQ {
+				best, bestQ = e.encoder, q
+			}
+		}
+	}
+	if best == nil {
+		return nil, fmt.Errorf("%w, supported media types: %s", ErrNotAcceptable, strings.Join(r.ContentTypes(), ", "))
+	}
+	return best, nil
+}
+
+// mediaRange is an element of Accept header like "text/*;q=0.5"
+type mediaRange struct {
+	typ string
+	subtype string
+	q float64
+}
+
+func parseAccept(accept string) []mediaRange {
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		typ, subtype, ok := strings.Cut(strings.ToLower(strings.TrimSpace(params[0])), "/")
+		if !ok {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range params[1:] {
+			key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.EqualFold(key, "q") {
+				if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		ranges = append(ranges, mediaRange{typ: typ, subtype: subtype, q: q})
+	}
+	return ranges
+}
+
+// quality is q of the most specific range matching mediaType, 0 when
+// none matches
+func quality(ranges []mediaRange, mediaType string) float64 {
+	typ, subtype, _ := strings.Cut(mediaType, "/")
+
+	q, specificity := 0.0, 0
+	for _, r := range ranges {
+		var s int
+		switch {
+		case r.typ == typ && r.subtype == subtype:
+			s = 3
+		case r.typ == typ && r.subtype == "*":
+			s = 2
+		case r.typ == "*" && r.subtype == "*":
+			s = 1
+		default:
+			continue
+		}
+		if s > specificity {
+			q, specificity = r.q, s
+		}
+	}
+	return q
+}
@@ -0,0 +1,97 @@
+package codec_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
+
+	"github.com/teamcutter/subscriptions-service-task/internal/codec"
+)
+
+type money struct {
+	Amount int `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+type item struct {
+	ID int64 `json:"-"`
+	Name string `json:"name"`
+	Price money `json:"price"`
+	UserID uuid.UUID `json:"user_id"`
+	EndDate string `json:"end_date,omitempty"`
+}
+
+var userID = uuid.MustParse("6f1c1f6e-2f4b-4c7e-9d6a-0a1b2c3d4e5f")
+
+func TestNegotiate(t *testing.T) {
+	r := codec.Default()
+
+	tests := []struct {
+		accept string
+		contentType string
+	}{
+		{"", "application/json"},
+		{"*/*", "application/json"},
+		{"text/csv", "text/csv"},
+		{"text/*", "text/csv"},
+		{"application/*", "application/json"},
+		{"text/csv;q=0.5, application/xml", "application/xml"},
+		{"text/xml", "application/xml"},
+		{"application/x-msgpack", "application/msgpack"},
+		{"*/*;q=0.1, application/msgpack", "application/msgpack"},
+		{"application/json;q=0, */*", "text/csv"},
+	}
+	for _, tt := range tests {
+		encoder, err := r.Negotiate(tt.accept)
+		if assert.NoError(t, err, tt.accept) {
+			assert.Equal(t, tt.contentType, encoder.ContentType(), tt.accept)
+		}
+	}
+
+	_, err := r.Negotiate("image/png, text/html")
+	assert.True(t, errors.Is(err, codec.ErrNotAcceptable))
+	_, err = r.Negotiate("*/*;q=0")
+	assert.True(t, errors.Is(err, codec.ErrNotAcceptable))
+}
+
+func TestCSV(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, codec.CSV{}.Encode(&buf, []item{
+		{ID: 1, Name: "Netflix", Price: money{400, "RUB"}, UserID: userID},
+		{ID: 2, Name: "Spotify, Family", Price: money{200, "RUB"}, UserID: userID, EndDate: "05-2025"},
+	}))
+	assert.Equal(t, "name,price.amount,price.currency,user_id,end_date\n"+
+		"Netflix,400,RUB,"+userID.String()+",\n"+
+		"\"Spotify, Family\",200,RUB,"+userID.String()+",05-2025\n", buf.String())
+
+	buf.Reset()
+	require.NoError(t, codec.CSV{}.Encode(&buf, []item{}))
+	assert.Equal(t, "name,price.amount,price.currency,user_id,end_date\n", buf.String())
+
+	assert.Error(t, codec.CSV{}.Encode(&buf, []string{"a"}))
+}
+
+func TestXML(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, codec.XML{}.Encode(&buf, []item{{Name: "Netflix & Co", Price: money{400, "RUB"}, UserID: userID}}))
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>`+"\n"+
+		`<response><item><name>Netflix &amp; Co</name><price><amount>400</amount><currency>RUB</currency></price>`+
+		`<user_id>`+userID.String()+`</user_id></item></response>`, buf.String())
+}
+
+func TestMessagePack(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, codec.MessagePack{}.Encode(&buf, item{Name: "Netflix", Price: money{400, "RUB"}, UserID: userID}))
+
+	var got map[string]any
+	require.NoError(t, msgpack.Unmarshal(buf.Bytes(), &got))
+	assert.Equal(t, "Netflix", got["name"])
+	// ids are strings like in JSON
+	assert.Equal(t, userID.String(), got["user_id"])
+	assert.EqualValues(t, 400, got["price"].(map[string]any)["amount"])
+}
@@ -0,0 +1,184 @@
+package codec
+
+import (
+	"encoding"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+var textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+
+// CSV encodes a struct or a slice of structs as text/csv with header,
+// columns are named by json tags and nested structs are flattened to
+// columns like price.amount
+type CSV struct{}
+
+func (CSV) ContentType() string {
+	return "text/csv"
+}
+
+func (CSV) Encode(w io.Writer, v any) error {
+	cw := NewCSVWriter(w)
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Slice {
+		// header is written for empty slices too
+		if err := cw.writeHeader(rv.Type().Elem()); err != nil {
+			return err
+		}
+		for i := range rv.Len() {
+			if err := cw.Write(rv.Index(i).Interface()); err != nil {
+				return err
+			}
+		}
+	} else if err := cw.Write(v); err != nil {
+		return err
+	}
+	return cw.Flush()
+}
+
+// column is a field of struct written to CSV
+type column struct {
+	name string
+	index []int
+}
+
+// CSVWriter writes structs of one type as CSV records, header is written
+// before the first record. Records are buffered until Flush
+type CSVWriter struct {
+	w *csv.Writer
+	columns []column
+	started bool
+}
+
+func NewCSVWriter(w io.Writer) *CSVWriter {
+	return &CSVWriter{w: csv.NewWriter(w)}
+}
+
+func (cw *CSVWriter) writeHeader(t reflect.Type) error {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return fmt.Errorf("csv: cannot encode %s, only structs and slices of structs", t)
+	}
+
+	cw.columns = columnsOf(t, "", nil)
+	names := make([]string, len(cw.columns))
+	for i, col := range cw.columns {
+		names[i] = col.name
+	}
+	cw.started = true
+	return cw.w.Write(names)
+}
+
+// WriteHeader writes header of structs of type of v, Write writes it
+// before the first record otherwise
+func (cw *CSVWriter) WriteHeader(v any) error {
+	return cw.writeHeader(reflect.TypeOf(v))
+}
+
+// Write writes v as a record
+func (cw *CSVWriter) Write(v any) error {
+	rv := reflect.ValueOf(v)
+	if !cw.started {
+		if err := cw.writeHeader(rv.Type()); err != nil {
+			return err
+		}
+	}
+
+	rv = reflect.Indirect(rv)
+	record := make([]string, len(cw.columns))
+	for i, col := range cw.columns {
+		field, err := rv.FieldByIndexErr(col.index)
+		if err != nil {
+			// nil embedded pointer
+			continue
+		}
+		record[i], err = formatCell(field)
+		if err != nil {
+			return err
+		}
+	}
+	return cw.w.Write(record)
+}
+
+// Flush writes buffered records
+func (cw *CSVWriter) Flush() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+// columnsOf lists fields of t like encoding/json does, fields of nested
+// structs get name of their parent as prefix
+func columnsOf(t reflect.Type, prefix string, index []int) []column {
+	var columns []column
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+
+		fieldIndex := append(append([]int{}, index...), i)
+		ft := f.Type
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		nested := ft.Kind() == reflect.Struct && !reflect.PointerTo(ft).Implements(textMarshalerType)
+
+		switch {
+		case nested && f.Anonymous && name == "":
+			columns = append(columns, columnsOf(ft, prefix, fieldIndex)...)
+		case nested:
+			if name == "" {
+				name = f.Name
+			}
+			columns = append(columns, columnsOf(ft, prefix+name+".", fieldIndex)...)
+		default:
+			if name == "" {
+				name = f.Name
+			}
+			columns = append(columns, column{name: prefix + name, index: fieldIndex})
+		}
+	}
+	return columns
+}
+
+// formatCell writes scalars as text, other values as JSON
+func formatCell(v reflect.Value) (string, error) {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return "", nil
+		}
+		v = v.Elem()
+	}
+	if m, ok := v.Interface().(encoding.TextMarshaler); ok {
+		text, err := m.MarshalText()
+		return string(text), err
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	}
+
+	data, err := json.Marshal(v.Interface())
+	return string(data), err
+}
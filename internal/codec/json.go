@@ -0,0 +1,18 @@
+package codec
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// JSON encodes values as application/json followed by newline, so
+// values encoded one by one make NDJSON
+type JSON struct{}
+
+func (JSON) ContentType() string {
+	return "application/json"
+}
+
+func (JSON) Encode(w io.Writer, v any) error {
+	return json.NewEncoder(w).Encode(v)
+}
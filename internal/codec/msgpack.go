@@ -0,0 +1,59 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// MessagePack encodes values as application/msgpack in the shape of their
+// JSON, so ids are strings like in JSON rather than binary
+type MessagePack struct{}
+
+func (MessagePack) ContentType() string {
+	return "application/msgpack"
+}
+
+func (MessagePack) Encode(w io.Writer, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var tree any
+	if err := dec.Decode(&tree); err != nil {
+		return err
+	}
+
+	enc := msgpack.NewEncoder(w)
+	// sorted keys keep encoding of the same value stable for ETag
+	enc.SetSortMapKeys(true)
+	enc.UseCompactInts(true)
+	return enc.Encode(numbers(tree))
+}
+
+// numbers replaces JSON numbers with integers where possible and floats
+// otherwise
+func numbers(v any) any {
+	switch v := v.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]any:
+		for key, value := range v {
+			v[key] = numbers(value)
+		}
+	case []any:
+		for i, value := range v {
+			v[i] = numbers(value)
+		}
+	}
+	return v
+}
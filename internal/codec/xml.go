@@ -0,0 +1,76 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+// XML encodes values as application/xml in the shape of their JSON:
+// object keys become elements, array elements are item elements and
+// the root element is response
+type XML struct{}
+
+func (XML) ContentType() string {
+	return "application/xml"
+}
+
+func (XML) Encode(w io.Writer, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	if err := encodeXML(enc, dec, "response"); err != nil {
+		return err
+	}
+	return enc.Flush()
+}
+
+// encodeXML writes the next JSON value of dec as element name
+func encodeXML(enc *xml.Encoder, dec *json.Decoder, name string) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		if tok == nil {
+			// null is an empty element
+			tok = ""
+		}
+		return enc.EncodeElement(fmt.Sprint(tok), start)
+	}
+
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+	for dec.More() {
+		child := "item"
+		if delim == '{' {
+			key, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			child = key.(string)
+		}
+		if err := encodeXML(enc, dec, child); err != nil {
+			return err
+		}
+	}
+	// closing delimiter
+	if _, err := dec.Token(); err != nil {
+		return err
+	}
+	return enc.EncodeToken(start.End())
+}
@@ -0,0 +1,34 @@
+package db
+
+import (
+	"database/sql"
+	_ "embed"
+)
+
+// schema is also run by postgres on first start of docker-compose
+//
+//go:embed migrate.sql
+var schema string
+
+// migrateLock is the advisory lock key held while the schema runs
+const migrateLock = 7_146_201
+
+// Migrate creates missing tables, columns and indexes. Columns added to
+// existing tables have their own ADD COLUMN IF NOT EXISTS, so the schema
+// upgrades databases created by earlier versions and can run repeatedly.
+// Instances starting together take turns
+func Migrate(conn *sql.DB) error {
+	tx, err := conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, migrateLock); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(schema); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,116 @@
+package gql
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+const (
+	// MaxDepth is the deepest nesting of fields a query may have
+	MaxDepth = 7
+	// MaxComplexity bounds the estimated cost of a query, see complexity
+	MaxComplexity = 1000
+	// defaultListSize estimates lists queried without first argument
+	defaultListSize = 100
+)
+
+// fieldCosts are costs of fields computing totals, other fields cost 1
+var fieldCosts = map[string]int{
+	"total": 5,
+	"services": 10,
+	"monthly": 25,
+}
+
+// listFields multiply the cost of their selection by the number of items
+var listFields = map[string]bool{
+	"subscriptions": true,
+}
+
+type analysis struct {
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]any
+}
+
+// measure returns depth and complexity of the operation of doc. A field
+// costs its fieldCosts entry or 1 plus the cost of its selection, which
+// counts once per item for list fields. Introspection fields are free
+func measure(doc *ast.Document, operationName string, variables map[string]any) (depth, complexity int) {
+	a := analysis{fragments: map[string]*ast.FragmentDefinition{}, variables: variables}
+	var operation *ast.OperationDefinition
+	for _, definition := range doc.Definitions {
+		switch definition := definition.(type) {
+		case *ast.FragmentDefinition:
+			a.fragments[definition.Name.Value] = definition
+		case *ast.OperationDefinition:
+			if operationName == "" || (definition.Name != nil && definition.Name.Value == operationName) {
+				operation = definition
+			}
+		}
+	}
+	if operation == nil {
+		return 0, 0
+	}
+	return a.selections(operation.SelectionSet, 1)
+}
+
+// selections measures fields of set that are at depth
+func (a analysis) selections(set *ast.SelectionSet, depth int) (maxDepth, cost int) {
+	if set == nil {
+		return 0, 0
+	}
+
+	for _, selection := range set.Selections {
+		var d, c int
+		switch selection := selection.(type) {
+		case *ast.Field:
+			name := selection.Name.Value
+			if strings.HasPrefix(name, "__") {
+				continue
+			}
+			d, c = a.selections(selection.SelectionSet, depth+1)
+			d = max(d, depth)
+			if listFields[name] {
+				c *= a.listSize(selection)
+			}
+			own, ok := fieldCosts[name]
+			if !ok {
+				own = 1
+			}
+			c += own
+		case *ast.InlineFragment:
+			d, c = a.selections(selection.SelectionSet, depth)
+		case *ast.FragmentSpread:
+			// fragment cycles are rejected by validation before
+			if fragment, ok := a.fragments[selection.Name.Value]; ok {
+				d, c = a.selections(fragment.SelectionSet, depth)
+			}
+		}
+		maxDepth = max(maxDepth, d)
+		cost += c
+	}
+	return maxDepth, cost
+}
+
+func (a analysis) listSize(field *ast.Field) int {
+	for _, argument := range field.Arguments {
+		if argument.Name.Value != "first" {
+			continue
+		}
+		switch value := argument.Value.(type) {
+		case *ast.IntValue:
+			if n, err := strconv.Atoi(value.Value); err == nil {
+				return max(n, 1)
+			}
+		case *ast.Variable:
+			switch n := a.variables[value.Name.Value].(type) {
+			case int:
+				return max(n, 1)
+			case float64:
+				return max(int(n), 1)
+			}
+		}
+	}
+	return defaultListSize
+}
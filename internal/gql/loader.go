@@ -0,0 +1,112 @@
+package gql
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/graph-gophers/dataloader/v7"
+
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+	"github.com/teamcutter/subscriptions-service-task/internal/repo"
+)
+
+// batchWait is how long loaders collect keys before querying, fields of
+// one level are resolved before their values are awaited so it only needs
+// to cover scheduling
+const batchWait = 2 * time.Millisecond
+
+// Data is what a request reads, repositories already limited to the
+// tenant of the request and to the caller when they only read their own
+// subscriptions
+type Data struct {
+	Repo repo.Repo
+	Users repo.UserRepo
+}
+
+// loaders batch lookups of one request so that nested fields make one
+// query per level instead of one per parent
+type loaders struct {
+	users *dataloader.Loader[uuid.UUID, *model.User]
+	subscriptions *dataloader.Loader[uuid.UUID, []model.Subscription]
+}
+
+func newLoaders(data Data) *loaders {
+	return &loaders{
+		users: dataloader.NewBatchedLoader(loadUsers(data.Users), dataloader.WithWait[uuid.UUID, *model.User](batchWait)),
+		subscriptions: dataloader.NewBatchedLoader(loadSubscriptions(data.Repo), dataloader.WithWait[uuid.UUID, []model.Subscription](batchWait)),
+	}
+}
+
+// loadUsers returns users by ids, users without profile get the defaults
+func loadUsers(users repo.UserRepo) dataloader.BatchFunc[uuid.UUID, *model.User] {
+	return func(_ context.Context, ids []uuid.UUID) []*dataloader.Result[*model.User] {
+		results := make([]*dataloader.Result[*model.User], len(ids))
+
+		found, err := users.GetMany(ids)
+		if err != nil {
+			for i := range results {
+				results[i] = &dataloader.Result[*model.User]{Error: err}
+			}
+			return results
+		}
+
+		byID := make(map[uuid.UUID]*model.User, len(found))
+		for i := range found {
+			byID[found[i].ID] = &found[i]
+		}
+		for i, id := range ids {
+			user, ok := byID[id]
+			if !ok {
+				user = &model.User{ID: id, Currency: model.DefaultCurrency, Timezone: model.DefaultTimezone}
+			}
+			results[i] = &dataloader.Result[*model.User]{Data: user}
+		}
+		return results
+	}
+}
+
+// loadSubscriptions returns subscriptions of users in one query
+func loadSubscriptions(r repo.Repo) dataloader.BatchFunc[uuid.UUID, []model.Subscription] {
+	return func(_ context.Context, ids []uuid.UUID) []*dataloader.Result[[]model.Subscription] {
+		results := make([]*dataloader.Result[[]model.Subscription], len(ids))
+
+		subs, err := r.GetAll(model.SubscriptionFilter{UserIDs: ids})
+		if err != nil {
+			for i := range results {
+				results[i] = &dataloader.Result[[]model.Subscription]{Error: err}
+			}
+			return results
+		}
+
+		byUser := map[uuid.UUID][]model.Subscription{}
+		for _, sub := range subs {
+			byUser[sub.UserID] = append(byUser[sub.UserID], sub)
+		}
+		for i, id := range ids {
+			// lists are never null in the schema
+			subs := byUser[id]
+			if subs == nil {
+				subs = []model.Subscription{}
+			}
+			results[i] = &dataloader.Result[[]model.Subscription]{Data: subs}
+		}
+		return results
+	}
+}
+
+type requestKey struct{}
+
+// request is the state of one request shared by resolvers
+type request struct {
+	data Data
+	loaders *loaders
+}
+
+func withRequest(ctx context.Context, data Data) context.Context {
+	return context.WithValue(ctx, requestKey{}, &request{data: data, loaders: newLoaders(data)})
+}
+
+func requestFrom(ctx context.Context) *request {
+	return ctx.Value(requestKey{}).(*request)
+}
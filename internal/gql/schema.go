@@ -0,0 +1,501 @@
+package gql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+
+	"github.com/teamcutter/subscriptions-service-task/internal/auth"
+	"github.com/teamcutter/subscriptions-service-task/internal/authz"
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+)
+
+const (
+	maxPageSize = 1000
+	// maxMonths bounds the monthly breakdown, every month is a query
+	maxMonths = 24
+	monthLayout = "01-2006"
+)
+
+// Request is a GraphQL request as sent over HTTP
+type Request struct {
+	Query string `json:"query"`
+	OperationName string `json:"operationName,omitempty"`
+	Variables map[string]any `json:"variables,omitempty"`
+}
+
+// Schema answers queries over subscriptions, their users and cost totals
+type Schema struct {
+	schema graphql.Schema
+	policy *authz.Policy
+}
+
+func NewSchema(policy *authz.Policy) (*Schema, error) {
+	s := &Schema{policy: policy}
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{Query: s.query()})
+	if err != nil {
+		return nil, err
+	}
+	s.schema = schema
+	return s, nil
+}
+
+func errorResult(format string, args ...any) *graphql.Result {
+	return &graphql.Result{Errors: []gqlerrors.FormattedError{gqlerrors.NewFormattedError(fmt.Sprintf(format, args...))}}
+}
+
+// Execute runs query over data. Queries that do not parse, are invalid or
+// exceed MaxDepth or MaxComplexity are not executed and ok is false
+func (s *Schema) Execute(ctx context.Context, data Data, req Request) (result *graphql.Result, ok bool) {
+	doc, err := parser.Parse(parser.ParseParams{Source: source.NewSource(&source.Source{
+		Body: []byte(req.Query),
+		Name: "GraphQL request",
+	})})
+	if err != nil {
+		return &graphql.Result{Errors: gqlerrors.FormatErrors(err)}, false
+	}
+
+	if validation := graphql.ValidateDocument(&s.schema, doc, nil); !validation.IsValid {
+		return &graphql.Result{Errors: validation.Errors}, false
+	}
+
+	depth, complexity := measure(doc, req.OperationName, req.Variables)
+	if depth > MaxDepth {
+		return errorResult("query depth %d exceeds the limit of %d", depth, MaxDepth), false
+	}
+	if complexity > MaxComplexity {
+		return errorResult("query complexity %d exceeds the limit of %d", complexity, MaxComplexity), false
+	}
+
+	return graphql.Execute(graphql.ExecuteParams{
+		Schema: s.schema,
+		AST: doc,
+		OperationName: req.OperationName,
+		Args: req.Variables,
+		Context: withRequest(ctx, data),
+	}), true
+}
+
+// authorize checks that caller may read subscriptions of owner, invalid
+// owner stands for all subscriptions of the tenant
+func (s *Schema) authorize(ctx context.Context, field string, owner uuid.NullUUID) error {
+	_, err := s.policy.AuthorizeOwner(ctx, "graphql "+field, false, owner)
+	return err
+}
+
+func parseUUID(value any) (uuid.NullUUID, error) {
+	id, _ := value.(string)
+	if id == "" {
+		return uuid.NullUUID{}, nil
+	}
+	parsed, err := uuid.Parse(id)
+	if err != nil {
+		return uuid.NullUUID{}, fmt.Errorf("invalid user id: %w", err)
+	}
+	return uuid.NullUUID{UUID: parsed, Valid: true}, nil
+}
+
+// period returns start and end of totals, end defaults to the current month
+// in timezone of user and start to end
+func period(user *model.User, args map[string]any) (string, string) {
+	end, _ := args["end"].(string)
+	if end == "" {
+		location, err := time.LoadLocation(user.Timezone)
+		if err != nil {
+			location = time.UTC
+		}
+		end = time.Now().In(location).Format(monthLayout)
+	}
+	start, _ := args["start"].(string)
+	if start == "" {
+		start = end
+	}
+	return start, end
+}
+
+// months lists months from start to end (MM-YYYY)
+func months(start, end string) ([]string, error) {
+	from, err := time.Parse(monthLayout, start)
+	if err != nil {
+		return nil, fmt.Errorf("invalid start: %w", err)
+	}
+	to, err := time.Parse(monthLayout, end)
+	if err != nil {
+		return nil, fmt.Errorf("invalid end: %w", err)
+	}
+	if to.Before(from) {
+		return nil, errors.New("end is before start")
+	}
+
+	var list []string
+	for month := from; !month.After(to); month = month.AddDate(0, 1, 0) {
+		if len(list) == maxMonths {
+			return nil, fmt.Errorf("monthly breakdown covers at most %d months", maxMonths)
+		}
+		list = append(list, month.Format(monthLayout))
+	}
+	return list, nil
+}
+
+// subscriptionField resolves a field of Subscription
+func subscriptionField(fn func(model.Subscription) any) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (any, error) {
+		return fn(p.Source.(model.Subscription)), nil
+	}
+}
+
+// userField resolves a field of User
+func userField(fn func(*model.User) any) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (any, error) {
+		return fn(p.Source.(*model.User)), nil
+	}
+}
+
+// optional turns empty strings into nulls
+func optional(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// loadUser returns a thunk resolving user, lookups of one level are batched
+func loadUser(ctx context.Context, id uuid.UUID) func() (any, error) {
+	thunk := requestFrom(ctx).loaders.users.Load(ctx, id)
+	return func() (any, error) {
+		return thunk()
+	}
+}
+
+type serviceTotal struct {
+	service string
+	total int
+	currency string
+}
+
+type monthlyCost struct {
+	month string
+	total int
+	currency string
+}
+
+func (s *Schema) query() *graphql.Object {
+	totalType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Total",
+		Fields: graphql.Fields{
+			"total": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"currency": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		},
+	})
+
+	serviceTotalType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "ServiceTotal",
+		Fields: graphql.Fields{
+			"service": &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: func(p graphql.ResolveParams) (any, error) {
+				return p.Source.(serviceTotal).service, nil
+			}},
+			"total": &graphql.Field{Type: graphql.NewNonNull(graphql.Int), Resolve: func(p graphql.ResolveParams) (any, error) {
+				return p.Source.(serviceTotal).total, nil
+			}},
+			"currency": &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: func(p graphql.ResolveParams) (any, error) {
+				return p.Source.(serviceTotal).currency, nil
+			}},
+		},
+	})
+
+	monthlyCostType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "MonthlyCost",
+		Fields: graphql.Fields{
+			"month": &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: func(p graphql.ResolveParams) (any, error) {
+				return p.Source.(monthlyCost).month, nil
+			}},
+			"total": &graphql.Field{Type: graphql.NewNonNull(graphql.Int), Resolve: func(p graphql.ResolveParams) (any, error) {
+				return p.Source.(monthlyCost).total, nil
+			}},
+			"currency": &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: func(p graphql.ResolveParams) (any, error) {
+				return p.Source.(monthlyCost).currency, nil
+			}},
+		},
+	})
+
+	var userType *graphql.Object
+	subscriptionType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Subscription",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id": &graphql.Field{Type: graphql.NewNonNull(graphql.ID), Resolve: subscriptionField(func(sub model.Subscription) any {
+					return strconv.FormatInt(sub.ID, 10)
+				})},
+				"serviceName": &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: subscriptionField(func(sub model.Subscription) any {
+					return sub.ServiceName
+				})},
+				"price": &graphql.Field{Type: graphql.NewNonNull(graphql.Int), Resolve: subscriptionField(func(sub model.Subscription) any {
+					return sub.Price
+				})},
+				"userId": &graphql.Field{Type: graphql.NewNonNull(graphql.ID), Resolve: subscriptionField(func(sub model.Subscription) any {
+					return sub.UserID.String()
+				})},
+				"startDate": &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: subscriptionField(func(sub model.Subscription) any {
+					return sub.StartDate
+				})},
+				"endDate": &graphql.Field{Type: graphql.String, Resolve: subscriptionField(func(sub model.Subscription) any {
+					return optional(sub.EndDate)
+				})},
+				"trialEndDate": &graphql.Field{Type: graphql.String, Resolve: subscriptionField(func(sub model.Subscription) any {
+					return optional(sub.TrialEndDate)
+				})},
+				"version": &graphql.Field{Type: graphql.NewNonNull(graphql.Int), Resolve: subscriptionField(func(sub model.Subscription) any {
+					return sub.Version
+				})},
+				"user": &graphql.Field{
+					Type: graphql.NewNonNull(userType),
+					Resolve: func(p graphql.ResolveParams) (any, error) {
+						return loadUser(p.Context, p.Source.(model.Subscription).UserID), nil
+					},
+				},
+			}
+		}),
+	})
+
+	userType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "User",
+		Description: "User with profile, users without one have the default currency and timezone",
+		Fields: graphql.Fields{
+			"id": &graphql.Field{Type: graphql.NewNonNull(graphql.ID), Resolve: userField(func(user *model.User) any {
+				return user.ID.String()
+			})},
+			"name": &graphql.Field{Type: graphql.String, Resolve: userField(func(user *model.User) any {
+				return optional(user.Name)
+			})},
+			"email": &graphql.Field{Type: graphql.String, Resolve: userField(func(user *model.User) any {
+				return optional(user.Email)
+			})},
+			"currency": &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: userField(func(user *model.User) any {
+				return user.Currency
+			})},
+			"timezone": &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: userField(func(user *model.User) any {
+				return user.Timezone
+			})},
+			"subscriptions": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(subscriptionType))),
+				Args: graphql.FieldConfigArgument{
+					"service": &graphql.ArgumentConfig{Type: graphql.String},
+				},
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					user := p.Source.(*model.User)
+					service, _ := p.Args["service"].(string)
+					thunk := requestFrom(p.Context).loaders.subscriptions.Load(p.Context, user.ID)
+					return func() (any, error) {
+						subs, err := thunk()
+						if err != nil {
+							return nil, err
+						}
+						if service == "" {
+							return subs, nil
+						}
+						filtered := []model.Subscription{}
+						for _, sub := range subs {
+							if sub.ServiceName == service {
+								filtered = append(filtered, sub)
+							}
+						}
+						return filtered, nil
+					}, nil
+				},
+			},
+			"total": &graphql.Field{
+				Type: graphql.NewNonNull(totalType),
+				Description: "Total of subscriptions in the user's currency, shared subscriptions count with the user's share",
+				Args: graphql.FieldConfigArgument{
+					"service": &graphql.ArgumentConfig{Type: graphql.String},
+					"start": &graphql.ArgumentConfig{Type: graphql.String, Description: "MM-YYYY, defaults to end"},
+					"end": &graphql.ArgumentConfig{Type: graphql.String, Description: "MM-YYYY, defaults to the current month"},
+				},
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					user := p.Source.(*model.User)
+					service, _ := p.Args["service"].(string)
+					start, end := period(user, p.Args)
+
+					total, err := requestFrom(p.Context).data.Repo.TotalCost(user.ID.String(), service, start, end)
+					if err != nil {
+						return nil, err
+					}
+					return model.Total{Total: total, Currency: user.Currency}, nil
+				},
+			},
+			"services": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(serviceTotalType))),
+				Description: "Totals per service of the user's subscriptions",
+				Args: graphql.FieldConfigArgument{
+					"start": &graphql.ArgumentConfig{Type: graphql.String, Description: "MM-YYYY, defaults to end"},
+					"end": &graphql.ArgumentConfig{Type: graphql.String, Description: "MM-YYYY, defaults to the current month"},
+				},
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					user := p.Source.(*model.User)
+					start, end := period(user, p.Args)
+					r := requestFrom(p.Context).data.Repo
+					thunk := requestFrom(p.Context).loaders.subscriptions.Load(p.Context, user.ID)
+					return func() (any, error) {
+						subs, err := thunk()
+						if err != nil {
+							return nil, err
+						}
+
+						var services []string
+						for _, sub := range subs {
+							if !slices.Contains(services, sub.ServiceName) {
+								services = append(services, sub.ServiceName)
+							}
+						}
+						slices.Sort(services)
+
+						totals := make([]serviceTotal, 0, len(services))
+						for _, service := range services {
+							total, err := r.TotalCost(user.ID.String(), service, start, end)
+							if err != nil {
+								return nil, err
+							}
+							totals = append(totals, serviceTotal{service: service, total: total, currency: user.Currency})
+						}
+						return totals, nil
+					}, nil
+				},
+			},
+			"monthly": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(monthlyCostType))),
+				Description: fmt.Sprintf("Total of every month from start to end, at most %d months", maxMonths),
+				Args: graphql.FieldConfigArgument{
+					"service": &graphql.ArgumentConfig{Type: graphql.String},
+					"start": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String), Description: "MM-YYYY"},
+					"end": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String), Description: "MM-YYYY"},
+				},
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					user := p.Source.(*model.User)
+					service, _ := p.Args["service"].(string)
+					list, err := months(p.Args["start"].(string), p.Args["end"].(string))
+					if err != nil {
+						return nil, err
+					}
+
+					r := requestFrom(p.Context).data.Repo
+					costs := make([]monthlyCost, len(list))
+					for i, month := range list {
+						total, err := r.TotalCost(user.ID.String(), service, month, month)
+						if err != nil {
+							return nil, err
+						}
+						costs[i] = monthlyCost{month: month, total: total, currency: user.Currency}
+					}
+					return costs, nil
+				},
+			},
+		},
+	})
+
+	return graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"me": &graphql.Field{
+				Type: graphql.NewNonNull(userType),
+				Description: "The authenticated user",
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					userID, ok := auth.UserIDFromContext(p.Context)
+					if !ok {
+						return nil, errors.New("me is only available to users")
+					}
+					if err := s.authorize(p.Context, "me", uuid.NullUUID{UUID: userID, Valid: true}); err != nil {
+						return nil, err
+					}
+					return loadUser(p.Context, userID), nil
+				},
+			},
+			"user": &graphql.Field{
+				Type: graphql.NewNonNull(userType),
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+				},
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					id, err := parseUUID(p.Args["id"])
+					if err != nil {
+						return nil, err
+					}
+					if err := s.authorize(p.Context, "user", id); err != nil {
+						return nil, err
+					}
+					return loadUser(p.Context, id.UUID), nil
+				},
+			},
+			"subscription": &graphql.Field{
+				Type: subscriptionType,
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+				},
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					id, err := strconv.Atoi(p.Args["id"].(string))
+					if err != nil {
+						return nil, fmt.Errorf("invalid subscription id: %w", err)
+					}
+					sub, err := requestFrom(p.Context).data.Repo.GetByID(id)
+					if err != nil {
+						return nil, err
+					}
+					return *sub, nil
+				},
+			},
+			"subscriptions": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(subscriptionType))),
+				Description: "Subscriptions ordered by id, pass id of the last one as after to get the next page",
+				Args: graphql.FieldConfigArgument{
+					"user": &graphql.ArgumentConfig{Type: graphql.ID},
+					"service": &graphql.ArgumentConfig{Type: graphql.String},
+					"first": &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: defaultListSize},
+					"after": &graphql.ArgumentConfig{Type: graphql.ID},
+				},
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					owner, err := parseUUID(p.Args["user"])
+					if err != nil {
+						return nil, err
+					}
+					if err := s.authorize(p.Context, "subscriptions", owner); err != nil {
+						return nil, err
+					}
+
+					first, _ := p.Args["first"].(int)
+					if first <= 0 || first > maxPageSize {
+						return nil, fmt.Errorf("first must be from 1 to %d", maxPageSize)
+					}
+					var after int64
+					if cursor, _ := p.Args["after"].(string); cursor != "" {
+						if after, err = strconv.ParseInt(cursor, 10, 64); err != nil {
+							return nil, fmt.Errorf("invalid after: %w", err)
+						}
+					}
+
+					service, _ := p.Args["service"].(string)
+					subs, err := requestFrom(p.Context).data.Repo.GetAll(model.SubscriptionFilter{
+						UserID: owner,
+						ServiceName: service,
+						AfterID: after,
+						Limit: first,
+					})
+					if err != nil {
+						return nil, err
+					}
+					if subs == nil {
+						subs = []model.Subscription{}
+					}
+					return subs, nil
+				},
+			},
+		},
+	})
+}
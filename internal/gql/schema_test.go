@@ -0,0 +1,217 @@
+package gql_test
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/teamcutter/subscriptions-service-task/internal/auth"
+	"github.com/teamcutter/subscriptions-service-task/internal/authz"
+	"github.com/teamcutter/subscriptions-service-task/internal/gql"
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+	"github.com/teamcutter/subscriptions-service-task/internal/repo"
+)
+
+var mockUUID1 uuid.UUID = uuid.New()
+var mockUUID2 uuid.UUID = uuid.New()
+
+// fakeRepo keeps subscriptions in memory and records queries
+type fakeRepo struct {
+	repo.Repo
+	mu sync.Mutex
+	subs []model.Subscription
+	filters []model.SubscriptionFilter
+	totals []string
+}
+
+func (r *fakeRepo) GetAll(filter model.SubscriptionFilter) ([]model.Subscription, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.filters = append(r.filters, filter)
+
+	var subs []model.Subscription
+	for _, sub := range r.subs {
+		if filter.UserID.Valid && sub.UserID != filter.UserID.UUID {
+			continue
+		}
+		if len(filter.UserIDs) > 0 && !containsID(filter.UserIDs, sub.UserID) {
+			continue
+		}
+		subs = append(subs, sub)
+	}
+	return subs, nil
+}
+
+func (r *fakeRepo) TotalCost(userID, service, start, end string) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.totals = append(r.totals, service+" "+start+" "+end)
+	return 100, nil
+}
+
+func containsID(ids []uuid.UUID, id uuid.UUID) bool {
+	for _, candidate := range ids {
+		if candidate == id {
+			return true
+		}
+	}
+	return false
+}
+
+// fakeUsers has profiles of some users and counts batch lookups
+type fakeUsers struct {
+	repo.UserRepo
+	mu sync.Mutex
+	profiles map[uuid.UUID]*model.User
+	batches [][]uuid.UUID
+}
+
+func (u *fakeUsers) GetMany(ids []uuid.UUID) ([]model.User, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.batches = append(u.batches, ids)
+
+	var users []model.User
+	for _, id := range ids {
+		if user, ok := u.profiles[id]; ok {
+			users = append(users, *user)
+		}
+	}
+	return users, nil
+}
+
+func setupTest(t *testing.T) (*gql.Schema, *fakeRepo, *fakeUsers) {
+	schema, err := gql.NewSchema(authz.NewPolicy(authz.DefaultRoles, nil, slog.Default()))
+	require.NoError(t, err)
+
+	subs := &fakeRepo{subs: []model.Subscription{
+		{ID: 1, ServiceName: "Netflix", Price: 500, UserID: mockUUID1, StartDate: "01-2025"},
+		{ID: 2, ServiceName: "Spotify", Price: 200, UserID: mockUUID1, StartDate: "01-2025"},
+		{ID: 3, ServiceName: "Netflix", Price: 500, UserID: mockUUID2, StartDate: "01-2025"},
+	}}
+	users := &fakeUsers{profiles: map[uuid.UUID]*model.User{
+		mockUUID1: {ID: mockUUID1, Name: "Ann", Currency: "EUR", Timezone: "UTC"},
+	}}
+	return schema, subs, users
+}
+
+// asSupport reads any subscription of the tenant
+func asSupport() context.Context {
+	return auth.WithPrincipal(context.Background(), auth.Principal{
+		UserID: uuid.NullUUID{UUID: uuid.New(), Valid: true},
+		TenantID: model.DefaultTenant,
+		Role: auth.RoleSupport,
+		Scopes: []string{auth.ScopeRead},
+	})
+}
+
+func execute(t *testing.T, schema *gql.Schema, ctx context.Context, subs *fakeRepo, users *fakeUsers, query string) (map[string]any, []string, bool) {
+	result, ok := schema.Execute(ctx, gql.Data{Repo: subs, Users: users}, gql.Request{Query: query})
+
+	var errs []string
+	for _, err := range result.Errors {
+		errs = append(errs, err.Message)
+	}
+
+	raw, err := json.Marshal(result.Data)
+	require.NoError(t, err)
+	var data map[string]any
+	require.NoError(t, json.Unmarshal(raw, &data))
+	return data, errs, ok
+}
+
+func TestNestedFieldsAreBatched(t *testing.T) {
+	schema, subs, users := setupTest(t)
+
+	data, errs, ok := execute(t, schema, asSupport(), subs, users,
+		`{ subscriptions(first: 3) { id user { name currency subscriptions { serviceName } } } }`)
+	require.True(t, ok)
+	require.Empty(t, errs)
+
+	list := data["subscriptions"].([]any)
+	require.Len(t, list, 3)
+	first := list[0].(map[string]any)["user"].(map[string]any)
+	assert.Equal(t, "Ann", first["name"])
+	assert.Len(t, first["subscriptions"], 2)
+	// users without profile get the defaults
+	assert.Equal(t, model.DefaultCurrency, list[2].(map[string]any)["user"].(map[string]any)["currency"])
+
+	// one query for the list, one for subscriptions of both users
+	assert.Len(t, subs.filters, 2)
+	assert.ElementsMatch(t, []uuid.UUID{mockUUID1, mockUUID2}, subs.filters[1].UserIDs)
+	require.Len(t, users.batches, 1)
+	assert.ElementsMatch(t, []uuid.UUID{mockUUID1, mockUUID2}, users.batches[0])
+}
+
+func TestTotals(t *testing.T) {
+	schema, subs, users := setupTest(t)
+
+	query := `{ user(id: "` + mockUUID1.String() + `") {
+		total(start: "01-2025", end: "03-2025") { total currency }
+		services(start: "01-2025", end: "03-2025") { service total }
+		monthly(start: "01-2025", end: "03-2025") { month total currency }
+	} }`
+	data, errs, ok := execute(t, schema, asSupport(), subs, users, query)
+	require.True(t, ok)
+	require.Empty(t, errs)
+
+	user := data["user"].(map[string]any)
+	assert.Equal(t, map[string]any{"total": float64(100), "currency": "EUR"}, user["total"])
+	assert.Equal(t, []any{
+		map[string]any{"service": "Netflix", "total": float64(100)},
+		map[string]any{"service": "Spotify", "total": float64(100)},
+	}, user["services"])
+	assert.Len(t, user["monthly"], 3)
+	assert.Contains(t, subs.totals, " 02-2025 02-2025")
+}
+
+func TestOtherUserForbidden(t *testing.T) {
+	schema, subs, users := setupTest(t)
+
+	ctx := auth.WithUserID(context.Background(), mockUUID1)
+	_, errs, ok := execute(t, schema, ctx, subs, users, `{ user(id: "`+mockUUID2.String()+`") { id } }`)
+	assert.True(t, ok)
+	assert.Equal(t, []string{authz.ErrForbidden.Error()}, errs)
+
+	data, errs, _ := execute(t, schema, ctx, subs, users, `{ me { id } }`)
+	assert.Empty(t, errs)
+	assert.Equal(t, mockUUID1.String(), data["me"].(map[string]any)["id"])
+}
+
+func TestDepthLimit(t *testing.T) {
+	schema, subs, users := setupTest(t)
+
+	_, errs, ok := execute(t, schema, asSupport(), subs, users,
+		`{ subscriptions(first: 1) { user { subscriptions { user { subscriptions { user { subscriptions { id } } } } } } } }`)
+	assert.False(t, ok)
+	require.Len(t, errs, 1)
+	assert.True(t, strings.HasPrefix(errs[0], "query depth 8"))
+	assert.Empty(t, subs.filters)
+}
+
+func TestComplexityLimit(t *testing.T) {
+	schema, subs, users := setupTest(t)
+
+	// fragments count as if they were inlined
+	_, errs, ok := execute(t, schema, asSupport(), subs, users,
+		`{ subscriptions(first: 50) { ...owner } } fragment owner on Subscription { user { subscriptions { id } } }`)
+	assert.False(t, ok)
+	require.Len(t, errs, 1)
+	assert.True(t, strings.HasPrefix(errs[0], "query complexity"))
+	assert.Empty(t, subs.filters)
+}
+
+func TestInvalidQuery(t *testing.T) {
+	schema, subs, users := setupTest(t)
+
+	_, errs, ok := execute(t, schema, asSupport(), subs, users, `{ subscriptions { unknown } }`)
+	assert.False(t, ok)
+	assert.NotEmpty(t, errs)
+}
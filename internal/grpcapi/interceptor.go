@@ -0,0 +1,225 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/teamcutter/subscriptions-service-task/internal/auth"
+	"github.com/teamcutter/subscriptions-service-task/internal/middleware"
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+	"github.com/teamcutter/subscriptions-service-task/internal/repo"
+	pb "github.com/teamcutter/subscriptions-service-task/pkg/api/subscriptions/v1"
+)
+
+// metadata keys are the lowercase REST headers
+const (
+	MetadataAuthorization = "authorization"
+	MetadataAPIKey = "x-api-key"
+	MetadataTenantID = "x-tenant-id"
+)
+
+// MetadataRetryAfter is sent with calls rejected by a rate limit
+const MetadataRetryAfter = "retry-after"
+
+// readMethods need read scope, the other methods need write scope
+var readMethods = map[string]bool{
+	pb.SubscriptionService_GetSubscription_FullMethodName: true,
+	pb.SubscriptionService_ListSubscriptions_FullMethodName: true,
+	pb.SubscriptionService_GetTotalCost_FullMethodName: true,
+}
+
+type APIKeyAuthenticator interface {
+	Authenticate(string) (*model.APIKey, error)
+}
+
+type TenantStore interface {
+	Exists(string) (bool, error)
+}
+
+// subscriptionMethod reports whether method is of SubscriptionService,
+// the other services are health and reflection
+func subscriptionMethod(method string) bool {
+	return strings.HasPrefix(method, "/"+pb.SubscriptionService_ServiceDesc.ServiceName+"/")
+}
+
+func firstValue(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// Auth authenticates calls of SubscriptionService like the REST auth
+// middlewares: x-api-key first, then a bearer token, resolves the tenant
+// from x-tenant-id and checks the scope of the method. Health and
+// reflection services are left open
+func Auth(keys APIKeyAuthenticator, verifier *auth.Verifier, tenants TenantStore, logger *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if !subscriptionMethod(info.FullMethod) {
+			return handler(ctx, req)
+		}
+
+		md, _ := metadata.FromIncomingContext(ctx)
+		principal, err := authenticate(md, keys, verifier, logger)
+		if err != nil {
+			return nil, err
+		}
+		ctx = auth.WithPrincipal(ctx, principal)
+
+		header := firstValue(md, MetadataTenantID)
+		tenantID := header
+		if principal.TenantID != "" {
+			if header != "" && header != principal.TenantID {
+				logger.Warn("tenant mismatch", "principal", principal.String(), "tenant_id", header)
+				return nil, status.Error(codes.PermissionDenied, "access to tenant "+header+" is not allowed")
+			}
+			tenantID = principal.TenantID
+		}
+		if tenantID == "" {
+			tenantID = model.DefaultTenant
+		}
+
+		exists, err := tenants.Exists(tenantID)
+		if err != nil {
+			logger.Error("tenant error", "error", err)
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		if !exists {
+			return nil, status.Error(codes.PermissionDenied, "unknown tenant "+tenantID)
+		}
+		ctx = auth.WithTenant(ctx, tenantID)
+		if resolved, ok := ctx.Value(tenantSlotKey{}).(*string); ok {
+			*resolved = tenantID
+		}
+
+		scope := auth.ScopeWrite
+		if readMethods[info.FullMethod] {
+			scope = auth.ScopeRead
+		}
+		if !principal.HasScope(scope) {
+			return nil, status.Error(codes.PermissionDenied, "missing scope "+scope)
+		}
+		return handler(ctx, req)
+	}
+}
+
+func authenticate(md metadata.MD, keys APIKeyAuthenticator, verifier *auth.Verifier, logger *slog.Logger) (auth.Principal, error) {
+	if key := firstValue(md, MetadataAPIKey); key != "" {
+		apiKey, err := keys.Authenticate(auth.HashAPIKey(key))
+		if errors.Is(err, repo.ErrAPIKeyNotFound) {
+			logger.Warn("authentication failed", "error", err)
+			return auth.Principal{}, status.Error(codes.Unauthenticated, "invalid api key")
+		}
+		if err != nil {
+			logger.Error("api key error", "error", err)
+			return auth.Principal{}, status.Error(codes.Internal, err.Error())
+		}
+		return auth.Principal{
+			APIKeyID: apiKey.ID,
+			TenantID: apiKey.TenantID,
+			Scopes: apiKey.Scopes,
+		}, nil
+	}
+
+	token, ok := strings.CutPrefix(firstValue(md, MetadataAuthorization), "Bearer ")
+	if !ok || token == "" {
+		return auth.Principal{}, status.Error(codes.Unauthenticated, "missing bearer token")
+	}
+	principal, err := verifier.Verify(token)
+	if err != nil {
+		logger.Warn("authentication failed", "error", err)
+		return auth.Principal{}, status.Error(codes.Unauthenticated, "invalid token")
+	}
+	return principal, nil
+}
+
+// rateLimitKey is the key of middleware.KeyByPrincipal: the principal set
+// by Auth and otherwise the address of the peer, so calls share buckets
+// with REST requests of the same caller
+func rateLimitKey(ctx context.Context) string {
+	if principal, ok := auth.PrincipalFromContext(ctx); ok {
+		return principal.String()
+	}
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return "ip:"
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		host = p.Addr.String()
+	}
+	return "ip:" + host
+}
+
+// RateLimit rejects calls of SubscriptionService over the limit with
+// ResourceExhausted and retry-after metadata. It takes the limiters of the
+// REST middlewares: before Auth calls are limited by peer address, after
+// it by principal. With methods set only those methods are limited
+func RateLimit(limiter *middleware.RateLimiter, logger *slog.Logger, methods ...string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if !subscriptionMethod(info.FullMethod) || (len(methods) > 0 && !slices.Contains(methods, info.FullMethod)) {
+			return handler(ctx, req)
+		}
+
+		key := rateLimitKey(ctx)
+		allowed, _, _, retryAfter := limiter.Allow(key)
+		if !allowed {
+			logger.Warn("rate limit exceeded", "key", key, "method", info.FullMethod)
+			grpc.SetHeader(ctx, metadata.Pairs(MetadataRetryAfter, strconv.Itoa(int(math.Ceil(retryAfter.Seconds())))))
+			return nil, status.Error(codes.ResourceExhausted, "rate limit exceeded")
+		}
+		return handler(ctx, req)
+	}
+}
+
+type tenantSlotKey struct{}
+
+// Metrics counts calls with the HTTP request metrics, method is "gRPC",
+// endpoint is the full method name and status is the code name. It runs
+// before Auth, which reports the resolved tenant back through ctx
+func Metrics(total *prometheus.CounterVec, duration *prometheus.HistogramVec) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		// unauthenticated calls belong to no tenant
+		tenant := "none"
+		res, err := handler(context.WithValue(ctx, tenantSlotKey{}, &tenant), req)
+
+		total.WithLabelValues("gRPC", info.FullMethod, status.Code(err).String(), tenant).Inc()
+		duration.WithLabelValues("gRPC", info.FullMethod, tenant).Observe(time.Since(start).Seconds())
+		return res, err
+	}
+}
+
+// Logging logs every call with its code and duration
+func Logging(logger *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		res, err := handler(ctx, req)
+
+		code := status.Code(err)
+		level := slog.LevelInfo
+		if code == codes.Internal || code == codes.Unknown {
+			level = slog.LevelError
+		}
+		logger.Log(ctx, level, "grpc call",
+			"method", info.FullMethod,
+			"code", code.String(),
+			"duration", time.Since(start),
+		)
+		return res, err
+	}
+}
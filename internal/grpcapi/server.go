@@ -0,0 +1,369 @@
+package grpcapi
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	"github.com/teamcutter/subscriptions-service-task/internal/auth"
+	"github.com/teamcutter/subscriptions-service-task/internal/authz"
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+	"github.com/teamcutter/subscriptions-service-task/internal/repo"
+	pb "github.com/teamcutter/subscriptions-service-task/pkg/api/subscriptions/v1"
+)
+
+const (
+	defaultPageSize = 100
+	maxPageSize = 1000
+	// monthDate is the format of start and end of total cost
+	monthDate = "01-2006"
+)
+
+// Server implements SubscriptionService over the same repository and
+// access rules as the REST handlers
+type Server struct {
+	pb.UnimplementedSubscriptionServiceServer
+	repository repo.Repo
+	users repo.UserRepo
+	policy *authz.Policy
+	logger *slog.Logger
+}
+
+func NewServer(repository repo.Repo, users repo.UserRepo, policy *authz.Policy, logger *slog.Logger) *Server {
+	return &Server{
+		repository: repository,
+		users: users,
+		policy: policy,
+		logger: logger,
+	}
+}
+
+// errorCode maps repository errors to gRPC codes
+func errorCode(err error) codes.Code {
+	switch {
+	case errors.Is(err, repo.ErrNotFound):
+		return codes.NotFound
+	case errors.Is(err, repo.ErrVersionMismatch):
+		return codes.FailedPrecondition
+	case errors.Is(err, repo.ErrInvalidSubscription), errors.Is(err, repo.ErrUserNotFound):
+		return codes.InvalidArgument
+	case errors.Is(err, repo.ErrBatchAborted):
+		return codes.Aborted
+	case errors.Is(err, authz.ErrForbidden):
+		return codes.PermissionDenied
+	}
+	return codes.Internal
+}
+
+func (s *Server) error(method string, err error) error {
+	code := errorCode(err)
+	if code == codes.Internal {
+		s.logger.Error(method+" error", "error", err)
+	}
+	return status.Error(code, err.Error())
+}
+
+// access authorizes the call like the REST handlers do, see handler access
+func (s *Server) access(ctx context.Context, write bool, owner uuid.NullUUID) (repo.Repo, error) {
+	resource, _ := grpc.Method(ctx)
+	scoped, err := s.policy.AuthorizeOwner(ctx, resource, write, owner)
+	if err != nil {
+		return nil, err
+	}
+
+	r := s.repository.ForTenant(auth.TenantFromContext(ctx))
+	if !scoped {
+		return r, nil
+	}
+	userID, _ := auth.UserIDFromContext(ctx)
+	return repo.NewScopedRepo(r, userID), nil
+}
+
+func parseUserID(id string) (uuid.NullUUID, error) {
+	if id == "" {
+		return uuid.NullUUID{}, nil
+	}
+	userID, err := uuid.Parse(id)
+	if err != nil {
+		return uuid.NullUUID{}, status.Errorf(codes.InvalidArgument, "invalid user_id: %v", err)
+	}
+	return uuid.NullUUID{UUID: userID, Valid: true}, nil
+}
+
+func toProto(sub *model.Subscription) *pb.Subscription {
+	return &pb.Subscription{
+		Id: sub.ID,
+		ServiceName: sub.ServiceName,
+		Price: int64(sub.Price),
+		UserId: sub.UserID.String(),
+		StartDate: sub.StartDate,
+		EndDate: sub.EndDate,
+		TrialEndDate: sub.TrialEndDate,
+		Version: int32(sub.Version),
+	}
+}
+
+func fromProto(sub *pb.Subscription) (*model.Subscription, error) {
+	if sub == nil {
+		return nil, status.Error(codes.InvalidArgument, "missing subscription")
+	}
+	userID, err := parseUserID(sub.GetUserId())
+	if err != nil {
+		return nil, err
+	}
+	return &model.Subscription{
+		ID: sub.GetId(),
+		ServiceName: sub.GetServiceName(),
+		Price: int(sub.GetPrice()),
+		UserID: userID.UUID,
+		StartDate: sub.GetStartDate(),
+		EndDate: sub.GetEndDate(),
+		TrialEndDate: sub.GetTrialEndDate(),
+	}, nil
+}
+
+func (s *Server) CreateSubscription(ctx context.Context, req *pb.CreateSubscriptionRequest) (*pb.Subscription, error) {
+	sub, err := fromProto(req.GetSubscription())
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := s.access(ctx, true, uuid.NullUUID{UUID: sub.UserID, Valid: sub.UserID != uuid.Nil})
+	if err != nil {
+		return nil, s.error("create", err)
+	}
+	if sub.UserID == uuid.Nil {
+		sub.UserID, _ = auth.UserIDFromContext(ctx)
+	}
+	if err := r.Create(sub); err != nil {
+		return nil, s.error("create", err)
+	}
+
+	s.logger.Info("subscription created", "user_id", sub.UserID, "service", sub.ServiceName, "start", sub.StartDate)
+	return toProto(sub), nil
+}
+
+func (s *Server) GetSubscription(ctx context.Context, req *pb.GetSubscriptionRequest) (*pb.Subscription, error) {
+	r, err := s.access(ctx, false, uuid.NullUUID{})
+	if err != nil {
+		return nil, s.error("get by id", err)
+	}
+
+	sub, err := r.GetByID(int(req.GetId()))
+	if err != nil {
+		return nil, s.error("get by id", err)
+	}
+	return toProto(sub), nil
+}
+
+// page tokens are opaque to clients, they hold the last returned id
+func encodePageToken(id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(id, 10)))
+}
+
+func decodePageToken(token string) (int64, error) {
+	if token == "" {
+		return 0, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, status.Error(codes.InvalidArgument, "invalid page_token")
+	}
+	id, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil || id < 0 {
+		return 0, status.Error(codes.InvalidArgument, "invalid page_token")
+	}
+	return id, nil
+}
+
+func (s *Server) ListSubscriptions(ctx context.Context, req *pb.ListSubscriptionsRequest) (*pb.ListSubscriptionsResponse, error) {
+	userID, err := parseUserID(req.GetUserId())
+	if err != nil {
+		return nil, err
+	}
+	afterID, err := decodePageToken(req.GetPageToken())
+	if err != nil {
+		return nil, err
+	}
+
+	pageSize := int(req.GetPageSize())
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+	pageSize = min(pageSize, maxPageSize)
+
+	r, err := s.access(ctx, false, userID)
+	if err != nil {
+		return nil, s.error("get all", err)
+	}
+
+	// one extra row tells whether there is a next page
+	subs, err := r.GetAll(model.SubscriptionFilter{
+		UserID: userID,
+		ServiceName: req.GetServiceName(),
+		AfterID: afterID,
+		Limit: pageSize + 1,
+	})
+	if err != nil {
+		return nil, s.error("get all", err)
+	}
+
+	res := &pb.ListSubscriptionsResponse{}
+	if len(subs) > pageSize {
+		subs = subs[:pageSize]
+		res.NextPageToken = encodePageToken(subs[pageSize-1].ID)
+	}
+	for i := range subs {
+		res.Subscriptions = append(res.Subscriptions, toProto(&subs[i]))
+	}
+	return res, nil
+}
+
+func (s *Server) UpdateSubscription(ctx context.Context, req *pb.UpdateSubscriptionRequest) (*pb.Subscription, error) {
+	sub, err := fromProto(req.GetSubscription())
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := s.access(ctx, true, uuid.NullUUID{UUID: sub.UserID, Valid: sub.UserID != uuid.Nil})
+	if err != nil {
+		return nil, s.error("update", err)
+	}
+	if err := r.Update(sub, int(req.GetVersion())); err != nil {
+		return nil, s.error("update", err)
+	}
+
+	s.logger.Info("subscription updated", "service_id", sub.ID)
+	return toProto(sub), nil
+}
+
+func (s *Server) DeleteSubscription(ctx context.Context, req *pb.DeleteSubscriptionRequest) (*emptypb.Empty, error) {
+	r, err := s.access(ctx, true, uuid.NullUUID{})
+	if err != nil {
+		return nil, s.error("delete", err)
+	}
+	if err := r.Delete(int(req.GetId()), int(req.GetVersion())); err != nil {
+		return nil, s.error("delete", err)
+	}
+
+	s.logger.Info("subscription deleted", "service_id", req.GetId())
+	return &emptypb.Empty{}, nil
+}
+
+func (s *Server) BatchSubscriptions(ctx context.Context, req *pb.BatchSubscriptionsRequest) (*pb.BatchSubscriptionsResponse, error) {
+	if len(req.GetOperations()) == 0 || len(req.GetOperations()) > repo.MaxBatchSize {
+		return nil, status.Errorf(codes.InvalidArgument, "batch must contain from 1 to %d operations", repo.MaxBatchSize)
+	}
+
+	ops := make([]model.BatchOperation, len(req.GetOperations()))
+	for i, op := range req.GetOperations() {
+		ops[i] = model.BatchOperation{Op: op.GetOp(), ID: op.GetId(), Version: int(op.GetVersion())}
+		if op.GetSubscription() != nil {
+			sub, err := fromProto(op.GetSubscription())
+			if err != nil {
+				return nil, status.Errorf(codes.InvalidArgument, "operation %d: %v", i, status.Convert(err).Message())
+			}
+			ops[i].Subscription = sub
+		}
+	}
+
+	r, err := s.access(ctx, true, uuid.NullUUID{})
+	if err != nil {
+		return nil, s.error("batch", err)
+	}
+
+	results, err := r.Batch(ops, req.GetAtomic())
+	if err != nil {
+		return nil, s.error("batch", err)
+	}
+
+	res := &pb.BatchSubscriptionsResponse{Results: make([]*pb.BatchResult, len(results))}
+	failed := 0
+	for i, result := range results {
+		res.Results[i] = &pb.BatchResult{Index: int32(result.Index), Id: result.ID, Version: int32(result.Version)}
+		if result.Err != nil {
+			res.Results[i].Code = int32(errorCode(result.Err))
+			res.Results[i].Error = result.Err.Error()
+			failed++
+		}
+	}
+
+	s.logger.Info("subscriptions batch applied", "operations", len(results), "failed", failed, "atomic", req.GetAtomic())
+	return res, nil
+}
+
+// preferences returns currency and timezone of user, defaults for
+// users without profile
+func (s *Server) preferences(ctx context.Context, userID uuid.UUID) (string, *time.Location, error) {
+	user, err := s.users.ForTenant(auth.TenantFromContext(ctx)).Get(userID)
+	if errors.Is(err, repo.ErrUserNotFound) {
+		return model.DefaultCurrency, time.UTC, nil
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	location, err := time.LoadLocation(user.Timezone)
+	if err != nil {
+		location = time.UTC
+	}
+	return user.Currency, location, nil
+}
+
+func (s *Server) GetTotalCost(ctx context.Context, req *pb.GetTotalCostRequest) (*pb.TotalCost, error) {
+	owner, err := parseUserID(req.GetUserId())
+	if err != nil {
+		return nil, err
+	}
+	if !owner.Valid {
+		userID, ok := auth.UserIDFromContext(ctx)
+		if !ok {
+			return nil, status.Error(codes.InvalidArgument, "missing user_id")
+		}
+		owner = uuid.NullUUID{UUID: userID, Valid: true}
+	}
+
+	r, err := s.access(ctx, false, owner)
+	if err != nil {
+		return nil, s.error("total cost", err)
+	}
+
+	currency, location, err := s.preferences(ctx, owner.UUID)
+	if err != nil {
+		return nil, s.error("total cost", err)
+	}
+	end := req.GetEnd()
+	if end == "" {
+		end = time.Now().In(location).Format(monthDate)
+	}
+	start := req.GetStart()
+	if start == "" {
+		start = end
+	}
+	from, err := time.Parse(monthDate, start)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid start: %v", err)
+	}
+	to, err := time.Parse(monthDate, end)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid end: %v", err)
+	}
+	if to.Before(from) {
+		return nil, status.Error(codes.InvalidArgument, "end is before start")
+	}
+
+	total, err := r.TotalCost(owner.UUID.String(), req.GetServiceName(), start, end)
+	if err != nil {
+		return nil, s.error("total cost", err)
+	}
+	return &pb.TotalCost{Total: int64(total), Currency: currency}, nil
+}
@@ -0,0 +1,308 @@
+package grpcapi_test
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/teamcutter/subscriptions-service-task/internal/auth"
+	"github.com/teamcutter/subscriptions-service-task/internal/authz"
+	"github.com/teamcutter/subscriptions-service-task/internal/grpcapi"
+	"github.com/teamcutter/subscriptions-service-task/internal/middleware"
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+	"github.com/teamcutter/subscriptions-service-task/internal/repo"
+	pb "github.com/teamcutter/subscriptions-service-task/pkg/api/subscriptions/v1"
+)
+
+const secret = "secret"
+
+var mockUUID1 uuid.UUID = uuid.New()
+var mockUUID2 uuid.UUID = uuid.New()
+
+type MockRepo struct {
+	mock.Mock
+	tenantID string
+}
+
+func (m *MockRepo) Create(sub *model.Subscription) error {
+	args := m.Called(sub)
+	return args.Error(0)
+}
+
+func (m *MockRepo) GetAll(filter model.SubscriptionFilter) ([]model.Subscription, error) {
+	args := m.Called(filter)
+	return args.Get(0).([]model.Subscription), args.Error(1)
+}
+
+func (m *MockRepo) Stream(filter model.SubscriptionFilter, fn func(model.Subscription) error) error {
+	args := m.Called(filter, fn)
+	return args.Error(0)
+}
+
+func (m *MockRepo) GetByID(id int) (*model.Subscription, error) {
+	args := m.Called(id)
+	sub, _ := args.Get(0).(*model.Subscription)
+	return sub, args.Error(1)
+}
+
+func (m *MockRepo) Update(sub *model.Subscription, version int) error {
+	args := m.Called(sub, version)
+	return args.Error(0)
+}
+
+func (m *MockRepo) Delete(id int, version int) error {
+	args := m.Called(id, version)
+	return args.Error(0)
+}
+
+func (m *MockRepo) TotalCost(userID, service, start, end string) (int, error) {
+	args := m.Called(userID, service, start, end)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockRepo) Batch(ops []model.BatchOperation, atomic bool) ([]model.BatchResult, error) {
+	args := m.Called(ops, atomic)
+	return args.Get(0).([]model.BatchResult), args.Error(1)
+}
+
+// ForTenant remembers the tenant so that tests can check it
+func (m *MockRepo) ForTenant(tenantID string) repo.Repo {
+	m.tenantID = tenantID
+	return m
+}
+
+// fakeUsers has no user profiles
+type fakeUsers struct{}
+
+func (fakeUsers) Get(uuid.UUID) (*model.User, error) { return nil, repo.ErrUserNotFound }
+func (fakeUsers) GetMany([]uuid.UUID) ([]model.User, error) { return nil, nil }
+func (fakeUsers) Save(*model.User) error { return nil }
+func (fakeUsers) SaveMany([]model.User) error { return nil }
+func (fakeUsers) Missing([]uuid.UUID) ([]uuid.UUID, error) { return nil, nil }
+func (fakeUsers) Ensure([]uuid.UUID) error { return nil }
+func (f fakeUsers) ForTenant(string) repo.UserRepo { return f }
+
+// fakeKeys knows a single read-only key
+type fakeKeys struct{}
+
+func (fakeKeys) Authenticate(hash string) (*model.APIKey, error) {
+	if hash != auth.HashAPIKey("sk_read") {
+		return nil, repo.ErrAPIKeyNotFound
+	}
+	return &model.APIKey{ID: 1, TenantID: model.DefaultTenant, Scopes: []string{auth.ScopeRead}}, nil
+}
+
+type fakeTenants struct{}
+
+func (fakeTenants) Exists(tenantID string) (bool, error) { return tenantID == model.DefaultTenant, nil }
+
+// setupTest serves SubscriptionService with interceptors run after Auth
+func setupTest(t *testing.T, interceptors ...grpc.UnaryServerInterceptor) (pb.SubscriptionServiceClient, *grpc.ClientConn, *MockRepo) {
+	log := slog.Default()
+	verifier, err := auth.NewVerifier(auth.Config{HS256Secret: secret})
+	require.NoError(t, err)
+
+	total := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_requests_total"}, []string{"method", "endpoint", "status", "tenant"})
+	duration := prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "test_request_duration_seconds"}, []string{"method", "endpoint", "tenant"})
+
+	mockRepo := new(MockRepo)
+	server := grpc.NewServer(grpc.ChainUnaryInterceptor(append([]grpc.UnaryServerInterceptor{
+		grpcapi.Metrics(total, duration),
+		grpcapi.Logging(log),
+		grpcapi.Auth(fakeKeys{}, verifier, fakeTenants{}, log),
+	}, interceptors...)...))
+	pb.RegisterSubscriptionServiceServer(server, grpcapi.NewServer(mockRepo, fakeUsers{}, authz.NewPolicy(authz.DefaultRoles, nil, log), log))
+	healthpb.RegisterHealthServer(server, health.NewServer())
+
+	lis := bufconn.Listen(1 << 20)
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return pb.NewSubscriptionServiceClient(conn), conn, mockRepo
+}
+
+func asUser(t *testing.T, userID uuid.UUID) context.Context {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		Subject: userID.String(),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}).SignedString([]byte(secret))
+	require.NoError(t, err)
+	return metadata.AppendToOutgoingContext(context.Background(), grpcapi.MetadataAuthorization, "Bearer "+token)
+}
+
+func TestCreateSubscription(t *testing.T) {
+	client, _, repo := setupTest(t)
+
+	repo.On("Create", mock.AnythingOfType("*model.Subscription")).Return(nil)
+
+	sub, err := client.CreateSubscription(asUser(t, mockUUID1), &pb.CreateSubscriptionRequest{
+		Subscription: &pb.Subscription{ServiceName: "Netflix", Price: 500, StartDate: "07-2025"},
+	})
+	if assert.NoError(t, err) {
+		// the owner defaults to the caller
+		assert.Equal(t, mockUUID1.String(), sub.UserId)
+		assert.Equal(t, "Netflix", sub.ServiceName)
+		assert.Equal(t, model.DefaultTenant, repo.tenantID)
+	}
+}
+
+func TestListSubscriptionsPages(t *testing.T) {
+	client, _, repo := setupTest(t)
+
+	owner := uuid.NullUUID{UUID: mockUUID1, Valid: true}
+	repo.On("GetAll", model.SubscriptionFilter{UserID: owner, Limit: 3}).Return([]model.Subscription{
+		{ID: 1, UserID: mockUUID1}, {ID: 2, UserID: mockUUID1}, {ID: 3, UserID: mockUUID1},
+	}, nil)
+	repo.On("GetAll", model.SubscriptionFilter{UserID: owner, AfterID: 2, Limit: 3}).Return([]model.Subscription{
+		{ID: 3, UserID: mockUUID1},
+	}, nil)
+
+	ctx := asUser(t, mockUUID1)
+	first, err := client.ListSubscriptions(ctx, &pb.ListSubscriptionsRequest{UserId: mockUUID1.String(), PageSize: 2})
+	require.NoError(t, err)
+	assert.Len(t, first.Subscriptions, 2)
+	assert.NotEmpty(t, first.NextPageToken)
+
+	second, err := client.ListSubscriptions(ctx, &pb.ListSubscriptionsRequest{UserId: mockUUID1.String(), PageSize: 2, PageToken: first.NextPageToken})
+	require.NoError(t, err)
+	assert.Len(t, second.Subscriptions, 1)
+	assert.Empty(t, second.NextPageToken)
+}
+
+func TestListSubscriptionsOfOtherUserForbidden(t *testing.T) {
+	client, _, repo := setupTest(t)
+
+	_, err := client.ListSubscriptions(asUser(t, mockUUID1), &pb.ListSubscriptionsRequest{UserId: mockUUID2.String()})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	repo.AssertNotCalled(t, "GetAll", mock.Anything)
+}
+
+func TestGetSubscriptionNotFound(t *testing.T) {
+	client, _, mockRepo := setupTest(t)
+
+	mockRepo.On("GetByID", 42).Return(nil, repo.ErrNotFound)
+
+	_, err := client.GetSubscription(asUser(t, mockUUID1), &pb.GetSubscriptionRequest{Id: 42})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestUnauthenticated(t *testing.T) {
+	client, _, _ := setupTest(t)
+
+	_, err := client.GetSubscription(context.Background(), &pb.GetSubscriptionRequest{Id: 1})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), grpcapi.MetadataAPIKey, "sk_unknown")
+	_, err = client.GetSubscription(ctx, &pb.GetSubscriptionRequest{Id: 1})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestReadOnlyKeyCannotWrite(t *testing.T) {
+	client, _, repo := setupTest(t)
+
+	repo.On("GetByID", 1).Return(&model.Subscription{ID: 1, UserID: mockUUID1}, nil)
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), grpcapi.MetadataAPIKey, "sk_read")
+	_, err := client.GetSubscription(ctx, &pb.GetSubscriptionRequest{Id: 1})
+	assert.NoError(t, err)
+
+	_, err = client.DeleteSubscription(ctx, &pb.DeleteSubscriptionRequest{Id: 1})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	repo.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+}
+
+func TestUnknownTenant(t *testing.T) {
+	client, _, _ := setupTest(t)
+
+	ctx := metadata.AppendToOutgoingContext(asUser(t, mockUUID1), grpcapi.MetadataTenantID, "other")
+	_, err := client.GetSubscription(ctx, &pb.GetSubscriptionRequest{Id: 1})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
+
+func TestHealthNeedsNoAuth(t *testing.T) {
+	_, conn, _ := setupTest(t)
+
+	res, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{})
+	if assert.NoError(t, err) {
+		assert.Equal(t, healthpb.HealthCheckResponse_SERVING, res.Status)
+	}
+}
+
+func TestRateLimitByPrincipal(t *testing.T) {
+	limiter := middleware.NewRateLimiter("caller", middleware.Limit{Rate: 0.001, Burst: 1})
+	client, _, repo := setupTest(t, grpcapi.RateLimit(limiter, slog.Default()))
+
+	repo.On("GetByID", 1).Return(&model.Subscription{ID: 1, UserID: mockUUID1}, nil)
+	repo.On("GetByID", 2).Return(&model.Subscription{ID: 2, UserID: mockUUID2}, nil)
+
+	_, err := client.GetSubscription(asUser(t, mockUUID1), &pb.GetSubscriptionRequest{Id: 1})
+	assert.NoError(t, err)
+
+	var header metadata.MD
+	_, err = client.GetSubscription(asUser(t, mockUUID1), &pb.GetSubscriptionRequest{Id: 1}, grpc.Header(&header))
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.NotEmpty(t, header.Get(grpcapi.MetadataRetryAfter))
+
+	// another caller has a bucket of its own
+	_, err = client.GetSubscription(asUser(t, mockUUID2), &pb.GetSubscriptionRequest{Id: 2})
+	assert.NoError(t, err)
+}
+
+func TestRateLimitOfMethod(t *testing.T) {
+	limiter := middleware.NewRateLimiter("total", middleware.Limit{Rate: 0.001, Burst: 1})
+	client, _, repo := setupTest(t, grpcapi.RateLimit(limiter, slog.Default(), pb.SubscriptionService_GetTotalCost_FullMethodName))
+
+	repo.On("GetByID", 1).Return(&model.Subscription{ID: 1, UserID: mockUUID1}, nil)
+	repo.On("TotalCost", mockUUID1.String(), "", "01-2025", "01-2025").Return(100, nil)
+
+	ctx := asUser(t, mockUUID1)
+	req := &pb.GetTotalCostRequest{Start: "01-2025", End: "01-2025"}
+	_, err := client.GetTotalCost(ctx, req)
+	assert.NoError(t, err)
+	_, err = client.GetTotalCost(ctx, req)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	// other methods are not limited
+	for range 2 {
+		_, err = client.GetSubscription(ctx, &pb.GetSubscriptionRequest{Id: 1})
+		assert.NoError(t, err)
+	}
+}
+
+func TestGetTotalCostInvalidMonths(t *testing.T) {
+	client, _, repo := setupTest(t)
+
+	for _, req := range []*pb.GetTotalCostRequest{
+		{Start: "2025-01", End: "02-2025"},
+		{Start: "01-2025", End: "13-2025"},
+		{Start: "03-2025", End: "02-2025"},
+	} {
+		_, err := client.GetTotalCost(asUser(t, mockUUID1), req)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err), "start %s end %s", req.Start, req.End)
+	}
+	repo.AssertNotCalled(t, "TotalCost", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
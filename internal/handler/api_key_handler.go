@@ -0,0 +1,224 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/teamcutter/subscriptions-service-task/internal/auth"
+	"github.com/teamcutter/subscriptions-service-task/internal/authz"
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+	"github.com/teamcutter/subscriptions-service-task/internal/repo"
+)
+
+type APIKeyHandler struct {
+	keys repo.APIKeyRepo
+	policy *authz.Policy
+	logger *slog.Logger
+}
+
+func NewAPIKeyHandler(keys repo.APIKeyRepo, policy *authz.Policy, logger *slog.Logger) *APIKeyHandler {
+	return &APIKeyHandler{
+		keys: keys,
+		policy: policy,
+		logger: logger,
+	}
+}
+
+func (h *APIKeyHandler) authorize(c echo.Context) error {
+	return h.policy.Authorize(c.Request().Context(), authz.ManageAPIKeys, c.Request().Method+" "+c.Path())
+}
+
+// callerTenant is the tenant whose keys the caller manages,
+// empty for platform callers managing keys of every tenant
+func callerTenant(c echo.Context) string {
+	principal, _ := auth.PrincipalFromContext(c.Request().Context())
+	return principal.TenantID
+}
+
+type createAPIKeyRequest struct {
+	// TenantID binds the key to a tenant, it is only accepted from platform
+	// callers, keys created by tenant admins always belong to their tenant
+	TenantID string `json:"tenant_id,omitempty"`
+	Name string `json:"name"`
+	Scopes []string `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+func apiKeyStatus(err error) int {
+	switch {
+	case errors.Is(err, repo.ErrAPIKeyNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, repo.ErrTenantNotFound):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// Create godoc
+// @Summary Create API key
+// @Description The key is returned only in this response.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param key body createAPIKeyRequest true "API key"
+// @Success 201 {object} model.NewAPIKey
+// @Failure 400
+// @Failure 403
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /v1/admin/api-keys [post]
+func (h *APIKeyHandler) Create(c echo.Context) error {
+	if err := h.authorize(c); err != nil {
+		return c.JSON(http.StatusForbidden, err.Error())
+	}
+
+	var req createAPIKeyRequest
+	if err := c.Bind(&req); err != nil {
+		h.logger.Error("JSON binding error", "error", err)
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	if req.Name == "" || len(req.Scopes) == 0 {
+		err := errors.New("name and scopes are required")
+		h.logger.Error("create api key error", "error", err)
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	for _, scope := range req.Scopes {
+		if !slices.Contains(auth.Scopes, scope) {
+			err := fmt.Errorf("unknown scope %q", scope)
+			h.logger.Error("create api key error", "error", err)
+			return c.JSON(http.StatusBadRequest, err.Error())
+		}
+	}
+	if req.ExpiresAt != nil && req.ExpiresAt.Before(time.Now()) {
+		err := errors.New("expires_at must be in the future")
+		h.logger.Error("create api key error", "error", err)
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	tenantID := callerTenant(c)
+	if tenantID == "" {
+		tenantID = req.TenantID
+	} else if req.TenantID != "" && req.TenantID != tenantID {
+		return c.JSON(http.StatusForbidden, "keys of other tenants can not be created")
+	}
+
+	key, prefix, hash, err := auth.GenerateAPIKey()
+	if err != nil {
+		h.logger.Error("create api key error", "error", err)
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+
+	apiKey := model.APIKey{
+		TenantID: tenantID,
+		Name: req.Name,
+		Prefix: prefix,
+		Scopes: req.Scopes,
+		ExpiresAt: req.ExpiresAt,
+	}
+	if err := h.keys.Create(&apiKey, hash); err != nil {
+		h.logger.Error("create api key error", "error", err)
+		return c.JSON(apiKeyStatus(err), err.Error())
+	}
+
+	h.logger.Info("api key created", "key_id", apiKey.ID, "tenant_id", apiKey.TenantID, "name", apiKey.Name, "scopes", apiKey.Scopes)
+	return c.JSON(http.StatusCreated, model.NewAPIKey{APIKey: apiKey, Key: key})
+}
+
+// List godoc
+// @Summary List API keys
+// @Description Tenant admins see keys of their tenant, platform keys see all.
+// @Tags admin
+// @Produce json
+// @Success 200 {array} model.APIKey
+// @Failure 403
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /v1/admin/api-keys [get]
+func (h *APIKeyHandler) List(c echo.Context) error {
+	if err := h.authorize(c); err != nil {
+		return c.JSON(http.StatusForbidden, err.Error())
+	}
+
+	keys, err := h.keys.List(callerTenant(c))
+	if err != nil {
+		h.logger.Error("list api keys error", "error", err)
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, keys)
+}
+
+// Rotate godoc
+// @Summary Rotate API key
+// @Description Old key stops working immediately, the new one is returned only in this response.
+// @Tags admin
+// @Produce json
+// @Param id path int true "Key ID"
+// @Success 200 {object} model.NewAPIKey
+// @Failure 403
+// @Failure 404
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /v1/admin/api-keys/{id}/rotate [post]
+func (h *APIKeyHandler) Rotate(c echo.Context) error {
+	if err := h.authorize(c); err != nil {
+		return c.JSON(http.StatusForbidden, err.Error())
+	}
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		h.logger.Error("rotate api key error", "error", err)
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	key, prefix, hash, err := auth.GenerateAPIKey()
+	if err != nil {
+		h.logger.Error("rotate api key error", "error", err)
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+
+	apiKey, err := h.keys.Rotate(callerTenant(c), id, prefix, hash)
+	if err != nil {
+		h.logger.Error("rotate api key error", "error", err)
+		return c.JSON(apiKeyStatus(err), err.Error())
+	}
+
+	h.logger.Info("api key rotated", "key_id", id)
+	return c.JSON(http.StatusOK, model.NewAPIKey{APIKey: *apiKey, Key: key})
+}
+
+// Revoke godoc
+// @Summary Revoke API key
+// @Tags admin
+// @Param id path int true "Key ID"
+// @Success 204
+// @Failure 403
+// @Failure 404
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /v1/admin/api-keys/{id} [delete]
+func (h *APIKeyHandler) Revoke(c echo.Context) error {
+	if err := h.authorize(c); err != nil {
+		return c.JSON(http.StatusForbidden, err.Error())
+	}
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		h.logger.Error("revoke api key error", "error", err)
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	if err := h.keys.Revoke(callerTenant(c), id); err != nil {
+		h.logger.Error("revoke api key error", "error", err)
+		return c.JSON(apiKeyStatus(err), err.Error())
+	}
+
+	h.logger.Info("api key revoked", "key_id", id)
+	return c.NoContent(http.StatusNoContent)
+}
\ No newline at end of file
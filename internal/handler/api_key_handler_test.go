@@ -0,0 +1,157 @@
+package handler_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/teamcutter/subscriptions-service-task/internal/auth"
+	"github.com/teamcutter/subscriptions-service-task/internal/authz"
+	"github.com/teamcutter/subscriptions-service-task/internal/handler"
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+	repoPkg "github.com/teamcutter/subscriptions-service-task/internal/repo"
+)
+
+type MockAPIKeyRepo struct {
+	mock.Mock
+}
+
+func (m *MockAPIKeyRepo) Create(key *model.APIKey, hash string) error {
+	args := m.Called(key, hash)
+	key.ID = 1
+	return args.Error(0)
+}
+
+func (m *MockAPIKeyRepo) List(tenantID string) ([]model.APIKey, error) {
+	args := m.Called(tenantID)
+	return args.Get(0).([]model.APIKey), args.Error(1)
+}
+
+func (m *MockAPIKeyRepo) Rotate(tenantID string, id int64, prefix, hash string) (*model.APIKey, error) {
+	args := m.Called(tenantID, id, prefix, hash)
+	key, _ := args.Get(0).(*model.APIKey)
+	return key, args.Error(1)
+}
+
+func (m *MockAPIKeyRepo) Revoke(tenantID string, id int64) error {
+	args := m.Called(tenantID, id)
+	return args.Error(0)
+}
+
+func (m *MockAPIKeyRepo) Authenticate(hash string) (*model.APIKey, error) {
+	args := m.Called(hash)
+	key, _ := args.Get(0).(*model.APIKey)
+	return key, args.Error(1)
+}
+
+// platformAdmin is an admin API key not bound to a tenant
+var platformAdmin = auth.Principal{APIKeyID: 1, Scopes: []string{auth.ScopeAdmin}}
+
+func TestCreateAPIKey(t *testing.T) {
+	e := echo.New()
+	keys := new(MockAPIKeyRepo)
+	h := handler.NewAPIKeyHandler(keys, authz.NewPolicy(authz.DefaultRoles, nil, slog.Default()), slog.Default())
+
+	keys.On("Create", mock.AnythingOfType("*model.APIKey"), mock.AnythingOfType("string")).Return(nil)
+
+	body := `{"name":"billing","scopes":["read"]}`
+	req := httptest.NewRequest(http.MethodPost, "/admin/api-keys", bytes.NewBufferString(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req = req.WithContext(auth.WithPrincipal(req.Context(), platformAdmin))
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	if assert.NoError(t, h.Create(c)) {
+		assert.Equal(t, http.StatusCreated, rec.Code)
+
+		var got model.NewAPIKey
+		if assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got)) {
+			assert.True(t, strings.HasPrefix(got.Key, got.Prefix))
+			assert.Equal(t, []string{"read"}, got.Scopes)
+		}
+
+		// only the hash is stored
+		hash := keys.Calls[0].Arguments.String(1)
+		assert.NotContains(t, rec.Body.String(), hash)
+	}
+}
+
+func TestCreateAPIKeyUnknownScope(t *testing.T) {
+	e := echo.New()
+	keys := new(MockAPIKeyRepo)
+	h := handler.NewAPIKeyHandler(keys, authz.NewPolicy(authz.DefaultRoles, nil, slog.Default()), slog.Default())
+
+	body := `{"name":"billing","scopes":["superuser"]}`
+	req := httptest.NewRequest(http.MethodPost, "/admin/api-keys", bytes.NewBufferString(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req = req.WithContext(auth.WithPrincipal(req.Context(), platformAdmin))
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	if assert.NoError(t, h.Create(c)) {
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		keys.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	}
+}
+
+func TestRevokeMissingAPIKey(t *testing.T) {
+	e := echo.New()
+	keys := new(MockAPIKeyRepo)
+	h := handler.NewAPIKeyHandler(keys, authz.NewPolicy(authz.DefaultRoles, nil, slog.Default()), slog.Default())
+
+	keys.On("Revoke", "", int64(7)).Return(repoPkg.ErrAPIKeyNotFound)
+
+	req := httptest.NewRequest(http.MethodDelete, "/admin/api-keys/7", nil)
+	req = req.WithContext(auth.WithPrincipal(req.Context(), platformAdmin))
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("7")
+
+	if assert.NoError(t, h.Revoke(c)) {
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestListAPIKeysRequiresAdmin(t *testing.T) {
+	e := echo.New()
+	keys := new(MockAPIKeyRepo)
+	h := handler.NewAPIKeyHandler(keys, authz.NewPolicy(authz.DefaultRoles, nil, slog.Default()), slog.Default())
+
+	keys.On("List", "").Return([]model.APIKey{{ID: 1, Name: "billing"}}, nil)
+
+	for role, status := range map[string]int{auth.RoleUser: http.StatusForbidden, auth.RoleAdmin: http.StatusOK} {
+		req := httptest.NewRequest(http.MethodGet, "/admin/api-keys", nil)
+		req = req.WithContext(auth.WithPrincipal(req.Context(), auth.Principal{Role: role}))
+		rec := httptest.NewRecorder()
+
+		if assert.NoError(t, h.List(e.NewContext(req, rec))) {
+			assert.Equal(t, status, rec.Code, role)
+		}
+	}
+}
+
+func TestCreateAPIKeyOfOtherTenant(t *testing.T) {
+	e := echo.New()
+	keys := new(MockAPIKeyRepo)
+	h := handler.NewAPIKeyHandler(keys, authz.NewPolicy(authz.DefaultRoles, nil, slog.Default()), slog.Default())
+
+	body := `{"tenant_id":"globex","name":"billing","scopes":["read"]}`
+	req := httptest.NewRequest(http.MethodPost, "/admin/api-keys", bytes.NewBufferString(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req = req.WithContext(auth.WithPrincipal(req.Context(), auth.Principal{TenantID: "acme", Role: auth.RoleAdmin}))
+	rec := httptest.NewRecorder()
+
+	if assert.NoError(t, h.Create(e.NewContext(req, rec))) {
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		keys.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	}
+}
@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/teamcutter/subscriptions-service-task/internal/codec"
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+)
+
+// exportFlushEvery is how many rows are written between flushes to client
+const exportFlushEvery = 100
+
+// Export godoc
+// @Summary Export subscriptions as CSV or NDJSON
+// @Description Rows are streamed from DB, filters are the same as in list.
+// @Tags subscriptions
+// @Produce text/csv
+// @Produce application/x-ndjson
+// @Param format query string true "csv or ndjson"
+// @Param user query string false "User ID"
+// @Param service query string false "Service name"
+// @Success 200
+// @Failure 400
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /v1/subscriptions/export [get]
+func (h *Handler) Export(c echo.Context) error {
+	format := c.QueryParam("format")
+
+	var contentType string
+	switch format {
+	case "csv":
+		contentType = "text/csv"
+	case "ndjson":
+		contentType = "application/x-ndjson"
+	default:
+		err := errors.New("format must be csv or ndjson")
+		h.logger.Error("export error", "error", err)
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	filter, err := parseFilter(c)
+	if err != nil {
+		h.logger.Error("export error", "error", err)
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	r, err := h.access(c, false, filter.UserID)
+	if err != nil {
+		return h.forbidden(c, err)
+	}
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, contentType)
+	res.Header().Set(echo.HeaderContentDisposition,
+		fmt.Sprintf(`attachment; filename="subscriptions-%s.%s"`, time.Now().Format("2006-01-02"), format))
+	res.WriteHeader(http.StatusOK)
+
+	// rows are written by the same encoders as lists
+	var write func(model.Subscription) error
+	var flush func() error
+	if format == "csv" {
+		w := codec.NewCSVWriter(res)
+		if err := w.WriteHeader(model.Subscription{}); err != nil {
+			return err
+		}
+		write = func(sub model.Subscription) error {
+			return w.Write(sub)
+		}
+		flush = w.Flush
+	} else {
+		write = func(sub model.Subscription) error {
+			return codec.JSON{}.Encode(res, sub)
+		}
+		flush = func() error {
+			return nil
+		}
+	}
+
+	rows := 0
+	err = r.Stream(filter, func(sub model.Subscription) error {
+		if err := write(sub); err != nil {
+			return err
+		}
+
+		rows++
+		if rows%exportFlushEvery == 0 {
+			if err := flush(); err != nil {
+				return err
+			}
+			res.Flush()
+		}
+		return nil
+	})
+	if err == nil {
+		err = flush()
+	}
+
+	// headers are already sent, so failure can only be logged
+	if err != nil {
+		h.logger.Error("export error", "error", err, "rows", rows)
+		return nil
+	}
+
+	h.logger.Info("subscriptions exported", "format", format, "rows", rows)
+	return nil
+}
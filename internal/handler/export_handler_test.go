@@ -0,0 +1,73 @@
+package handler_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/teamcutter/subscriptions-service-task/internal/auth"
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+)
+
+func TestExportCSV(t *testing.T) {
+	e, repo, h := setupTest(t)
+
+	filter := model.SubscriptionFilter{UserID: uuid.NullUUID{UUID: mockUUID1, Valid: true}}
+	repo.On("Stream", filter, mock.Anything).Return([]model.Subscription{
+		{UserID: mockUUID1, ServiceName: "Netflix", Price: 400, StartDate: "01-2024"},
+		{UserID: mockUUID1, ServiceName: "Spotify", Price: 200, StartDate: "02-2024", EndDate: "05-2024"},
+	}, nil)
+
+	req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/subscriptions/export?format=csv&user=%s", mockUUID1), nil)
+	req = req.WithContext(auth.WithUserID(req.Context(), mockUUID1))
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	if assert.NoError(t, h.Export(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "text/csv", rec.Header().Get("Content-Type"))
+		assert.Contains(t, rec.Header().Get("Content-Disposition"), "attachment")
+
+		lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+		assert.Len(t, lines, 3)
+		assert.Equal(t, "service_name,price,user_id,start_date,end_date,trial_end_date", lines[0])
+		assert.Equal(t, fmt.Sprintf("Spotify,200,%s,02-2024,05-2024,", mockUUID1), lines[2])
+	}
+}
+
+func TestExportNDJSON(t *testing.T) {
+	e, repo, h := setupTest(t)
+
+	repo.On("Stream", model.SubscriptionFilter{ServiceName: "Netflix"}, mock.Anything).Return([]model.Subscription{
+		{UserID: mockUUID1, ServiceName: "Netflix", Price: 400, StartDate: "01-2024"},
+		{UserID: mockUUID2, ServiceName: "Netflix", Price: 400, StartDate: "03-2024"},
+	}, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/subscriptions/export?format=ndjson&service=Netflix", nil)
+	req = req.WithContext(auth.WithPrincipal(req.Context(), auth.Principal{APIKeyID: 1, Scopes: []string{auth.ScopeRead}}))
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	if assert.NoError(t, h.Export(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Len(t, strings.Split(strings.TrimSpace(rec.Body.String()), "\n"), 2)
+	}
+}
+
+func TestExportUnknownFormat(t *testing.T) {
+	e, _, h := setupTest(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/subscriptions/export?format=xml", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	if assert.NoError(t, h.Export(c)) {
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	}
+}
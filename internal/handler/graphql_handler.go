@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/teamcutter/subscriptions-service-task/internal/auth"
+	"github.com/teamcutter/subscriptions-service-task/internal/authz"
+	"github.com/teamcutter/subscriptions-service-task/internal/gql"
+	"github.com/teamcutter/subscriptions-service-task/internal/repo"
+)
+
+type GraphQLHandler struct {
+	schema *gql.Schema
+	repository repo.Repo
+	users repo.UserRepo
+	policy *authz.Policy
+	logger *slog.Logger
+}
+
+func NewGraphQLHandler(schema *gql.Schema, repository repo.Repo, users repo.UserRepo, policy *authz.Policy, logger *slog.Logger) *GraphQLHandler {
+	return &GraphQLHandler{
+		schema: schema,
+		repository: repository,
+		users: users,
+		policy: policy,
+		logger: logger,
+	}
+}
+
+// Query godoc
+// @Summary GraphQL query
+// @Description Queries subscriptions, users and cost totals in one request. Callers see their own subscriptions unless they may read any. Queries deeper than 7 levels or with complexity over 1000 are rejected with 400, errors of fields are returned in errors with 200.
+// @Tags graphql
+// @Accept json
+// @Produce json
+// @Param request body gql.Request true "GraphQL request"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /v1/graphql [post]
+func (h *GraphQLHandler) Query(c echo.Context) error {
+	var req gql.Request
+	if err := c.Bind(&req); err != nil {
+		h.logger.Error("graphql error", "error", err)
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	r, err := access(c, h.policy, h.repository, false, uuid.NullUUID{})
+	if err != nil {
+		return c.JSON(http.StatusForbidden, err.Error())
+	}
+
+	ctx := c.Request().Context()
+	data := gql.Data{Repo: r, Users: h.users.ForTenant(auth.TenantFromContext(ctx))}
+
+	result, ok := h.schema.Execute(ctx, data, req)
+	if !ok {
+		return c.JSON(http.StatusBadRequest, result)
+	}
+	if result.HasErrors() {
+		h.logger.Warn("graphql errors", "errors", result.Errors)
+	}
+	return c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,68 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/teamcutter/subscriptions-service-task/internal/auth"
+	"github.com/teamcutter/subscriptions-service-task/internal/authz"
+	"github.com/teamcutter/subscriptions-service-task/internal/gql"
+	"github.com/teamcutter/subscriptions-service-task/internal/handler"
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+)
+
+func setupGraphQLTest(t *testing.T) (*echo.Echo, *MockRepo, *handler.GraphQLHandler) {
+	log := slog.Default()
+	policy := authz.NewPolicy(authz.DefaultRoles, nil, log)
+	schema, err := gql.NewSchema(policy)
+	require.NoError(t, err)
+
+	repo := new(MockRepo)
+	return echo.New(), repo, handler.NewGraphQLHandler(schema, repo, fakeUsers{}, policy, log)
+}
+
+func graphQLRequest(query string) *http.Request {
+	body, _ := json.Marshal(gql.Request{Query: query})
+	req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(string(body)))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	return req.WithContext(auth.WithUserID(req.Context(), mockUUID1))
+}
+
+func TestGraphQLScopedToAuthenticatedUser(t *testing.T) {
+	e, repo, h := setupGraphQLTest(t)
+
+	filter := model.SubscriptionFilter{UserID: uuid.NullUUID{UUID: mockUUID1, Valid: true}, Limit: 10}
+	repo.On("GetAll", filter).Return([]model.Subscription{{ID: 1, UserID: mockUUID1, ServiceName: "Netflix"}}, nil)
+
+	rec := httptest.NewRecorder()
+	c := e.NewContext(graphQLRequest(`{ subscriptions(first: 10) { id serviceName } }`), rec)
+
+	if assert.NoError(t, h.Query(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"data":{"subscriptions":[{"id":"1","serviceName":"Netflix"}]}}`, rec.Body.String())
+		repo.AssertCalled(t, "GetAll", filter)
+	}
+}
+
+func TestGraphQLRejectsComplexQuery(t *testing.T) {
+	e, repo, h := setupGraphQLTest(t)
+
+	rec := httptest.NewRecorder()
+	c := e.NewContext(graphQLRequest(`{ subscriptions(first: 1000) { user { subscriptions { id } } } }`), rec)
+
+	if assert.NoError(t, h.Query(c)) {
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "query complexity")
+		repo.AssertNotCalled(t, "GetAll", mock.Anything)
+	}
+}
@@ -0,0 +1,461 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/teamcutter/subscriptions-service-task/internal/auth"
+	"github.com/teamcutter/subscriptions-service-task/internal/authz"
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+	"github.com/teamcutter/subscriptions-service-task/internal/repo"
+	"github.com/teamcutter/subscriptions-service-task/internal/split"
+	"github.com/teamcutter/subscriptions-service-task/internal/utils"
+)
+
+type HouseholdHandler struct {
+	households repo.HouseholdRepo
+	repository repo.Repo
+	policy *authz.Policy
+	logger *slog.Logger
+}
+
+func NewHouseholdHandler(households repo.HouseholdRepo, repository repo.Repo, policy *authz.Policy, logger *slog.Logger) *HouseholdHandler {
+	return &HouseholdHandler{
+		households: households,
+		repository: repository,
+		policy: policy,
+		logger: logger,
+	}
+}
+
+func householdStatus(err error) int {
+	switch {
+	case errors.Is(err, authz.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, repo.ErrHouseholdNotFound), errors.Is(err, repo.ErrMemberNotFound),
+		errors.Is(err, repo.ErrSplitNotFound), errors.Is(err, repo.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, split.ErrInvalidSplit):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func (h *HouseholdHandler) tenantRepo(c echo.Context) repo.HouseholdRepo {
+	return h.households.ForTenant(auth.TenantFromContext(c.Request().Context()))
+}
+
+// load returns household of id param if caller may read it, or change it
+// when write is set. Members read household, its owner changes it
+func (h *HouseholdHandler) load(c echo.Context, write bool) (*model.Household, error) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return nil, repo.ErrHouseholdNotFound
+	}
+
+	household, err := h.tenantRepo(c).Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	userID, ok := auth.UserIDFromContext(c.Request().Context())
+	member := ok && (household.OwnerID == userID || (!write && slices.Contains(household.Members, userID)))
+	owner := uuid.NullUUID{}
+	if !member {
+		// others need permission to access data of any user
+		owner = ownerOf(household.OwnerID)
+	}
+
+	if _, err := authorizeOwner(c, h.policy, write, owner); err != nil {
+		return nil, err
+	}
+	return household, nil
+}
+
+type createHouseholdRequest struct {
+	Name string `json:"name"`
+	// OwnerID defaults to the authenticated user
+	OwnerID uuid.UUID `json:"owner_id"`
+}
+
+// Create godoc
+// @Summary Create household
+// @Description Owner becomes the first member.
+// @Tags households
+// @Accept json
+// @Produce json
+// @Param household body createHouseholdRequest true "Household"
+// @Success 201 {object} model.Household
+// @Failure 400
+// @Failure 403
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /v1/households [post]
+func (h *HouseholdHandler) Create(c echo.Context) error {
+	var req createHouseholdRequest
+	if err := c.Bind(&req); err != nil {
+		h.logger.Error("JSON binding error", "error", err)
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	if userID, ok := auth.UserIDFromContext(c.Request().Context()); ok && req.OwnerID == uuid.Nil {
+		req.OwnerID = userID
+	}
+	if req.Name == "" || req.OwnerID == uuid.Nil {
+		err := errors.New("name and owner_id are required")
+		h.logger.Error("create household error", "error", err)
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	if _, err := authorizeOwner(c, h.policy, true, ownerOf(req.OwnerID)); err != nil {
+		return c.JSON(http.StatusForbidden, err.Error())
+	}
+
+	household := model.Household{Name: req.Name, OwnerID: req.OwnerID}
+	if err := h.tenantRepo(c).Create(&household); err != nil {
+		h.logger.Error("create household error", "error", err)
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+
+	h.logger.Info("household created", "household_id", household.ID, "owner_id", household.OwnerID)
+	return c.JSON(http.StatusCreated, household)
+}
+
+// List godoc
+// @Summary List households of user
+// @Tags households
+// @Produce json
+// @Param user query string false "User ID, defaults to authenticated user"
+// @Success 200 {array} model.Household
+// @Failure 400
+// @Failure 403
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /v1/households [get]
+func (h *HouseholdHandler) List(c echo.Context) error {
+	filter, err := parseFilter(c)
+	if err != nil {
+		h.logger.Error("list households error", "error", err)
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	if userID, ok := auth.UserIDFromContext(c.Request().Context()); ok && !filter.UserID.Valid {
+		filter.UserID = uuid.NullUUID{UUID: userID, Valid: true}
+	}
+	if !filter.UserID.Valid {
+		return c.JSON(http.StatusBadRequest, "missing user")
+	}
+
+	if _, err := authorizeOwner(c, h.policy, false, filter.UserID); err != nil {
+		return c.JSON(http.StatusForbidden, err.Error())
+	}
+
+	households, err := h.tenantRepo(c).ListForUser(filter.UserID.UUID)
+	if err != nil {
+		h.logger.Error("list households error", "error", err)
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, households)
+}
+
+// Get godoc
+// @Summary Get household
+// @Tags households
+// @Produce json
+// @Param id path int true "Household ID"
+// @Success 200 {object} model.Household
+// @Failure 403
+// @Failure 404
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /v1/households/{id} [get]
+func (h *HouseholdHandler) Get(c echo.Context) error {
+	household, err := h.load(c, false)
+	if err != nil {
+		return c.JSON(householdStatus(err), err.Error())
+	}
+	return c.JSON(http.StatusOK, household)
+}
+
+// Delete godoc
+// @Summary Delete household
+// @Description Splits of subscriptions shared in the household are removed too.
+// @Tags households
+// @Param id path int true "Household ID"
+// @Success 204
+// @Failure 403
+// @Failure 404
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /v1/households/{id} [delete]
+func (h *HouseholdHandler) Delete(c echo.Context) error {
+	household, err := h.load(c, true)
+	if err != nil {
+		return c.JSON(householdStatus(err), err.Error())
+	}
+
+	if err := h.tenantRepo(c).Delete(household.ID); err != nil {
+		h.logger.Error("delete household error", "error", err)
+		return c.JSON(householdStatus(err), err.Error())
+	}
+
+	h.logger.Info("household deleted", "household_id", household.ID)
+	return c.NoContent(http.StatusNoContent)
+}
+
+type memberRequest struct {
+	UserID uuid.UUID `json:"user_id"`
+}
+
+// AddMember godoc
+// @Summary Add household member
+// @Tags households
+// @Accept json
+// @Param id path int true "Household ID"
+// @Param member body memberRequest true "Member"
+// @Success 204
+// @Failure 400
+// @Failure 403
+// @Failure 404
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /v1/households/{id}/members [post]
+func (h *HouseholdHandler) AddMember(c echo.Context) error {
+	household, err := h.load(c, true)
+	if err != nil {
+		return c.JSON(householdStatus(err), err.Error())
+	}
+
+	var req memberRequest
+	if err := c.Bind(&req); err != nil {
+		h.logger.Error("JSON binding error", "error", err)
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	if req.UserID == uuid.Nil {
+		return c.JSON(http.StatusBadRequest, "user_id is required")
+	}
+
+	if err := h.tenantRepo(c).AddMember(household.ID, req.UserID); err != nil {
+		h.logger.Error("add household member error", "error", err)
+		return c.JSON(householdStatus(err), err.Error())
+	}
+
+	h.logger.Info("household member added", "household_id", household.ID, "user_id", req.UserID)
+	return c.NoContent(http.StatusNoContent)
+}
+
+// RemoveMember godoc
+// @Summary Remove household member
+// @Description Owner removes members, members may leave themselves. Parts of the member in splits are dropped and paid by payers.
+// @Tags households
+// @Param id path int true "Household ID"
+// @Param user_id path string true "User ID"
+// @Success 204
+// @Failure 400
+// @Failure 403
+// @Failure 404
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /v1/households/{id}/members/{user_id} [delete]
+func (h *HouseholdHandler) RemoveMember(c echo.Context) error {
+	memberID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		h.logger.Error("remove household member error", "error", err)
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	// leaving is reading own membership, removing others is changing household
+	userID, _ := auth.UserIDFromContext(c.Request().Context())
+	household, err := h.load(c, memberID != userID)
+	if err != nil {
+		return c.JSON(householdStatus(err), err.Error())
+	}
+
+	if memberID == household.OwnerID {
+		return c.JSON(http.StatusBadRequest, "owner can not leave household, delete it instead")
+	}
+
+	if err := h.tenantRepo(c).RemoveMember(household.ID, memberID); err != nil {
+		h.logger.Error("remove household member error", "error", err)
+		return c.JSON(householdStatus(err), err.Error())
+	}
+
+	h.logger.Info("household member removed", "household_id", household.ID, "user_id", memberID)
+	return c.NoContent(http.StatusNoContent)
+}
+
+// Settle godoc
+// @Summary Settle up household
+// @Description Shows what members paid for subscriptions shared in the household, what their shares are and transfers evening balances out.
+// @Tags households
+// @Produce json
+// @Param id path int true "Household ID"
+// @Param start query string true "Start date (MM-YYYY)"
+// @Param end query string true "End date (MM-YYYY)"
+// @Success 200 {object} model.Settlement
+// @Failure 400
+// @Failure 403
+// @Failure 404
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /v1/households/{id}/settle [get]
+func (h *HouseholdHandler) Settle(c echo.Context) error {
+	household, err := h.load(c, false)
+	if err != nil {
+		return c.JSON(householdStatus(err), err.Error())
+	}
+
+	start, end := c.QueryParam("start"), c.QueryParam("end")
+	for _, date := range []string{start, end} {
+		if _, err := utils.ParseDateFromRequest(date); err != nil {
+			h.logger.Error("settle household error", "error", err)
+			return c.JSON(http.StatusBadRequest, err.Error())
+		}
+	}
+
+	costs, err := h.tenantRepo(c).Costs(household.ID, start, end)
+	if err != nil {
+		h.logger.Error("settle household error", "error", err)
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+
+	members, transfers := split.Settle(costs)
+	return c.JSON(http.StatusOK, model.Settlement{
+		HouseholdID: household.ID,
+		Start: start,
+		End: end,
+		Members: members,
+		Transfers: transfers,
+	})
+}
+
+// subscription returns subscription of id param the caller may read or change
+func (h *HouseholdHandler) subscription(c echo.Context, write bool) (*model.Subscription, error) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return nil, repo.ErrNotFound
+	}
+
+	r, err := access(c, h.policy, h.repository, write, uuid.NullUUID{})
+	if err != nil {
+		return nil, err
+	}
+	return r.GetByID(id)
+}
+
+// withShares fills monthly shares of split members
+func (h *HouseholdHandler) withShares(c echo.Context, sub *model.Subscription, s *model.Split) error {
+	household, err := h.tenantRepo(c).Get(s.HouseholdID)
+	if err != nil {
+		return err
+	}
+	s.Shares = split.Shares(sub.Price, sub.UserID, s, household.Members)
+	return nil
+}
+
+// SetSplit godoc
+// @Summary Share subscription in household
+// @Description Rule is equal, percentage or fixed. Parts are given to members other than the payer, who covers the rest.
+// @Tags households
+// @Accept json
+// @Produce json
+// @Param id path int true "Subscription ID"
+// @Param split body model.Split true "Split"
+// @Success 200 {object} model.Split
+// @Failure 400
+// @Failure 403
+// @Failure 404
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /v1/subscriptions/{id}/split [put]
+func (h *HouseholdHandler) SetSplit(c echo.Context) error {
+	sub, err := h.subscription(c, true)
+	if err != nil {
+		return c.JSON(householdStatus(err), err.Error())
+	}
+
+	var s model.Split
+	if err := c.Bind(&s); err != nil {
+		h.logger.Error("JSON binding error", "error", err)
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	s.SubscriptionID = sub.ID
+
+	household, err := h.tenantRepo(c).Get(s.HouseholdID)
+	if err != nil {
+		h.logger.Error("set split error", "error", err)
+		return c.JSON(householdStatus(err), err.Error())
+	}
+
+	if err := split.Validate(sub.Price, sub.UserID, &s, household.Members); err != nil {
+		h.logger.Error("set split error", "error", err)
+		return c.JSON(householdStatus(err), err.Error())
+	}
+
+	if err := h.tenantRepo(c).SetSplit(&s); err != nil {
+		h.logger.Error("set split error", "error", err)
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+
+	s.Shares = split.Shares(sub.Price, sub.UserID, &s, household.Members)
+	h.logger.Info("subscription shared", "service_id", sub.ID, "household_id", s.HouseholdID, "rule", s.Rule)
+	return c.JSON(http.StatusOK, s)
+}
+
+// GetSplit godoc
+// @Summary Get split of subscription
+// @Tags households
+// @Produce json
+// @Param id path int true "Subscription ID"
+// @Success 200 {object} model.Split
+// @Failure 403
+// @Failure 404
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /v1/subscriptions/{id}/split [get]
+func (h *HouseholdHandler) GetSplit(c echo.Context) error {
+	sub, err := h.subscription(c, false)
+	if err != nil {
+		return c.JSON(householdStatus(err), err.Error())
+	}
+
+	s, err := h.tenantRepo(c).GetSplit(sub.ID)
+	if err == nil {
+		err = h.withShares(c, sub, s)
+	}
+	if err != nil {
+		h.logger.Error("get split error", "error", err)
+		return c.JSON(householdStatus(err), err.Error())
+	}
+	return c.JSON(http.StatusOK, s)
+}
+
+// DeleteSplit godoc
+// @Summary Stop sharing subscription
+// @Tags households
+// @Param id path int true "Subscription ID"
+// @Success 204
+// @Failure 403
+// @Failure 404
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /v1/subscriptions/{id}/split [delete]
+func (h *HouseholdHandler) DeleteSplit(c echo.Context) error {
+	sub, err := h.subscription(c, true)
+	if err != nil {
+		return c.JSON(householdStatus(err), err.Error())
+	}
+
+	if err := h.tenantRepo(c).DeleteSplit(sub.ID); err != nil {
+		h.logger.Error("delete split error", "error", err)
+		return c.JSON(householdStatus(err), err.Error())
+	}
+
+	h.logger.Info("subscription unshared", "service_id", sub.ID)
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,135 @@
+package handler_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/teamcutter/subscriptions-service-task/internal/auth"
+	"github.com/teamcutter/subscriptions-service-task/internal/authz"
+	"github.com/teamcutter/subscriptions-service-task/internal/handler"
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+	repoPkg "github.com/teamcutter/subscriptions-service-task/internal/repo"
+)
+
+type MockHouseholdRepo struct {
+	mock.Mock
+}
+
+func (m *MockHouseholdRepo) Create(household *model.Household) error {
+	args := m.Called(household)
+	return args.Error(0)
+}
+
+func (m *MockHouseholdRepo) Get(id int64) (*model.Household, error) {
+	args := m.Called(id)
+	household, _ := args.Get(0).(*model.Household)
+	return household, args.Error(1)
+}
+
+func (m *MockHouseholdRepo) ListForUser(userID uuid.UUID) ([]model.Household, error) {
+	args := m.Called(userID)
+	return args.Get(0).([]model.Household), args.Error(1)
+}
+
+func (m *MockHouseholdRepo) Delete(id int64) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockHouseholdRepo) AddMember(id int64, userID uuid.UUID) error {
+	args := m.Called(id, userID)
+	return args.Error(0)
+}
+
+func (m *MockHouseholdRepo) RemoveMember(id int64, userID uuid.UUID) error {
+	args := m.Called(id, userID)
+	return args.Error(0)
+}
+
+func (m *MockHouseholdRepo) SetSplit(split *model.Split) error {
+	args := m.Called(split)
+	return args.Error(0)
+}
+
+func (m *MockHouseholdRepo) GetSplit(subscriptionID int64) (*model.Split, error) {
+	args := m.Called(subscriptionID)
+	split, _ := args.Get(0).(*model.Split)
+	return split, args.Error(1)
+}
+
+func (m *MockHouseholdRepo) DeleteSplit(subscriptionID int64) error {
+	args := m.Called(subscriptionID)
+	return args.Error(0)
+}
+
+func (m *MockHouseholdRepo) Costs(id int64, start, end string) ([]model.Cost, error) {
+	args := m.Called(id, start, end)
+	return args.Get(0).([]model.Cost), args.Error(1)
+}
+
+func (m *MockHouseholdRepo) ForTenant(string) repoPkg.HouseholdRepo {
+	return m
+}
+
+func setupHouseholdTest() (*echo.Echo, *MockHouseholdRepo, *MockRepo, *handler.HouseholdHandler) {
+	households := new(MockHouseholdRepo)
+	subs := new(MockRepo)
+	log := slog.Default()
+	h := handler.NewHouseholdHandler(households, subs, authz.NewPolicy(authz.DefaultRoles, nil, log), log)
+	return echo.New(), households, subs, h
+}
+
+func TestSettle(t *testing.T) {
+	e, households, _, h := setupHouseholdTest()
+
+	household := &model.Household{ID: 1, OwnerID: mockUUID1, Members: []uuid.UUID{mockUUID1, mockUUID2}}
+	households.On("Get", int64(1)).Return(household, nil)
+	households.On("Costs", int64(1), "01-2025", "02-2025").Return([]model.Cost{
+		{Payer: mockUUID1, Price: 500, Months: 2, Split: &model.Split{Rule: model.SplitEqual}, Members: household.Members},
+	}, nil)
+
+	for userID, status := range map[uuid.UUID]int{mockUUID2: http.StatusOK, uuid.New(): http.StatusForbidden} {
+		req := httptest.NewRequest(http.MethodGet, "/households/1/settle?start=01-2025&end=02-2025", nil)
+		req = req.WithContext(auth.WithUserID(req.Context(), userID))
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("1")
+
+		if assert.NoError(t, h.Settle(c)) && assert.Equal(t, status, rec.Code) && status == http.StatusOK {
+			var settlement model.Settlement
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &settlement))
+			assert.Equal(t, []model.Transfer{{From: mockUUID2, To: mockUUID1, Amount: 500}}, settlement.Transfers)
+		}
+	}
+}
+
+func TestSetSplitPayerNotMember(t *testing.T) {
+	e, households, subs, h := setupHouseholdTest()
+
+	subs.On("GetByID", 3).Return(&model.Subscription{ID: 3, UserID: mockUUID1, Price: 400}, nil)
+	households.On("Get", int64(1)).Return(&model.Household{ID: 1, OwnerID: mockUUID2, Members: []uuid.UUID{mockUUID2}}, nil)
+
+	body := `{"household_id":1,"rule":"equal"}`
+	req := httptest.NewRequest(http.MethodPut, "/subscriptions/3/split", bytes.NewBufferString(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req = req.WithContext(auth.WithUserID(req.Context(), mockUUID1))
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("3")
+
+	if assert.NoError(t, h.SetSplit(c)) {
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		households.AssertNotCalled(t, "SetSplit", mock.Anything)
+	}
+}
@@ -0,0 +1,231 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/teamcutter/subscriptions-service-task/internal/auth"
+	"github.com/teamcutter/subscriptions-service-task/internal/authz"
+	"github.com/teamcutter/subscriptions-service-task/internal/importer"
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+	"github.com/teamcutter/subscriptions-service-task/internal/repo"
+)
+
+// asyncImportThreshold is the body size from which import runs as a job
+const asyncImportThreshold = 1 << 20
+
+type ImportHandler struct {
+	repository repo.Repo
+	jobs repo.ImportJobRepo
+	policy *authz.Policy
+	logger *slog.Logger
+}
+
+func NewImportHandler(repository repo.Repo, jobs repo.ImportJobRepo, policy *authz.Policy, logger *slog.Logger) *ImportHandler {
+	return &ImportHandler{
+		repository: repository,
+		jobs: jobs,
+		policy: policy,
+		logger: logger,
+	}
+}
+
+// importFormat resolves format from query param or Content-Type
+func importFormat(c echo.Context) string {
+	if format := c.QueryParam("format"); format != "" {
+		return format
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(c.Request().Header.Get(echo.HeaderContentType))
+	switch mediaType {
+	case "text/csv":
+		return importer.FormatCSV
+	case "application/x-ndjson", "application/jsonl":
+		return importer.FormatNDJSON
+	}
+	return ""
+}
+
+func newImportReader(format string, body io.Reader, mapping map[string]string) (importer.Reader, error) {
+	if format == importer.FormatCSV {
+		return importer.NewCSVReader(body, mapping)
+	}
+	return importer.NewNDJSONReader(body), nil
+}
+
+// Import godoc
+// @Summary Import subscriptions from CSV or NDJSON
+// @Description Rows are validated like in create, invalid rows are reported with their line numbers.
+// @Description Bodies larger than 1 MiB or async=true run as a job tracked at /subscriptions/import/{id}.
+// @Tags subscriptions
+// @Accept text/csv
+// @Accept application/x-ndjson
+// @Produce json
+// @Param format query string false "csv or ndjson, defaults to Content-Type"
+// @Param columns query string false "CSV column mapping, e.g. service_name=Service,price=Cost"
+// @Param dry_run query bool false "Only validate rows"
+// @Param async query bool false "Run import as a job"
+// @Success 200 {object} model.ImportReport
+// @Success 202 {object} model.ImportJob
+// @Failure 400
+// @Failure 415
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /v1/subscriptions/import [post]
+func (h *ImportHandler) Import(c echo.Context) error {
+	format := importFormat(c)
+	if format != importer.FormatCSV && format != importer.FormatNDJSON {
+		err := errors.New("unsupported import format, use csv or ndjson")
+		h.logger.Error("import error", "error", err)
+		return c.JSON(http.StatusUnsupportedMediaType, err.Error())
+	}
+
+	mapping, err := importer.ParseMapping(c.QueryParam("columns"))
+	if err != nil {
+		h.logger.Error("import error", "error", err)
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	dryRun := c.QueryParam("dry_run") == "true"
+	store, err := access(c, h.policy, h.repository, true, uuid.NullUUID{})
+	if err != nil {
+		return c.JSON(http.StatusForbidden, err.Error())
+	}
+
+	if c.QueryParam("async") == "true" || c.Request().ContentLength > asyncImportThreshold {
+		return h.startJob(c, store, format, mapping, dryRun)
+	}
+
+	reader, err := newImportReader(format, c.Request().Body, mapping)
+	if err != nil {
+		h.logger.Error("import error", "error", err)
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	report, err := importer.Import(reader, store, dryRun)
+	if err != nil {
+		h.logger.Error("import error", "error", err)
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+
+	h.logger.Info("subscriptions imported",
+		"format", format,
+		"dry_run", dryRun,
+		"total", report.TotalRows,
+		"imported", report.Imported,
+	)
+	return c.JSON(http.StatusOK, report)
+}
+
+// startJob spools body to a temporary file and imports it in background
+func (h *ImportHandler) startJob(c echo.Context, store importer.Store, format string, mapping map[string]string, dryRun bool) error {
+	file, err := os.CreateTemp("", "subscriptions-import-*")
+	if err != nil {
+		h.logger.Error("import error", "error", err)
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+
+	if _, err := io.Copy(file, c.Request().Body); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		h.logger.Error("import error", "error", err)
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	job := &model.ImportJob{
+		TenantID: auth.TenantFromContext(c.Request().Context()),
+		Format: format,
+	}
+	if userID, ok := auth.UserIDFromContext(c.Request().Context()); ok {
+		job.UserID = uuid.NullUUID{UUID: userID, Valid: true}
+	}
+	if err := h.jobs.Create(job); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		h.logger.Error("import error", "error", err)
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+
+	go h.runJob(job, store, file, mapping, dryRun)
+
+	h.logger.Info("import job started", "job_id", job.ID, "format", format)
+	c.Response().Header().Set(echo.HeaderLocation, fmt.Sprintf("%s/%s", strings.TrimSuffix(c.Request().URL.Path, "/"), job.ID))
+	return c.JSON(http.StatusAccepted, job)
+}
+
+func (h *ImportHandler) runJob(job *model.ImportJob, store importer.Store, file *os.File, mapping map[string]string, dryRun bool) {
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	report, err := func() (model.ImportReport, error) {
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return model.ImportReport{}, err
+		}
+		reader, err := newImportReader(job.Format, file, mapping)
+		if err != nil {
+			return model.ImportReport{}, err
+		}
+		return importer.Import(reader, store, dryRun)
+	}()
+
+	job.Report = report
+	if err != nil {
+		job.Error = err.Error()
+	}
+
+	if err := h.jobs.Finish(job); err != nil {
+		h.logger.Error("import job error", "job_id", job.ID, "error", err)
+		return
+	}
+	h.logger.Info("import job finished",
+		"job_id", job.ID,
+		"status", job.Status,
+		"total", report.TotalRows,
+		"imported", report.Imported,
+	)
+}
+
+// GetImportJob godoc
+// @Summary Get import job status and report
+// @Tags subscriptions
+// @Produce json
+// @Param id path string true "Job ID"
+// @Success 200 {object} model.ImportJob
+// @Failure 404
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /v1/subscriptions/import/{id} [get]
+func (h *ImportHandler) GetImportJob(c echo.Context) error {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		h.logger.Error("get import job error", "error", err)
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	job, err := h.jobs.Get(id)
+	if err == nil && job.TenantID != auth.TenantFromContext(c.Request().Context()) {
+		err = repo.ErrImportJobNotFound
+	}
+	if err == nil && job.UserID.Valid {
+		// jobs of other users are hidden
+		if userID, ok := auth.UserIDFromContext(c.Request().Context()); ok && userID != job.UserID.UUID {
+			err = repo.ErrImportJobNotFound
+		}
+	}
+	if errors.Is(err, repo.ErrImportJobNotFound) {
+		return c.JSON(http.StatusNotFound, err.Error())
+	}
+	if err != nil {
+		h.logger.Error("get import job error", "error", err)
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, job)
+}
\ No newline at end of file
@@ -0,0 +1,80 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/teamcutter/subscriptions-service-task/internal/authz"
+	"github.com/teamcutter/subscriptions-service-task/internal/handler"
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+)
+
+type MockImportJobRepo struct {
+	mock.Mock
+}
+
+func (m *MockImportJobRepo) Create(job *model.ImportJob) error {
+	args := m.Called(job)
+	return args.Error(0)
+}
+
+func (m *MockImportJobRepo) Finish(job *model.ImportJob) error {
+	args := m.Called(job)
+	return args.Error(0)
+}
+
+func (m *MockImportJobRepo) Get(id uuid.UUID) (*model.ImportJob, error) {
+	args := m.Called(id)
+	job, _ := args.Get(0).(*model.ImportJob)
+	return job, args.Error(1)
+}
+
+func TestImportCSV(t *testing.T) {
+	e := echo.New()
+	repo := new(MockRepo)
+	h := handler.NewImportHandler(repo, nil, authz.NewPolicy(authz.DefaultRoles, nil, slog.Default()), slog.Default())
+
+	repo.On("Batch", mock.AnythingOfType("[]model.BatchOperation"), false).Return([]model.BatchResult{{Index: 0, ID: 1}}, nil)
+
+	body := "service_name,price,user_id,start_date,end_date\n" +
+		"Netflix,400," + mockUUID1.String() + ",01-2024,12-2024\n" +
+		"Spotify,200," + mockUUID1.String() + ",13-2024,\n"
+	req := systemRequest(http.MethodPost, "/subscriptions/import", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, "text/csv")
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	if assert.NoError(t, h.Import(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var report model.ImportReport
+		if assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report)) {
+			assert.Equal(t, 2, report.TotalRows)
+			assert.Equal(t, 1, report.Imported)
+			assert.Equal(t, 3, report.Errors[0].Line)
+		}
+	}
+}
+
+func TestImportUnsupportedFormat(t *testing.T) {
+	e := echo.New()
+	h := handler.NewImportHandler(new(MockRepo), nil, authz.NewPolicy(authz.DefaultRoles, nil, slog.Default()), slog.Default())
+
+	req := httptest.NewRequest(http.MethodPost, "/subscriptions/import", strings.NewReader("<xml/>"))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationXML)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	if assert.NoError(t, h.Import(c)) {
+		assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+	}
+}
@@ -0,0 +1,355 @@
+package handler_test
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/teamcutter/subscriptions-service-task/docs"
+	"github.com/teamcutter/subscriptions-service-task/internal/auth"
+	"github.com/teamcutter/subscriptions-service-task/internal/authz"
+	"github.com/teamcutter/subscriptions-service-task/internal/codec"
+	"github.com/teamcutter/subscriptions-service-task/internal/gql"
+	"github.com/teamcutter/subscriptions-service-task/internal/handler"
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+	"github.com/teamcutter/subscriptions-service-task/internal/openapi"
+	repoPkg "github.com/teamcutter/subscriptions-service-task/internal/repo"
+	"github.com/teamcutter/subscriptions-service-task/internal/router"
+	"github.com/teamcutter/subscriptions-service-task/internal/stream"
+	"github.com/teamcutter/subscriptions-service-task/internal/webhook"
+)
+
+// bodyRecorder keeps a copy of the response body for validation
+type bodyRecorder struct {
+	http.ResponseWriter
+	body *bytes.Buffer
+}
+
+func (r bodyRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+// conform fails the test when a request or a response does not conform
+// to the spec, so that handlers and their annotations do not drift apart
+func conform(t *testing.T, validator *openapi.Validator) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			if err := validator.ValidateRequest(req, ""); err != nil {
+				t.Errorf("%s %s: request does not conform to spec: %v", req.Method, req.URL, err)
+			}
+
+			body := new(bytes.Buffer)
+			c.Response().Writer = bodyRecorder{c.Response().Writer, body}
+			err := next(c)
+
+			res := c.Response()
+			if err := validator.ValidateResponse(req, "", res.Status, res.Header(), body.Bytes()); err != nil {
+				t.Errorf("%s %s: response %d does not conform to spec: %v", req.Method, req.URL, res.Status, err)
+			}
+			return err
+		}
+	}
+}
+
+// contract holds the mocks behind the routes of contract tests
+type contract struct {
+	subs *MockRepo
+	households *MockHouseholdRepo
+	jobs *MockImportJobRepo
+	data *MockUserDataRepo
+	webhooks *MockWebhookRepo
+	keys *MockAPIKeyRepo
+	tenants *MockTenantRepo
+}
+
+// setupContractTest mounts every API route the way the service does,
+// without authentication, callers are set on request context
+func setupContractTest(t *testing.T) (*echo.Echo, contract) {
+	validator, err := openapi.NewValidator(docs.OpenAPI)
+	require.NoError(t, err)
+
+	log := slog.Default()
+	policy := authz.NewPolicy(authz.DefaultRoles, nil, log)
+	users := fakeUsers{mockUUID1: {ID: mockUUID1, Currency: "EUR", Timezone: "UTC"}}
+	m := contract{
+		subs: new(MockRepo),
+		households: new(MockHouseholdRepo),
+		jobs: new(MockImportJobRepo),
+		data: new(MockUserDataRepo),
+		webhooks: new(MockWebhookRepo),
+		keys: new(MockAPIKeyRepo),
+		tenants: new(MockTenantRepo),
+	}
+	schema, err := gql.NewSchema(policy)
+	require.NoError(t, err)
+
+	e := echo.New()
+	e.Use(conform(t, validator))
+	router.Mount(e, router.Handlers{
+		Subscriptions: handler.NewHandler(m.subs, users, codec.Default(), policy, log),
+		SubscriptionsV2: handler.NewHandlerV2(m.subs, users, policy, log),
+		Import: handler.NewImportHandler(m.subs, m.jobs, policy, log),
+		Stream: handler.NewStreamHandler(stream.NewHub(10), policy, log),
+		Households: handler.NewHouseholdHandler(m.households, m.subs, policy, log),
+		Users: newUserHandler(users, m.data),
+		GraphQL: handler.NewGraphQLHandler(schema, m.subs, users, policy, log),
+		Webhooks: handler.NewWebhookHandler(m.webhooks, webhook.Targets{}, policy, log),
+		APIKeys: handler.NewAPIKeyHandler(m.keys, policy, log),
+		Tenants: handler.NewTenantHandler(m.tenants, policy, log),
+	}, router.Middlewares{})
+	return e, m
+}
+
+func TestHandlersConformToSpec(t *testing.T) {
+	e, m := setupContractTest(t)
+	subs, households := m.subs, m.households
+
+	sub := &model.Subscription{ID: 1, ServiceName: "Netflix", Price: 400, UserID: mockUUID1, StartDate: "01-2025", Version: 1}
+	other := &model.Subscription{ID: 3, ServiceName: "Spotify", Price: 200, UserID: mockUUID2, StartDate: "01-2025", Version: 1}
+	subs.On("Create", mock.Anything).Return(nil)
+	subs.On("GetAll", mock.Anything).Return([]model.Subscription{*sub}, nil)
+	subs.On("GetByID", 1).Return(sub, nil)
+	subs.On("GetByID", 2).Return(nil, repoPkg.ErrNotFound)
+	subs.On("GetByID", 3).Return(other, nil)
+	subs.On("Update", mock.Anything, 0).Return(nil)
+	subs.On("Update", mock.Anything, 5).Return(repoPkg.ErrVersionMismatch)
+	subs.On("Delete", 1, 0).Return(nil)
+	subs.On("TotalCost", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(400, nil)
+	subs.On("Batch", mock.Anything, false).Return([]model.BatchResult{{Index: 0, ID: 10, Version: 1}}, nil)
+
+	households.On("Get", int64(1)).Return(&model.Household{ID: 1, OwnerID: mockUUID1, Members: []uuid.UUID{mockUUID1}}, nil)
+	households.On("Get", int64(2)).Return(nil, repoPkg.ErrHouseholdNotFound)
+	households.On("Costs", int64(1), "01-2025", "02-2025").Return([]model.Cost{}, nil)
+	households.On("GetSplit", int64(1)).Return(nil, repoPkg.ErrSplitNotFound)
+
+	subscription := `{"service_name":"Netflix","price":400,"user_id":"` + mockUUID1.String() + `","start_date":"01-2025"}`
+	subscriptionV2 := `{"service_name":"Netflix","price":{"amount":400,"currency":"EUR"},"user_id":"` + mockUUID1.String() + `","start_date":"2025-01-01"}`
+
+	tests := []struct {
+		method string
+		target string
+		body string
+		ifMatch string
+		status int
+	}{
+		{http.MethodPost, "/v1/subscriptions", subscription, "", http.StatusCreated},
+		{http.MethodGet, "/v1/subscriptions", "", "", http.StatusOK},
+		{http.MethodGet, "/v1/subscriptions?user=" + mockUUID2.String(), "", "", http.StatusForbidden},
+		{http.MethodGet, "/v1/subscriptions/1", "", "", http.StatusOK},
+		{http.MethodGet, "/v1/subscriptions/2", "", "", http.StatusNotFound},
+		{http.MethodGet, "/v1/subscriptions/3", "", "", http.StatusNotFound},
+		{http.MethodPut, "/v1/subscriptions/1", subscription, "", http.StatusOK},
+		{http.MethodPut, "/v1/subscriptions/1", subscription, `"5"`, http.StatusPreconditionFailed},
+		{http.MethodDelete, "/v1/subscriptions/1", "", "", http.StatusNoContent},
+		{http.MethodGet, "/v1/subscriptions/total?start=01-2025", "", "", http.StatusOK},
+		{http.MethodGet, "/v1/subscriptions/total?start=2025-01", "", "", http.StatusBadRequest},
+		{http.MethodGet, "/v1/subscriptions/total?user=" + mockUUID2.String(), "", "", http.StatusForbidden},
+		{http.MethodPost, "/v1/subscriptions/batch", `{"operations":[{"op":"create","subscription":` + subscription + `}]}`, "", http.StatusOK},
+		{http.MethodGet, "/v1/subscriptions/1/split", "", "", http.StatusNotFound},
+		{http.MethodGet, "/v1/households/1", "", "", http.StatusOK},
+		{http.MethodGet, "/v1/households/2", "", "", http.StatusNotFound},
+		{http.MethodGet, "/v1/households/1/settle?start=01-2025&end=02-2025", "", "", http.StatusOK},
+		{http.MethodGet, "/v1/users/me", "", "", http.StatusOK},
+		{http.MethodGet, "/v1/users/" + mockUUID2.String(), "", "", http.StatusForbidden},
+		{http.MethodPut, "/v1/users/me", `{"email":"user@example.com","currency":"EUR","timezone":"Europe/Berlin"}`, "", http.StatusOK},
+
+		{http.MethodPost, "/v2/subscriptions", subscriptionV2, "", http.StatusCreated},
+		{http.MethodPost, "/v2/subscriptions", strings.Replace(subscriptionV2, "EUR", "USD", 1), "", http.StatusBadRequest},
+		{http.MethodGet, "/v2/subscriptions?limit=1", "", "", http.StatusOK},
+		{http.MethodGet, "/v2/subscriptions/1", "", "", http.StatusOK},
+		{http.MethodGet, "/v2/subscriptions/2", "", "", http.StatusNotFound},
+		{http.MethodPut, "/v2/subscriptions/1", subscriptionV2, "", http.StatusOK},
+		{http.MethodDelete, "/v2/subscriptions/1", "", "", http.StatusNoContent},
+		{http.MethodGet, "/v2/subscriptions/total?start=2025-01-01&end=2025-03-31", "", "", http.StatusOK},
+		{http.MethodGet, "/v2/subscriptions/total?start=01-2025", "", "", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+		if tt.body != "" {
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		}
+		if tt.ifMatch != "" {
+			req.Header.Set("If-Match", tt.ifMatch)
+		}
+		req = req.WithContext(auth.WithUserID(req.Context(), mockUUID1))
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+		assert.Equal(t, tt.status, rec.Code, "%s %s: %s", tt.method, tt.target, rec.Body)
+	}
+}
+
+func TestNegotiatedResponsesConformToSpec(t *testing.T) {
+	e, m := setupContractTest(t)
+	subs := m.subs
+
+	subs.On("GetAll", mock.Anything).Return([]model.Subscription{{ID: 1, ServiceName: "Netflix", Price: 400, UserID: mockUUID1, StartDate: "01-2025"}}, nil)
+	subs.On("TotalCost", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(400, nil)
+	subs.On("GetByID", 1).Return(&model.Subscription{ID: 1, ServiceName: "Netflix", Price: 400, UserID: mockUUID1, StartDate: "01-2025", Version: 1}, nil)
+	subs.On("GetByID", 2).Return(nil, repoPkg.ErrNotFound)
+	subs.On("Update", mock.Anything, 0).Return(nil)
+	subs.On("Batch", mock.Anything, false).Return([]model.BatchResult{{Index: 0, ID: 10, Version: 1}}, nil)
+	subs.On("Create", mock.Anything).Return(nil)
+	subs.On("Delete", 1, 0).Return(nil)
+	subs.On("GetByID", 3).Return(&model.Subscription{ID: 3, ServiceName: "Spotify", Price: 200, UserID: mockUUID1, StartDate: "01-2025", Version: 2}, nil)
+	subs.On("Delete", 3, 0).Return(repoPkg.ErrVersionMismatch)
+
+	subscription := `{"service_name":"Netflix","price":400,"user_id":"` + mockUUID1.String() + `","start_date":"01-2025"}`
+	requests := []struct {
+		method string
+		target string
+		body string
+		status int
+	}{
+		{http.MethodGet, "/v1/subscriptions", "", http.StatusOK},
+		{http.MethodGet, "/v1/subscriptions/total", "", http.StatusOK},
+		{http.MethodGet, "/v1/subscriptions/1", "", http.StatusOK},
+		{http.MethodGet, "/v1/subscriptions/2", "", http.StatusNotFound},
+		{http.MethodPut, "/v1/subscriptions/1", subscription, http.StatusOK},
+		{http.MethodPost, "/v1/subscriptions/batch", `{"operations":[{"op":"create","subscription":` + subscription + `}]}`, http.StatusOK},
+		{http.MethodPost, "/v1/subscriptions", subscription, http.StatusCreated},
+		{http.MethodPost, "/v1/subscriptions", strings.Replace(subscription, mockUUID1.String(), mockUUID2.String(), 1), http.StatusForbidden},
+		{http.MethodDelete, "/v1/subscriptions/1", "", http.StatusNoContent},
+		{http.MethodDelete, "/v1/subscriptions/3", "", http.StatusPreconditionFailed},
+	}
+	for _, tt := range requests {
+		for _, accept := range []string{"application/json", "text/csv", "application/xml", "application/msgpack", "image/png"} {
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			if tt.body != "" {
+				req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			}
+			req.Header.Set(echo.HeaderAccept, accept)
+			req = req.WithContext(auth.WithUserID(req.Context(), mockUUID1))
+			rec := httptest.NewRecorder()
+
+			e.ServeHTTP(rec, req)
+			status := tt.status
+			if accept == "image/png" {
+				status = http.StatusNotAcceptable
+			}
+			if assert.Equal(t, status, rec.Code, "%s %s %s", tt.method, tt.target, accept) && rec.Body.Len() > 0 {
+				contentType := rec.Header().Get(echo.HeaderContentType)
+				switch {
+				case status == http.StatusNotAcceptable:
+					assert.Equal(t, echo.MIMEApplicationJSON, contentType, "%s %s", tt.method, tt.target)
+				case status >= 300 && accept == "text/csv":
+					assert.Equal(t, echo.MIMETextPlainCharsetUTF8, contentType, "%s %s", tt.method, tt.target)
+				default:
+					assert.Equal(t, accept, contentType, "%s %s %s", tt.method, tt.target, accept)
+				}
+			}
+		}
+	}
+}
+
+func TestOtherRoutesConformToSpec(t *testing.T) {
+	e, m := setupContractTest(t)
+
+	sub := model.Subscription{ID: 1, ServiceName: "Netflix", Price: 400, UserID: mockUUID1, StartDate: "01-2025", Version: 1}
+	jobID := uuid.New()
+	household := &model.Household{ID: 1, Name: "Flat", OwnerID: mockUUID1, Members: []uuid.UUID{mockUUID1}}
+	delivery := &model.WebhookDelivery{ID: 2, WebhookID: 1, EventID: uuid.New(), EventType: model.EventSubscriptionCreated,
+		Payload: []byte(`{}`), Status: model.DeliveryPending, NextAttemptAt: time.Now(), CreatedAt: time.Now()}
+
+	m.subs.On("Stream", mock.Anything, mock.Anything).Return([]model.Subscription{sub}, nil)
+	m.subs.On("GetAll", mock.Anything).Return([]model.Subscription{sub}, nil)
+	m.subs.On("GetByID", 1).Return(&sub, nil)
+	m.subs.On("Batch", mock.Anything, false).Return([]model.BatchResult{{Index: 0, ID: 10, Version: 1}}, nil)
+	m.jobs.On("Get", jobID).Return(&model.ImportJob{ID: jobID, TenantID: model.DefaultTenant, Status: model.ImportJobDone, Format: "csv", CreatedAt: time.Now()}, nil)
+	m.jobs.On("Get", mock.Anything).Return(nil, repoPkg.ErrImportJobNotFound)
+
+	m.households.On("Create", mock.Anything).Run(func(args mock.Arguments) {
+		created := args.Get(0).(*model.Household)
+		created.ID, created.Members = 2, []uuid.UUID{created.OwnerID}
+	}).Return(nil)
+	m.households.On("ListForUser", mockUUID1).Return([]model.Household{*household}, nil)
+	m.households.On("Get", int64(1)).Return(household, nil)
+	m.households.On("Delete", int64(1)).Return(nil)
+	m.households.On("AddMember", int64(1), mockUUID2).Return(nil)
+	m.households.On("RemoveMember", int64(1), mockUUID2).Return(nil)
+	m.households.On("SetSplit", mock.Anything).Return(nil)
+	m.households.On("DeleteSplit", int64(1)).Return(nil)
+
+	m.data.On("Export", mockUUID1).Return(&model.UserData{Profile: &model.User{ID: mockUUID1}}, nil)
+	m.data.On("Erase", mockUUID1, mock.Anything).Return(map[string]int64{"users": 1}, nil)
+
+	m.webhooks.On("Create", mock.Anything).Return(nil)
+	m.webhooks.On("List").Return([]model.Webhook{{ID: 1, URL: "https://203.0.113.10/hook", Events: []string{}, CreatedAt: time.Now()}}, nil)
+	m.webhooks.On("Delete", int64(1)).Return(nil)
+	m.webhooks.On("Deliveries", int64(1), mock.Anything).Return([]model.WebhookDelivery{*delivery}, nil)
+	m.webhooks.On("Redeliver", int64(1), int64(2)).Return(delivery, nil)
+
+	m.keys.On("Create", mock.Anything, mock.Anything).Return(nil)
+	m.keys.On("List", mock.Anything).Return([]model.APIKey{{ID: 1, Name: "billing", Prefix: "sk_abcdefgh", Scopes: []string{auth.ScopeRead}, CreatedAt: time.Now()}}, nil)
+	m.keys.On("Rotate", mock.Anything, int64(1), mock.Anything, mock.Anything).Return(&model.APIKey{ID: 1, Name: "billing", Scopes: []string{auth.ScopeRead}, CreatedAt: time.Now()}, nil)
+	m.keys.On("Revoke", mock.Anything, int64(1)).Return(nil)
+	m.tenants.On("Create", mock.Anything).Return(nil)
+	m.tenants.On("List").Return([]model.Tenant{{ID: "acme", Name: "Acme", CreatedAt: time.Now()}}, nil)
+	m.tenants.On("Delete", "acme").Return(nil)
+
+	csv := "service_name,price,user_id,start_date\nNetflix,400," + mockUUID1.String() + ",01-2025\n"
+	tests := []struct {
+		method string
+		target string
+		contentType string
+		body string
+		admin bool
+		status int
+	}{
+		{http.MethodGet, "/v1/subscriptions/export?format=csv", "", "", false, http.StatusOK},
+		{http.MethodPost, "/v1/subscriptions/import", "text/csv", csv, false, http.StatusOK},
+		{http.MethodGet, "/v1/subscriptions/import/" + jobID.String(), "", "", false, http.StatusOK},
+		{http.MethodGet, "/v1/subscriptions/import/" + uuid.NewString(), "", "", false, http.StatusNotFound},
+		{http.MethodPut, "/v1/subscriptions/1/split", echo.MIMEApplicationJSON, `{"household_id":1,"rule":"equal"}`, false, http.StatusOK},
+		{http.MethodDelete, "/v1/subscriptions/1/split", "", "", false, http.StatusNoContent},
+		{http.MethodPost, "/v1/households", echo.MIMEApplicationJSON, `{"name":"Flat"}`, false, http.StatusCreated},
+		{http.MethodGet, "/v1/households", "", "", false, http.StatusOK},
+		{http.MethodPost, "/v1/households/1/members", echo.MIMEApplicationJSON, `{"user_id":"` + mockUUID2.String() + `"}`, false, http.StatusNoContent},
+		{http.MethodDelete, "/v1/households/1/members/" + mockUUID2.String(), "", "", false, http.StatusNoContent},
+		{http.MethodDelete, "/v1/households/1", "", "", false, http.StatusNoContent},
+		{http.MethodGet, "/v1/users/me/export", "", "", false, http.StatusOK},
+		{http.MethodDelete, "/v1/users/me", "", "", false, http.StatusOK},
+		{http.MethodPost, "/v1/graphql", echo.MIMEApplicationJSON, `{"query":"{ subscriptions { id } }"}`, false, http.StatusOK},
+
+		{http.MethodPost, "/v1/webhooks", echo.MIMEApplicationJSON, `{"url":"https://203.0.113.10/hook","events":["subscription.created"]}`, true, http.StatusCreated},
+		{http.MethodGet, "/v1/webhooks", "", "", true, http.StatusOK},
+		{http.MethodGet, "/v1/webhooks/1/deliveries", "", "", true, http.StatusOK},
+		{http.MethodPost, "/v1/webhooks/1/deliveries/2/redeliver", "", "", true, http.StatusAccepted},
+		{http.MethodDelete, "/v1/webhooks/1", "", "", true, http.StatusNoContent},
+		{http.MethodPost, "/v1/admin/api-keys", echo.MIMEApplicationJSON, `{"name":"billing","scopes":["read"]}`, true, http.StatusCreated},
+		{http.MethodGet, "/v1/admin/api-keys", "", "", true, http.StatusOK},
+		{http.MethodPost, "/v1/admin/api-keys/1/rotate", "", "", true, http.StatusOK},
+		{http.MethodDelete, "/v1/admin/api-keys/1", "", "", true, http.StatusNoContent},
+		{http.MethodPost, "/v1/admin/tenants", echo.MIMEApplicationJSON, `{"id":"acme","name":"Acme"}`, true, http.StatusCreated},
+		{http.MethodGet, "/v1/admin/tenants", "", "", true, http.StatusOK},
+		{http.MethodDelete, "/v1/admin/tenants/acme", "", "", true, http.StatusNoContent},
+		{http.MethodGet, "/v1/admin/tenants", "", "", false, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+		if tt.contentType != "" {
+			req.Header.Set(echo.HeaderContentType, tt.contentType)
+		}
+		if tt.admin {
+			req = req.WithContext(auth.WithPrincipal(req.Context(), auth.Principal{Role: auth.RoleAdmin}))
+		} else {
+			req = req.WithContext(auth.WithUserID(req.Context(), mockUUID1))
+		}
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+		assert.Equal(t, tt.status, rec.Code, "%s %s: %s", tt.method, tt.target, rec.Body)
+	}
+}
@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/teamcutter/subscriptions-service-task/internal/auth"
+	"github.com/teamcutter/subscriptions-service-task/internal/authz"
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+	"github.com/teamcutter/subscriptions-service-task/internal/stream"
+)
+
+const (
+	heartbeatInterval = 15 * time.Second
+	// reconnectDelay is sent to clients as the SSE retry field
+	reconnectDelay = 3 * time.Second
+	// EventReset tells client that missed events are lost and
+	// subscriptions have to be fetched again
+	EventReset = "reset"
+)
+
+type StreamHandler struct {
+	hub *stream.Hub
+	policy *authz.Policy
+	logger *slog.Logger
+}
+
+func NewStreamHandler(hub *stream.Hub, policy *authz.Policy, logger *slog.Logger) *StreamHandler {
+	return &StreamHandler{
+		hub: hub,
+		policy: policy,
+		logger: logger,
+	}
+}
+
+func writeEvent(w http.ResponseWriter, event model.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	return err
+}
+
+// Stream godoc
+// @Summary Stream subscription changes
+// @Description Server-Sent Events of changes of the caller's subscriptions, of all subscriptions of the tenant or of user for callers reading any subscription. Reconnecting clients get missed events after Last-Event-ID, a reset event means they are no longer kept and subscriptions have to be fetched again. Comments are sent as heartbeats.
+// @Tags subscriptions
+// @Produce text/event-stream
+// @Param user query string false "User ID"
+// @Param Last-Event-ID header string false "ID of the last received event"
+// @Success 200
+// @Failure 400
+// @Failure 403
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /v1/subscriptions/stream [get]
+func (h *StreamHandler) Stream(c echo.Context) error {
+	filter, err := parseFilter(c)
+	if err != nil {
+		h.logger.Error("stream error", "error", err)
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	scoped, err := authorizeOwner(c, h.policy, false, filter.UserID)
+	if err != nil {
+		return c.JSON(http.StatusForbidden, err.Error())
+	}
+
+	ctx := c.Request().Context()
+	tenantID := auth.TenantFromContext(ctx)
+	owner := filter.UserID
+	if scoped {
+		userID, _ := auth.UserIDFromContext(ctx)
+		owner = uuid.NullUUID{UUID: userID, Valid: true}
+	}
+	match := func(event model.Event) bool {
+		return event.TenantID == tenantID && (!owner.Valid || event.UserID == owner.UUID)
+	}
+
+	// an unparsable id cannot be resumed from, like an evicted one
+	lastEventID := uuid.Nil
+	if header := c.Request().Header.Get("Last-Event-ID"); header != "" {
+		if lastEventID, err = uuid.Parse(header); err != nil {
+			lastEventID = uuid.New()
+		}
+	}
+
+	sub, missed, resumed := h.hub.Subscribe(lastEventID, match)
+	defer h.hub.Unsubscribe(sub)
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "text/event-stream")
+	res.Header().Set(echo.HeaderCacheControl, "no-cache")
+	// keeps proxies from buffering the stream
+	res.Header().Set("X-Accel-Buffering", "no")
+	res.WriteHeader(http.StatusOK)
+
+	fmt.Fprintf(res, "retry: %d\n\n", reconnectDelay.Milliseconds())
+	if !resumed {
+		fmt.Fprintf(res, "event: %s\ndata: {}\n\n", EventReset)
+	}
+	for _, event := range missed {
+		if err := writeEvent(res, event); err != nil {
+			return nil
+		}
+	}
+	res.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-sub.Events():
+			// the client fell behind or events were lost, it resumes after reconnect
+			if !ok {
+				return nil
+			}
+			if err := writeEvent(res, event); err != nil {
+				return nil
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(res, ": heartbeat\n\n"); err != nil {
+				return nil
+			}
+		}
+		res.Flush()
+	}
+}
@@ -0,0 +1,110 @@
+package handler_test
+
+import (
+	"bufio"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/teamcutter/subscriptions-service-task/internal/auth"
+	"github.com/teamcutter/subscriptions-service-task/internal/authz"
+	"github.com/teamcutter/subscriptions-service-task/internal/handler"
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+	"github.com/teamcutter/subscriptions-service-task/internal/stream"
+)
+
+// setupStreamTest serves stream to user mockUUID1
+func setupStreamTest(t *testing.T) (*httptest.Server, *stream.Hub) {
+	hub := stream.NewHub(10)
+	h := handler.NewStreamHandler(hub, authz.NewPolicy(authz.DefaultRoles, nil, slog.Default()), slog.Default())
+
+	e := echo.New()
+	e.GET("/subscriptions/stream", h.Stream, func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			c.SetRequest(req.WithContext(auth.WithUserID(req.Context(), mockUUID1)))
+			return next(c)
+		}
+	})
+
+	server := httptest.NewServer(e)
+	t.Cleanup(server.Close)
+	return server, hub
+}
+
+// readFrame reads SSE lines up to the next blank line
+func readFrame(t *testing.T, r *bufio.Reader) []string {
+	var lines []string
+	for {
+		line, err := r.ReadString('\n')
+		require.NoError(t, err)
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			return lines
+		}
+		lines = append(lines, line)
+	}
+}
+
+func openStream(t *testing.T, url, lastEventID string) *bufio.Reader {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	require.NoError(t, err)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { res.Body.Close() })
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "text/event-stream", res.Header.Get(echo.HeaderContentType))
+
+	r := bufio.NewReader(res.Body)
+	assert.Equal(t, []string{"retry: 3000"}, readFrame(t, r))
+	return r
+}
+
+func TestStreamFiltersAndResumes(t *testing.T) {
+	server, hub := setupStreamTest(t)
+	url := server.URL + "/subscriptions/stream"
+
+	created := model.Event{ID: uuid.New(), Type: model.EventSubscriptionCreated, TenantID: model.DefaultTenant, SubscriptionID: 1, UserID: mockUUID1}
+	foreign := model.Event{ID: uuid.New(), Type: model.EventSubscriptionCreated, TenantID: model.DefaultTenant, SubscriptionID: 2, UserID: mockUUID2}
+	otherTenant := model.Event{ID: uuid.New(), Type: model.EventSubscriptionCreated, TenantID: "acme", SubscriptionID: 3, UserID: mockUUID1}
+	deleted := model.Event{ID: uuid.New(), Type: model.EventSubscriptionDeleted, TenantID: model.DefaultTenant, SubscriptionID: 1, UserID: mockUUID1}
+
+	r := openStream(t, url, "")
+	for _, event := range []model.Event{created, foreign, otherTenant, deleted} {
+		hub.Publish(event)
+	}
+
+	frame := readFrame(t, r)
+	assert.Equal(t, "id: "+created.ID.String(), frame[0])
+	assert.Equal(t, "event: subscription.created", frame[1])
+	assert.Contains(t, frame[2], `"subscription_id":1`)
+	assert.Equal(t, "id: "+deleted.ID.String(), readFrame(t, r)[0])
+
+	// resuming replays events after the last received one
+	r = openStream(t, url, created.ID.String())
+	assert.Equal(t, "id: "+deleted.ID.String(), readFrame(t, r)[0])
+
+	// unknown events cannot be resumed from
+	r = openStream(t, url, uuid.NewString())
+	assert.Equal(t, []string{"event: reset", "data: {}"}, readFrame(t, r))
+}
+
+func TestStreamOfOtherUserForbidden(t *testing.T) {
+	server, _ := setupStreamTest(t)
+
+	res, err := http.Get(server.URL + "/subscriptions/stream?user=" + mockUUID2.String())
+	require.NoError(t, err)
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusForbidden, res.StatusCode)
+}
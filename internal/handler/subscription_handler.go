@@ -1,46 +1,214 @@
 package handler
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
+	"github.com/teamcutter/subscriptions-service-task/internal/auth"
+	"github.com/teamcutter/subscriptions-service-task/internal/authz"
+	"github.com/teamcutter/subscriptions-service-task/internal/codec"
 	"github.com/teamcutter/subscriptions-service-task/internal/model"
 	"github.com/teamcutter/subscriptions-service-task/internal/repo"
 )
 
 type Handler struct {
 	repository repo.Repo
+	users repo.UserRepo
+	// encoders write lists and totals in the media type of Accept header
+	encoders *codec.Registry
+	policy *authz.Policy
 	logger *slog.Logger
 }
 
-func NewHandler(repository repo.Repo, logger *slog.Logger) *Handler {
+func NewHandler(repository repo.Repo, users repo.UserRepo, encoders *codec.Registry, policy *authz.Policy, logger *slog.Logger) *Handler {
 	return &Handler{
 		repository: repository,
+		users: users,
+		encoders: encoders,
+		policy: policy,
 		logger: logger,
 	}
 }
 
+// negotiate picks encoder of the response by Accept header
+func (h *Handler) negotiate(c echo.Context) (codec.Encoder, error) {
+	c.Response().Header().Add(echo.HeaderVary, echo.HeaderAccept)
+	return h.encoders.Negotiate(c.Request().Header.Get(echo.HeaderAccept))
+}
+
+// encode writes v in the media type of encoder
+func encode(c echo.Context, encoder codec.Encoder, status int, v any) error {
+	var body bytes.Buffer
+	if err := encoder.Encode(&body, v); err != nil {
+		return err
+	}
+	return c.Blob(status, encoder.ContentType(), body.Bytes())
+}
+
+// fail writes error message in the media type of encoder, as plain text
+// when the media type can not hold a message like CSV
+func fail(c echo.Context, encoder codec.Encoder, status int, message string) error {
+	if err := encode(c, encoder, status, message); err != nil {
+		return c.String(status, message)
+	}
+	return nil
+}
+
+// notAcceptable writes negotiation error in JSON, the default media type
+func notAcceptable(c echo.Context, err error) error {
+	return fail(c, codec.JSON{}, http.StatusNotAcceptable, err.Error())
+}
+
+func versionETag(version int) string {
+	return fmt.Sprintf(`"%d"`, version)
+}
+
+// ifMatchVersion returns version expected by If-Match header,
+// 0 when header is absent or "*"
+func ifMatchVersion(c echo.Context) (int, error) {
+	header := strings.TrimSpace(c.Request().Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return 0, nil
+	}
+
+	version, err := strconv.Atoi(strings.Trim(strings.TrimPrefix(header, "W/"), `"`))
+	if err != nil || version <= 0 {
+		return 0, errors.New("invalid If-Match header")
+	}
+	return version, nil
+}
+
+// noneMatch reports whether If-None-Match header matches etag. Tags are
+// compared weakly as RFC 9110 requires for If-None-Match, so W/ prefixes
+// added or dropped by proxies do not matter, and "*" matches any
+func noneMatch(header, etag string) bool {
+	if strings.TrimSpace(header) == "*" {
+		return true
+	}
+	opaque := strings.TrimPrefix(etag, "W/")
+	for _, tag := range strings.Split(header, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(tag), "W/") == opaque {
+			return true
+		}
+	}
+	return false
+}
+
+// authorizeOwner checks permission of the request to read or write data
+// of owner, see authz.Policy.AuthorizeOwner
+func authorizeOwner(c echo.Context, policy *authz.Policy, write bool, owner uuid.NullUUID) (scoped bool, err error) {
+	return policy.AuthorizeOwner(c.Request().Context(), c.Request().Method+" "+c.Path(), write, owner)
+}
+
+// access authorizes the request to read or write subscriptions of owner,
+// own subscriptions when owner is not set. Returned repository is limited
+// to the request tenant and to the caller's subscriptions unless the caller
+// may access any of them
+func access(c echo.Context, policy *authz.Policy, r repo.Repo, write bool, owner uuid.NullUUID) (repo.Repo, error) {
+	ctx := c.Request().Context()
+	r = r.ForTenant(auth.TenantFromContext(ctx))
+
+	scoped, err := authorizeOwner(c, policy, write, owner)
+	if err != nil {
+		return nil, err
+	}
+	if !scoped {
+		return r, nil
+	}
+
+	userID, _ := auth.UserIDFromContext(ctx)
+	return repo.NewScopedRepo(r, userID), nil
+}
+
+func (h *Handler) access(c echo.Context, write bool, owner uuid.NullUUID) (repo.Repo, error) {
+	return access(c, h.policy, h.repository, write, owner)
+}
+
+func (h *Handler) forbidden(c echo.Context, err error) error {
+	return c.JSON(http.StatusForbidden, err.Error())
+}
+
+// ownerOf treats zero user id as not set
+func ownerOf(userID uuid.UUID) uuid.NullUUID {
+	return uuid.NullUUID{UUID: userID, Valid: userID != uuid.Nil}
+}
+
+// parseFilter reads list filters from user and service query params
+func parseFilter(c echo.Context) (model.SubscriptionFilter, error) {
+	filter := model.SubscriptionFilter{ServiceName: c.QueryParam("service")}
+
+	if user := c.QueryParam("user"); user != "" {
+		userID, err := uuid.Parse(user)
+		if err != nil {
+			return filter, fmt.Errorf("invalid user: %w", err)
+		}
+		filter.UserID = uuid.NullUUID{UUID: userID, Valid: true}
+	}
+	return filter, nil
+}
+
+// repoErrorStatus maps repository errors to HTTP statuses
+func repoErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, repo.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, repo.ErrVersionMismatch):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, repo.ErrInvalidSubscription):
+		return http.StatusBadRequest
+	case errors.Is(err, repo.ErrBatchAborted):
+		return http.StatusFailedDependency
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
 // Create godoc
 // @Summary Create new subscription
+// @Description Errors are encoded in the media type of Accept header: JSON, CSV, XML or MessagePack.
 // @Tags subscriptions
 // @Accept json
-// @Produce json
+// @Produce json,text/csv,application/xml,application/msgpack
+// @Param Idempotency-Key header string false "Key to safely retry the request"
 // @Param subscription body model.Subscription true "Subscription"
 // @Success 201
 // @Failure 400
-// @Router /subscriptions [post]
+// @Failure 403
+// @Failure 406
+// @Failure 409
+// @Failure 422
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /v1/subscriptions [post]
 func (h *Handler) Create(c echo.Context) error {
+	encoder, err := h.negotiate(c)
+	if err != nil {
+		return notAcceptable(c, err)
+	}
+
 	var sub model.Subscription
 	if err := c.Bind(&sub); err != nil {
 		h.logger.Error("JSON binding error", "error", err)
-		return c.JSON(http.StatusBadRequest, err.Error())
+		return fail(c, encoder, http.StatusBadRequest, err.Error())
+	}
+
+	r, err := h.access(c, true, ownerOf(sub.UserID))
+	if err != nil {
+		return fail(c, encoder, http.StatusForbidden, err.Error())
 	}
-	if err := h.repository.Create(&sub); err != nil {
+	if err := r.Create(&sub); err != nil {
 		h.logger.Error("create error", "error", err)
-		return c.JSON(http.StatusInternalServerError, err.Error())
+		return fail(c, encoder, repoErrorStatus(err), err.Error())
 	}
 
 	h.logger.Info("subscription created",
@@ -48,75 +216,375 @@ func (h *Handler) Create(c echo.Context) error {
 		"service", sub.ServiceName,
 		"start", sub.StartDate,
 	)
-	
+
+	c.Response().Header().Set(echo.HeaderLocation, fmt.Sprintf("%s/%d", strings.TrimSuffix(c.Request().URL.Path, "/"), sub.ID))
+	c.Response().Header().Set("ETag", versionETag(sub.Version))
 	return c.NoContent(http.StatusCreated)
 }
 
 // GetAll godoc
 // @Summary Get all subscriptions
+// @Description The list is encoded in the media type of Accept header: JSON, CSV, XML or MessagePack.
 // @Tags subscriptions
-// @Produce json
+// @Produce json,text/csv,application/xml,application/msgpack
+// @Param user query string false "User ID"
+// @Param service query string false "Service name"
+// @Param If-None-Match header string false "ETag of previously fetched list"
 // @Success 200 {array} model.Subscription
-// @Router /subscriptions [get]
+// @Success 304
+// @Failure 400
+// @Failure 403
+// @Failure 406
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /v1/subscriptions [get]
 func (h *Handler) GetAll(c echo.Context) error {
-	subs, err := h.repository.GetAll()
+	encoder, err := h.negotiate(c)
+	if err != nil {
+		return notAcceptable(c, err)
+	}
+
+	filter, err := parseFilter(c)
 	if err != nil {
 		h.logger.Error("get all error", "error", err)
-		return c.JSON(http.StatusInternalServerError, err.Error())
+		return fail(c, encoder, http.StatusBadRequest, err.Error())
 	}
-	return c.JSON(http.StatusOK, subs)
+
+	r, err := h.access(c, false, filter.UserID)
+	if err != nil {
+		return fail(c, encoder, http.StatusForbidden, err.Error())
+	}
+
+	subs, err := r.GetAll(filter)
+	if err != nil {
+		h.logger.Error("get all error", "error", err)
+		return fail(c, encoder, http.StatusInternalServerError, err.Error())
+	}
+
+	var body bytes.Buffer
+	if err := encoder.Encode(&body, subs); err != nil {
+		h.logger.Error("get all error", "error", err)
+		return fail(c, encoder, http.StatusInternalServerError, err.Error())
+	}
+
+	// representations differ in body, so they get different tags
+	sum := sha256.Sum256(body.Bytes())
+	etag := `W/"` + hex.EncodeToString(sum[:16]) + `"`
+	c.Response().Header().Set("ETag", etag)
+	if noneMatch(c.Request().Header.Get("If-None-Match"), etag) {
+		return c.NoContent(http.StatusNotModified)
+	}
+
+	return c.Blob(http.StatusOK, encoder.ContentType(), body.Bytes())
+}
+
+// GetByID godoc
+// @Summary Get subscription by ID
+// @Description The subscription is encoded in the media type of Accept header: JSON, CSV, XML or MessagePack.
+// @Tags subscriptions
+// @Produce json,text/csv,application/xml,application/msgpack
+// @Param id path int true "ID"
+// @Success 200 {object} model.Subscription
+// @Failure 400
+// @Failure 403
+// @Failure 404
+// @Failure 406
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /v1/subscriptions/{id} [get]
+func (h *Handler) GetByID(c echo.Context) error {
+	encoder, err := h.negotiate(c)
+	if err != nil {
+		return notAcceptable(c, err)
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		h.logger.Error("get by id error", "error", err)
+		return fail(c, encoder, http.StatusBadRequest, err.Error())
+	}
+
+	r, err := h.access(c, false, uuid.NullUUID{})
+	if err != nil {
+		return fail(c, encoder, http.StatusForbidden, err.Error())
+	}
+
+	sub, err := r.GetByID(id)
+	if err != nil {
+		h.logger.Error("get by id error", "error", err)
+		return fail(c, encoder, repoErrorStatus(err), err.Error())
+	}
+
+	c.Response().Header().Set("ETag", versionETag(sub.Version))
+	return encode(c, encoder, http.StatusOK, sub)
+}
+
+// Update godoc
+// @Summary Update subscription by ID
+// @Description The updated subscription is encoded in the media type of Accept header: JSON, CSV, XML or MessagePack.
+// @Tags subscriptions
+// @Accept json
+// @Produce json,text/csv,application/xml,application/msgpack
+// @Param id path int true "ID"
+// @Param If-Match header string false "ETag of subscription being updated"
+// @Param subscription body model.Subscription true "Subscription"
+// @Success 200 {object} model.Subscription
+// @Failure 400
+// @Failure 403
+// @Failure 404
+// @Failure 406
+// @Failure 412
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /v1/subscriptions/{id} [put]
+func (h *Handler) Update(c echo.Context) error {
+	encoder, err := h.negotiate(c)
+	if err != nil {
+		return notAcceptable(c, err)
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		h.logger.Error("update error", "error", err)
+		return fail(c, encoder, http.StatusBadRequest, err.Error())
+	}
+
+	version, err := ifMatchVersion(c)
+	if err != nil {
+		h.logger.Error("update error", "error", err)
+		return fail(c, encoder, http.StatusBadRequest, err.Error())
+	}
+
+	var sub model.Subscription
+	if err := c.Bind(&sub); err != nil {
+		h.logger.Error("JSON binding error", "error", err)
+		return fail(c, encoder, http.StatusBadRequest, err.Error())
+	}
+	sub.ID = int64(id)
+
+	r, err := h.access(c, true, ownerOf(sub.UserID))
+	if err != nil {
+		return fail(c, encoder, http.StatusForbidden, err.Error())
+	}
+
+	if err := r.Update(&sub, version); err != nil {
+		h.logger.Error("update error", "error", err)
+		return fail(c, encoder, repoErrorStatus(err), err.Error())
+	}
+
+	h.logger.Info("subscription updated", "service_id", id, "version", sub.Version)
+	c.Response().Header().Set("ETag", versionETag(sub.Version))
+	return encode(c, encoder, http.StatusOK, sub)
+}
+
+// Batch godoc
+// @Summary Create, update and delete subscriptions in one request
+// @Description Atomic batch is applied all-or-nothing, otherwise every operation succeeds or fails on its own.
+// @Description Responds 200 when all operations succeeded and 207 with per-item statuses otherwise.
+// @Description Results are encoded in the media type of Accept header: JSON, CSV, XML or MessagePack.
+// @Tags subscriptions
+// @Accept json
+// @Produce json,text/csv,application/xml,application/msgpack
+// @Param batch body model.BatchRequest true "Operations"
+// @Success 200 {array} model.BatchResult
+// @Success 207 {array} model.BatchResult
+// @Failure 400
+// @Failure 403
+// @Failure 406
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /v1/subscriptions/batch [post]
+func (h *Handler) Batch(c echo.Context) error {
+	encoder, err := h.negotiate(c)
+	if err != nil {
+		return notAcceptable(c, err)
+	}
+
+	var req model.BatchRequest
+	if err := c.Bind(&req); err != nil {
+		h.logger.Error("JSON binding error", "error", err)
+		return fail(c, encoder, http.StatusBadRequest, err.Error())
+	}
+
+	if len(req.Operations) == 0 || len(req.Operations) > repo.MaxBatchSize {
+		err := fmt.Errorf("batch must contain from 1 to %d operations", repo.MaxBatchSize)
+		h.logger.Error("batch error", "error", err)
+		return fail(c, encoder, http.StatusBadRequest, err.Error())
+	}
+
+	r, err := h.access(c, true, uuid.NullUUID{})
+	if err != nil {
+		return fail(c, encoder, http.StatusForbidden, err.Error())
+	}
+
+	results, err := r.Batch(req.Operations, req.Atomic)
+	if err != nil {
+		h.logger.Error("batch error", "error", err)
+		return fail(c, encoder, http.StatusInternalServerError, err.Error())
+	}
+
+	status := http.StatusOK
+	failed := 0
+	for i := range results {
+		if results[i].Err != nil {
+			results[i].Status = repoErrorStatus(results[i].Err)
+			results[i].Error = results[i].Err.Error()
+			status = http.StatusMultiStatus
+			failed++
+			continue
+		}
+
+		switch req.Operations[i].Op {
+		case model.BatchOpCreate:
+			results[i].Status = http.StatusCreated
+		case model.BatchOpUpdate:
+			results[i].Status = http.StatusOK
+		case model.BatchOpDelete:
+			results[i].Status = http.StatusNoContent
+		}
+	}
+
+	h.logger.Info("subscriptions batch applied",
+		"operations", len(results),
+		"failed", failed,
+		"atomic", req.Atomic,
+	)
+	return encode(c, encoder, status, results)
 }
 
 // Delete godoc
 // @Summary Delete subscription by ID
+// @Description Errors are encoded in the media type of Accept header: JSON, CSV, XML or MessagePack.
 // @Tags subscriptions
+// @Produce json,text/csv,application/xml,application/msgpack
 // @Param id path int true "ID"
+// @Param If-Match header string false "ETag of subscription being deleted"
 // @Success 204
-// @Router /subscriptions/{id} [delete]
+// @Failure 400
+// @Failure 403
+// @Failure 404
+// @Failure 406
+// @Failure 412
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /v1/subscriptions/{id} [delete]
 func (h *Handler) Delete(c echo.Context) error {
-	subscriptionId := c.Param("id")
-	if subscriptionId == "" {
-		err := errors.New("missing required params")
+	encoder, err := h.negotiate(c)
+	if err != nil {
+		return notAcceptable(c, err)
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		h.logger.Error("delete error", "error", err)
+		return fail(c, encoder, http.StatusBadRequest, err.Error())
+	}
+
+	version, err := ifMatchVersion(c)
+	if err != nil {
 		h.logger.Error("delete error", "error", err)
-		return c.JSON(http.StatusInternalServerError, err.Error())
+		return fail(c, encoder, http.StatusBadRequest, err.Error())
+	}
+
+	r, err := h.access(c, true, uuid.NullUUID{})
+	if err != nil {
+		return fail(c, encoder, http.StatusForbidden, err.Error())
 	}
 
-	id, _ := strconv.Atoi(subscriptionId)
-	if err := h.repository.Delete(id); err != nil {
+	if err := r.Delete(id, version); err != nil {
 		h.logger.Error("delete error", "error", err)
-		return c.JSON(http.StatusInternalServerError, err.Error())
+		return fail(c, encoder, repoErrorStatus(err), err.Error())
 	}
 
 	h.logger.Info("subscription deleted", "service_id", id)
 	return c.NoContent(http.StatusNoContent)
 }
 
+// preferences returns currency and timezone of user, defaults for
+// users without profile
+func preferences(c echo.Context, users repo.UserRepo, userID uuid.UUID) (string, *time.Location, error) {
+	user, err := users.ForTenant(auth.TenantFromContext(c.Request().Context())).Get(userID)
+	if errors.Is(err, repo.ErrUserNotFound) {
+		return model.DefaultCurrency, time.UTC, nil
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	location, err := time.LoadLocation(user.Timezone)
+	if err != nil {
+		location = time.UTC
+	}
+	return user.Currency, location, nil
+}
+
 // TotalCost godoc
 // @Summary Total of all subscriptions
+// @Description Shared subscriptions count with the user's share only. Total is in the user's currency, end defaults to the current month in the user's timezone and start to end. The total is encoded in the media type of Accept header: JSON, CSV, XML or MessagePack.
 // @Tags subscriptions
-// @Produce json
-// @Param user query string true "User ID"
+// @Produce json,text/csv,application/xml,application/msgpack
+// @Param user query string false "User ID, defaults to authenticated user"
 // @Param service query string false "Service name"
-// @Param start query string true "Start date (MM-YYYY)"
-// @Param end query string true "End date (MM-YYYY)"
-// @Success 200 {object} map[string]int
-// @Router /subscriptions/total [get]
+// @Param start query string false "Start date (MM-YYYY)"
+// @Param end query string false "End date (MM-YYYY)"
+// @Success 200 {object} model.Total
+// @Failure 400
+// @Failure 403
+// @Failure 406
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /v1/subscriptions/total [get]
 func (h *Handler) TotalCost(c echo.Context) error {
+	encoder, err := h.negotiate(c)
+	if err != nil {
+		return notAcceptable(c, err)
+	}
+
 	userID := c.QueryParam("user")
 	service := c.QueryParam("service")
 	start := c.QueryParam("start")
 	end := c.QueryParam("end")
 
-	if userID == "" || start == "" || end == "" {
-		err := errors.New("missing required params")
+	if authUserID, ok := auth.UserIDFromContext(c.Request().Context()); ok && userID == "" {
+		userID = authUserID.String()
+	}
+
+	if userID == "" {
+		return fail(c, encoder, http.StatusBadRequest, "missing required param user")
+	}
+	for _, month := range []string{start, end} {
+		if _, err := time.Parse(monthDate, month); month != "" && err != nil {
+			return fail(c, encoder, http.StatusBadRequest, fmt.Sprintf("invalid date %q, expected MM-YYYY", month))
+		}
+	}
+
+	owner, err := uuid.Parse(userID)
+	if err != nil {
+		h.logger.Error("total cost error", "error", err)
+		return fail(c, encoder, http.StatusBadRequest, err.Error())
+	}
+
+	r, err := h.access(c, false, ownerOf(owner))
+	if err != nil {
+		return fail(c, encoder, http.StatusForbidden, err.Error())
+	}
+
+	currency, location, err := preferences(c, h.users, owner)
+	if err != nil {
 		h.logger.Error("total cost error", "error", err)
-		return c.JSON(http.StatusInternalServerError, err.Error())
+		return fail(c, encoder, http.StatusInternalServerError, err.Error())
+	}
+	if end == "" {
+		end = time.Now().In(location).Format(monthDate)
+	}
+	if start == "" {
+		start = end
 	}
 
-	total, err := h.repository.TotalCost(userID, service, start, end)
+	total, err := r.TotalCost(userID, service, start, end)
 	if err != nil {
 		h.logger.Error("total cost error", "error", err)
-		return c.JSON(http.StatusInternalServerError, err.Error())
+		return fail(c, encoder, http.StatusInternalServerError, err.Error())
 	}
 
 	h.logger.Info("total cost for subscription",
@@ -124,5 +592,5 @@ func (h *Handler) TotalCost(c echo.Context) error {
 		"service", service,
 		"total", total,
 	)
-	return c.JSON(http.StatusOK, map[string]int{"total": total})
+	return encode(c, encoder, http.StatusOK, model.Total{Total: total, Currency: currency})
 }
@@ -4,8 +4,10 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"log/slog"
@@ -15,8 +17,12 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 
+	"github.com/teamcutter/subscriptions-service-task/internal/auth"
+	"github.com/teamcutter/subscriptions-service-task/internal/authz"
+	"github.com/teamcutter/subscriptions-service-task/internal/codec"
 	"github.com/teamcutter/subscriptions-service-task/internal/handler"
 	"github.com/teamcutter/subscriptions-service-task/internal/model"
+	repoPkg "github.com/teamcutter/subscriptions-service-task/internal/repo"
 )
 
 var mockUUID1 uuid.UUID = uuid.New()
@@ -24,6 +30,7 @@ var mockUUID2 uuid.UUID = uuid.New()
 
 type MockRepo struct {
 	mock.Mock
+	tenantID string
 }
 
 func (m *MockRepo) Create(sub *model.Subscription) error {
@@ -31,13 +38,34 @@ func (m *MockRepo) Create(sub *model.Subscription) error {
 	return args.Error(0)
 }
 
-func (m *MockRepo) GetAll() ([]model.Subscription, error) {
-	args := m.Called()
+func (m *MockRepo) GetAll(filter model.SubscriptionFilter) ([]model.Subscription, error) {
+	args := m.Called(filter)
 	return args.Get(0).([]model.Subscription), args.Error(1)
 }
 
-func (m *MockRepo) Delete(id int) error {
+func (m *MockRepo) Stream(filter model.SubscriptionFilter, fn func(model.Subscription) error) error {
+	args := m.Called(filter, fn)
+	for _, sub := range args.Get(0).([]model.Subscription) {
+		if err := fn(sub); err != nil {
+			return err
+		}
+	}
+	return args.Error(1)
+}
+
+func (m *MockRepo) GetByID(id int) (*model.Subscription, error) {
 	args := m.Called(id)
+	sub, _ := args.Get(0).(*model.Subscription)
+	return sub, args.Error(1)
+}
+
+func (m *MockRepo) Update(sub *model.Subscription, version int) error {
+	args := m.Called(sub, version)
+	return args.Error(0)
+}
+
+func (m *MockRepo) Delete(id int, version int) error {
+	args := m.Called(id, version)
 	return args.Error(0)
 }
 
@@ -46,14 +74,31 @@ func (m *MockRepo) TotalCost(userID, service, start, end string) (int, error) {
 	return args.Int(0), args.Error(1)
 }
 
+func (m *MockRepo) Batch(ops []model.BatchOperation, atomic bool) ([]model.BatchResult, error) {
+	args := m.Called(ops, atomic)
+	return args.Get(0).([]model.BatchResult), args.Error(1)
+}
+
+// ForTenant remembers the tenant so that tests can check it
+func (m *MockRepo) ForTenant(tenantID string) repoPkg.Repo {
+	m.tenantID = tenantID
+	return m
+}
+
 func setupTest(t *testing.T) (*echo.Echo, *MockRepo, *handler.Handler) {
 	e := echo.New()
 	mockRepo := new(MockRepo)
 	log := slog.Default()
-	h := handler.NewHandler(mockRepo, log)
+	h := handler.NewHandler(mockRepo, fakeUsers{}, codec.Default(), authz.NewPolicy(authz.DefaultRoles, nil, log), log)
 	return e, mockRepo, h
 }
 
+// systemRequest is a request of the service itself, allowed everything
+func systemRequest(method, target string, body io.Reader) *http.Request {
+	req := httptest.NewRequest(method, target, body)
+	return req.WithContext(auth.WithSystem(req.Context()))
+}
+
 func TestCreate(t *testing.T) {
 	e, repo, h := setupTest(t)
 
@@ -61,7 +106,7 @@ func TestCreate(t *testing.T) {
 	repo.On("Create", mock.AnythingOfType("*model.Subscription")).Return(nil)
 
 	body, _ := json.Marshal(sub)
-	req := httptest.NewRequest(http.MethodPost, "/subscriptions", bytes.NewBuffer(body))
+	req := systemRequest(http.MethodPost, "/subscriptions", bytes.NewBuffer(body))
 	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
 	rec := httptest.NewRecorder()
 	c := e.NewContext(req, rec)
@@ -72,6 +117,22 @@ func TestCreate(t *testing.T) {
 	}
 }
 
+func TestCreateInvalid(t *testing.T) {
+	e, repo, h := setupTest(t)
+
+	// strict users mode rejects unknown users as invalid subscriptions
+	repo.On("Create", mock.AnythingOfType("*model.Subscription")).Return(repoPkg.ErrInvalidSubscription)
+
+	body, _ := json.Marshal(model.Subscription{UserID: mockUUID1, ServiceName: "Netflix"})
+	req := systemRequest(http.MethodPost, "/subscriptions", bytes.NewBuffer(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+
+	if assert.NoError(t, h.Create(e.NewContext(req, rec))) {
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	}
+}
+
 func TestGetAll(t *testing.T) {
 	e, repo, h := setupTest(t)
 
@@ -79,9 +140,9 @@ func TestGetAll(t *testing.T) {
 		{ID: 1, UserID: mockUUID1, ServiceName: "Netflix"},
 		{ID: 2, UserID: mockUUID2, ServiceName: "Spotify"},
 	}
-	repo.On("GetAll").Return(expectedSubs, nil)
+	repo.On("GetAll", model.SubscriptionFilter{}).Return(expectedSubs, nil)
 
-	req := httptest.NewRequest(http.MethodGet, "/subscriptions", nil)
+	req := systemRequest(http.MethodGet, "/subscriptions", nil)
 	rec := httptest.NewRecorder()
 	c := e.NewContext(req, rec)
 
@@ -93,16 +154,16 @@ func TestGetAll(t *testing.T) {
 			assert.Len(t, got, 2)
 			assert.Equal(t, "Netflix", got[0].ServiceName)
 		}
-		repo.AssertCalled(t, "GetAll")
+		repo.AssertCalled(t, "GetAll", model.SubscriptionFilter{})
 	}
 }
 
 func TestDelete(t *testing.T) {
 	e, repo, h := setupTest(t)
 
-	repo.On("Delete", 1).Return(nil)
+	repo.On("Delete", 1, 0).Return(nil)
 
-	req := httptest.NewRequest(http.MethodDelete, "/subscriptions/1", nil)
+	req := systemRequest(http.MethodDelete, "/subscriptions/1", nil)
 	rec := httptest.NewRecorder()
 	c := e.NewContext(req, rec)
 	c.SetParamNames("id")
@@ -110,7 +171,145 @@ func TestDelete(t *testing.T) {
 
 	if assert.NoError(t, h.Delete(c)) {
 		assert.Equal(t, http.StatusNoContent, rec.Code)
-		repo.AssertCalled(t, "Delete", 1)
+		repo.AssertCalled(t, "Delete", 1, 0)
+	}
+}
+
+func TestDeleteInvalidID(t *testing.T) {
+	e, repo, h := setupTest(t)
+
+	for _, id := range []string{"abc", ""} {
+		req := systemRequest(http.MethodDelete, "/subscriptions/"+id, nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues(id)
+
+		if assert.NoError(t, h.Delete(c)) {
+			assert.Equal(t, http.StatusBadRequest, rec.Code, id)
+		}
+	}
+	repo.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+}
+
+func TestGetAllNotModified(t *testing.T) {
+	e, repo, h := setupTest(t)
+
+	repo.On("GetAll", model.SubscriptionFilter{}).Return([]model.Subscription{{ID: 1, UserID: mockUUID1, ServiceName: "Netflix"}}, nil)
+
+	req := systemRequest(http.MethodGet, "/subscriptions", nil)
+	rec := httptest.NewRecorder()
+	assert.NoError(t, h.GetAll(e.NewContext(req, rec)))
+	etag := rec.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+
+	for _, header := range []string{
+		etag,
+		strings.TrimPrefix(etag, "W/"),
+		`"other", ` + etag,
+		"*",
+	} {
+		req = systemRequest(http.MethodGet, "/subscriptions", nil)
+		req.Header.Set("If-None-Match", header)
+		rec = httptest.NewRecorder()
+
+		if assert.NoError(t, h.GetAll(e.NewContext(req, rec))) {
+			assert.Equal(t, http.StatusNotModified, rec.Code, header)
+			assert.Empty(t, rec.Body.Bytes())
+		}
+	}
+
+	req = systemRequest(http.MethodGet, "/subscriptions", nil)
+	req.Header.Set("If-None-Match", `"other"`)
+	rec = httptest.NewRecorder()
+
+	if assert.NoError(t, h.GetAll(e.NewContext(req, rec))) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+}
+
+func TestGetAllNegotiatesContentType(t *testing.T) {
+	e, repo, h := setupTest(t)
+
+	repo.On("GetAll", model.SubscriptionFilter{}).Return([]model.Subscription{
+		{ID: 1, UserID: mockUUID1, ServiceName: "Netflix", Price: 400, StartDate: "01-2025"},
+	}, nil)
+
+	req := systemRequest(http.MethodGet, "/subscriptions", nil)
+	req.Header.Set(echo.HeaderAccept, "text/csv, application/json;q=0.5")
+	rec := httptest.NewRecorder()
+
+	if assert.NoError(t, h.GetAll(e.NewContext(req, rec))) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "text/csv", rec.Header().Get(echo.HeaderContentType))
+		assert.Equal(t, echo.HeaderAccept, rec.Header().Get(echo.HeaderVary))
+		assert.Equal(t, "service_name,price,user_id,start_date,end_date,trial_end_date\n"+
+			"Netflix,400,"+mockUUID1.String()+",01-2025,,\n", rec.Body.String())
+	}
+
+	// tag of JSON list does not match CSV one
+	req = systemRequest(http.MethodGet, "/subscriptions", nil)
+	req.Header.Set("If-None-Match", rec.Header().Get("ETag"))
+	rec = httptest.NewRecorder()
+
+	if assert.NoError(t, h.GetAll(e.NewContext(req, rec))) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, echo.MIMEApplicationJSON, rec.Header().Get(echo.HeaderContentType))
+	}
+}
+
+func TestNotAcceptable(t *testing.T) {
+	e, repo, h := setupTest(t)
+
+	for _, handle := range []echo.HandlerFunc{h.GetAll, h.TotalCost} {
+		req := httptest.NewRequest(http.MethodGet, "/subscriptions?user="+mockUUID1.String(), nil)
+		req.Header.Set(echo.HeaderAccept, "image/png")
+		rec := httptest.NewRecorder()
+
+		if assert.NoError(t, handle(e.NewContext(req, rec))) {
+			assert.Equal(t, http.StatusNotAcceptable, rec.Code)
+			assert.Equal(t, echo.MIMEApplicationJSON, rec.Header().Get(echo.HeaderContentType))
+			assert.Contains(t, rec.Body.String(), "text/csv")
+		}
+	}
+	repo.AssertNotCalled(t, "GetAll", mock.Anything)
+	repo.AssertNotCalled(t, "TotalCost", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestGetByID(t *testing.T) {
+	e, repo, h := setupTest(t)
+
+	repo.On("GetByID", 1).Return(&model.Subscription{ID: 1, UserID: mockUUID1, ServiceName: "Netflix", Version: 3}, nil)
+
+	req := systemRequest(http.MethodGet, "/subscriptions/1", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	if assert.NoError(t, h.GetByID(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, `"3"`, rec.Header().Get("ETag"))
+	}
+}
+
+func TestUpdatePreconditionFailed(t *testing.T) {
+	e, repo, h := setupTest(t)
+
+	repo.On("Update", mock.AnythingOfType("*model.Subscription"), 2).Return(repoPkg.ErrVersionMismatch)
+
+	body, _ := json.Marshal(model.Subscription{UserID: mockUUID1, ServiceName: "Netflix"})
+	req := systemRequest(http.MethodPut, "/subscriptions/1", bytes.NewBuffer(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set("If-Match", `"2"`)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	if assert.NoError(t, h.Update(c)) {
+		assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+		repo.AssertCalled(t, "Update", mock.AnythingOfType("*model.Subscription"), 2)
 	}
 }
 
@@ -119,17 +318,199 @@ func TestTotalCost(t *testing.T) {
 
 	repo.On("TotalCost", mockUUID1.String(), "Netflix", "01-2024", "12-2024").Return(100, nil)
 
-	req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/subscriptions/total?user=%s&service=Netflix&start=01-2024&end=12-2024", mockUUID1.String()), nil)
+	req := systemRequest(http.MethodGet, fmt.Sprintf("/subscriptions/total?user=%s&service=Netflix&start=01-2024&end=12-2024", mockUUID1.String()), nil)
 	rec := httptest.NewRecorder()
 	c := e.NewContext(req, rec)
 
 	if assert.NoError(t, h.TotalCost(c)) {
 		assert.Equal(t, http.StatusOK, rec.Code)
 
-		var resp map[string]int
+		var resp model.Total
 		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
-		assert.Equal(t, 100, resp["total"])
+		assert.Equal(t, model.Total{Total: 100, Currency: model.DefaultCurrency}, resp)
 
 		repo.AssertCalled(t, "TotalCost", mockUUID1.String(), "Netflix", "01-2024", "12-2024")
 	}
 }
+
+func TestTotalCostInvalidParams(t *testing.T) {
+	e, repo, h := setupTest(t)
+
+	for _, target := range []string{
+		"/subscriptions/total",
+		fmt.Sprintf("/subscriptions/total?user=%s&start=2024-01", mockUUID1.String()),
+	} {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+
+		if assert.NoError(t, h.TotalCost(c)) {
+			assert.Equal(t, http.StatusBadRequest, rec.Code, target)
+		}
+	}
+	repo.AssertNotCalled(t, "TotalCost", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestBatchPartialFailure(t *testing.T) {
+	e, repo, h := setupTest(t)
+
+	repo.On("Batch", mock.AnythingOfType("[]model.BatchOperation"), false).Return([]model.BatchResult{
+		{Index: 0, ID: 10, Version: 1},
+		{Index: 1, Err: repoPkg.ErrNotFound},
+	}, nil)
+
+	body := `{"operations":[{"op":"create","subscription":{"service_name":"Netflix"}},{"op":"delete","id":5,"version":1}]}`
+	req := systemRequest(http.MethodPost, "/subscriptions/batch", bytes.NewBufferString(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	if assert.NoError(t, h.Batch(c)) {
+		assert.Equal(t, http.StatusMultiStatus, rec.Code)
+
+		var got []model.BatchResult
+		if assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got)) {
+			assert.Equal(t, http.StatusCreated, got[0].Status)
+			assert.Equal(t, int64(10), got[0].ID)
+			assert.Equal(t, http.StatusNotFound, got[1].Status)
+		}
+	}
+}
+
+func TestBatchEmpty(t *testing.T) {
+	e, repo, h := setupTest(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/subscriptions/batch", bytes.NewBufferString(`{"operations":[]}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	if assert.NoError(t, h.Batch(c)) {
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		repo.AssertNotCalled(t, "Batch", mock.Anything, mock.Anything)
+	}
+}
+
+func TestGetAllScopedToAuthenticatedUser(t *testing.T) {
+	e, repo, h := setupTest(t)
+
+	filter := model.SubscriptionFilter{UserID: uuid.NullUUID{UUID: mockUUID1, Valid: true}}
+	repo.On("GetAll", filter).Return([]model.Subscription{{ID: 1, UserID: mockUUID1, ServiceName: "Netflix"}}, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/subscriptions", nil)
+	req = req.WithContext(auth.WithUserID(req.Context(), mockUUID1))
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	if assert.NoError(t, h.GetAll(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		repo.AssertCalled(t, "GetAll", filter)
+	}
+}
+
+func TestGetAllOfOtherUserForbidden(t *testing.T) {
+	e, repo, h := setupTest(t)
+
+	req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/subscriptions?user=%s", mockUUID2), nil)
+	req = req.WithContext(auth.WithUserID(req.Context(), mockUUID1))
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	if assert.NoError(t, h.GetAll(c)) {
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		repo.AssertNotCalled(t, "GetAll", mock.Anything)
+	}
+}
+
+func TestGetAllOfOtherUserBySupport(t *testing.T) {
+	e, repo, h := setupTest(t)
+
+	filter := model.SubscriptionFilter{UserID: uuid.NullUUID{UUID: mockUUID2, Valid: true}}
+	repo.On("GetAll", filter).Return([]model.Subscription{{ID: 2, UserID: mockUUID2, ServiceName: "Spotify"}}, nil)
+
+	req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/subscriptions?user=%s", mockUUID2), nil)
+	req = req.WithContext(auth.WithPrincipal(req.Context(), auth.Principal{
+		UserID: uuid.NullUUID{UUID: mockUUID1, Valid: true},
+		Role:   auth.RoleSupport,
+	}))
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	if assert.NoError(t, h.GetAll(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		repo.AssertCalled(t, "GetAll", filter)
+	}
+}
+
+func TestDeleteBySupportForbidden(t *testing.T) {
+	e, repo, h := setupTest(t)
+
+	req := httptest.NewRequest(http.MethodDelete, "/subscriptions/1", nil)
+	req = req.WithContext(auth.WithPrincipal(req.Context(), auth.Principal{Role: auth.RoleSupport}))
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	if assert.NoError(t, h.Delete(c)) {
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		repo.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+	}
+}
+
+func TestGetByIDOfOtherUser(t *testing.T) {
+	e, repo, h := setupTest(t)
+
+	repo.On("GetByID", 1).Return(&model.Subscription{ID: 1, UserID: mockUUID2, ServiceName: "Netflix", Version: 1}, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/subscriptions/1", nil)
+	req = req.WithContext(auth.WithUserID(req.Context(), mockUUID1))
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	if assert.NoError(t, h.GetByID(c)) {
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestTotalCostUsesAuthenticatedUser(t *testing.T) {
+	e, repo, h := setupTest(t)
+
+	repo.On("TotalCost", mockUUID1.String(), "", "01-2024", "12-2024").Return(100, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/subscriptions/total?start=01-2024&end=12-2024", nil)
+	req = req.WithContext(auth.WithUserID(req.Context(), mockUUID1))
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	if assert.NoError(t, h.TotalCost(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		repo.AssertCalled(t, "TotalCost", mockUUID1.String(), "", "01-2024", "12-2024")
+	}
+
+	// other user's total cannot be requested
+	req = httptest.NewRequest(http.MethodGet, fmt.Sprintf("/subscriptions/total?user=%s&start=01-2024&end=12-2024", mockUUID2), nil)
+	req = req.WithContext(auth.WithUserID(req.Context(), mockUUID1))
+	rec = httptest.NewRecorder()
+
+	if assert.NoError(t, h.TotalCost(e.NewContext(req, rec))) {
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	}
+}
+
+func TestGetAllUsesRequestTenant(t *testing.T) {
+	e, repo, h := setupTest(t)
+
+	repo.On("GetAll", model.SubscriptionFilter{}).Return([]model.Subscription{}, nil)
+
+	req := systemRequest(http.MethodGet, "/subscriptions", nil)
+	req = req.WithContext(auth.WithTenant(req.Context(), "acme"))
+	rec := httptest.NewRecorder()
+
+	if assert.NoError(t, h.GetAll(e.NewContext(req, rec))) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "acme", repo.tenantID)
+	}
+}
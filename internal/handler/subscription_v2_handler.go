@@ -0,0 +1,471 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/teamcutter/subscriptions-service-task/internal/auth"
+	"github.com/teamcutter/subscriptions-service-task/internal/authz"
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+	"github.com/teamcutter/subscriptions-service-task/internal/repo"
+)
+
+const (
+	isoDate = "2006-01-02"
+	monthDate = "01-2006"
+
+	defaultPageLimit = 100
+	maxPageLimit = 1000
+)
+
+// HandlerV2 serves subscriptions in v2 shapes, it stores and authorizes
+// the same way as Handler
+type HandlerV2 struct {
+	repository repo.Repo
+	users repo.UserRepo
+	policy *authz.Policy
+	logger *slog.Logger
+}
+
+func NewHandlerV2(repository repo.Repo, users repo.UserRepo, policy *authz.Policy, logger *slog.Logger) *HandlerV2 {
+	return &HandlerV2{
+		repository: repository,
+		users: users,
+		policy: policy,
+		logger: logger,
+	}
+}
+
+// monthOf returns month (MM-YYYY) of ISO date, empty for empty date
+func monthOf(date string) (string, error) {
+	if date == "" {
+		return "", nil
+	}
+	t, err := time.Parse(isoDate, date)
+	if err != nil {
+		return "", fmt.Errorf("invalid date %q, expected YYYY-MM-DD", date)
+	}
+	return t.Format(monthDate), nil
+}
+
+// firstDay returns ISO date of the first day of month (MM-YYYY)
+func firstDay(month string) string {
+	t, err := time.Parse(monthDate, month)
+	if err != nil {
+		return ""
+	}
+	return t.Format(isoDate)
+}
+
+// lastDay returns ISO date of the last day of month (MM-YYYY)
+func lastDay(month string) string {
+	t, err := time.Parse(monthDate, month)
+	if err != nil {
+		return ""
+	}
+	return t.AddDate(0, 1, -1).Format(isoDate)
+}
+
+func toV2(sub *model.Subscription, currency string) model.SubscriptionV2 {
+	return model.SubscriptionV2{
+		ID: sub.ID,
+		ServiceName: sub.ServiceName,
+		Price: model.Money{Amount: sub.Price, Currency: currency},
+		UserID: sub.UserID,
+		StartDate: firstDay(sub.StartDate),
+		EndDate: lastDay(sub.EndDate),
+		TrialEndDate: firstDay(sub.TrialEndDate),
+	}
+}
+
+// fromV2 converts request body to subscription, subscriptions are monthly
+// so only months of dates are kept
+func fromV2(in model.SubscriptionV2) (model.Subscription, error) {
+	sub := model.Subscription{
+		ServiceName: in.ServiceName,
+		Price: in.Price.Amount,
+		UserID: in.UserID,
+	}
+
+	var err error
+	if in.StartDate == "" {
+		return sub, errors.New("start_date is required")
+	}
+	if sub.StartDate, err = monthOf(in.StartDate); err != nil {
+		return sub, err
+	}
+	if sub.EndDate, err = monthOf(in.EndDate); err != nil {
+		return sub, err
+	}
+	if sub.TrialEndDate, err = monthOf(in.TrialEndDate); err != nil {
+		return sub, err
+	}
+	return sub, nil
+}
+
+// currencies returns currency of every user, defaults for users without
+// profile
+func (h *HandlerV2) currencies(c echo.Context, userIDs []uuid.UUID) (map[uuid.UUID]string, error) {
+	users, err := h.users.ForTenant(auth.TenantFromContext(c.Request().Context())).GetMany(userIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	currencies := make(map[uuid.UUID]string, len(userIDs))
+	for _, id := range userIDs {
+		currencies[id] = model.DefaultCurrency
+	}
+	for _, user := range users {
+		currencies[user.ID] = user.Currency
+	}
+	return currencies, nil
+}
+
+// bind reads subscription from request body and checks that its price is
+// in currency of the owner, own subscription when user_id is not set.
+// Returned currency is the owner's
+func (h *HandlerV2) bind(c echo.Context) (model.Subscription, string, error) {
+	var in model.SubscriptionV2
+	if err := c.Bind(&in); err != nil {
+		return model.Subscription{}, "", err
+	}
+
+	sub, err := fromV2(in)
+	if err != nil {
+		return sub, "", err
+	}
+
+	owner := sub.UserID
+	if owner == uuid.Nil {
+		owner, _ = auth.UserIDFromContext(c.Request().Context())
+	}
+	currency, _, err := preferences(c, h.users, owner)
+	if err != nil {
+		return sub, "", err
+	}
+	// prices are not converted
+	if in.Price.Currency != "" && !strings.EqualFold(in.Price.Currency, currency) {
+		return sub, "", fmt.Errorf("price currency must be %s, the currency of the user", currency)
+	}
+	return sub, currency, nil
+}
+
+// Create godoc
+// @Summary Create new subscription
+// @Tags subscriptions-v2
+// @Accept json
+// @Produce json
+// @Param Idempotency-Key header string false "Key to safely retry the request"
+// @Param subscription body model.SubscriptionV2 true "Subscription, id is ignored"
+// @Success 201 {object} model.SubscriptionV2
+// @Failure 400
+// @Failure 403
+// @Failure 409
+// @Failure 422
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /v2/subscriptions [post]
+func (h *HandlerV2) Create(c echo.Context) error {
+	sub, currency, err := h.bind(c)
+	if err != nil {
+		h.logger.Error("create error", "error", err)
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	r, err := access(c, h.policy, h.repository, true, ownerOf(sub.UserID))
+	if err != nil {
+		return c.JSON(http.StatusForbidden, err.Error())
+	}
+	if err := r.Create(&sub); err != nil {
+		h.logger.Error("create error", "error", err)
+		return c.JSON(repoErrorStatus(err), err.Error())
+	}
+
+	h.logger.Info("subscription created",
+		"user_id", sub.UserID,
+		"service", sub.ServiceName,
+		"start", sub.StartDate,
+	)
+
+	c.Response().Header().Set(echo.HeaderLocation, fmt.Sprintf("%s/%d", strings.TrimSuffix(c.Request().URL.Path, "/"), sub.ID))
+	c.Response().Header().Set("ETag", versionETag(sub.Version))
+	return c.JSON(http.StatusCreated, toV2(&sub, currency))
+}
+
+// List godoc
+// @Summary List subscriptions
+// @Description Subscriptions are ordered by id, pass next of a page as cursor to get the following page.
+// @Tags subscriptions-v2
+// @Produce json
+// @Param user query string false "User ID"
+// @Param service query string false "Service name"
+// @Param limit query int false "Page size, 100 by default and at most 1000"
+// @Param cursor query string false "Cursor from the previous page"
+// @Success 200 {object} model.SubscriptionPageV2
+// @Failure 400
+// @Failure 403
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /v2/subscriptions [get]
+func (h *HandlerV2) List(c echo.Context) error {
+	filter, err := parseFilter(c)
+	if err != nil {
+		h.logger.Error("list error", "error", err)
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	limit := defaultPageLimit
+	if s := c.QueryParam("limit"); s != "" {
+		if limit, err = strconv.Atoi(s); err != nil || limit <= 0 {
+			return c.JSON(http.StatusBadRequest, "invalid limit")
+		}
+	}
+	limit = min(limit, maxPageLimit)
+
+	if s := c.QueryParam("cursor"); s != "" {
+		if filter.AfterID, err = strconv.ParseInt(s, 10, 64); err != nil || filter.AfterID < 0 {
+			return c.JSON(http.StatusBadRequest, "invalid cursor")
+		}
+	}
+	// one extra row tells whether there is a next page
+	filter.Limit = limit + 1
+
+	r, err := access(c, h.policy, h.repository, false, filter.UserID)
+	if err != nil {
+		return c.JSON(http.StatusForbidden, err.Error())
+	}
+
+	subs, err := r.GetAll(filter)
+	if err != nil {
+		h.logger.Error("list error", "error", err)
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+
+	page := model.SubscriptionPageV2{Items: []model.SubscriptionV2{}}
+	if len(subs) > limit {
+		subs = subs[:limit]
+		page.Next = strconv.FormatInt(subs[limit-1].ID, 10)
+	}
+
+	var userIDs []uuid.UUID
+	seen := map[uuid.UUID]bool{}
+	for _, sub := range subs {
+		if !seen[sub.UserID] {
+			seen[sub.UserID] = true
+			userIDs = append(userIDs, sub.UserID)
+		}
+	}
+	currencies, err := h.currencies(c, userIDs)
+	if err != nil {
+		h.logger.Error("list error", "error", err)
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+
+	for i := range subs {
+		page.Items = append(page.Items, toV2(&subs[i], currencies[subs[i].UserID]))
+	}
+	return c.JSON(http.StatusOK, page)
+}
+
+// GetByID godoc
+// @Summary Get subscription by ID
+// @Tags subscriptions-v2
+// @Produce json
+// @Param id path int true "ID"
+// @Success 200 {object} model.SubscriptionV2
+// @Failure 400
+// @Failure 403
+// @Failure 404
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /v2/subscriptions/{id} [get]
+func (h *HandlerV2) GetByID(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		h.logger.Error("get by id error", "error", err)
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	r, err := access(c, h.policy, h.repository, false, uuid.NullUUID{})
+	if err != nil {
+		return c.JSON(http.StatusForbidden, err.Error())
+	}
+
+	sub, err := r.GetByID(id)
+	if err != nil {
+		h.logger.Error("get by id error", "error", err)
+		return c.JSON(repoErrorStatus(err), err.Error())
+	}
+
+	currency, _, err := preferences(c, h.users, sub.UserID)
+	if err != nil {
+		h.logger.Error("get by id error", "error", err)
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+
+	c.Response().Header().Set("ETag", versionETag(sub.Version))
+	return c.JSON(http.StatusOK, toV2(sub, currency))
+}
+
+// Update godoc
+// @Summary Update subscription by ID
+// @Tags subscriptions-v2
+// @Accept json
+// @Produce json
+// @Param id path int true "ID"
+// @Param If-Match header string false "ETag of subscription being updated"
+// @Param subscription body model.SubscriptionV2 true "Subscription, id is ignored"
+// @Success 200 {object} model.SubscriptionV2
+// @Failure 400
+// @Failure 403
+// @Failure 404
+// @Failure 412
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /v2/subscriptions/{id} [put]
+func (h *HandlerV2) Update(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		h.logger.Error("update error", "error", err)
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	version, err := ifMatchVersion(c)
+	if err != nil {
+		h.logger.Error("update error", "error", err)
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	sub, currency, err := h.bind(c)
+	if err != nil {
+		h.logger.Error("update error", "error", err)
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	sub.ID = int64(id)
+
+	r, err := access(c, h.policy, h.repository, true, ownerOf(sub.UserID))
+	if err != nil {
+		return c.JSON(http.StatusForbidden, err.Error())
+	}
+
+	if err := r.Update(&sub, version); err != nil {
+		h.logger.Error("update error", "error", err)
+		return c.JSON(repoErrorStatus(err), err.Error())
+	}
+
+	h.logger.Info("subscription updated", "service_id", id, "version", sub.Version)
+	c.Response().Header().Set("ETag", versionETag(sub.Version))
+	return c.JSON(http.StatusOK, toV2(&sub, currency))
+}
+
+// Delete godoc
+// @Summary Delete subscription by ID
+// @Tags subscriptions-v2
+// @Param id path int true "ID"
+// @Param If-Match header string false "ETag of subscription being deleted"
+// @Success 204
+// @Failure 400
+// @Failure 403
+// @Failure 404
+// @Failure 412
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /v2/subscriptions/{id} [delete]
+func (h *HandlerV2) Delete(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		h.logger.Error("delete error", "error", err)
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	version, err := ifMatchVersion(c)
+	if err != nil {
+		h.logger.Error("delete error", "error", err)
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	r, err := access(c, h.policy, h.repository, true, uuid.NullUUID{})
+	if err != nil {
+		return c.JSON(http.StatusForbidden, err.Error())
+	}
+
+	if err := r.Delete(id, version); err != nil {
+		h.logger.Error("delete error", "error", err)
+		return c.JSON(repoErrorStatus(err), err.Error())
+	}
+
+	h.logger.Info("subscription deleted", "service_id", id)
+	return c.NoContent(http.StatusNoContent)
+}
+
+// TotalCost godoc
+// @Summary Total of all subscriptions
+// @Description Counts whole months containing start and end dates, shared subscriptions count with the user's share only. End defaults to today in the user's timezone and start to end.
+// @Tags subscriptions-v2
+// @Produce json
+// @Param user query string false "User ID, defaults to authenticated user"
+// @Param service query string false "Service name"
+// @Param start query string false "Start date (YYYY-MM-DD)"
+// @Param end query string false "End date (YYYY-MM-DD)"
+// @Success 200 {object} model.TotalV2
+// @Failure 400
+// @Failure 403
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /v2/subscriptions/total [get]
+func (h *HandlerV2) TotalCost(c echo.Context) error {
+	owner, ok := auth.UserIDFromContext(c.Request().Context())
+	if user := c.QueryParam("user"); user != "" {
+		var err error
+		if owner, err = uuid.Parse(user); err != nil {
+			return c.JSON(http.StatusBadRequest, fmt.Sprintf("invalid user: %v", err))
+		}
+	} else if !ok {
+		return c.JSON(http.StatusBadRequest, "user is required")
+	}
+
+	start, err := monthOf(c.QueryParam("start"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	end, err := monthOf(c.QueryParam("end"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	r, err := access(c, h.policy, h.repository, false, ownerOf(owner))
+	if err != nil {
+		return c.JSON(http.StatusForbidden, err.Error())
+	}
+
+	currency, location, err := preferences(c, h.users, owner)
+	if err != nil {
+		h.logger.Error("total cost error", "error", err)
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	if end == "" {
+		end = time.Now().In(location).Format(monthDate)
+	}
+	if start == "" {
+		start = end
+	}
+
+	total, err := r.TotalCost(owner.String(), c.QueryParam("service"), start, end)
+	if err != nil {
+		h.logger.Error("total cost error", "error", err)
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, model.TotalV2{
+		Total: model.Money{Amount: total, Currency: currency},
+		Start: firstDay(start),
+		End: lastDay(end),
+	})
+}
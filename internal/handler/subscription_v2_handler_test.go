@@ -0,0 +1,114 @@
+package handler_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"log/slog"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/teamcutter/subscriptions-service-task/internal/auth"
+	"github.com/teamcutter/subscriptions-service-task/internal/authz"
+	"github.com/teamcutter/subscriptions-service-task/internal/handler"
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+)
+
+func setupV2Test(t *testing.T) (*echo.Echo, *MockRepo, *handler.HandlerV2) {
+	log := slog.Default()
+	users := fakeUsers{mockUUID1: {ID: mockUUID1, Currency: "EUR", Timezone: "UTC"}}
+	mockRepo := new(MockRepo)
+	return echo.New(), mockRepo, handler.NewHandlerV2(mockRepo, users, authz.NewPolicy(authz.DefaultRoles, nil, log), log)
+}
+
+func v2Request(method, target, body string) *http.Request {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	return req.WithContext(auth.WithUserID(req.Context(), mockUUID1))
+}
+
+func TestCreateV2(t *testing.T) {
+	e, repo, h := setupV2Test(t)
+
+	expected := &model.Subscription{ServiceName: "Netflix", Price: 500, UserID: mockUUID1, StartDate: "07-2025", EndDate: "12-2025"}
+	repo.On("Create", expected).Run(func(args mock.Arguments) {
+		args.Get(0).(*model.Subscription).ID = 7
+	}).Return(nil)
+
+	rec := httptest.NewRecorder()
+	c := e.NewContext(v2Request(http.MethodPost, "/v2/subscriptions",
+		`{"service_name":"Netflix","price":{"amount":500},"start_date":"2025-07-15","end_date":"2025-12-01"}`), rec)
+
+	if assert.NoError(t, h.Create(c)) {
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.Equal(t, "/v2/subscriptions/7", rec.Header().Get(echo.HeaderLocation))
+		assert.JSONEq(t, `{"id":7,"service_name":"Netflix","price":{"amount":500,"currency":"EUR"},
+			"user_id":"`+mockUUID1.String()+`","start_date":"2025-07-01","end_date":"2025-12-31"}`, rec.Body.String())
+	}
+}
+
+func TestCreateV2RejectsOtherCurrency(t *testing.T) {
+	e, repo, h := setupV2Test(t)
+
+	rec := httptest.NewRecorder()
+	c := e.NewContext(v2Request(http.MethodPost, "/v2/subscriptions",
+		`{"service_name":"Netflix","price":{"amount":500,"currency":"USD"},"start_date":"2025-07-01"}`), rec)
+
+	if assert.NoError(t, h.Create(c)) {
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		repo.AssertNotCalled(t, "Create", mock.Anything)
+	}
+}
+
+func TestListV2Pages(t *testing.T) {
+	e, repo, h := setupV2Test(t)
+
+	owner := uuid.NullUUID{UUID: mockUUID1, Valid: true}
+	repo.On("GetAll", model.SubscriptionFilter{UserID: owner, AfterID: 3, Limit: 3}).Return([]model.Subscription{
+		{ID: 4, UserID: mockUUID1, ServiceName: "Netflix", StartDate: "01-2025"},
+		{ID: 5, UserID: mockUUID1, ServiceName: "Spotify", StartDate: "02-2025"},
+		{ID: 6, UserID: mockUUID1, ServiceName: "Yandex", StartDate: "03-2025"},
+	}, nil)
+
+	rec := httptest.NewRecorder()
+	c := e.NewContext(v2Request(http.MethodGet, "/v2/subscriptions?limit=2&cursor=3", ""), rec)
+
+	if assert.NoError(t, h.List(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"items":[
+			{"id":4,"service_name":"Netflix","price":{"amount":0,"currency":"EUR"},"user_id":"`+mockUUID1.String()+`","start_date":"2025-01-01"},
+			{"id":5,"service_name":"Spotify","price":{"amount":0,"currency":"EUR"},"user_id":"`+mockUUID1.String()+`","start_date":"2025-02-01"}
+		],"next":"5"}`, rec.Body.String())
+	}
+}
+
+func TestTotalCostV2(t *testing.T) {
+	e, repo, h := setupV2Test(t)
+
+	repo.On("TotalCost", mockUUID1.String(), "", "01-2025", "03-2025").Return(900, nil)
+
+	rec := httptest.NewRecorder()
+	c := e.NewContext(v2Request(http.MethodGet, "/v2/subscriptions/total?start=2025-01-10&end=2025-03-05", ""), rec)
+
+	if assert.NoError(t, h.TotalCost(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"total":{"amount":900,"currency":"EUR"},"start":"2025-01-01","end":"2025-03-31"}`, rec.Body.String())
+	}
+}
+
+func TestTotalCostV2InvalidDate(t *testing.T) {
+	e, repo, h := setupV2Test(t)
+
+	rec := httptest.NewRecorder()
+	c := e.NewContext(v2Request(http.MethodGet, "/v2/subscriptions/total?start=01-2025", ""), rec)
+
+	if assert.NoError(t, h.TotalCost(c)) {
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		repo.AssertNotCalled(t, "TotalCost", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	}
+}
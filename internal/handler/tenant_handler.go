@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"regexp"
+
+	"github.com/labstack/echo/v4"
+	"github.com/teamcutter/subscriptions-service-task/internal/auth"
+	"github.com/teamcutter/subscriptions-service-task/internal/authz"
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+	"github.com/teamcutter/subscriptions-service-task/internal/repo"
+)
+
+var tenantIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+type TenantHandler struct {
+	tenants repo.TenantRepo
+	policy *authz.Policy
+	logger *slog.Logger
+}
+
+func NewTenantHandler(tenants repo.TenantRepo, policy *authz.Policy, logger *slog.Logger) *TenantHandler {
+	return &TenantHandler{
+		tenants: tenants,
+		policy: policy,
+		logger: logger,
+	}
+}
+
+// authorize allows only platform principals, tenant admins can not
+// see or change other tenants
+func (h *TenantHandler) authorize(c echo.Context) error {
+	ctx := c.Request().Context()
+	if principal, ok := auth.PrincipalFromContext(ctx); ok && principal.TenantID != "" {
+		return authz.ErrForbidden
+	}
+	return h.policy.Authorize(ctx, authz.ManageTenants, c.Request().Method+" "+c.Path())
+}
+
+func tenantStatus(err error) int {
+	switch {
+	case errors.Is(err, repo.ErrTenantNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, repo.ErrTenantExists):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// Create godoc
+// @Summary Provision tenant
+// @Description Tenant id is 1-63 lowercase letters, digits and dashes.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param tenant body model.Tenant true "Tenant"
+// @Success 201 {object} model.Tenant
+// @Failure 400
+// @Failure 403
+// @Failure 409
+// @Security APIKeyAuth
+// @Router /v1/admin/tenants [post]
+func (h *TenantHandler) Create(c echo.Context) error {
+	if err := h.authorize(c); err != nil {
+		return c.JSON(http.StatusForbidden, err.Error())
+	}
+
+	var tenant model.Tenant
+	if err := c.Bind(&tenant); err != nil {
+		h.logger.Error("JSON binding error", "error", err)
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	if !tenantIDPattern.MatchString(tenant.ID) || tenant.Name == "" {
+		err := errors.New("valid id and name are required")
+		h.logger.Error("create tenant error", "error", err)
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	if err := h.tenants.Create(&tenant); err != nil {
+		h.logger.Error("create tenant error", "error", err)
+		return c.JSON(tenantStatus(err), err.Error())
+	}
+
+	h.logger.Info("tenant created", "tenant_id", tenant.ID)
+	return c.JSON(http.StatusCreated, tenant)
+}
+
+// List godoc
+// @Summary List tenants
+// @Tags admin
+// @Produce json
+// @Success 200 {array} model.Tenant
+// @Failure 403
+// @Security APIKeyAuth
+// @Router /v1/admin/tenants [get]
+func (h *TenantHandler) List(c echo.Context) error {
+	if err := h.authorize(c); err != nil {
+		return c.JSON(http.StatusForbidden, err.Error())
+	}
+
+	tenants, err := h.tenants.List()
+	if err != nil {
+		h.logger.Error("list tenants error", "error", err)
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, tenants)
+}
+
+// Delete godoc
+// @Summary Delete tenant
+// @Description Deletes tenant with all its subscriptions, import jobs and API keys. Audit records are kept without the tenant.
+// @Tags admin
+// @Param id path string true "Tenant ID"
+// @Success 204
+// @Failure 400
+// @Failure 403
+// @Failure 404
+// @Security APIKeyAuth
+// @Router /v1/admin/tenants/{id} [delete]
+func (h *TenantHandler) Delete(c echo.Context) error {
+	if err := h.authorize(c); err != nil {
+		return c.JSON(http.StatusForbidden, err.Error())
+	}
+
+	id := c.Param("id")
+	if id == model.DefaultTenant {
+		return c.JSON(http.StatusBadRequest, "default tenant can not be deleted")
+	}
+
+	if err := h.tenants.Delete(id); err != nil {
+		h.logger.Error("delete tenant error", "error", err)
+		return c.JSON(tenantStatus(err), err.Error())
+	}
+
+	h.logger.Info("tenant deleted", "tenant_id", id)
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,97 @@
+package handler_test
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/teamcutter/subscriptions-service-task/internal/auth"
+	"github.com/teamcutter/subscriptions-service-task/internal/authz"
+	"github.com/teamcutter/subscriptions-service-task/internal/handler"
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+)
+
+type MockTenantRepo struct {
+	mock.Mock
+}
+
+func (m *MockTenantRepo) Create(tenant *model.Tenant) error {
+	args := m.Called(tenant)
+	return args.Error(0)
+}
+
+func (m *MockTenantRepo) List() ([]model.Tenant, error) {
+	args := m.Called()
+	return args.Get(0).([]model.Tenant), args.Error(1)
+}
+
+func (m *MockTenantRepo) Delete(id string) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockTenantRepo) Exists(id string) (bool, error) {
+	args := m.Called(id)
+	return args.Bool(0), args.Error(1)
+}
+
+func setupTenantTest() (*echo.Echo, *MockTenantRepo, *handler.TenantHandler) {
+	tenants := new(MockTenantRepo)
+	h := handler.NewTenantHandler(tenants, authz.NewPolicy(authz.DefaultRoles, nil, slog.Default()), slog.Default())
+	return echo.New(), tenants, h
+}
+
+func TestCreateTenant(t *testing.T) {
+	e, tenants, h := setupTenantTest()
+
+	tenants.On("Create", &model.Tenant{ID: "acme", Name: "Acme"}).Return(nil)
+
+	for body, status := range map[string]int{
+		`{"id":"acme","name":"Acme"}`: http.StatusCreated,
+		`{"id":"Acme Inc","name":"Acme"}`: http.StatusBadRequest,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/admin/tenants", bytes.NewBufferString(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req = req.WithContext(auth.WithPrincipal(req.Context(), auth.Principal{APIKeyID: 1, Scopes: auth.Scopes}))
+		rec := httptest.NewRecorder()
+
+		if assert.NoError(t, h.Create(e.NewContext(req, rec))) {
+			assert.Equal(t, status, rec.Code, body)
+		}
+	}
+	tenants.AssertNumberOfCalls(t, "Create", 1)
+}
+
+func TestTenantsForbiddenToTenantAdmin(t *testing.T) {
+	e, tenants, h := setupTenantTest()
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/tenants", nil)
+	req = req.WithContext(auth.WithPrincipal(req.Context(), auth.Principal{TenantID: "acme", Role: auth.RoleAdmin}))
+	rec := httptest.NewRecorder()
+
+	if assert.NoError(t, h.List(e.NewContext(req, rec))) {
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		tenants.AssertNotCalled(t, "List")
+	}
+}
+
+func TestDeleteDefaultTenant(t *testing.T) {
+	e, tenants, h := setupTenantTest()
+
+	req := systemRequest(http.MethodDelete, "/admin/tenants/default", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues(model.DefaultTenant)
+
+	if assert.NoError(t, h.Delete(c)) {
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		tenants.AssertNotCalled(t, "Delete", mock.Anything)
+	}
+}
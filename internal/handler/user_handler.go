@@ -0,0 +1,282 @@
+package handler
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/mail"
+	"regexp"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/teamcutter/subscriptions-service-task/internal/auth"
+	"github.com/teamcutter/subscriptions-service-task/internal/authz"
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+	"github.com/teamcutter/subscriptions-service-task/internal/privacy"
+	"github.com/teamcutter/subscriptions-service-task/internal/repo"
+)
+
+var currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+
+type UserHandler struct {
+	users repo.UserRepo
+	data repo.UserDataRepo
+	signer *privacy.Signer
+	policy *authz.Policy
+	logger *slog.Logger
+}
+
+func NewUserHandler(users repo.UserRepo, data repo.UserDataRepo, signer *privacy.Signer, policy *authz.Policy, logger *slog.Logger) *UserHandler {
+	return &UserHandler{
+		users: users,
+		data: data,
+		signer: signer,
+		policy: policy,
+		logger: logger,
+	}
+}
+
+func userStatus(err error) int {
+	switch {
+	case errors.Is(err, authz.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, repo.ErrUserNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, repo.ErrEmailTaken):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// target returns tenant repository and user of id param, "me" stands for
+// the authenticated user, if caller may read or change the user
+func (h *UserHandler) target(c echo.Context, write bool) (repo.UserRepo, uuid.UUID, error) {
+	ctx := c.Request().Context()
+
+	var id uuid.UUID
+	if param := c.Param("id"); param == "me" {
+		userID, ok := auth.UserIDFromContext(ctx)
+		if !ok {
+			return nil, uuid.Nil, repo.ErrUserNotFound
+		}
+		id = userID
+	} else {
+		var err error
+		if id, err = uuid.Parse(param); err != nil {
+			return nil, uuid.Nil, repo.ErrUserNotFound
+		}
+	}
+
+	if _, err := authorizeOwner(c, h.policy, write, ownerOf(id)); err != nil {
+		return nil, uuid.Nil, err
+	}
+	return h.users.ForTenant(auth.TenantFromContext(ctx)), id, nil
+}
+
+// validateUser fills defaults and checks profile fields
+func validateUser(user *model.User) error {
+	if user.Currency == "" {
+		user.Currency = model.DefaultCurrency
+	}
+	if user.Timezone == "" {
+		user.Timezone = model.DefaultTimezone
+	}
+
+	if user.Email != "" {
+		if _, err := mail.ParseAddress(user.Email); err != nil {
+			return fmt.Errorf("invalid email: %w", err)
+		}
+	}
+	if !currencyPattern.MatchString(user.Currency) {
+		return fmt.Errorf("invalid currency %q, ISO 4217 code expected", user.Currency)
+	}
+	if _, err := time.LoadLocation(user.Timezone); err != nil {
+		return fmt.Errorf("invalid timezone: %w", err)
+	}
+	if user.Notifications.RemindDaysBefore < 0 {
+		return errors.New("remind_days_before must not be negative")
+	}
+	return nil
+}
+
+// Get godoc
+// @Summary Get user profile
+// @Tags users
+// @Produce json
+// @Param id path string true "User ID or me"
+// @Success 200 {object} model.User
+// @Failure 403
+// @Failure 404
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /v1/users/{id} [get]
+func (h *UserHandler) Get(c echo.Context) error {
+	users, id, err := h.target(c, false)
+	if err != nil {
+		return c.JSON(userStatus(err), err.Error())
+	}
+
+	user, err := users.Get(id)
+	if err != nil {
+		if !errors.Is(err, repo.ErrUserNotFound) {
+			h.logger.Error("get user error", "error", err)
+		}
+		return c.JSON(userStatus(err), err.Error())
+	}
+	return c.JSON(http.StatusOK, user)
+}
+
+// Save godoc
+// @Summary Create or replace user profile
+// @Description Currency defaults to RUB and timezone to UTC.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param id path string true "User ID or me"
+// @Param user body model.User true "Profile"
+// @Success 200 {object} model.User
+// @Failure 400
+// @Failure 403
+// @Failure 409
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /v1/users/{id} [put]
+func (h *UserHandler) Save(c echo.Context) error {
+	users, id, err := h.target(c, true)
+	if err != nil {
+		return c.JSON(userStatus(err), err.Error())
+	}
+
+	var user model.User
+	if err := c.Bind(&user); err != nil {
+		h.logger.Error("JSON binding error", "error", err)
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	user.ID = id
+
+	if err := validateUser(&user); err != nil {
+		h.logger.Error("save user error", "error", err)
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	if err := users.Save(&user); err != nil {
+		h.logger.Error("save user error", "error", err)
+		return c.JSON(userStatus(err), err.Error())
+	}
+
+	h.logger.Info("user saved", "user_id", user.ID)
+	return c.JSON(http.StatusOK, user)
+}
+
+// Export godoc
+// @Summary Export all data of user
+// @Description ZIP archive with JSON files of profile, subscriptions, price history, splits, households, import jobs, notifications, webhook deliveries and audit entries made by the user.
+// @Tags users
+// @Produce application/zip
+// @Param id path string true "User ID or me"
+// @Success 200
+// @Failure 403
+// @Failure 404
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /v1/users/{id}/export [get]
+func (h *UserHandler) Export(c echo.Context) error {
+	_, id, err := h.target(c, false)
+	if err != nil {
+		return c.JSON(userStatus(err), err.Error())
+	}
+
+	data, err := h.data.ForTenant(auth.TenantFromContext(c.Request().Context())).Export(id)
+	if err != nil {
+		if !errors.Is(err, repo.ErrUserNotFound) {
+			h.logger.Error("export user data error", "error", err)
+		}
+		return c.JSON(userStatus(err), err.Error())
+	}
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "application/zip")
+	res.Header().Set(echo.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="user-%s.zip"`, id))
+	res.WriteHeader(http.StatusOK)
+
+	archive := zip.NewWriter(res)
+	for _, file := range []struct {
+		name string
+		content any
+	}{
+		{"profile.json", data.Profile},
+		{"subscriptions.json", data.Subscriptions},
+		{"price_history.json", data.Prices},
+		{"splits.json", data.Splits},
+		{"households.json", data.Households},
+		{"import_jobs.json", data.ImportJobs},
+		{"notifications.json", data.Notifications},
+		{"webhook_deliveries.json", data.WebhookDeliveries},
+		{"audit_log.json", data.Audit},
+	} {
+		w, err := archive.Create(file.name)
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(file.content); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+// Delete godoc
+// @Summary Erase all data of user
+// @Description Subscriptions, price history, memberships, notifications, import jobs, webhook deliveries and profile are deleted and audit entries anonymised in one transaction, deletion of subscriptions is sent as events that do not name the user. Owned households pass to another member. The receipt is signed and does not contain the user id.
+// @Tags users
+// @Produce json
+// @Param id path string true "User ID or me"
+// @Success 200 {object} model.ErasureReceipt
+// @Failure 403
+// @Failure 404
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /v1/users/{id} [delete]
+func (h *UserHandler) Delete(c echo.Context) error {
+	_, id, err := h.target(c, true)
+	if err != nil {
+		return c.JSON(userStatus(err), err.Error())
+	}
+
+	ctx := c.Request().Context()
+	tenantID := auth.TenantFromContext(ctx)
+	receipt := privacy.NewReceipt(tenantID, id)
+
+	entry := &model.AuditEntry{
+		Action: model.AuditErasure,
+		Resource: "erasure:" + receipt.ID.String(),
+		Outcome: model.AuditAllowed,
+	}
+	if principal, ok := auth.PrincipalFromContext(ctx); ok {
+		entry.Actor = principal.String()
+	}
+
+	receipt.Erased, err = h.data.ForTenant(tenantID).Erase(id, entry)
+	if err != nil {
+		if !errors.Is(err, repo.ErrUserNotFound) {
+			h.logger.Error("erase user error", "error", err)
+		}
+		return c.JSON(userStatus(err), err.Error())
+	}
+
+	if err := h.signer.Sign(receipt); err != nil {
+		h.logger.Error("sign erasure receipt error", "error", err)
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+
+	// the user id is personal data, only the receipt is logged
+	h.logger.Info("user data erased", "receipt_id", receipt.ID, "tenant_id", tenantID)
+	return c.JSON(http.StatusOK, receipt)
+}
@@ -0,0 +1,258 @@
+package handler_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/teamcutter/subscriptions-service-task/internal/auth"
+	"github.com/teamcutter/subscriptions-service-task/internal/authz"
+	"github.com/teamcutter/subscriptions-service-task/internal/codec"
+	"github.com/teamcutter/subscriptions-service-task/internal/handler"
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+	"github.com/teamcutter/subscriptions-service-task/internal/privacy"
+	repoPkg "github.com/teamcutter/subscriptions-service-task/internal/repo"
+)
+
+// fakeUsers keeps profiles in memory
+type fakeUsers map[uuid.UUID]*model.User
+
+func (u fakeUsers) Get(id uuid.UUID) (*model.User, error) {
+	user, ok := u[id]
+	if !ok {
+		return nil, repoPkg.ErrUserNotFound
+	}
+	return user, nil
+}
+
+func (u fakeUsers) GetMany(ids []uuid.UUID) ([]model.User, error) {
+	var users []model.User
+	for _, id := range ids {
+		if user, ok := u[id]; ok {
+			users = append(users, *user)
+		}
+	}
+	return users, nil
+}
+
+func (u fakeUsers) Save(user *model.User) error {
+	u[user.ID] = user
+	return nil
+}
+
+func (u fakeUsers) SaveMany(users []model.User) error {
+	for i := range users {
+		u[users[i].ID] = &users[i]
+	}
+	return nil
+}
+
+func (u fakeUsers) Missing(ids []uuid.UUID) ([]uuid.UUID, error) {
+	var missing []uuid.UUID
+	for _, id := range ids {
+		if _, ok := u[id]; !ok {
+			missing = append(missing, id)
+		}
+	}
+	return missing, nil
+}
+
+func (u fakeUsers) Ensure(ids []uuid.UUID) error {
+	for _, id := range ids {
+		if _, ok := u[id]; !ok {
+			u[id] = &model.User{ID: id, Currency: model.DefaultCurrency, Timezone: model.DefaultTimezone}
+		}
+	}
+	return nil
+}
+
+func (u fakeUsers) ForTenant(string) repoPkg.UserRepo {
+	return u
+}
+
+type MockUserDataRepo struct {
+	mock.Mock
+}
+
+func (m *MockUserDataRepo) Export(id uuid.UUID) (*model.UserData, error) {
+	args := m.Called(id)
+	data, _ := args.Get(0).(*model.UserData)
+	return data, args.Error(1)
+}
+
+func (m *MockUserDataRepo) Erase(id uuid.UUID, entry *model.AuditEntry) (map[string]int64, error) {
+	args := m.Called(id, entry)
+	erased, _ := args.Get(0).(map[string]int64)
+	return erased, args.Error(1)
+}
+
+func (m *MockUserDataRepo) ForTenant(string) repoPkg.UserDataRepo {
+	return m
+}
+
+var testSigner = privacy.NewSigner([]byte("test-key"))
+
+func newUserHandler(users fakeUsers, data *MockUserDataRepo) *handler.UserHandler {
+	return handler.NewUserHandler(users, data, testSigner, authz.NewPolicy(authz.DefaultRoles, nil, slog.Default()), slog.Default())
+}
+
+func TestSaveMe(t *testing.T) {
+	e := echo.New()
+	users := fakeUsers{}
+	h := newUserHandler(users, new(MockUserDataRepo))
+
+	for body, status := range map[string]int{
+		`{"name":"Ann","email":"ann@example.com","timezone":"Europe/Moscow"}`: http.StatusOK,
+		`{"name":"Ann","email":"not an email"}`: http.StatusBadRequest,
+		`{"name":"Ann","timezone":"Mars/Olympus"}`: http.StatusBadRequest,
+		`{"name":"Ann","currency":"rubles"}`: http.StatusBadRequest,
+	} {
+		req := httptest.NewRequest(http.MethodPut, "/users/me", bytes.NewBufferString(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req = req.WithContext(auth.WithUserID(req.Context(), mockUUID1))
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues("me")
+
+		if assert.NoError(t, h.Save(c)) {
+			assert.Equal(t, status, rec.Code, body)
+		}
+	}
+
+	if assert.Contains(t, users, mockUUID1) {
+		assert.Equal(t, model.DefaultCurrency, users[mockUUID1].Currency)
+		assert.Equal(t, "Europe/Moscow", users[mockUUID1].Timezone)
+	}
+}
+
+func TestGetOtherUserForbidden(t *testing.T) {
+	e := echo.New()
+	h := newUserHandler(fakeUsers{}, new(MockUserDataRepo))
+
+	req := httptest.NewRequest(http.MethodGet, "/users/"+mockUUID2.String(), nil)
+	req = req.WithContext(auth.WithUserID(req.Context(), mockUUID1))
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues(mockUUID2.String())
+
+	if assert.NoError(t, h.Get(c)) {
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	}
+}
+
+func TestTotalCostUsesPreferences(t *testing.T) {
+	e := echo.New()
+	repo := new(MockRepo)
+	users := fakeUsers{mockUUID1: {ID: mockUUID1, Currency: "EUR", Timezone: "Pacific/Kiritimati"}}
+	h := handler.NewHandler(repo, users, codec.Default(), authz.NewPolicy(authz.DefaultRoles, nil, slog.Default()), slog.Default())
+
+	location, _ := time.LoadLocation("Pacific/Kiritimati")
+	month := time.Now().In(location).Format("01-2006")
+	repo.On("TotalCost", mockUUID1.String(), "", month, month).Return(300, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/subscriptions/total", nil)
+	req = req.WithContext(auth.WithUserID(req.Context(), mockUUID1))
+	rec := httptest.NewRecorder()
+
+	if assert.NoError(t, h.TotalCost(e.NewContext(req, rec))) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"total":300,"currency":"EUR"}`, rec.Body.String())
+		repo.AssertCalled(t, "TotalCost", mockUUID1.String(), "", month, month)
+	}
+}
+
+func TestExportMe(t *testing.T) {
+	e := echo.New()
+	data := new(MockUserDataRepo)
+	h := newUserHandler(fakeUsers{}, data)
+
+	data.On("Export", mockUUID1).Return(&model.UserData{
+		Profile: &model.User{ID: mockUUID1, Name: "Ann"},
+		Subscriptions: []model.Subscription{{ServiceName: "Netflix", Price: 500, UserID: mockUUID1, StartDate: "01-2025"}},
+	}, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/users/me/export", nil)
+	req = req.WithContext(auth.WithUserID(req.Context(), mockUUID1))
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("me")
+
+	require.NoError(t, h.Export(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/zip", rec.Header().Get(echo.HeaderContentType))
+
+	archive, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+	require.NoError(t, err)
+	files := map[string]*zip.File{}
+	for _, f := range archive.File {
+		files[f.Name] = f
+	}
+	assert.Len(t, files, 9)
+	assert.Contains(t, files, "price_history.json")
+
+	if assert.Contains(t, files, "subscriptions.json") {
+		r, err := files["subscriptions.json"].Open()
+		require.NoError(t, err)
+		var subs []model.Subscription
+		require.NoError(t, json.NewDecoder(r).Decode(&subs))
+		assert.Equal(t, "Netflix", subs[0].ServiceName)
+	}
+}
+
+func TestEraseMe(t *testing.T) {
+	e := echo.New()
+	data := new(MockUserDataRepo)
+	h := newUserHandler(fakeUsers{}, data)
+
+	data.On("Erase", mockUUID1, mock.MatchedBy(func(entry *model.AuditEntry) bool {
+		return entry.Action == model.AuditErasure && entry.Actor == "user:"+mockUUID1.String()
+	})).Return(map[string]int64{"subscriptions": 2, "users": 1}, nil)
+
+	req := httptest.NewRequest(http.MethodDelete, "/users/me", nil)
+	req = req.WithContext(auth.WithUserID(req.Context(), mockUUID1))
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("me")
+
+	require.NoError(t, h.Delete(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, rec.Body.String(), mockUUID1.String())
+
+	var receipt model.ErasureReceipt
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &receipt))
+	assert.Equal(t, int64(2), receipt.Erased["subscriptions"])
+	assert.Equal(t, privacy.Subject(model.DefaultTenant, mockUUID1), receipt.Subject)
+	assert.True(t, testSigner.Verify(receipt))
+}
+
+func TestEraseOtherUserForbidden(t *testing.T) {
+	e := echo.New()
+	data := new(MockUserDataRepo)
+	h := newUserHandler(fakeUsers{}, data)
+
+	req := httptest.NewRequest(http.MethodDelete, "/users/"+mockUUID2.String(), nil)
+	req = req.WithContext(auth.WithUserID(req.Context(), mockUUID1))
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues(mockUUID2.String())
+
+	require.NoError(t, h.Delete(c))
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	data.AssertNotCalled(t, "Erase", mock.Anything, mock.Anything)
+}
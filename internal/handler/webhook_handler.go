@@ -0,0 +1,253 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/teamcutter/subscriptions-service-task/internal/auth"
+	"github.com/teamcutter/subscriptions-service-task/internal/authz"
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+	"github.com/teamcutter/subscriptions-service-task/internal/repo"
+	"github.com/teamcutter/subscriptions-service-task/internal/webhook"
+)
+
+// deliveriesLimit caps listed deliveries of a webhook
+const deliveriesLimit = 100
+
+type WebhookHandler struct {
+	webhooks repo.WebhookRepo
+	targets webhook.Targets
+	policy *authz.Policy
+	logger *slog.Logger
+}
+
+func NewWebhookHandler(webhooks repo.WebhookRepo, targets webhook.Targets, policy *authz.Policy, logger *slog.Logger) *WebhookHandler {
+	return &WebhookHandler{
+		webhooks: webhooks,
+		targets: targets,
+		policy: policy,
+		logger: logger,
+	}
+}
+
+// authorize returns webhooks of the request tenant if caller may manage them
+func (h *WebhookHandler) authorize(c echo.Context) (repo.WebhookRepo, error) {
+	ctx := c.Request().Context()
+	if err := h.policy.Authorize(ctx, authz.ManageWebhooks, c.Request().Method+" "+c.Path()); err != nil {
+		return nil, err
+	}
+	return h.webhooks.ForTenant(auth.TenantFromContext(ctx)), nil
+}
+
+func webhookStatus(err error) int {
+	switch {
+	case errors.Is(err, authz.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, repo.ErrWebhookNotFound), errors.Is(err, repo.ErrDeliveryNotFound):
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+type createWebhookRequest struct {
+	URL string `json:"url"`
+	// Events to send, all events when empty
+	Events []string `json:"events"`
+}
+
+func (h *WebhookHandler) validateWebhook(ctx context.Context, req createWebhookRequest) error {
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("url must be an absolute http or https URL")
+	}
+	for _, event := range req.Events {
+		if !slices.Contains(model.Events, event) {
+			return fmt.Errorf("unknown event %q", event)
+		}
+	}
+	// the dispatcher checks addresses again when it dials
+	return h.targets.Check(ctx, req.URL)
+}
+
+// Create godoc
+// @Summary Create webhook
+// @Description The secret signing payloads is returned only in this response. URLs resolving to loopback, private or link-local addresses are refused.
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param webhook body createWebhookRequest true "Webhook"
+// @Success 201 {object} model.Webhook
+// @Failure 400
+// @Failure 403
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /v1/webhooks [post]
+func (h *WebhookHandler) Create(c echo.Context) error {
+	webhooks, err := h.authorize(c)
+	if err != nil {
+		return c.JSON(webhookStatus(err), err.Error())
+	}
+
+	var req createWebhookRequest
+	if err := c.Bind(&req); err != nil {
+		h.logger.Error("JSON binding error", "error", err)
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	if err := h.validateWebhook(c.Request().Context(), req); err != nil {
+		h.logger.Error("create webhook error", "error", err)
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	secret, err := webhook.GenerateSecret()
+	if err != nil {
+		h.logger.Error("create webhook error", "error", err)
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+
+	hook := model.Webhook{URL: req.URL, Events: req.Events, Secret: secret}
+	if err := webhooks.Create(&hook); err != nil {
+		h.logger.Error("create webhook error", "error", err)
+		return c.JSON(webhookStatus(err), err.Error())
+	}
+
+	h.logger.Info("webhook created", "webhook_id", hook.ID, "tenant_id", hook.TenantID, "events", hook.Events)
+	return c.JSON(http.StatusCreated, hook)
+}
+
+// List godoc
+// @Summary List webhooks
+// @Tags webhooks
+// @Produce json
+// @Success 200 {array} model.Webhook
+// @Failure 403
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /v1/webhooks [get]
+func (h *WebhookHandler) List(c echo.Context) error {
+	webhooks, err := h.authorize(c)
+	if err != nil {
+		return c.JSON(webhookStatus(err), err.Error())
+	}
+
+	list, err := webhooks.List()
+	if err != nil {
+		h.logger.Error("list webhooks error", "error", err)
+		return c.JSON(webhookStatus(err), err.Error())
+	}
+	return c.JSON(http.StatusOK, list)
+}
+
+// Delete godoc
+// @Summary Delete webhook
+// @Description Pending deliveries of the webhook are dropped.
+// @Tags webhooks
+// @Param id path int true "Webhook ID"
+// @Success 204
+// @Failure 403
+// @Failure 404
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /v1/webhooks/{id} [delete]
+func (h *WebhookHandler) Delete(c echo.Context) error {
+	webhooks, err := h.authorize(c)
+	if err != nil {
+		return c.JSON(webhookStatus(err), err.Error())
+	}
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		h.logger.Error("delete webhook error", "error", err)
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	if err := webhooks.Delete(id); err != nil {
+		h.logger.Error("delete webhook error", "error", err)
+		return c.JSON(webhookStatus(err), err.Error())
+	}
+
+	h.logger.Info("webhook deleted", "webhook_id", id)
+	return c.NoContent(http.StatusNoContent)
+}
+
+// Deliveries godoc
+// @Summary List latest deliveries of webhook
+// @Tags webhooks
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Success 200 {array} model.WebhookDelivery
+// @Failure 403
+// @Failure 404
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /v1/webhooks/{id}/deliveries [get]
+func (h *WebhookHandler) Deliveries(c echo.Context) error {
+	webhooks, err := h.authorize(c)
+	if err != nil {
+		return c.JSON(webhookStatus(err), err.Error())
+	}
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		h.logger.Error("list webhook deliveries error", "error", err)
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	deliveries, err := webhooks.Deliveries(id, deliveriesLimit)
+	if err != nil {
+		if !errors.Is(err, repo.ErrWebhookNotFound) {
+			h.logger.Error("list webhook deliveries error", "error", err)
+		}
+		return c.JSON(webhookStatus(err), err.Error())
+	}
+	return c.JSON(http.StatusOK, deliveries)
+}
+
+// Redeliver godoc
+// @Summary Send delivery again
+// @Description The delivery gets a fresh set of attempts, dead deliveries are revived.
+// @Tags webhooks
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Param delivery_id path int true "Delivery ID"
+// @Success 202 {object} model.WebhookDelivery
+// @Failure 403
+// @Failure 404
+// @Security BearerAuth
+// @Security APIKeyAuth
+// @Router /v1/webhooks/{id}/deliveries/{delivery_id}/redeliver [post]
+func (h *WebhookHandler) Redeliver(c echo.Context) error {
+	webhooks, err := h.authorize(c)
+	if err != nil {
+		return c.JSON(webhookStatus(err), err.Error())
+	}
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		h.logger.Error("redeliver webhook error", "error", err)
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	deliveryID, err := strconv.ParseInt(c.Param("delivery_id"), 10, 64)
+	if err != nil {
+		h.logger.Error("redeliver webhook error", "error", err)
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	delivery, err := webhooks.Redeliver(id, deliveryID)
+	if err != nil {
+		if !errors.Is(err, repo.ErrDeliveryNotFound) {
+			h.logger.Error("redeliver webhook error", "error", err)
+		}
+		return c.JSON(webhookStatus(err), err.Error())
+	}
+
+	h.logger.Info("webhook delivery scheduled again", "webhook_id", id, "delivery_id", deliveryID)
+	return c.JSON(http.StatusAccepted, delivery)
+}
@@ -0,0 +1,125 @@
+package handler_test
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/teamcutter/subscriptions-service-task/internal/auth"
+	"github.com/teamcutter/subscriptions-service-task/internal/authz"
+	"github.com/teamcutter/subscriptions-service-task/internal/handler"
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+	repoPkg "github.com/teamcutter/subscriptions-service-task/internal/repo"
+	"github.com/teamcutter/subscriptions-service-task/internal/webhook"
+)
+
+type MockWebhookRepo struct {
+	mock.Mock
+}
+
+func (m *MockWebhookRepo) Create(webhook *model.Webhook) error {
+	args := m.Called(webhook)
+	return args.Error(0)
+}
+
+func (m *MockWebhookRepo) List() ([]model.Webhook, error) {
+	args := m.Called()
+	return args.Get(0).([]model.Webhook), args.Error(1)
+}
+
+func (m *MockWebhookRepo) Delete(id int64) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockWebhookRepo) Enqueue(event model.Event) error {
+	args := m.Called(event)
+	return args.Error(0)
+}
+
+func (m *MockWebhookRepo) Deliveries(webhookID int64, limit int) ([]model.WebhookDelivery, error) {
+	args := m.Called(webhookID, limit)
+	return args.Get(0).([]model.WebhookDelivery), args.Error(1)
+}
+
+func (m *MockWebhookRepo) Redeliver(webhookID, deliveryID int64) (*model.WebhookDelivery, error) {
+	args := m.Called(webhookID, deliveryID)
+	delivery, _ := args.Get(0).(*model.WebhookDelivery)
+	return delivery, args.Error(1)
+}
+
+func (m *MockWebhookRepo) ForTenant(string) repoPkg.WebhookRepo {
+	return m
+}
+
+func setupWebhookTest() (*echo.Echo, *MockWebhookRepo, *handler.WebhookHandler) {
+	webhooks := new(MockWebhookRepo)
+	h := handler.NewWebhookHandler(webhooks, webhook.Targets{}, authz.NewPolicy(authz.DefaultRoles, nil, slog.Default()), slog.Default())
+	return echo.New(), webhooks, h
+}
+
+func asTenantAdmin(req *http.Request) *http.Request {
+	return req.WithContext(auth.WithPrincipal(req.Context(), auth.Principal{TenantID: "acme", Role: auth.RoleAdmin}))
+}
+
+func TestCreateWebhook(t *testing.T) {
+	e, webhooks, h := setupWebhookTest()
+
+	webhooks.On("Create", mock.MatchedBy(func(w *model.Webhook) bool {
+		return w.URL == "https://203.0.113.10/hook" && strings.HasPrefix(w.Secret, "whsec_")
+	})).Return(nil)
+
+	for body, status := range map[string]int{
+		`{"url":"https://203.0.113.10/hook","events":["subscription.created"]}`: http.StatusCreated,
+		`{"url":"http://127.0.0.1:8080/hook"}`: http.StatusBadRequest,
+		`{"url":"http://169.254.169.254/latest/meta-data"}`: http.StatusBadRequest,
+		`{"url":"example.com/hook"}`: http.StatusBadRequest,
+		`{"url":"ftp://example.com/hook"}`: http.StatusBadRequest,
+		`{"url":"https://example.com/hook","events":["subscription.renamed"]}`: http.StatusBadRequest,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/webhooks", bytes.NewBufferString(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+
+		if assert.NoError(t, h.Create(e.NewContext(asTenantAdmin(req), rec))) {
+			assert.Equal(t, status, rec.Code, body)
+		}
+	}
+	webhooks.AssertNumberOfCalls(t, "Create", 1)
+}
+
+func TestWebhooksForbiddenToUser(t *testing.T) {
+	e, webhooks, h := setupWebhookTest()
+
+	req := httptest.NewRequest(http.MethodGet, "/webhooks", nil)
+	req = req.WithContext(auth.WithPrincipal(req.Context(), auth.Principal{TenantID: "acme", Role: auth.RoleUser}))
+	rec := httptest.NewRecorder()
+
+	if assert.NoError(t, h.List(e.NewContext(req, rec))) {
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		webhooks.AssertNotCalled(t, "List")
+	}
+}
+
+func TestRedeliverNotFound(t *testing.T) {
+	e, webhooks, h := setupWebhookTest()
+
+	webhooks.On("Redeliver", int64(1), int64(42)).Return(nil, repoPkg.ErrDeliveryNotFound)
+
+	req := httptest.NewRequest(http.MethodPost, "/webhooks/1/deliveries/42/redeliver", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(asTenantAdmin(req), rec)
+	c.SetParamNames("id", "delivery_id")
+	c.SetParamValues("1", "42")
+
+	if assert.NoError(t, h.Redeliver(c)) {
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	}
+}
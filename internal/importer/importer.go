@@ -0,0 +1,90 @@
+package importer
+
+import (
+	"io"
+
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+	"github.com/teamcutter/subscriptions-service-task/internal/repo"
+)
+
+const (
+	chunkSize = 500
+	// maxReportedErrors caps row errors kept in a report
+	maxReportedErrors = 1000
+)
+
+type Store interface {
+	Batch([]model.BatchOperation, bool) ([]model.BatchResult, error)
+}
+
+func addError(report *model.ImportReport, line int, err error) {
+	report.Failed++
+	if len(report.Errors) < maxReportedErrors {
+		report.Errors = append(report.Errors, model.ImportRowError{Line: line, Error: err.Error()})
+	}
+}
+
+// Import validates every row and inserts valid ones in chunks,
+// in dry-run mode nothing is inserted
+func Import(r Reader, store Store, dryRun bool) (model.ImportReport, error) {
+	report := model.ImportReport{DryRun: dryRun}
+
+	var ops []model.BatchOperation
+	var lines []int
+	flush := func() error {
+		if len(ops) == 0 {
+			return nil
+		}
+		defer func() {
+			ops, lines = ops[:0], lines[:0]
+		}()
+
+		if dryRun {
+			report.Imported += len(ops)
+			return nil
+		}
+
+		results, err := store.Batch(ops, false)
+		if err != nil {
+			return err
+		}
+		for i, res := range results {
+			if res.Err != nil {
+				addError(&report, lines[i], res.Err)
+				continue
+			}
+			report.Imported++
+		}
+		return nil
+	}
+
+	for {
+		row, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return report, err
+		}
+		report.TotalRows++
+
+		if row.Err == nil {
+			row.Err = repo.Validate(&row.Subscription)
+		}
+		if row.Err != nil {
+			addError(&report, row.Line, row.Err)
+			continue
+		}
+
+		sub := row.Subscription
+		ops = append(ops, model.BatchOperation{Op: model.BatchOpCreate, Subscription: &sub})
+		lines = append(lines, row.Line)
+		if len(ops) == chunkSize {
+			if err := flush(); err != nil {
+				return report, err
+			}
+		}
+	}
+
+	return report, flush()
+}
\ No newline at end of file
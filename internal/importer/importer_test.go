@@ -0,0 +1,74 @@
+package importer_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/teamcutter/subscriptions-service-task/internal/importer"
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+)
+
+var mockUUID uuid.UUID = uuid.New()
+
+type fakeStore struct {
+	created []model.Subscription
+}
+
+func (s *fakeStore) Batch(ops []model.BatchOperation, atomic bool) ([]model.BatchResult, error) {
+	results := make([]model.BatchResult, len(ops))
+	for i, op := range ops {
+		s.created = append(s.created, *op.Subscription)
+		results[i] = model.BatchResult{Index: i, ID: int64(len(s.created))}
+	}
+	return results, nil
+}
+
+func TestImportCSVWithMapping(t *testing.T) {
+	csv := "Service,Cost,User,From,To\n" +
+		"Netflix,400," + mockUUID.String() + ",01-2024,\n" +
+		"Spotify,abc," + mockUUID.String() + ",01-2024,\n" +
+		"HBO,300," + mockUUID.String() + ",2024-01,\n"
+
+	mapping, err := importer.ParseMapping("service_name=Service,price=Cost,user_id=User,start_date=From,end_date=To")
+	require.NoError(t, err)
+
+	reader, err := importer.NewCSVReader(strings.NewReader(csv), mapping)
+	require.NoError(t, err)
+
+	store := &fakeStore{}
+	report, err := importer.Import(reader, store, false)
+	require.NoError(t, err)
+
+	assert.Equal(t, 3, report.TotalRows)
+	assert.Equal(t, 1, report.Imported)
+	assert.Equal(t, 2, report.Failed)
+	assert.Equal(t, 3, report.Errors[0].Line)
+	assert.Equal(t, 4, report.Errors[1].Line)
+	assert.Equal(t, "Netflix", store.created[0].ServiceName)
+	assert.Equal(t, 400, store.created[0].Price)
+}
+
+func TestImportCSVMissingColumn(t *testing.T) {
+	_, err := importer.NewCSVReader(strings.NewReader("service_name,price\n"), nil)
+	assert.Error(t, err)
+}
+
+func TestImportNDJSONDryRun(t *testing.T) {
+	ndjson := `{"service_name":"Netflix","price":400,"user_id":"` + mockUUID.String() + `","start_date":"01-2024"}` + "\n" +
+		"\n" +
+		`{"service_name":"Spotify",` + "\n"
+
+	store := &fakeStore{}
+	report, err := importer.Import(importer.NewNDJSONReader(strings.NewReader(ndjson)), store, true)
+	require.NoError(t, err)
+
+	assert.True(t, report.DryRun)
+	assert.Equal(t, 2, report.TotalRows)
+	assert.Equal(t, 1, report.Imported)
+	assert.Equal(t, 3, report.Errors[0].Line)
+	assert.Empty(t, store.created)
+}
\ No newline at end of file
@@ -0,0 +1,179 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+)
+
+const (
+	FormatCSV = "csv"
+	FormatNDJSON = "ndjson"
+)
+
+// Fields are subscription fields that can be imported, also used
+// as default CSV column names
+var Fields = []string{"service_name", "price", "user_id", "start_date", "end_date", "trial_end_date"}
+
+// optionalFields may be missing from CSV header
+var optionalFields = []string{"end_date", "trial_end_date"}
+
+// Row is one parsed record, Err is set when the record is malformed
+type Row struct {
+	Line int
+	Subscription model.Subscription
+	Err error
+}
+
+// Reader returns rows one by one and io.EOF after the last one
+type Reader interface {
+	Next() (Row, error)
+}
+
+type csvReader struct {
+	r *csv.Reader
+	// columns maps subscription field to its index in a record
+	columns map[string]int
+}
+
+// ParseMapping parses column mapping in form "field=Column,field=Column",
+// fields missing from mapping are read from columns with the same name
+func ParseMapping(s string) (map[string]string, error) {
+	mapping := map[string]string{}
+	if s == "" {
+		return mapping, nil
+	}
+
+	for _, pair := range strings.Split(s, ",") {
+		field, column, ok := strings.Cut(pair, "=")
+		if !ok || !isField(field) || column == "" {
+			return nil, fmt.Errorf("invalid column mapping %q", pair)
+		}
+		mapping[field] = column
+	}
+	return mapping, nil
+}
+
+func isField(name string) bool {
+	for _, f := range Fields {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+// NewCSVReader reads header line and resolves columns by mapping
+func NewCSVReader(r io.Reader, mapping map[string]string) (Reader, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	cr.ReuseRecord = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read CSV header: %w", err)
+	}
+
+	index := map[string]int{}
+	for i, name := range header {
+		index[strings.TrimSpace(name)] = i
+	}
+
+	columns := map[string]int{}
+	for _, field := range Fields {
+		column := field
+		if mapped, ok := mapping[field]; ok {
+			column = mapped
+		}
+		if i, ok := index[column]; ok {
+			columns[field] = i
+		} else if !slices.Contains(optionalFields, field) {
+			return nil, fmt.Errorf("CSV column %q for %s is missing", column, field)
+		}
+	}
+
+	return &csvReader{r: cr, columns: columns}, nil
+}
+
+func (c *csvReader) Next() (Row, error) {
+	record, err := c.r.Read()
+	if err == io.EOF {
+		return Row{}, io.EOF
+	}
+
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return Row{Line: parseErr.Line, Err: parseErr.Err}, nil
+	}
+	if err != nil {
+		return Row{}, err
+	}
+
+	line, _ := c.r.FieldPos(0)
+	row := Row{Line: line}
+	value := func(field string) string {
+		i, ok := c.columns[field]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	row.Subscription.ServiceName = value("service_name")
+	row.Subscription.StartDate = value("start_date")
+	row.Subscription.EndDate = value("end_date")
+	row.Subscription.TrialEndDate = value("trial_end_date")
+
+	row.Subscription.Price, err = strconv.Atoi(value("price"))
+	if err != nil {
+		row.Err = fmt.Errorf("invalid price %q", value("price"))
+		return row, nil
+	}
+
+	row.Subscription.UserID, err = uuid.Parse(value("user_id"))
+	if err != nil {
+		row.Err = fmt.Errorf("invalid user_id %q", value("user_id"))
+	}
+	return row, nil
+}
+
+type ndjsonReader struct {
+	s *bufio.Scanner
+	line int
+}
+
+func NewNDJSONReader(r io.Reader) Reader {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64*1024), 1024*1024)
+	return &ndjsonReader{s: s}
+}
+
+func (n *ndjsonReader) Next() (Row, error) {
+	for n.s.Scan() {
+		n.line++
+		line := bytes.TrimSpace(n.s.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		row := Row{Line: n.line}
+		row.Err = json.Unmarshal(line, &row.Subscription)
+		return row, nil
+	}
+
+	if err := n.s.Err(); err != nil {
+		return Row{}, err
+	}
+	return Row{}, io.EOF
+}
\ No newline at end of file
@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/teamcutter/subscriptions-service-task/internal/auth"
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+	"github.com/teamcutter/subscriptions-service-task/internal/repo"
+)
+
+const HeaderAPIKey = "X-API-Key"
+
+type APIKeyAuthenticator interface {
+	Authenticate(string) (*model.APIKey, error)
+}
+
+// APIKey authenticates services sending X-API-Key header, requests
+// without the header are left to the next auth middleware
+func APIKey(keys APIKeyAuthenticator, logger *slog.Logger) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			key := c.Request().Header.Get(HeaderAPIKey)
+			if key == "" {
+				return next(c)
+			}
+
+			apiKey, err := keys.Authenticate(auth.HashAPIKey(key))
+			if errors.Is(err, repo.ErrAPIKeyNotFound) {
+				logger.Warn("authentication failed", "error", err)
+				return c.JSON(http.StatusUnauthorized, "invalid api key")
+			}
+			if err != nil {
+				logger.Error("api key error", "error", err)
+				return c.JSON(http.StatusInternalServerError, err.Error())
+			}
+
+			ctx := auth.WithPrincipal(c.Request().Context(), auth.Principal{
+				APIKeyID: apiKey.ID,
+				TenantID: apiKey.TenantID,
+				Scopes: apiKey.Scopes,
+			})
+			c.SetRequest(c.Request().WithContext(ctx))
+			return next(c)
+		}
+	}
+}
+
+// RequireScope rejects callers without the scope
+func RequireScope(scope string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			p, ok := auth.PrincipalFromContext(c.Request().Context())
+			if !ok {
+				return c.JSON(http.StatusUnauthorized, "authentication required")
+			}
+			if !p.HasScope(scope) {
+				return c.JSON(http.StatusForbidden, "missing scope "+scope)
+			}
+			return next(c)
+		}
+	}
+}
+
+// MethodScope requires read scope for safe methods and write scope for the rest
+func MethodScope() echo.MiddlewareFunc {
+	read, write := RequireScope(auth.ScopeRead), RequireScope(auth.ScopeWrite)
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		readNext, writeNext := read(next), write(next)
+		return func(c echo.Context) error {
+			switch c.Request().Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+				return readNext(c)
+			}
+			return writeNext(c)
+		}
+	}
+}
\ No newline at end of file
@@ -0,0 +1,59 @@
+package middleware_test
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/teamcutter/subscriptions-service-task/internal/auth"
+	"github.com/teamcutter/subscriptions-service-task/internal/middleware"
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+	"github.com/teamcutter/subscriptions-service-task/internal/repo"
+)
+
+type fakeKeys map[string]model.APIKey
+
+func (k fakeKeys) Authenticate(hash string) (*model.APIKey, error) {
+	key, ok := k[hash]
+	if !ok {
+		return nil, repo.ErrAPIKeyNotFound
+	}
+	return &key, nil
+}
+
+func setupAPIKey() *echo.Echo {
+	keys := fakeKeys{
+		auth.HashAPIKey("sk_reader"): {ID: 1, Scopes: []string{auth.ScopeRead}},
+	}
+
+	e := echo.New()
+	g := e.Group("/subscriptions")
+	g.Use(middleware.APIKey(keys, slog.Default()))
+	g.Use(middleware.MethodScope())
+	g.GET("", func(c echo.Context) error { return c.NoContent(http.StatusOK) })
+	g.POST("", func(c echo.Context) error { return c.NoContent(http.StatusCreated) })
+	return e
+}
+
+func requestWithKey(e *echo.Echo, method, key string) int {
+	req := httptest.NewRequest(method, "/subscriptions", nil)
+	if key != "" {
+		req.Header.Set(middleware.HeaderAPIKey, key)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestAPIKeyScopes(t *testing.T) {
+	e := setupAPIKey()
+
+	assert.Equal(t, http.StatusOK, requestWithKey(e, http.MethodGet, "sk_reader"))
+	assert.Equal(t, http.StatusForbidden, requestWithKey(e, http.MethodPost, "sk_reader"))
+	assert.Equal(t, http.StatusUnauthorized, requestWithKey(e, http.MethodGet, "sk_unknown"))
+	assert.Equal(t, http.StatusUnauthorized, requestWithKey(e, http.MethodGet, ""))
+}
@@ -0,0 +1,65 @@
+package middleware_test
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/teamcutter/subscriptions-service-task/internal/auth"
+	"github.com/teamcutter/subscriptions-service-task/internal/middleware"
+)
+
+func setupJWT(t *testing.T) *echo.Echo {
+	verifier, err := auth.NewVerifier(auth.Config{HS256Secret: "secret"})
+	require.NoError(t, err)
+
+	e := echo.New()
+	e.GET("/subscriptions", func(c echo.Context) error {
+		userID, _ := auth.UserIDFromContext(c.Request().Context())
+		return c.String(http.StatusOK, userID.String())
+	}, middleware.JWT(verifier, slog.Default()))
+	return e
+}
+
+func TestJWTAuthenticated(t *testing.T) {
+	e := setupJWT(t)
+	userID := uuid.New()
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		Subject:   userID.String(),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}).SignedString([]byte("secret"))
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "/subscriptions", nil)
+	req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, userID.String(), rec.Body.String())
+}
+
+func TestJWTMissingOrInvalidToken(t *testing.T) {
+	e := setupJWT(t)
+
+	for _, header := range []string{"", "Bearer garbage", "Basic dXNlcjpwYXNz"} {
+		req := httptest.NewRequest(http.MethodGet, "/subscriptions", nil)
+		if header != "" {
+			req.Header.Set(echo.HeaderAuthorization, header)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code, header)
+		assert.NotEmpty(t, rec.Header().Get(echo.HeaderWWWAuthenticate))
+	}
+}
@@ -0,0 +1,127 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/teamcutter/subscriptions-service-task/internal/auth"
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+)
+
+const HeaderIdempotencyKey = "Idempotency-Key"
+
+// replayedHeaders are response headers stored together with the body
+var replayedHeaders = []string{
+	echo.HeaderContentType,
+	echo.HeaderLocation,
+	"ETag",
+}
+
+type IdempotencyStore interface {
+	// methods take tenant ID first, keys of different tenants never collide
+	Reserve(string, string, string, time.Duration) (*model.IdempotencyRecord, bool, error)
+	Complete(string, string, int, http.Header, []byte) error
+	Release(string, string) error
+}
+
+// bodyRecorder copies everything written to the response
+type bodyRecorder struct {
+	http.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *bodyRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func requestHash(r *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Idempotency replays the stored response for requests repeating
+// an Idempotency-Key, requests without the header pass through untouched
+func Idempotency(store IdempotencyStore, ttl time.Duration, logger *slog.Logger) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			key := c.Request().Header.Get(HeaderIdempotencyKey)
+			if key == "" {
+				return next(c)
+			}
+			// keys of different users never collide
+			ctx := c.Request().Context()
+			if userID, ok := auth.UserIDFromContext(ctx); ok {
+				key = userID.String() + ":" + key
+			}
+			tenantID := auth.TenantFromContext(ctx)
+
+			body, err := io.ReadAll(c.Request().Body)
+			if err != nil {
+				logger.Error("idempotency error", "error", err)
+				return c.JSON(http.StatusBadRequest, err.Error())
+			}
+			c.Request().Body = io.NopCloser(bytes.NewReader(body))
+			hash := requestHash(c.Request(), body)
+
+			record, reserved, err := store.Reserve(tenantID, key, hash, ttl)
+			if err != nil {
+				logger.Error("idempotency error", "error", err)
+				return c.JSON(http.StatusInternalServerError, err.Error())
+			}
+
+			if !reserved {
+				switch {
+				case record.RequestHash != hash:
+					return c.JSON(http.StatusUnprocessableEntity, "idempotency key is already used for a different request")
+				case record.StatusCode == 0:
+					return c.JSON(http.StatusConflict, "request with this idempotency key is in progress")
+				}
+
+				logger.Info("idempotent replay", "key", key, "status", record.StatusCode)
+				for name, values := range record.Headers {
+					for _, v := range values {
+						c.Response().Header().Add(name, v)
+					}
+				}
+				c.Response().Header().Set("Idempotent-Replayed", "true")
+				c.Response().WriteHeader(record.StatusCode)
+				_, err := c.Response().Write(record.Body)
+				return err
+			}
+
+			recorder := &bodyRecorder{ResponseWriter: c.Response().Writer}
+			c.Response().Writer = recorder
+
+			err = next(c)
+			status := c.Response().Status
+
+			// failed requests are not remembered so that client can retry them
+			if err != nil || status >= http.StatusInternalServerError {
+				if releaseErr := store.Release(tenantID, key); releaseErr != nil {
+					logger.Error("idempotency error", "error", releaseErr)
+				}
+				return err
+			}
+
+			headers := http.Header{}
+			for _, name := range replayedHeaders {
+				if v := c.Response().Header().Get(name); v != "" {
+					headers.Set(name, v)
+				}
+			}
+			if err := store.Complete(tenantID, key, status, headers, recorder.body.Bytes()); err != nil {
+				logger.Error("idempotency error", "error", err)
+			}
+			return nil
+		}
+	}
+}
\ No newline at end of file
@@ -0,0 +1,101 @@
+package middleware_test
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/teamcutter/subscriptions-service-task/internal/middleware"
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+)
+
+type memoryStore struct {
+	records map[string]*model.IdempotencyRecord
+}
+
+func (s *memoryStore) Reserve(tenantID, key, hash string, ttl time.Duration) (*model.IdempotencyRecord, bool, error) {
+	key = tenantID + ":" + key
+	if record, ok := s.records[key]; ok {
+		return record, false, nil
+	}
+	s.records[key] = &model.IdempotencyRecord{Key: key, RequestHash: hash}
+	return nil, true, nil
+}
+
+func (s *memoryStore) Complete(tenantID, key string, status int, headers http.Header, body []byte) error {
+	key = tenantID + ":" + key
+	s.records[key].StatusCode = status
+	s.records[key].Headers = headers
+	s.records[key].Body = body
+	return nil
+}
+
+func (s *memoryStore) Release(tenantID, key string) error {
+	key = tenantID + ":" + key
+	delete(s.records, key)
+	return nil
+}
+
+func setupIdempotency() (*echo.Echo, *int32) {
+	e := echo.New()
+	store := &memoryStore{records: map[string]*model.IdempotencyRecord{}}
+
+	var calls int32
+	e.POST("/subscriptions", func(c echo.Context) error {
+		atomic.AddInt32(&calls, 1)
+		c.Response().Header().Set(echo.HeaderLocation, "/subscriptions/1")
+		return c.NoContent(http.StatusCreated)
+	}, middleware.Idempotency(store, time.Hour, slog.Default()))
+
+	return e, &calls
+}
+
+func post(e *echo.Echo, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/subscriptions", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	if key != "" {
+		req.Header.Set(middleware.HeaderIdempotencyKey, key)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestIdempotencyReplay(t *testing.T) {
+	e, calls := setupIdempotency()
+
+	first := post(e, "key-1", `{"service_name":"Netflix"}`)
+	second := post(e, "key-1", `{"service_name":"Netflix"}`)
+
+	assert.Equal(t, http.StatusCreated, first.Code)
+	assert.Equal(t, http.StatusCreated, second.Code)
+	assert.Equal(t, "/subscriptions/1", second.Header().Get(echo.HeaderLocation))
+	assert.Equal(t, "true", second.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, int32(1), *calls)
+}
+
+func TestIdempotencyKeyReusedWithDifferentBody(t *testing.T) {
+	e, calls := setupIdempotency()
+
+	post(e, "key-1", `{"service_name":"Netflix"}`)
+	rec := post(e, "key-1", `{"service_name":"Spotify"}`)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Equal(t, int32(1), *calls)
+}
+
+func TestIdempotencyWithoutKey(t *testing.T) {
+	e, calls := setupIdempotency()
+
+	post(e, "", `{"service_name":"Netflix"}`)
+	post(e, "", `{"service_name":"Netflix"}`)
+
+	assert.Equal(t, int32(2), *calls)
+}
\ No newline at end of file
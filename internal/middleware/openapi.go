@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/teamcutter/subscriptions-service-task/internal/openapi"
+)
+
+var invalidRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "http_invalid_requests_total",
+	Help: "Total number of HTTP requests not conforming to the OpenAPI spec",
+}, []string{"method", "endpoint", "mode"})
+
+// ValidationMode is what happens to requests not conforming to the spec
+type ValidationMode string
+
+const (
+	// ValidationStrict rejects invalid requests with 400
+	ValidationStrict ValidationMode = "strict"
+	// ValidationLenient logs invalid requests and lets them through
+	ValidationLenient ValidationMode = "lenient"
+	// ValidationOff does not check requests
+	ValidationOff ValidationMode = "off"
+)
+
+// ParseValidationMode reads mode like "strict", "lenient" or "off"
+func ParseValidationMode(s string) (ValidationMode, error) {
+	switch mode := ValidationMode(s); mode {
+	case ValidationStrict, ValidationLenient, ValidationOff:
+		return mode, nil
+	}
+	return "", fmt.Errorf("invalid validation mode %q", s)
+}
+
+// ValidateRequests checks requests against the OpenAPI spec, prefix is
+// prepended to paths of routes mounted without version. Routes missing
+// from the spec are not checked
+func ValidateRequests(validator *openapi.Validator, prefix string, mode ValidationMode, logger *slog.Logger) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		if mode == ValidationOff {
+			return next
+		}
+		return func(c echo.Context) error {
+			err := validator.ValidateRequest(c.Request(), prefix)
+			if err == nil || errors.Is(err, openapi.ErrUnknownRoute) {
+				return next(c)
+			}
+
+			invalidRequestsTotal.WithLabelValues(c.Request().Method, c.Path(), string(mode)).Inc()
+			if mode == ValidationStrict {
+				return c.JSON(http.StatusBadRequest, err.Error())
+			}
+			logger.Warn("request does not conform to spec", "method", c.Request().Method, "endpoint", c.Path(), "error", err)
+			return next(c)
+		}
+	}
+}
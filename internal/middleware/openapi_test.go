@@ -0,0 +1,67 @@
+package middleware_test
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/teamcutter/subscriptions-service-task/docs"
+	"github.com/teamcutter/subscriptions-service-task/internal/middleware"
+	"github.com/teamcutter/subscriptions-service-task/internal/openapi"
+)
+
+func TestValidateRequests(t *testing.T) {
+	validator, err := openapi.NewValidator(docs.OpenAPI)
+	require.NoError(t, err)
+	ok := func(c echo.Context) error { return c.NoContent(http.StatusOK) }
+
+	e := echo.New()
+	strict := middleware.ValidateRequests(validator, "", middleware.ValidationStrict, slog.Default())
+	e.Group("/v1/subscriptions", strict).POST("", ok)
+	e.Group("/v1/subscriptions", strict).GET("/:id", ok)
+	e.Group("/v1/unknown", strict).GET("", ok)
+	// unversioned routes are checked against their /v1 operations
+	e.Group("/subscriptions", middleware.ValidateRequests(validator, "/v1", middleware.ValidationStrict, slog.Default())).POST("", ok)
+	e.Group("/v2/subscriptions", middleware.ValidateRequests(validator, "", middleware.ValidationLenient, slog.Default())).POST("", ok)
+
+	tests := []struct {
+		method string
+		target string
+		body string
+		status int
+	}{
+		{http.MethodPost, "/v1/subscriptions", `{"service_name":"Netflix","price":400,"start_date":"01-2025"}`, http.StatusOK},
+		{http.MethodPost, "/v1/subscriptions", `{"service_name":"Netflix","price":"400","start_date":"01-2025"}`, http.StatusBadRequest},
+		{http.MethodPost, "/subscriptions", `{"service_name":"Netflix","price":"400","start_date":"01-2025"}`, http.StatusBadRequest},
+		{http.MethodGet, "/v1/subscriptions/1", "", http.StatusOK},
+		{http.MethodGet, "/v1/subscriptions/abc", "", http.StatusBadRequest},
+		{http.MethodGet, "/v1/unknown", "", http.StatusOK},
+		// price of v2 is an object, lenient mode only logs it
+		{http.MethodPost, "/v2/subscriptions", `{"service_name":"Netflix","price":400,"start_date":"2025-01-01"}`, http.StatusOK},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+		if tt.body != "" {
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		}
+		rec := httptest.NewRecorder()
+
+		e.ServeHTTP(rec, req)
+		assert.Equal(t, tt.status, rec.Code, "%s %s: %s", tt.method, tt.target, rec.Body)
+	}
+}
+
+func TestParseValidationMode(t *testing.T) {
+	mode, err := middleware.ParseValidationMode("strict")
+	assert.NoError(t, err)
+	assert.Equal(t, middleware.ValidationStrict, mode)
+
+	_, err = middleware.ParseValidationMode("loud")
+	assert.Error(t, err)
+}
@@ -0,0 +1,209 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/teamcutter/subscriptions-service-task/internal/auth"
+)
+
+var rateLimitRejectionsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "http_rate_limited_total",
+	Help: "Total number of HTTP requests rejected by rate limits",
+}, []string{"method", "endpoint", "limiter"})
+
+// Limit allows Burst requests at once refilled at Rate requests per second
+type Limit struct {
+	Rate float64
+	Burst int
+}
+
+// ParseLimit reads limit like "600/m", "10/s" or "1000/h",
+// burst equals the number of requests per period
+func ParseLimit(s string) (Limit, error) {
+	count, period, ok := strings.Cut(s, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("invalid rate limit %q", s)
+	}
+
+	n, err := strconv.Atoi(count)
+	if err != nil || n <= 0 {
+		return Limit{}, fmt.Errorf("invalid rate limit %q", s)
+	}
+
+	var d time.Duration
+	switch period {
+	case "s":
+		d = time.Second
+	case "m":
+		d = time.Minute
+	case "h":
+		d = time.Hour
+	default:
+		return Limit{}, fmt.Errorf("invalid rate limit period %q", period)
+	}
+	return Limit{Rate: float64(n) / d.Seconds(), Burst: n}, nil
+}
+
+// ParseRouteLimits reads limits of routes like
+// "GET /subscriptions/total=30/m,GET /households/:id/settle=10/m"
+func ParseRouteLimits(s string) (map[string]Limit, error) {
+	limits := map[string]Limit{}
+	if strings.TrimSpace(s) == "" {
+		return limits, nil
+	}
+	for _, entry := range strings.Split(s, ",") {
+		i := strings.LastIndex(entry, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("invalid route limit %q, expected <method> <path>=<limit>", entry)
+		}
+		route := strings.Join(strings.Fields(entry[:i]), " ")
+		limit, err := ParseLimit(strings.TrimSpace(entry[i+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("route %s: %w", route, err)
+		}
+		limits[route] = limit
+	}
+	return limits, nil
+}
+
+// MaxRateLimitKeys caps buckets a limiter keeps. When it is reached, new
+// keys share one overflow bucket until sweep frees space, so a flood of
+// fresh addresses is limited as a whole instead of growing memory
+const MaxRateLimitKeys = 100_000
+
+const overflowKey = "overflow"
+
+type bucket struct {
+	tokens float64
+	updated time.Time
+}
+
+// RateLimiter keeps a token bucket per key in memory
+type RateLimiter struct {
+	name string
+	limit Limit
+
+	mu sync.Mutex
+	buckets map[string]*bucket
+	swept time.Time
+}
+
+func NewRateLimiter(name string, limit Limit) *RateLimiter {
+	if limit.Rate <= 0 || limit.Burst <= 0 {
+		panic(errors.New("rate limit must be positive"))
+	}
+	return &RateLimiter{
+		name: name,
+		limit: limit,
+		buckets: map[string]*bucket{},
+		swept: time.Now(),
+	}
+}
+
+// Allow takes a token from bucket of key. It returns number of tokens
+// left, time until the bucket is full and, when there were no tokens,
+// time until the next one
+func (l *RateLimiter) Allow(key string) (allowed bool, remaining int, reset, retryAfter time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok && len(l.buckets) >= MaxRateLimitKeys {
+		key = overflowKey
+		b, ok = l.buckets[key]
+	}
+	if !ok {
+		b = &bucket{tokens: float64(l.limit.Burst), updated: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(float64(l.limit.Burst), b.tokens+now.Sub(b.updated).Seconds()*l.limit.Rate)
+	b.updated = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		allowed = true
+	} else {
+		retryAfter = l.duration(1 - b.tokens)
+	}
+	return allowed, int(b.tokens), l.duration(float64(l.limit.Burst) - b.tokens), retryAfter
+}
+
+func (l *RateLimiter) duration(tokens float64) time.Duration {
+	return time.Duration(tokens / l.limit.Rate * float64(time.Second))
+}
+
+// sweep forgets buckets that have been refilled completely,
+// they are the same as new ones
+func (l *RateLimiter) sweep(now time.Time) {
+	if now.Sub(l.swept) < time.Minute {
+		return
+	}
+	l.swept = now
+
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.updated).Seconds()*l.limit.Rate >= float64(l.limit.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// RateLimitKey returns the key requests are limited by
+type RateLimitKey func(echo.Context) string
+
+// KeyByIP limits clients by address, read by IPExtractor of the server
+func KeyByIP(c echo.Context) string {
+	return "ip:" + c.RealIP()
+}
+
+// KeyByPrincipal limits authenticated callers by user or API key
+// and the rest by address
+func KeyByPrincipal(c echo.Context) string {
+	if principal, ok := auth.PrincipalFromContext(c.Request().Context()); ok {
+		return principal.String()
+	}
+	return KeyByIP(c)
+}
+
+// seconds rounds up so that clients never retry too early
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+// RateLimit rejects requests over the limit with 429, every response gets
+// RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers
+func RateLimit(limiter *RateLimiter, key RateLimitKey, logger *slog.Logger) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			k := key(c)
+			allowed, remaining, reset, retryAfter := limiter.Allow(k)
+
+			header := c.Response().Header()
+			header.Set("RateLimit-Limit", strconv.Itoa(limiter.limit.Burst))
+			header.Set("RateLimit-Remaining", strconv.Itoa(remaining))
+			header.Set("RateLimit-Reset", seconds(reset))
+
+			if !allowed {
+				rateLimitRejectionsTotal.WithLabelValues(c.Request().Method, c.Path(), limiter.name).Inc()
+				logger.Warn("rate limit exceeded", "key", k, "limiter", limiter.name, "endpoint", c.Path())
+				header.Set(echo.HeaderRetryAfter, seconds(retryAfter))
+				return c.JSON(http.StatusTooManyRequests, "rate limit exceeded")
+			}
+			return next(c)
+		}
+	}
+}
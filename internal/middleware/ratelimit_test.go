@@ -0,0 +1,92 @@
+package middleware_test
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/teamcutter/subscriptions-service-task/internal/middleware"
+)
+
+func TestParseLimit(t *testing.T) {
+	limit, err := middleware.ParseLimit("120/m")
+	require.NoError(t, err)
+	assert.Equal(t, middleware.Limit{Rate: 2, Burst: 120}, limit)
+
+	for _, s := range []string{"", "10", "0/s", "10/d", "x/m"} {
+		_, err := middleware.ParseLimit(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestParseRouteLimits(t *testing.T) {
+	limits, err := middleware.ParseRouteLimits("GET /subscriptions/total=60/m, GET  /households/:id/settle = 10/s")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]middleware.Limit{
+		"GET /subscriptions/total": {Rate: 1, Burst: 60},
+		"GET /households/:id/settle": {Rate: 10, Burst: 10},
+	}, limits)
+
+	limits, err = middleware.ParseRouteLimits("")
+	require.NoError(t, err)
+	assert.Empty(t, limits)
+
+	for _, s := range []string{"GET /subscriptions/total", "GET /subscriptions/total=0/m"} {
+		_, err := middleware.ParseRouteLimits(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	limiter := middleware.NewRateLimiter("total", middleware.Limit{Rate: 1.0 / 60, Burst: 2})
+
+	e := echo.New()
+	e.Use(middleware.RateLimit(limiter, middleware.KeyByIP, slog.Default()))
+	e.GET("/subscriptions/total", func(c echo.Context) error { return c.NoContent(http.StatusOK) })
+
+	request := func(ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/subscriptions/total", nil)
+		req.RemoteAddr = ip + ":1234"
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	first := request("10.0.0.1")
+	assert.Equal(t, http.StatusOK, first.Code)
+	assert.Equal(t, "2", first.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "1", first.Header().Get("RateLimit-Remaining"))
+
+	assert.Equal(t, http.StatusOK, request("10.0.0.1").Code)
+
+	rejected := request("10.0.0.1")
+	assert.Equal(t, http.StatusTooManyRequests, rejected.Code)
+	assert.Equal(t, "0", rejected.Header().Get("RateLimit-Remaining"))
+	retryAfter, err := time.ParseDuration(rejected.Header().Get(echo.HeaderRetryAfter) + "s")
+	require.NoError(t, err)
+	assert.InDelta(t, time.Minute.Seconds(), retryAfter.Seconds(), 1)
+
+	// other clients have own buckets
+	assert.Equal(t, http.StatusOK, request("10.0.0.2").Code)
+}
+
+func TestRateLimitKeysAreCapped(t *testing.T) {
+	limiter := middleware.NewRateLimiter("ip", middleware.Limit{Rate: 1.0 / 60, Burst: 1})
+	for i := range middleware.MaxRateLimitKeys {
+		allowed, _, _, _ := limiter.Allow(fmt.Sprintf("ip:%d", i))
+		require.True(t, allowed)
+	}
+
+	// new keys share the overflow bucket
+	allowed, _, _, _ := limiter.Allow("ip:new-1")
+	assert.True(t, allowed)
+	allowed, _, _, _ = limiter.Allow("ip:new-2")
+	assert.False(t, allowed)
+}
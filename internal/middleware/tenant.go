@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/teamcutter/subscriptions-service-task/internal/auth"
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+)
+
+const HeaderTenantID = "X-Tenant-ID"
+
+type TenantStore interface {
+	Exists(string) (bool, error)
+}
+
+// Tenant resolves tenant of the request. Callers bound to a tenant work
+// with it and may only repeat it in X-Tenant-ID header, platform callers
+// choose the tenant with the header and get the default one without it
+func Tenant(tenants TenantStore, logger *slog.Logger) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			header := c.Request().Header.Get(HeaderTenantID)
+
+			tenantID := header
+			if principal, ok := auth.PrincipalFromContext(c.Request().Context()); ok && principal.TenantID != "" {
+				if header != "" && header != principal.TenantID {
+					logger.Warn("tenant mismatch", "principal", principal.String(), "tenant_id", header)
+					return c.JSON(http.StatusForbidden, "access to tenant "+header+" is not allowed")
+				}
+				tenantID = principal.TenantID
+			}
+			if tenantID == "" {
+				tenantID = model.DefaultTenant
+			}
+
+			exists, err := tenants.Exists(tenantID)
+			if err != nil {
+				logger.Error("tenant error", "error", err)
+				return c.JSON(http.StatusInternalServerError, err.Error())
+			}
+			if !exists {
+				return c.JSON(http.StatusForbidden, "unknown tenant "+tenantID)
+			}
+
+			c.SetRequest(c.Request().WithContext(auth.WithTenant(c.Request().Context(), tenantID)))
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package middleware_test
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/teamcutter/subscriptions-service-task/internal/auth"
+	"github.com/teamcutter/subscriptions-service-task/internal/middleware"
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+)
+
+type fakeTenants map[string]bool
+
+func (t fakeTenants) Exists(id string) (bool, error) {
+	return t[id], nil
+}
+
+func setupTenant() *echo.Echo {
+	keys := fakeKeys{
+		auth.HashAPIKey("sk_acme"): {ID: 1, TenantID: "acme", Scopes: []string{auth.ScopeRead}},
+		auth.HashAPIKey("sk_platform"): {ID: 2, Scopes: []string{auth.ScopeRead}},
+	}
+	tenants := fakeTenants{model.DefaultTenant: true, "acme": true, "globex": true}
+
+	e := echo.New()
+	g := e.Group("/subscriptions")
+	g.Use(middleware.APIKey(keys, slog.Default()))
+	g.Use(middleware.Tenant(tenants, slog.Default()))
+	g.GET("", func(c echo.Context) error {
+		return c.String(http.StatusOK, auth.TenantFromContext(c.Request().Context()))
+	})
+	return e
+}
+
+func requestTenant(e *echo.Echo, key, tenant string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/subscriptions", nil)
+	req.Header.Set(middleware.HeaderAPIKey, key)
+	if tenant != "" {
+		req.Header.Set(middleware.HeaderTenantID, tenant)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestTenantOfBoundKey(t *testing.T) {
+	e := setupTenant()
+
+	rec := requestTenant(e, "sk_acme", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "acme", rec.Body.String())
+
+	assert.Equal(t, http.StatusOK, requestTenant(e, "sk_acme", "acme").Code)
+	assert.Equal(t, http.StatusForbidden, requestTenant(e, "sk_acme", "globex").Code)
+}
+
+func TestTenantOfPlatformKey(t *testing.T) {
+	e := setupTenant()
+
+	rec := requestTenant(e, "sk_platform", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, model.DefaultTenant, rec.Body.String())
+
+	rec = requestTenant(e, "sk_platform", "globex")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "globex", rec.Body.String())
+
+	assert.Equal(t, http.StatusForbidden, requestTenant(e, "sk_platform", "unknown").Code)
+}
@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var apiRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "http_api_version_requests_total",
+	Help: "Total number of HTTP requests by API version",
+}, []string{"version"})
+
+// APIVersion counts requests to routes of API version
+func APIVersion(version string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			apiRequestsTotal.WithLabelValues(version).Inc()
+			return next(c)
+		}
+	}
+}
+
+// Deprecated marks responses of routes deprecated since deprecatedAt and
+// removed at sunset (RFC 9745, RFC 8594), Link points to the same path
+// under successor prefix
+func Deprecated(deprecatedAt, sunset time.Time, successor string) echo.MiddlewareFunc {
+	deprecation := fmt.Sprintf("@%d", deprecatedAt.Unix())
+	sunsetDate := sunset.UTC().Format(http.TimeFormat)
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			header := c.Response().Header()
+			header.Set("Deprecation", deprecation)
+			header.Set("Sunset", sunsetDate)
+			header.Add("Link", fmt.Sprintf(`<%s%s>; rel="successor-version"`, successor, c.Request().URL.Path))
+			return next(c)
+		}
+	}
+}
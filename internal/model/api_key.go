@@ -0,0 +1,23 @@
+package model
+
+import "time"
+
+type APIKey struct {
+	ID int64 `json:"id" db:"id"`
+	// TenantID is empty for platform keys not bound to a tenant
+	TenantID string `json:"tenant_id,omitempty" db:"tenant_id"`
+	Name string `json:"name" db:"name"`
+	// Prefix is the beginning of the key to recognise it, the key itself is never stored
+	Prefix string `json:"prefix" db:"prefix"`
+	Scopes []string `json:"scopes" db:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty" db:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty" db:"last_used_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// NewAPIKey is returned once on create and rotate, Key is not shown again
+type NewAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
\ No newline at end of file
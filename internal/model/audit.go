@@ -0,0 +1,21 @@
+package model
+
+import "time"
+
+const (
+	AuditAllowed = "allowed"
+	AuditDenied = "denied"
+	// ErasedActor replaces actor of audit entries made by erased users
+	ErasedActor = "user:erased"
+)
+
+type AuditEntry struct {
+	ID int64 `json:"id" db:"id"`
+	TenantID string `json:"tenant_id,omitempty" db:"tenant_id"`
+	// Actor is "user:<id>" or "api_key:<id>"
+	Actor string `json:"actor" db:"actor"`
+	Action string `json:"action" db:"action"`
+	Resource string `json:"resource" db:"resource"`
+	Outcome string `json:"outcome" db:"outcome"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
\ No newline at end of file
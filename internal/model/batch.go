@@ -0,0 +1,32 @@
+package model
+
+const (
+	BatchOpCreate = "create"
+	BatchOpUpdate = "update"
+	BatchOpDelete = "delete"
+)
+
+// BatchOperation is one item of a batch request, ID and Version
+// are used by update and delete, Subscription by create and update
+type BatchOperation struct {
+	Op string `json:"op"`
+	ID int64 `json:"id,omitempty"`
+	Version int `json:"version,omitempty"`
+	Subscription *Subscription `json:"subscription,omitempty"`
+}
+
+type BatchRequest struct {
+	// Atomic runs all operations in one transaction, otherwise
+	// every operation succeeds or fails on its own
+	Atomic bool `json:"atomic"`
+	Operations []BatchOperation `json:"operations"`
+}
+
+type BatchResult struct {
+	Index int `json:"index"`
+	Status int `json:"status"`
+	ID int64 `json:"id,omitempty"`
+	Version int `json:"version,omitempty"`
+	Error string `json:"error,omitempty"`
+	Err error `json:"-"`
+}
\ No newline at end of file
@@ -0,0 +1,46 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	EventSubscriptionCreated = "subscription.created"
+	// EventSubscriptionUpdated is sent for updates that are neither
+	// price changes nor cancellations
+	EventSubscriptionUpdated = "subscription.updated"
+	EventSubscriptionPriceChanged = "subscription.price_changed"
+	// EventSubscriptionCancelled is sent when end date is set on open-ended subscription
+	EventSubscriptionCancelled = "subscription.cancelled"
+	EventSubscriptionDeleted = "subscription.deleted"
+)
+
+var Events = []string{
+	EventSubscriptionCreated,
+	EventSubscriptionUpdated,
+	EventSubscriptionPriceChanged,
+	EventSubscriptionCancelled,
+	EventSubscriptionDeleted,
+}
+
+// Event is a change of subscription, Subscription is not set for deleted ones
+type Event struct {
+	ID uuid.UUID `json:"id"`
+	Type string `json:"type"`
+	TenantID string `json:"tenant_id"`
+	OccurredAt time.Time `json:"occurred_at"`
+	SubscriptionID int64 `json:"subscription_id"`
+	// UserID owns the subscription
+	UserID uuid.UUID `json:"user_id"`
+	Subscription *Subscription `json:"subscription,omitempty"`
+}
+
+// OutboxEntry is an event written together with the change and waiting
+// to be published
+type OutboxEntry struct {
+	ID int64
+	Attempts int
+	Event Event
+}
\ No newline at end of file
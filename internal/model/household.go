@@ -0,0 +1,78 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// SplitEqual shares price equally among all household members
+	SplitEqual = "equal"
+	// SplitPercentage gives members parts in percent of price
+	SplitPercentage = "percentage"
+	// SplitFixed gives members fixed monthly amounts
+	SplitFixed = "fixed"
+)
+
+type Household struct {
+	ID int64 `json:"id" db:"id"`
+	Name string `json:"name" db:"name"`
+	OwnerID uuid.UUID `json:"owner_id" db:"owner_id"`
+	Members []uuid.UUID `json:"members"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+type SplitPart struct {
+	UserID uuid.UUID `json:"user_id" db:"user_id"`
+	Value int `json:"value" db:"value"`
+}
+
+// Share is the monthly amount a member pays for a subscription
+type Share struct {
+	UserID uuid.UUID `json:"user_id"`
+	Amount int `json:"amount"`
+}
+
+// Split shares subscription among household members. Parts are given to
+// members other than the payer, the payer covers what is left
+type Split struct {
+	SubscriptionID int64 `json:"subscription_id" db:"subscription_id"`
+	HouseholdID int64 `json:"household_id" db:"household_id"`
+	Rule string `json:"rule" db:"rule"`
+	Parts []SplitPart `json:"parts,omitempty"`
+	Shares []Share `json:"shares,omitempty"`
+}
+
+// Cost is a subscription active for Months months of a period
+// together with its split, Split is nil for unshared subscriptions
+type Cost struct {
+	SubscriptionID int64
+	Payer uuid.UUID
+	Price int
+	Months int
+	Split *Split
+	Members []uuid.UUID
+}
+
+type MemberBalance struct {
+	UserID uuid.UUID `json:"user_id"`
+	Paid int `json:"paid"`
+	Share int `json:"share"`
+	// Balance is positive for members who are owed money
+	Balance int `json:"balance"`
+}
+
+type Transfer struct {
+	From uuid.UUID `json:"from"`
+	To uuid.UUID `json:"to"`
+	Amount int `json:"amount"`
+}
+
+type Settlement struct {
+	HouseholdID int64 `json:"household_id"`
+	Start string `json:"start"`
+	End string `json:"end"`
+	Members []MemberBalance `json:"members"`
+	Transfers []Transfer `json:"transfers"`
+}
\ No newline at end of file
@@ -0,0 +1,13 @@
+package model
+
+import "net/http"
+
+// IdempotencyRecord is a stored response for an Idempotency-Key,
+// StatusCode is 0 while the first request is still in progress
+type IdempotencyRecord struct {
+	Key string `db:"key"`
+	RequestHash string `db:"request_hash"`
+	StatusCode int `db:"status_code"`
+	Headers http.Header `db:"response_headers"`
+	Body []byte `db:"response_body"`
+}
\ No newline at end of file
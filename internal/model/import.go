@@ -0,0 +1,40 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	ImportJobRunning = "running"
+	ImportJobDone = "done"
+	ImportJobFailed = "failed"
+)
+
+type ImportRowError struct {
+	Line int `json:"line"`
+	Error string `json:"error"`
+}
+
+// ImportReport is the outcome of an import, in dry-run mode
+// Imported counts rows that passed validation
+type ImportReport struct {
+	DryRun bool `json:"dry_run"`
+	TotalRows int `json:"total_rows"`
+	Imported int `json:"imported"`
+	Failed int `json:"failed"`
+	Errors []ImportRowError `json:"errors,omitempty"`
+}
+
+type ImportJob struct {
+	ID uuid.UUID `json:"id" db:"id"`
+	TenantID string `json:"-" db:"tenant_id"`
+	UserID uuid.NullUUID `json:"-" db:"user_id"`
+	Status string `json:"status" db:"status"`
+	Format string `json:"format" db:"format"`
+	Report ImportReport `json:"report" db:"report"`
+	Error string `json:"error,omitempty" db:"error"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty" db:"finished_at"`
+}
\ No newline at end of file
@@ -0,0 +1,38 @@
+package model
+
+import "time"
+
+// reminder kinds
+const (
+	ReminderRenewal = "renewal"
+	ReminderTrialEnd = "trial_end"
+	ReminderEnd = "end"
+)
+
+const (
+	ChannelEmail = "email"
+	ChannelWebhook = "webhook"
+)
+
+const (
+	NotificationPending = "pending"
+	NotificationSending = "sending"
+	NotificationSent = "sent"
+	NotificationFailed = "failed"
+)
+
+// Notification is a reminder about a date of subscription sent to its
+// user through one channel
+type Notification struct {
+	ID int64 `json:"id" db:"id"`
+	TenantID string `json:"tenant_id" db:"tenant_id"`
+	Kind string `json:"kind" db:"kind"`
+	Channel string `json:"channel" db:"channel"`
+	// DueDate is the day of renewal, trial end or subscription end
+	DueDate time.Time `json:"due_date" db:"due_date"`
+	// Attempts counts deliveries including the current one
+	Attempts int `json:"attempts" db:"attempts"`
+	SubscriptionID int64 `json:"subscription_id" db:"subscription_id"`
+	Subscription Subscription `json:"subscription"`
+	User User `json:"user"`
+}
\ No newline at end of file
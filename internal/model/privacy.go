@@ -0,0 +1,38 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AuditErasure is the action of audit entries recording erasure of user data
+const AuditErasure = "users:erase"
+
+// UserData is everything stored about a user, it is what data export returns
+type UserData struct {
+	Profile *User `json:"profile"`
+	Subscriptions []Subscription `json:"subscriptions"`
+	// Prices are prices subscriptions of the user had, including deleted ones
+	Prices []PriceChange `json:"prices"`
+	// Splits are splits of subscriptions the user pays or has a part in
+	Splits []Split `json:"splits"`
+	Households []Household `json:"households"`
+	ImportJobs []ImportJob `json:"import_jobs"`
+	Notifications []Notification `json:"notifications"`
+	// WebhookDeliveries are deliveries of events about the user
+	WebhookDeliveries []WebhookDelivery `json:"webhook_deliveries"`
+	Audit []AuditEntry `json:"audit"`
+}
+
+// ErasureReceipt confirms erasure without naming the user, Subject is
+// a hash only those knowing the user id can match
+type ErasureReceipt struct {
+	ID uuid.UUID `json:"id"`
+	TenantID string `json:"tenant_id"`
+	Subject string `json:"subject"`
+	// Erased counts removed or anonymised rows per table
+	Erased map[string]int64 `json:"erased"`
+	ErasedAt time.Time `json:"erased_at"`
+	Signature string `json:"signature"`
+}
\ No newline at end of file
@@ -1,6 +1,10 @@
 package model
 
-import "github.com/google/uuid"
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
 
 type Subscription struct {
 	ID int64 `json:"-" db:"id"`
@@ -9,5 +13,33 @@ type Subscription struct {
 	UserID uuid.UUID `json:"user_id" db:"user_id"`
 	StartDate string `json:"start_date" db:"start_date"`
 	EndDate string `json:"end_date,omitempty" db:"end_date"`
+	// TrialEndDate is the first paid month, users are reminded before it.
+	// Totals count the subscription from start date all the same
+	TrialEndDate string `json:"trial_end_date,omitempty" db:"trial_end_date"`
+	Version int `json:"-" db:"version"`
 	CreatedAt string `json:"-" db:"created_at"`
+}
+
+// PriceChange is the price subscription has from ChangedAt on
+type PriceChange struct {
+	SubscriptionID int64 `json:"subscription_id"`
+	Price int `json:"price"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// SubscriptionFilter narrows list and export queries, zero fields match everything
+type SubscriptionFilter struct {
+	UserID uuid.NullUUID
+	// UserIDs matches subscriptions of any of the users
+	UserIDs []uuid.UUID
+	ServiceName string
+	// AfterID and Limit page through subscriptions ordered by id
+	AfterID int64
+	Limit int
+}
+
+// Total is the cost of subscriptions in currency of the user
+type Total struct {
+	Total int `json:"total"`
+	Currency string `json:"currency"`
 }
\ No newline at end of file
@@ -0,0 +1,40 @@
+package model
+
+import "github.com/google/uuid"
+
+// Money is an amount in whole units of currency
+type Money struct {
+	Amount int `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+// SubscriptionV2 is Subscription in v2 API, dates are ISO dates and price
+// is in currency of the user
+type SubscriptionV2 struct {
+	ID int64 `json:"id"`
+	ServiceName string `json:"service_name"`
+	// Price currency may be omitted in requests, otherwise it must be the user's currency
+	Price Money `json:"price"`
+	UserID uuid.UUID `json:"user_id"`
+	// StartDate is the first day of the first month
+	StartDate string `json:"start_date"`
+	// EndDate is the last day of the last month
+	EndDate string `json:"end_date,omitempty"`
+	// TrialEndDate is the first day of the first paid month
+	TrialEndDate string `json:"trial_end_date,omitempty"`
+}
+
+// SubscriptionPageV2 is a page of subscriptions ordered by id, Next is the
+// cursor of the next page and is empty on the last one
+type SubscriptionPageV2 struct {
+	Items []SubscriptionV2 `json:"items"`
+	Next string `json:"next,omitempty"`
+}
+
+// TotalV2 is the cost of subscriptions from the first day of Start month
+// to the last day of End month
+type TotalV2 struct {
+	Total Money `json:"total"`
+	Start string `json:"start"`
+	End string `json:"end"`
+}
\ No newline at end of file
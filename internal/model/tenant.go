@@ -0,0 +1,13 @@
+package model
+
+import "time"
+
+// DefaultTenant owns data created before tenants were introduced and
+// requests of callers not bound to any tenant
+const DefaultTenant = "default"
+
+type Tenant struct {
+	ID string `json:"id" db:"id"`
+	Name string `json:"name" db:"name"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
\ No newline at end of file
@@ -0,0 +1,31 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultCurrency = "RUB"
+	DefaultTimezone = "UTC"
+)
+
+type NotificationPreferences struct {
+	Email bool `json:"email"`
+	// RemindDaysBefore is how many days before renewal to remind, 0 disables reminders
+	RemindDaysBefore int `json:"remind_days_before"`
+	// Webhook sends reminders to the webhook configured for the service
+	Webhook bool `json:"webhook"`
+}
+
+type User struct {
+	ID uuid.UUID `json:"id" db:"id"`
+	Name string `json:"name" db:"name"`
+	Email string `json:"email,omitempty" db:"email"`
+	Currency string `json:"currency" db:"currency"`
+	Timezone string `json:"timezone" db:"timezone"`
+	Notifications NotificationPreferences `json:"notifications" db:"notifications"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
\ No newline at end of file
@@ -0,0 +1,45 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DeliveryPending = "pending"
+	DeliverySending = "sending"
+	DeliveryDelivered = "delivered"
+	// DeliveryDead is a delivery that failed every attempt
+	DeliveryDead = "dead"
+)
+
+type Webhook struct {
+	ID int64 `json:"id" db:"id"`
+	TenantID string `json:"tenant_id" db:"tenant_id"`
+	URL string `json:"url" db:"url"`
+	// Events filters sent events, empty means all of them
+	Events []string `json:"events" db:"events"`
+	// Secret signs payloads, it is returned only on create
+	Secret string `json:"secret,omitempty" db:"secret"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+type WebhookDelivery struct {
+	ID int64 `json:"id" db:"id"`
+	WebhookID int64 `json:"webhook_id" db:"webhook_id"`
+	EventID uuid.UUID `json:"event_id" db:"event_id"`
+	EventType string `json:"event_type" db:"event_type"`
+	Payload json.RawMessage `json:"payload" db:"payload" swaggertype:"object"`
+	Status string `json:"status" db:"status"`
+	Attempts int `json:"attempts" db:"attempts"`
+	ResponseStatus int `json:"response_status,omitempty" db:"response_status"`
+	LastError string `json:"last_error,omitempty" db:"last_error"`
+	NextAttemptAt time.Time `json:"next_attempt_at" db:"next_attempt_at"`
+	DeliveredAt *time.Time `json:"delivered_at,omitempty" db:"delivered_at"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	// URL and Secret of the webhook are loaded for sending
+	URL string `json:"-"`
+	Secret string `json:"-"`
+}
\ No newline at end of file
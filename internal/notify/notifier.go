@@ -0,0 +1,40 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+)
+
+// Notifier delivers notification through one channel
+type Notifier interface {
+	Notify(context.Context, model.Notification) error
+}
+
+// SendTimeout bounds one delivery, it is used when ctx of Notify has no
+// earlier deadline
+const SendTimeout = 10 * time.Second
+
+const dateLayout = "2 January 2006"
+
+// Message returns subject and plain text of notification
+func Message(n model.Notification) (subject, body string) {
+	service := n.Subscription.ServiceName
+	date := n.DueDate.Format(dateLayout)
+	price := fmt.Sprintf("%d %s", n.Subscription.Price, n.User.Currency)
+
+	switch n.Kind {
+	case model.ReminderTrialEnd:
+		subject = fmt.Sprintf("%s trial ends on %s", service, date)
+		body = fmt.Sprintf("The trial of %s ends on %s, after that it costs %s a month.", service, date, price)
+	case model.ReminderEnd:
+		subject = fmt.Sprintf("%s ends on %s", service, date)
+		body = fmt.Sprintf("Your subscription to %s ends on %s.", service, date)
+	default:
+		subject = fmt.Sprintf("%s renews on %s", service, date)
+		body = fmt.Sprintf("Your subscription to %s renews on %s, %s will be charged.", service, date, price)
+	}
+	return subject, body
+}
@@ -0,0 +1,123 @@
+package notify_test
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+	"github.com/teamcutter/subscriptions-service-task/internal/notify"
+)
+
+// fakeSMTP accepts one session and sends received message to channel
+func fakeSMTP(t *testing.T) (string, <-chan string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	messages := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		tp := textproto.NewConn(conn)
+		tp.PrintfLine("220 localhost ESMTP")
+		for {
+			line, err := tp.ReadLine()
+			if err != nil {
+				return
+			}
+			switch cmd := strings.ToUpper(strings.Fields(line)[0]); cmd {
+			case "EHLO", "HELO":
+				tp.PrintfLine("250 localhost")
+			case "DATA":
+				tp.PrintfLine("354 end data with <CR><LF>.<CR><LF>")
+				data, err := tp.ReadDotBytes()
+				if err != nil {
+					return
+				}
+				messages <- string(data)
+				tp.PrintfLine("250 OK")
+			case "QUIT":
+				tp.PrintfLine("221 bye")
+				return
+			default:
+				tp.PrintfLine("250 OK")
+			}
+		}
+	}()
+	return ln.Addr().String(), messages
+}
+
+func TestSMTPNotifier(t *testing.T) {
+	addr, messages := fakeSMTP(t)
+	notifier, err := notify.NewSMTPNotifier(notify.SMTPConfig{Addr: addr, From: "Subscriptions <noreply@example.com>"})
+	require.NoError(t, err)
+
+	n := model.Notification{
+		ID: 7,
+		Kind: model.ReminderRenewal,
+		DueDate: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC),
+		Subscription: model.Subscription{ServiceName: "Netflix\r\nBcc: evil@example.com", Price: 500},
+		User: model.User{ID: uuid.New(), Name: "Ann", Email: "ann@example.com", Currency: "RUB"},
+	}
+	require.NoError(t, notifier.Notify(context.Background(), n))
+
+	select {
+	case msg := <-messages:
+		header, err := textproto.NewReader(bufio.NewReader(strings.NewReader(msg))).ReadMIMEHeader()
+		require.NoError(t, err)
+		assert.Equal(t, `"Ann" <ann@example.com>`, header.Get("To"))
+		assert.Equal(t, "<notification-7@127.0.0.1>", header.Get("Message-Id"))
+		assert.Empty(t, header.Get("Bcc"))
+		assert.Contains(t, msg, "renews on 1 March 2025, 500 RUB will be charged")
+	case <-time.After(5 * time.Second):
+		t.Fatal("message was not received")
+	}
+}
+
+func TestSMTPNotifierWithoutEmail(t *testing.T) {
+	notifier, err := notify.NewSMTPNotifier(notify.SMTPConfig{Addr: "127.0.0.1:25", From: "noreply@example.com"})
+	require.NoError(t, err)
+	assert.Error(t, notifier.Notify(context.Background(), model.Notification{}))
+}
+
+func TestSMTPNotifierDeadline(t *testing.T) {
+	// server accepts connections but never greets
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	done := make(chan struct{})
+	t.Cleanup(func() {
+		close(done)
+		ln.Close()
+	})
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		<-done
+	}()
+
+	notifier, err := notify.NewSMTPNotifier(notify.SMTPConfig{Addr: ln.Addr().String(), From: "noreply@example.com"})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	started := time.Now()
+	err = notifier.Notify(ctx, model.Notification{User: model.User{Email: "ann@example.com"}})
+	assert.Error(t, err)
+	assert.Less(t, time.Since(started), 2*time.Second)
+}
@@ -0,0 +1,62 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+)
+
+// HeaderNotificationID lets webhook receivers drop retried duplicates
+const HeaderNotificationID = "X-Notification-ID"
+
+// WebhookNotifier posts notifications as JSON to a URL
+type WebhookNotifier struct {
+	url string
+	client *http.Client
+}
+
+func NewWebhookNotifier(url string) *WebhookNotifier {
+	return &WebhookNotifier{
+		url: url,
+		client: &http.Client{Timeout: SendTimeout},
+	}
+}
+
+type webhookPayload struct {
+	model.Notification
+	Subject string `json:"subject"`
+	Text string `json:"text"`
+}
+
+func (w *WebhookNotifier) Notify(ctx context.Context, n model.Notification) error {
+	payload := webhookPayload{Notification: n}
+	payload.Subject, payload.Text = Message(n)
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderNotificationID, strconv.FormatInt(n.ID, 10))
+
+	res, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("webhook responded %s", res.Status)
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package notify_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+	"github.com/teamcutter/subscriptions-service-task/internal/notify"
+)
+
+func TestWebhookNotifier(t *testing.T) {
+	var payload map[string]any
+	var id string
+	status := http.StatusNoContent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id = r.Header.Get(notify.HeaderNotificationID)
+		json.NewDecoder(r.Body).Decode(&payload)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	notifier := notify.NewWebhookNotifier(server.URL)
+	n := model.Notification{ID: 3, Kind: model.ReminderEnd, SubscriptionID: 12, Subscription: model.Subscription{ServiceName: "Spotify"}}
+
+	require.NoError(t, notifier.Notify(context.Background(), n))
+	assert.Equal(t, "3", id)
+	assert.Equal(t, "end", payload["kind"])
+	assert.Equal(t, float64(12), payload["subscription_id"])
+	assert.Contains(t, payload["subject"], "Spotify ends on")
+
+	status = http.StatusBadGateway
+	assert.Error(t, notifier.Notify(context.Background(), n))
+}
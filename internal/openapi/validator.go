@@ -0,0 +1,112 @@
+package openapi
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/legacy"
+)
+
+// ErrUnknownRoute is returned for requests of operations missing from the spec
+var ErrUnknownRoute = errors.New("operation is not in the spec")
+
+// Validator checks requests and responses against OpenAPI 3 spec
+type Validator struct {
+	router routers.Router
+}
+
+// NewValidator loads spec, servers of the spec are ignored so that
+// requests are matched by path only
+func NewValidator(spec []byte) (*Validator, error) {
+	loader := openapi3.NewLoader()
+	doc, err := loader.LoadFromData(spec)
+	if err != nil {
+		return nil, err
+	}
+	if err := doc.Validate(loader.Context); err != nil {
+		return nil, err
+	}
+	doc.Servers = nil
+
+	router, err := legacy.NewRouter(doc)
+	if err != nil {
+		return nil, err
+	}
+	return &Validator{router: router}, nil
+}
+
+// input finds operation of request at prefix followed by the request
+// path, prefix maps routes mounted without version to their spec paths
+func (v *Validator) input(r *http.Request, prefix string) (*openapi3filter.RequestValidationInput, error) {
+	lookup := *r
+	u := *r.URL
+	u.Path = prefix + u.Path
+	u.RawPath = ""
+	lookup.URL = &u
+
+	route, params, err := v.router.FindRoute(&lookup)
+	var routeErr *routers.RouteError
+	if errors.As(err, &routeErr) {
+		return nil, ErrUnknownRoute
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for name, value := range params {
+		if unescaped, err := url.PathUnescape(value); err == nil {
+			params[name] = unescaped
+		}
+	}
+	return &openapi3filter.RequestValidationInput{
+		Request: r,
+		PathParams: params,
+		Route: route,
+		Options: &openapi3filter.Options{
+			// credentials are checked by auth middlewares
+			AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+			MultiError: true,
+		},
+	}, nil
+}
+
+// ValidateRequest checks params and body of request, the body is left
+// for handlers to read
+func (v *Validator) ValidateRequest(r *http.Request, prefix string) error {
+	input, err := v.input(r, prefix)
+	if err != nil {
+		return err
+	}
+	return openapi3filter.ValidateRequest(r.Context(), input)
+}
+
+// ValidateResponse checks that status of response is documented and that
+// its body matches the documented schema. Schemas describe JSON, so bodies
+// of other media types are only checked to be documented
+func (v *Validator) ValidateResponse(r *http.Request, prefix string, status int, header http.Header, body []byte) error {
+	input, err := v.input(r, prefix)
+	if err != nil {
+		return err
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(header.Get("Content-Type"))
+	return openapi3filter.ValidateResponse(r.Context(), &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: input,
+		Status: status,
+		Header: header,
+		Body: io.NopCloser(bytes.NewReader(body)),
+		Options: &openapi3filter.Options{
+			IncludeResponseStatus: true,
+			ExcludeResponseBody: mediaType != "" && !strings.HasSuffix(mediaType, "json"),
+			MultiError: true,
+		},
+	})
+}
@@ -0,0 +1,31 @@
+package openapi_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/teamcutter/subscriptions-service-task/docs"
+	"github.com/teamcutter/subscriptions-service-task/internal/openapi"
+)
+
+func TestValidateResponse(t *testing.T) {
+	validator, err := openapi.NewValidator(docs.OpenAPI)
+	require.NoError(t, err)
+
+	header := http.Header{"Content-Type": []string{"application/json"}}
+	req := httptest.NewRequest(http.MethodGet, "/v1/subscriptions/total", nil)
+
+	assert.NoError(t, validator.ValidateResponse(req, "", http.StatusOK, header, []byte(`{"total":400,"currency":"RUB"}`)))
+	assert.Error(t, validator.ValidateResponse(req, "", http.StatusOK, header, []byte(`{"total":"400","currency":"RUB"}`)))
+	// undocumented statuses are drift as well
+	assert.Error(t, validator.ValidateResponse(req, "", http.StatusTeapot, header, []byte(`"teapot"`)))
+
+	unversioned := httptest.NewRequest(http.MethodGet, "/subscriptions/total", nil)
+	assert.NoError(t, validator.ValidateResponse(unversioned, "/v1", http.StatusOK, header, []byte(`{"total":400,"currency":"RUB"}`)))
+	assert.True(t, errors.Is(validator.ValidateResponse(unversioned, "", http.StatusOK, header, nil), openapi.ErrUnknownRoute))
+}
@@ -0,0 +1,81 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+)
+
+// Broker publishes events. Publish returns once the broker took the event,
+// after an error the same event is published again later
+type Broker interface {
+	Publish(ctx context.Context, event model.Event) error
+}
+
+// Handler consumes events published in process
+type Handler func(context.Context, model.Event) error
+
+// InProcessBroker passes events to handlers of the same process. Publishing
+// fails when any handler fails and is retried for all of them, so handlers
+// must tolerate events they already consumed
+type InProcessBroker struct {
+	mu sync.RWMutex
+	handlers []Handler
+}
+
+func NewInProcessBroker() *InProcessBroker {
+	return &InProcessBroker{}
+}
+
+func (b *InProcessBroker) Subscribe(h Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers = append(b.handlers, h)
+}
+
+func (b *InProcessBroker) Publish(ctx context.Context, event model.Event) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for _, h := range b.handlers {
+		if err := h(ctx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LogBroker writes events to log, it is useful in development
+type LogBroker struct {
+	logger *slog.Logger
+}
+
+func NewLogBroker(logger *slog.Logger) *LogBroker {
+	return &LogBroker{logger: logger}
+}
+
+func (b *LogBroker) Publish(_ context.Context, event model.Event) error {
+	b.logger.Info("event published",
+		"event_id", event.ID,
+		"event", event.Type,
+		"tenant_id", event.TenantID,
+		"subscription_id", event.SubscriptionID,
+	)
+	return nil
+}
+
+// Fanout publishes every event to all brokers
+type Fanout []Broker
+
+func (f Fanout) Publish(ctx context.Context, event model.Event) error {
+	var errs []error
+	for _, b := range f {
+		if err := b.Publish(ctx, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,55 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+)
+
+// NATSBroker publishes events to a JetStream stream, Publish returns after
+// the stream acknowledged the event. The event id is sent as Nats-Msg-Id
+// so the stream drops events published again within its duplicate window
+type NATSBroker struct {
+	js jetstream.JetStream
+	prefix string
+}
+
+// NewNATSBroker creates stream if it does not exist, the stream keeps
+// all subjects under prefix
+func NewNATSBroker(ctx context.Context, nc *nats.Conn, stream, prefix string) (*NATSBroker, error) {
+	js, err := jetstream.New(nc)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+		Name: stream,
+		Subjects: []string{prefix + ".>"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &NATSBroker{js: js, prefix: prefix}, nil
+}
+
+// Subject returns subject of event, e.g. subscriptions.acme.subscription.created
+func (b *NATSBroker) Subject(event model.Event) string {
+	return b.prefix + "." + event.TenantID + "." + event.Type
+}
+
+func (b *NATSBroker) Publish(ctx context.Context, event model.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	msg := nats.NewMsg(b.Subject(event))
+	msg.Header.Set(nats.MsgIdHdr, event.ID.String())
+	msg.Data = data
+
+	_, err = b.js.PublishMsg(ctx, msg)
+	return err
+}
@@ -0,0 +1,68 @@
+package outbox_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+	"github.com/teamcutter/subscriptions-service-task/internal/outbox"
+)
+
+func runNATS(t *testing.T) *nats.Conn {
+	srv, err := server.NewServer(&server.Options{Host: "127.0.0.1", Port: -1, JetStream: true, StoreDir: t.TempDir()})
+	require.NoError(t, err)
+	go srv.Start()
+	t.Cleanup(srv.Shutdown)
+	require.True(t, srv.ReadyForConnections(5*time.Second))
+
+	nc, err := nats.Connect(srv.ClientURL())
+	require.NoError(t, err)
+	t.Cleanup(nc.Close)
+	return nc
+}
+
+func TestNATSBroker(t *testing.T) {
+	ctx := context.Background()
+	nc := runNATS(t)
+
+	broker, err := outbox.NewNATSBroker(ctx, nc, "SUBSCRIPTIONS", "subscriptions")
+	require.NoError(t, err)
+
+	created := model.Event{ID: uuid.New(), Type: model.EventSubscriptionCreated, TenantID: "acme", SubscriptionID: 1}
+	cancelled := model.Event{ID: uuid.New(), Type: model.EventSubscriptionCancelled, TenantID: "acme", SubscriptionID: 1}
+	require.NoError(t, broker.Publish(ctx, created))
+	require.NoError(t, broker.Publish(ctx, cancelled))
+	// published again after a crash, the stream drops it
+	require.NoError(t, broker.Publish(ctx, created))
+
+	js, err := jetstream.New(nc)
+	require.NoError(t, err)
+	consumer, err := js.CreateOrUpdateConsumer(ctx, "SUBSCRIPTIONS", jetstream.ConsumerConfig{})
+	require.NoError(t, err)
+
+	batch, err := consumer.Fetch(3, jetstream.FetchMaxWait(time.Second))
+	require.NoError(t, err)
+
+	var subjects []string
+	var ids []uuid.UUID
+	for msg := range batch.Messages() {
+		var event model.Event
+		require.NoError(t, json.Unmarshal(msg.Data(), &event))
+		subjects = append(subjects, msg.Subject())
+		ids = append(ids, event.ID)
+		assert.Equal(t, event.ID.String(), msg.Headers().Get(nats.MsgIdHdr))
+	}
+	require.NoError(t, batch.Error())
+
+	assert.Equal(t, []string{"subscriptions.acme.subscription.created", "subscriptions.acme.subscription.cancelled"}, subjects)
+	assert.Equal(t, []uuid.UUID{created.ID, cancelled.ID}, ids)
+}
@@ -0,0 +1,123 @@
+package outbox
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+)
+
+const (
+	claimBatch = 20
+	publishTimeout = 10 * time.Second
+	// lease hides claimed events from other instances until they are
+	// published. Events of a batch are published one by one, so to keep
+	// them in order the lease covers the whole batch timing out, with a
+	// minute left for the store
+	lease = claimBatch*publishTimeout + time.Minute
+	// retryBase is the delay after the first failed attempt, it doubles
+	// with every next one up to retryMax. Events are never dropped
+	retryBase = time.Second
+	retryMax = 5 * time.Minute
+)
+
+var eventsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "outbox_events_total",
+	Help: "Total number of outbox publish attempts",
+}, []string{"event", "outcome"})
+
+type Store interface {
+	Claim(limit int, lease time.Duration) ([]model.OutboxEntry, error)
+	MarkPublished(id int64) error
+	MarkFailed(id int64, reason string, retryAfter time.Duration) error
+}
+
+// Relay publishes events from the outbox to broker. Delivery is at least
+// once: an event published before a crash is published again, consumers
+// drop duplicates by event id
+type Relay struct {
+	store Store
+	broker Broker
+	logger *slog.Logger
+}
+
+func NewRelay(store Store, broker Broker, logger *slog.Logger) *Relay {
+	return &Relay{store: store, broker: broker, logger: logger}
+}
+
+// Run ticks every interval until ctx is done
+func (r *Relay) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		r.Tick(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Tick publishes all events due now. Publishing an event makes the next
+// event of its subscription due, so claiming repeats while events are published
+func (r *Relay) Tick(ctx context.Context) {
+	for ctx.Err() == nil {
+		entries, err := r.store.Claim(claimBatch, lease)
+		if err != nil {
+			r.logger.Error("claim outbox events error", "error", err)
+			return
+		}
+
+		published := 0
+		for _, entry := range entries {
+			if r.publish(ctx, entry) {
+				published++
+			}
+		}
+		if published == 0 {
+			return
+		}
+	}
+}
+
+// retryAfter returns delay before the next attempt
+func retryAfter(attempts int) time.Duration {
+	if attempts > 20 {
+		return retryMax
+	}
+	return min(retryBase<<(attempts-1), retryMax)
+}
+
+func (r *Relay) publish(ctx context.Context, entry model.OutboxEntry) bool {
+	publishCtx, cancel := context.WithTimeout(ctx, publishTimeout)
+	defer cancel()
+
+	err := r.broker.Publish(publishCtx, entry.Event)
+	if err == nil {
+		eventsTotal.WithLabelValues(entry.Event.Type, "published").Inc()
+		if err := r.store.MarkPublished(entry.ID); err != nil {
+			r.logger.Error("mark outbox event published error", "error", err)
+		}
+		return true
+	}
+
+	retry := retryAfter(entry.Attempts)
+	eventsTotal.WithLabelValues(entry.Event.Type, "failed").Inc()
+	r.logger.Warn("publish outbox event failed",
+		"event_id", entry.Event.ID,
+		"subscription_id", entry.Event.SubscriptionID,
+		"attempts", entry.Attempts,
+		"retry_after", retry,
+		"error", err,
+	)
+
+	if err := r.store.MarkFailed(entry.ID, err.Error(), retry); err != nil {
+		r.logger.Error("mark outbox event failed error", "error", err)
+	}
+	return false
+}
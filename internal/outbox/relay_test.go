@@ -0,0 +1,109 @@
+package outbox_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+	"github.com/teamcutter/subscriptions-service-task/internal/outbox"
+)
+
+// fakeStore hands out the oldest pending event of every subscription
+// like the database store does
+type fakeStore struct {
+	pending []model.OutboxEntry
+	published []int64
+	retries map[int64]time.Duration
+}
+
+func (s *fakeStore) Claim(limit int, _ time.Duration) ([]model.OutboxEntry, error) {
+	seen := map[int64]bool{}
+	var claimed []model.OutboxEntry
+	for i, entry := range s.pending {
+		id := entry.Event.SubscriptionID
+		if seen[id] || len(claimed) == limit {
+			seen[id] = true
+			continue
+		}
+		seen[id] = true
+		if _, failed := s.retries[entry.ID]; failed {
+			continue
+		}
+		s.pending[i].Attempts++
+		claimed = append(claimed, s.pending[i])
+	}
+	return claimed, nil
+}
+
+func (s *fakeStore) MarkPublished(id int64) error {
+	s.published = append(s.published, id)
+	for i, entry := range s.pending {
+		if entry.ID == id {
+			s.pending = append(s.pending[:i], s.pending[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (s *fakeStore) MarkFailed(id int64, _ string, retryAfter time.Duration) error {
+	s.retries[id] = retryAfter
+	return nil
+}
+
+func entry(id, subscriptionID int64, eventType string) model.OutboxEntry {
+	return model.OutboxEntry{ID: id, Event: model.Event{ID: uuid.New(), Type: eventType, SubscriptionID: subscriptionID}}
+}
+
+func TestRelayKeepsOrderOfSubscription(t *testing.T) {
+	store := &fakeStore{
+		retries: map[int64]time.Duration{},
+		pending: []model.OutboxEntry{
+			entry(1, 10, model.EventSubscriptionCreated),
+			entry(2, 20, model.EventSubscriptionCreated),
+			entry(3, 10, model.EventSubscriptionPriceChanged),
+			entry(4, 20, model.EventSubscriptionDeleted),
+			entry(5, 10, model.EventSubscriptionCancelled),
+		},
+	}
+
+	var received []int64
+	broker := outbox.NewInProcessBroker()
+	broker.Subscribe(func(_ context.Context, event model.Event) error {
+		if event.SubscriptionID == 20 && event.Type == model.EventSubscriptionDeleted {
+			return errors.New("unavailable")
+		}
+		received = append(received, event.SubscriptionID)
+		return nil
+	})
+
+	outbox.NewRelay(store, broker, slog.Default()).Tick(context.Background())
+
+	assert.Equal(t, []int64{1, 2, 3, 5}, store.published)
+	assert.Equal(t, []int64{10, 20, 10, 10}, received)
+	assert.Equal(t, map[int64]time.Duration{4: time.Second}, store.retries)
+}
+
+func TestFanoutPublishesToAll(t *testing.T) {
+	var first, second int
+	a, b := outbox.NewInProcessBroker(), outbox.NewInProcessBroker()
+	a.Subscribe(func(context.Context, model.Event) error {
+		first++
+		return errors.New("unavailable")
+	})
+	b.Subscribe(func(context.Context, model.Event) error {
+		second++
+		return nil
+	})
+
+	err := outbox.Fanout{a, b, outbox.NewLogBroker(slog.Default())}.Publish(context.Background(), model.Event{})
+	assert.Error(t, err)
+	assert.Equal(t, 1, first)
+	assert.Equal(t, 1, second)
+}
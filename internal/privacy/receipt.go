@@ -0,0 +1,73 @@
+package privacy
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+)
+
+// Signer signs erasure receipts with HMAC-SHA256, the same key verifies them
+type Signer struct {
+	key []byte
+}
+
+func NewSigner(key []byte) *Signer {
+	return &Signer{key: key}
+}
+
+// Subject identifies erased user in receipts without storing the user id
+func Subject(tenantID string, userID uuid.UUID) string {
+	sum := sha256.Sum256([]byte(tenantID + ":" + userID.String()))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// NewReceipt returns unsigned receipt for erasure of user
+func NewReceipt(tenantID string, userID uuid.UUID) *model.ErasureReceipt {
+	return &model.ErasureReceipt{
+		ID: uuid.New(),
+		TenantID: tenantID,
+		Subject: Subject(tenantID, userID),
+		ErasedAt: time.Now().UTC().Truncate(time.Second),
+	}
+}
+
+// digest is HMAC of receipt JSON without signature, map keys are
+// sorted by encoding/json so the result does not depend on order
+func (s *Signer) digest(receipt model.ErasureReceipt) ([]byte, error) {
+	receipt.Signature = ""
+	data, err := json.Marshal(receipt)
+	if err != nil {
+		return nil, err
+	}
+
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write(data)
+	return mac.Sum(nil), nil
+}
+
+func (s *Signer) Sign(receipt *model.ErasureReceipt) error {
+	digest, err := s.digest(*receipt)
+	if err != nil {
+		return err
+	}
+	receipt.Signature = hex.EncodeToString(digest)
+	return nil
+}
+
+func (s *Signer) Verify(receipt model.ErasureReceipt) bool {
+	signature, err := hex.DecodeString(receipt.Signature)
+	if err != nil {
+		return false
+	}
+
+	digest, err := s.digest(receipt)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(signature, digest)
+}
@@ -0,0 +1,34 @@
+package privacy_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+	"github.com/teamcutter/subscriptions-service-task/internal/privacy"
+)
+
+func TestReceiptSignature(t *testing.T) {
+	signer := privacy.NewSigner([]byte("secret"))
+	userID := uuid.New()
+
+	receipt := privacy.NewReceipt(model.DefaultTenant, userID)
+	receipt.Erased = map[string]int64{"subscriptions": 2, "users": 1}
+	require.NoError(t, signer.Sign(receipt))
+	assert.NotContains(t, receipt.Subject, userID.String())
+
+	// receipt stays valid after a round trip through JSON
+	data, err := json.Marshal(receipt)
+	require.NoError(t, err)
+	var decoded model.ErasureReceipt
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.True(t, signer.Verify(decoded))
+
+	decoded.Erased["subscriptions"] = 0
+	assert.False(t, signer.Verify(decoded))
+	assert.False(t, privacy.NewSigner([]byte("other")).Verify(*receipt))
+}
@@ -0,0 +1,139 @@
+package reminder
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+	"github.com/teamcutter/subscriptions-service-task/internal/notify"
+)
+
+const (
+	claimBatch = 20
+	// lease hides a notification being sent from other instances, it
+	// outlasts a batch of deliveries that all take the full send timeout
+	lease = claimBatch*notify.SendTimeout + time.Minute
+	maxAttempts = 5
+	// retryBase is the delay after the first failed attempt, it doubles
+	// with every next one
+	retryBase = time.Minute
+)
+
+var ErrNoNotifier = errors.New("no notifier for channel")
+
+var notificationsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "notifications_total",
+	Help: "Total number of notification delivery attempts",
+}, []string{"channel", "kind", "outcome"})
+
+type Store interface {
+	Schedule(channels []string) (int64, error)
+	Claim(limit int, lease time.Duration) ([]model.Notification, error)
+	MarkSent(id int64) error
+	MarkFailed(id int64, reason string, retryAfter time.Duration) error
+}
+
+// Scheduler creates reminders about upcoming subscription dates and
+// delivers them through notifiers of their channels
+type Scheduler struct {
+	store Store
+	notifiers map[string]notify.Notifier
+	logger *slog.Logger
+}
+
+func NewScheduler(store Store, notifiers map[string]notify.Notifier, logger *slog.Logger) *Scheduler {
+	return &Scheduler{
+		store: store,
+		notifiers: notifiers,
+		logger: logger,
+	}
+}
+
+// Run ticks every interval until ctx is done
+func (s *Scheduler) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		s.Tick(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Tick schedules reminders due today and delivers pending notifications
+func (s *Scheduler) Tick(ctx context.Context) {
+	channels := slices.Sorted(maps.Keys(s.notifiers))
+	scheduled, err := s.store.Schedule(channels)
+	if err != nil {
+		s.logger.Error("schedule reminders error", "error", err)
+	} else if scheduled > 0 {
+		s.logger.Info("reminders scheduled", "count", scheduled)
+	}
+
+	for ctx.Err() == nil {
+		notifications, err := s.store.Claim(claimBatch, lease)
+		if err != nil {
+			s.logger.Error("claim notifications error", "error", err)
+			return
+		}
+		for _, n := range notifications {
+			s.deliver(ctx, n)
+		}
+		if len(notifications) < claimBatch {
+			return
+		}
+	}
+}
+
+// retryAfter returns delay before the next attempt, zero after the last one
+func retryAfter(attempts int) time.Duration {
+	if attempts >= maxAttempts {
+		return 0
+	}
+	return retryBase << (attempts - 1)
+}
+
+func (s *Scheduler) deliver(ctx context.Context, n model.Notification) {
+	err := ErrNoNotifier
+	if notifier, ok := s.notifiers[n.Channel]; ok {
+		sendCtx, cancel := context.WithTimeout(ctx, notify.SendTimeout)
+		err = notifier.Notify(sendCtx, n)
+		cancel()
+	}
+
+	if err == nil {
+		notificationsTotal.WithLabelValues(n.Channel, n.Kind, model.NotificationSent).Inc()
+		if err := s.store.MarkSent(n.ID); err != nil {
+			s.logger.Error("mark notification sent error", "error", err)
+		}
+		return
+	}
+
+	retry := retryAfter(n.Attempts)
+	outcome := model.NotificationPending
+	if retry == 0 {
+		outcome = model.NotificationFailed
+	}
+	notificationsTotal.WithLabelValues(n.Channel, n.Kind, outcome).Inc()
+	s.logger.Warn("notification delivery failed",
+		"notification_id", n.ID,
+		"channel", n.Channel,
+		"attempts", n.Attempts,
+		"retry_after", retry,
+		"error", err,
+	)
+
+	if err := s.store.MarkFailed(n.ID, err.Error(), retry); err != nil {
+		s.logger.Error("mark notification failed error", "error", err)
+	}
+}
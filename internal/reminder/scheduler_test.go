@@ -0,0 +1,80 @@
+package reminder_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+	"github.com/teamcutter/subscriptions-service-task/internal/notify"
+	"github.com/teamcutter/subscriptions-service-task/internal/reminder"
+)
+
+type fakeStore struct {
+	channels []string
+	pending []model.Notification
+	sent []int64
+	retries map[int64]time.Duration
+}
+
+func (s *fakeStore) Schedule(channels []string) (int64, error) {
+	s.channels = channels
+	return 0, nil
+}
+
+func (s *fakeStore) Claim(limit int, _ time.Duration) ([]model.Notification, error) {
+	n := min(limit, len(s.pending))
+	claimed := s.pending[:n]
+	s.pending = s.pending[n:]
+	return claimed, nil
+}
+
+func (s *fakeStore) MarkSent(id int64) error {
+	s.sent = append(s.sent, id)
+	return nil
+}
+
+func (s *fakeStore) MarkFailed(id int64, _ string, retryAfter time.Duration) error {
+	s.retries[id] = retryAfter
+	return nil
+}
+
+type notifierFunc func(model.Notification) error
+
+func (f notifierFunc) Notify(_ context.Context, n model.Notification) error {
+	return f(n)
+}
+
+func TestTickDeliversAndRetries(t *testing.T) {
+	store := &fakeStore{
+		retries: map[int64]time.Duration{},
+		pending: []model.Notification{
+			{ID: 1, Channel: model.ChannelEmail, Attempts: 1},
+			{ID: 2, Channel: model.ChannelWebhook, Attempts: 1},
+			{ID: 3, Channel: model.ChannelWebhook, Attempts: 3},
+			{ID: 4, Channel: model.ChannelWebhook, Attempts: 5},
+			{ID: 5, Channel: "sms", Attempts: 1},
+		},
+	}
+	failing := notifierFunc(func(model.Notification) error { return errors.New("unavailable") })
+	scheduler := reminder.NewScheduler(store, map[string]notify.Notifier{
+		model.ChannelEmail: notifierFunc(func(model.Notification) error { return nil }),
+		model.ChannelWebhook: failing,
+	}, slog.Default())
+
+	scheduler.Tick(context.Background())
+
+	assert.Equal(t, []string{model.ChannelEmail, model.ChannelWebhook}, store.channels)
+	assert.Equal(t, []int64{1}, store.sent)
+	assert.Equal(t, map[int64]time.Duration{
+		2: time.Minute,
+		3: 4 * time.Minute,
+		// the last attempt is not retried
+		4: 0,
+		5: time.Minute,
+	}, store.retries)
+}
@@ -0,0 +1,121 @@
+package repo
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+	"github.com/teamcutter/subscriptions-service-task/internal/auth"
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+)
+
+var ErrAPIKeyNotFound = errors.New("api key not found")
+
+// APIKeyRepo methods taking a tenant only see keys of that tenant,
+// empty tenant means keys of all tenants and platform keys
+type APIKeyRepo interface {
+	Create(*model.APIKey, string) error
+	List(string) ([]model.APIKey, error)
+	Rotate(string, int64, string, string) (*model.APIKey, error)
+	Revoke(string, int64) error
+	// Authenticate finds active key by hash and marks it as used
+	Authenticate(string) (*model.APIKey, error)
+}
+
+type PostgresAPIKeyRepo struct {
+	db *sql.DB
+}
+
+func NewAPIKeyRepo(db *sql.DB) *PostgresAPIKeyRepo {
+	return &PostgresAPIKeyRepo{db: db}
+}
+
+const apiKeyColumns = `id, COALESCE(tenant_id, ''), name, prefix, scopes, expires_at, last_used_at, revoked_at, created_at`
+
+func scanAPIKey(row rowScanner) (*model.APIKey, error) {
+	var key model.APIKey
+	err := row.Scan(&key.ID, &key.TenantID, &key.Name, &key.Prefix, pq.Array(&key.Scopes),
+		&key.ExpiresAt, &key.LastUsedAt, &key.RevokedAt, &key.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (r *PostgresAPIKeyRepo) Create(key *model.APIKey, hash string) error {
+	err := r.db.QueryRow(
+		`INSERT INTO api_keys (tenant_id, name, prefix, key_hash, scopes, expires_at) VALUES (NULLIF($1, ''), $2, $3, $4, $5, $6) RETURNING id, created_at`,
+		key.TenantID, key.Name, key.Prefix, hash, pq.Array(key.Scopes), key.ExpiresAt).Scan(&key.ID, &key.CreatedAt)
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+		return ErrTenantNotFound
+	}
+	return err
+}
+
+func (r *PostgresAPIKeyRepo) List(tenantID string) ([]model.APIKey, error) {
+	rows, err := r.db.Query(
+		`SELECT `+apiKeyColumns+` FROM api_keys WHERE ($1 = '' OR tenant_id = $1) ORDER BY id`, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []model.APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *key)
+	}
+	return keys, rows.Err()
+}
+
+// Rotate replaces the secret of an active key keeping its name, scopes and expiry
+func (r *PostgresAPIKeyRepo) Rotate(tenantID string, id int64, prefix, hash string) (*model.APIKey, error) {
+	return scanAPIKey(r.db.QueryRow(
+		`UPDATE api_keys SET prefix = $2, key_hash = $3, last_used_at = NULL
+		WHERE id = $1 AND revoked_at IS NULL AND ($4 = '' OR tenant_id = $4)
+		RETURNING `+apiKeyColumns,
+		id, prefix, hash, tenantID))
+}
+
+func (r *PostgresAPIKeyRepo) Revoke(tenantID string, id int64) error {
+	res, err := r.db.Exec(
+		`UPDATE api_keys SET revoked_at = now() WHERE id = $1 AND revoked_at IS NULL AND ($2 = '' OR tenant_id = $2)`,
+		id, tenantID)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+func (r *PostgresAPIKeyRepo) Authenticate(hash string) (*model.APIKey, error) {
+	return scanAPIKey(r.db.QueryRow(
+		`UPDATE api_keys SET last_used_at = now()
+		WHERE key_hash = $1 AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > now())
+		RETURNING `+apiKeyColumns,
+		hash))
+}
+
+// Bootstrap makes sure an admin key with given secret exists,
+// so that the first keys can be created through the API
+func (r *PostgresAPIKeyRepo) Bootstrap(name, prefix, hash string) error {
+	_, err := r.db.Exec(
+		`INSERT INTO api_keys (name, prefix, key_hash, scopes) VALUES ($1, $2, $3, $4) ON CONFLICT (key_hash) DO NOTHING`,
+		name, prefix, hash, pq.Array(auth.Scopes))
+	return err
+}
\ No newline at end of file
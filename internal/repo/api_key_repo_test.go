@@ -0,0 +1,42 @@
+package repo_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/teamcutter/subscriptions-service-task/internal/auth"
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+	"github.com/teamcutter/subscriptions-service-task/internal/repo"
+)
+
+func TestAPIKeyLifecycle(t *testing.T) {
+	keys := repo.NewAPIKeyRepo(db)
+
+	key, prefix, hash, err := auth.GenerateAPIKey()
+	require.NoError(t, err)
+
+	apiKey := &model.APIKey{Name: "billing", Prefix: prefix, Scopes: []string{auth.ScopeRead}}
+	require.NoError(t, keys.Create(apiKey, hash))
+
+	found, err := keys.Authenticate(auth.HashAPIKey(key))
+	require.NoError(t, err)
+	assert.Equal(t, []string{auth.ScopeRead}, found.Scopes)
+	assert.NotNil(t, found.LastUsedAt)
+
+	newKey, newPrefix, newHash, err := auth.GenerateAPIKey()
+	require.NoError(t, err)
+	_, err = keys.Rotate("", apiKey.ID, newPrefix, newHash)
+	require.NoError(t, err)
+
+	_, err = keys.Authenticate(auth.HashAPIKey(key))
+	assert.ErrorIs(t, err, repo.ErrAPIKeyNotFound)
+	_, err = keys.Authenticate(auth.HashAPIKey(newKey))
+	require.NoError(t, err)
+
+	require.NoError(t, keys.Revoke("", apiKey.ID))
+	_, err = keys.Authenticate(auth.HashAPIKey(newKey))
+	assert.ErrorIs(t, err, repo.ErrAPIKeyNotFound)
+	assert.ErrorIs(t, keys.Revoke("", apiKey.ID), repo.ErrAPIKeyNotFound)
+}
\ No newline at end of file
@@ -0,0 +1,25 @@
+package repo
+
+import (
+	"database/sql"
+
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+)
+
+type AuditRepo struct {
+	db *sql.DB
+}
+
+func NewAuditRepo(db *sql.DB) *AuditRepo {
+	return &AuditRepo{db: db}
+}
+
+func (r *AuditRepo) Record(entry *model.AuditEntry) error {
+	return recordAudit(r.db, entry)
+}
+
+func recordAudit(q querier, entry *model.AuditEntry) error {
+	return q.QueryRow(
+		`INSERT INTO audit_log (tenant_id, actor, action, resource, outcome) VALUES (NULLIF($1, ''), $2, $3, $4, $5) RETURNING id, created_at`,
+		entry.TenantID, entry.Actor, entry.Action, entry.Resource, entry.Outcome).Scan(&entry.ID, &entry.CreatedAt)
+}
\ No newline at end of file
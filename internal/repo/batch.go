@@ -0,0 +1,274 @@
+package repo
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+)
+
+// ErrBatchAborted is set on operations not applied because another
+// operation of an atomic batch failed
+var ErrBatchAborted = errors.New("batch aborted")
+
+const MaxBatchSize = 1000
+
+// pendingCreate is a validated create operation waiting for bulk insert
+type pendingCreate struct {
+	index int
+	sub *model.Subscription
+	dates dbDates
+}
+
+// Batch applies operations in one transaction. Creates are inserted with a
+// single multi-row INSERT, updates and deletes run in request order.
+// In atomic mode the first failure rolls back everything, otherwise every
+// operation runs in its own savepoint and fails alone
+func (r *SubscriptionRepo) Batch(ops []model.BatchOperation, atomic bool) ([]model.BatchResult, error) {
+	results := make([]model.BatchResult, len(ops))
+	for i := range ops {
+		results[i].Index = i
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var creates []pendingCreate
+	failed := false
+	for i, op := range ops {
+		switch op.Op {
+		case model.BatchOpCreate, model.BatchOpUpdate:
+			if op.Subscription == nil {
+				results[i].Err = fmt.Errorf("%w: missing subscription", ErrInvalidSubscription)
+				failed = true
+				continue
+			}
+			dates, err := parseDates(op.Subscription)
+			if err != nil {
+				results[i].Err = err
+				failed = true
+				continue
+			}
+			if op.Op == model.BatchOpCreate {
+				creates = append(creates, pendingCreate{i, op.Subscription, dates})
+			}
+		case model.BatchOpDelete:
+		default:
+			results[i].Err = fmt.Errorf("%w: unknown operation %q", ErrInvalidSubscription, op.Op)
+			failed = true
+		}
+	}
+
+	if atomic && failed {
+		return abortBatch(results), nil
+	}
+
+	if err := r.batchCreate(tx, creates, results, atomic); err != nil {
+		if atomic {
+			return abortBatch(results), nil
+		}
+		return nil, err
+	}
+
+	for i, op := range ops {
+		if results[i].Err != nil || op.Op == model.BatchOpCreate {
+			continue
+		}
+
+		err := inSavepoint(tx, func() error {
+			if op.Op == model.BatchOpDelete {
+				return remove(tx, r.tenantID, int(op.ID), op.Version)
+			}
+			op.Subscription.ID = op.ID
+			return update(tx, r.tenantID, op.Subscription, op.Version)
+		})
+		if err != nil {
+			results[i].Err = err
+			if atomic {
+				return abortBatch(results), nil
+			}
+			continue
+		}
+
+		results[i].ID = op.ID
+		if op.Op == model.BatchOpUpdate {
+			results[i].Version = op.Subscription.Version
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// batchCreate inserts all creates at once. In best-effort mode a failed bulk
+// insert falls back to inserting rows one by one to find the broken ones
+func (r *SubscriptionRepo) batchCreate(tx *sql.Tx, creates []pendingCreate, results []model.BatchResult, atomic bool) error {
+	if len(creates) == 0 {
+		return nil
+	}
+
+	err := inSavepoint(tx, func() error {
+		return bulkInsert(tx, r.tenantID, creates, results)
+	})
+	if err == nil {
+		return nil
+	}
+	if atomic {
+		for _, c := range creates {
+			results[c.index].Err = err
+		}
+		return err
+	}
+
+	for _, c := range creates {
+		err := inSavepoint(tx, func() error {
+			return bulkInsert(tx, r.tenantID, []pendingCreate{c}, results)
+		})
+		if err != nil {
+			results[c.index].Err = err
+		}
+	}
+	return nil
+}
+
+// bulkInsert inserts creates with one statement. RETURNING does not keep
+// the order of VALUES, so ids are taken from the sequence first and rows
+// are matched to creates by id
+func bulkInsert(tx *sql.Tx, tenantID string, creates []pendingCreate, results []model.BatchResult) error {
+	ids, err := nextSubscriptionIDs(tx, len(creates))
+	if err != nil {
+		return err
+	}
+
+	byID := make(map[int64]pendingCreate, len(creates))
+	placeholders := make([]string, 0, len(creates))
+	args := make([]any, 0, len(creates)*8)
+	for i, c := range creates {
+		n := i * 8
+		placeholders = append(placeholders,
+			fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4, n+5, n+6, n+7, n+8))
+		args = append(args, ids[i], c.sub.ServiceName, c.sub.Price, c.sub.UserID, c.dates.start, c.dates.end, c.dates.trialEnd, tenantID)
+		byID[ids[i]] = c
+	}
+
+	query := `INSERT INTO subscriptions (id, service_name, price, user_id, start_date, end_date, trial_end_date, tenant_id) VALUES ` +
+		strings.Join(placeholders, ", ") +
+		` RETURNING id, version`
+
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		var version int
+		if err := rows.Scan(&id, &version); err != nil {
+			return err
+		}
+		c := byID[id]
+		c.sub.ID, c.sub.Version = id, version
+		results[c.index].ID = id
+		results[c.index].Version = version
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	// events follow the order of operations
+	events := make([]model.Event, 0, len(creates))
+	for _, c := range creates {
+		events = append(events, newEvent(tenantID, model.EventSubscriptionCreated, c.sub.ID, c.sub.UserID, c.sub))
+	}
+	return recordEvents(tx, events...)
+}
+
+func nextSubscriptionIDs(tx *sql.Tx, n int) ([]int64, error) {
+	rows, err := tx.Query(`SELECT nextval(pg_get_serial_sequence('subscriptions', 'id')) FROM generate_series(1, $1)`, n)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make([]int64, 0, n)
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// inSavepoint runs fn so that its failure leaves the transaction usable
+func inSavepoint(tx *sql.Tx, fn func() error) error {
+	if _, err := tx.Exec(`SAVEPOINT batch_item`); err != nil {
+		return err
+	}
+	if err := fn(); err != nil {
+		if _, rbErr := tx.Exec(`ROLLBACK TO SAVEPOINT batch_item`); rbErr != nil {
+			return rbErr
+		}
+		return err
+	}
+	_, err := tx.Exec(`RELEASE SAVEPOINT batch_item`)
+	return err
+}
+
+// filterBatch passes to r only operations accepted by check, the rest fail
+// with the error of check. check may replace subscription of the operation
+func filterBatch(r Repo, ops []model.BatchOperation, atomic bool, check func(*model.BatchOperation) error) ([]model.BatchResult, error) {
+	results := make([]model.BatchResult, len(ops))
+	allowed := make([]model.BatchOperation, 0, len(ops))
+	positions := make([]int, 0, len(ops))
+
+	for i, op := range ops {
+		results[i].Index = i
+		if err := check(&op); err != nil {
+			results[i].Err = err
+			continue
+		}
+		allowed = append(allowed, op)
+		positions = append(positions, i)
+	}
+
+	if atomic && len(allowed) < len(ops) {
+		return abortBatch(results), nil
+	}
+	if len(allowed) == 0 {
+		return results, nil
+	}
+
+	inner, err := r.Batch(allowed, atomic)
+	if err != nil {
+		return nil, err
+	}
+	for i, res := range inner {
+		res.Index = positions[i]
+		results[positions[i]] = res
+	}
+	return results, nil
+}
+
+// abortBatch marks every operation without own error as aborted
+// and clears ids of rows that were rolled back
+func abortBatch(results []model.BatchResult) []model.BatchResult {
+	for i := range results {
+		results[i].ID = 0
+		results[i].Version = 0
+		if results[i].Err == nil {
+			results[i].Err = ErrBatchAborted
+		}
+	}
+	return results
+}
\ No newline at end of file
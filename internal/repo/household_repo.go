@@ -0,0 +1,316 @@
+package repo
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+	"github.com/teamcutter/subscriptions-service-task/internal/utils"
+)
+
+var (
+	ErrHouseholdNotFound = errors.New("household not found")
+	ErrMemberNotFound = errors.New("household member not found")
+	ErrSplitNotFound = errors.New("split not found")
+)
+
+type HouseholdRepo interface {
+	// Create stores household with its owner as the first member
+	Create(*model.Household) error
+	Get(int64) (*model.Household, error)
+	ListForUser(uuid.UUID) ([]model.Household, error)
+	Delete(int64) error
+	AddMember(int64, uuid.UUID) error
+	// RemoveMember also drops parts of the member in splits of the household
+	RemoveMember(int64, uuid.UUID) error
+	SetSplit(*model.Split) error
+	GetSplit(int64) (*model.Split, error)
+	DeleteSplit(int64) error
+	// Costs returns subscriptions shared in household active between
+	// start and end (MM-YYYY)
+	Costs(int64, string, string) ([]model.Cost, error)
+	ForTenant(string) HouseholdRepo
+}
+
+type PostgresHouseholdRepo struct {
+	db *sql.DB
+	tenantID string
+}
+
+func NewHouseholdRepo(db *sql.DB) *PostgresHouseholdRepo {
+	return &PostgresHouseholdRepo{db: db, tenantID: model.DefaultTenant}
+}
+
+func (r *PostgresHouseholdRepo) ForTenant(tenantID string) HouseholdRepo {
+	return &PostgresHouseholdRepo{db: r.db, tenantID: tenantID}
+}
+
+func (r *PostgresHouseholdRepo) Create(household *model.Household) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(
+		`INSERT INTO households (tenant_id, name, owner_id) VALUES ($1, $2, $3) RETURNING id, created_at`,
+		r.tenantID, household.Name, household.OwnerID).Scan(&household.ID, &household.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`INSERT INTO household_members (household_id, user_id) VALUES ($1, $2)`, household.ID, household.OwnerID)
+	if err != nil {
+		return err
+	}
+	household.Members = []uuid.UUID{household.OwnerID}
+	return tx.Commit()
+}
+
+// householdColumns selects household with its members ordered by id
+const householdColumns = `h.id, h.name, h.owner_id, h.created_at,
+	ARRAY(SELECT m.user_id::text FROM household_members m WHERE m.household_id = h.id ORDER BY m.user_id)`
+
+func scanHousehold(row rowScanner) (*model.Household, error) {
+	var household model.Household
+	var members pq.StringArray
+
+	err := row.Scan(&household.ID, &household.Name, &household.OwnerID, &household.CreatedAt, &members)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrHouseholdNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	household.Members, err = parseUUIDs(members)
+	if err != nil {
+		return nil, err
+	}
+	return &household, nil
+}
+
+func parseUUIDs(values []string) ([]uuid.UUID, error) {
+	ids := make([]uuid.UUID, 0, len(values))
+	for _, v := range values {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func (r *PostgresHouseholdRepo) Get(id int64) (*model.Household, error) {
+	return scanHousehold(r.db.QueryRow(
+		`SELECT `+householdColumns+` FROM households h WHERE h.id = $1 AND h.tenant_id = $2`, id, r.tenantID))
+}
+
+func (r *PostgresHouseholdRepo) ListForUser(userID uuid.UUID) ([]model.Household, error) {
+	return listHouseholds(r.db, r.tenantID, userID)
+}
+
+func listHouseholds(q querier, tenantID string, userID uuid.UUID) ([]model.Household, error) {
+	rows, err := q.Query(
+		`SELECT `+householdColumns+` FROM households h
+		WHERE h.tenant_id = $1
+		AND EXISTS (SELECT 1 FROM household_members m WHERE m.household_id = h.id AND m.user_id = $2)
+		ORDER BY h.id`,
+		tenantID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	households := []model.Household{}
+	for rows.Next() {
+		household, err := scanHousehold(rows)
+		if err != nil {
+			return nil, err
+		}
+		households = append(households, *household)
+	}
+	return households, rows.Err()
+}
+
+// affected returns notFound when statement did not change any row
+func affected(res sql.Result, notFound error) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return notFound
+	}
+	return nil
+}
+
+func (r *PostgresHouseholdRepo) Delete(id int64) error {
+	res, err := r.db.Exec(`DELETE FROM households WHERE id = $1 AND tenant_id = $2`, id, r.tenantID)
+	if err != nil {
+		return err
+	}
+	return affected(res, ErrHouseholdNotFound)
+}
+
+// AddMember of an existing member is a no-op
+func (r *PostgresHouseholdRepo) AddMember(id int64, userID uuid.UUID) error {
+	if _, err := r.Get(id); err != nil {
+		return err
+	}
+
+	_, err := r.db.Exec(
+		`INSERT INTO household_members (household_id, user_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`, id, userID)
+	return err
+}
+
+func (r *PostgresHouseholdRepo) RemoveMember(id int64, userID uuid.UUID) error {
+	res, err := r.db.Exec(
+		`DELETE FROM household_members m USING households h
+		WHERE m.household_id = h.id AND h.id = $1 AND h.tenant_id = $2 AND m.user_id = $3`,
+		id, r.tenantID, userID)
+	if err != nil {
+		return err
+	}
+	return affected(res, ErrMemberNotFound)
+}
+
+// SetSplit replaces split of subscription, household and subscription
+// are expected to be checked by caller
+func (r *PostgresHouseholdRepo) SetSplit(split *model.Split) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		`INSERT INTO subscription_splits (subscription_id, household_id, rule) VALUES ($1, $2, $3)
+		ON CONFLICT (subscription_id) DO UPDATE SET household_id = $2, rule = $3`,
+		split.SubscriptionID, split.HouseholdID, split.Rule)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`DELETE FROM subscription_split_parts WHERE subscription_id = $1`, split.SubscriptionID)
+	if err != nil {
+		return err
+	}
+
+	for _, part := range split.Parts {
+		_, err = tx.Exec(
+			`INSERT INTO subscription_split_parts (subscription_id, household_id, user_id, value) VALUES ($1, $2, $3, $4)`,
+			split.SubscriptionID, split.HouseholdID, part.UserID, part.Value)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+const splitParts = `COALESCE((SELECT json_agg(json_build_object('user_id', p.user_id, 'value', p.value) ORDER BY p.user_id)
+	FROM subscription_split_parts p WHERE p.subscription_id = s.id), '[]')`
+
+func (r *PostgresHouseholdRepo) GetSplit(subscriptionID int64) (*model.Split, error) {
+	var split model.Split
+	var parts []byte
+
+	err := r.db.QueryRow(
+		`SELECT sp.subscription_id, sp.household_id, sp.rule, `+splitParts+`
+		FROM subscription_splits sp JOIN subscriptions s ON s.id = sp.subscription_id
+		WHERE sp.subscription_id = $1 AND s.tenant_id = $2`,
+		subscriptionID, r.tenantID).Scan(&split.SubscriptionID, &split.HouseholdID, &split.Rule, &parts)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrSplitNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(parts, &split.Parts); err != nil {
+		return nil, err
+	}
+	return &split, nil
+}
+
+func (r *PostgresHouseholdRepo) DeleteSplit(subscriptionID int64) error {
+	res, err := r.db.Exec(
+		`DELETE FROM subscription_splits sp USING subscriptions s
+		WHERE s.id = sp.subscription_id AND sp.subscription_id = $1 AND s.tenant_id = $2`,
+		subscriptionID, r.tenantID)
+	if err != nil {
+		return err
+	}
+	return affected(res, ErrSplitNotFound)
+}
+
+func (r *PostgresHouseholdRepo) Costs(id int64, start, end string) ([]model.Cost, error) {
+	return loadCosts(r.db, r.tenantID, start, end, `AND sp.household_id = $4`, id)
+}
+
+// loadCosts reads subscriptions of tenant active between start and end
+// (MM-YYYY) with their splits, condition may use params from $4 on
+func loadCosts(q querier, tenantID, start, end, condition string, args ...any) ([]model.Cost, error) {
+	query :=
+	`
+	SELECT s.id, s.user_id, s.price,
+		(DATE_PART('year', AGE(LEAST(COALESCE(s.end_date, $2), $2), GREATEST(s.start_date, $1))) * 12 +
+		DATE_PART('month', AGE(LEAST(COALESCE(s.end_date, $2), $2), GREATEST(s.start_date, $1))) + 1)::int,
+		sp.household_id, sp.rule,
+		ARRAY(SELECT m.user_id::text FROM household_members m WHERE m.household_id = sp.household_id ORDER BY m.user_id),
+		` + splitParts + `
+	FROM subscriptions s
+	LEFT JOIN subscription_splits sp ON sp.subscription_id = s.id
+	WHERE s.tenant_id = $3
+	AND s.start_date <= $2
+	AND (s.end_date >= $1 OR s.end_date IS NULL)
+	` + condition + `
+	ORDER BY s.id
+	`
+
+	startDate, err := utils.ParseDateFromRequest(start)
+	if err != nil {
+		return nil, err
+	}
+
+	endDate, err := utils.ParseDateFromRequest(end)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := q.Query(query, append([]any{startDate, endDate, tenantID}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	costs := []model.Cost{}
+	for rows.Next() {
+		var cost model.Cost
+		var householdID sql.NullInt64
+		var rule sql.NullString
+		var members pq.StringArray
+		var parts []byte
+
+		err := rows.Scan(&cost.SubscriptionID, &cost.Payer, &cost.Price, &cost.Months, &householdID, &rule, &members, &parts)
+		if err != nil {
+			return nil, err
+		}
+
+		if householdID.Valid {
+			cost.Split = &model.Split{SubscriptionID: cost.SubscriptionID, HouseholdID: householdID.Int64, Rule: rule.String}
+			if err := json.Unmarshal(parts, &cost.Split.Parts); err != nil {
+				return nil, err
+			}
+			if cost.Members, err = parseUUIDs(members); err != nil {
+				return nil, err
+			}
+		}
+		costs = append(costs, cost)
+	}
+	return costs, rows.Err()
+}
\ No newline at end of file
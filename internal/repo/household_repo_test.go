@@ -0,0 +1,52 @@
+package repo_test
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+	"github.com/teamcutter/subscriptions-service-task/internal/repo"
+)
+
+func TestSharedTotalCost(t *testing.T) {
+	households := repo.NewHouseholdRepo(db)
+	payer, member := uuid.New(), uuid.New()
+
+	household := &model.Household{Name: "Family", OwnerID: payer}
+	require.NoError(t, households.Create(household))
+	require.NoError(t, households.AddMember(household.ID, member))
+
+	sub := &model.Subscription{ServiceName: "Family plan", Price: 600, UserID: payer, StartDate: "01-2024", EndDate: "02-2024"}
+	require.NoError(t, testRepo.Create(sub))
+
+	split := &model.Split{SubscriptionID: sub.ID, HouseholdID: household.ID, Rule: model.SplitFixed,
+		Parts: []model.SplitPart{{UserID: member, Value: 200}}}
+	require.NoError(t, households.SetSplit(split))
+
+	got, err := households.GetSplit(sub.ID)
+	require.NoError(t, err)
+	assert.Equal(t, split.Parts, got.Parts)
+
+	// two months of 400 and 200
+	total, err := testRepo.TotalCost(payer.String(), "", "01-2024", "12-2024")
+	require.NoError(t, err)
+	assert.Equal(t, 800, total)
+	total, err = testRepo.TotalCost(member.String(), "", "01-2024", "12-2024")
+	require.NoError(t, err)
+	assert.Equal(t, 400, total)
+
+	costs, err := households.Costs(household.ID, "01-2024", "12-2024")
+	require.NoError(t, err)
+	require.Len(t, costs, 1)
+	assert.Equal(t, 2, costs[0].Months)
+	assert.ElementsMatch(t, []uuid.UUID{payer, member}, costs[0].Members)
+
+	// leaving member's part goes back to payer
+	require.NoError(t, households.RemoveMember(household.ID, member))
+	total, err = testRepo.TotalCost(payer.String(), "", "01-2024", "12-2024")
+	require.NoError(t, err)
+	assert.Equal(t, 1200, total)
+}
\ No newline at end of file
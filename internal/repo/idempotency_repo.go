@@ -0,0 +1,107 @@
+package repo
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+)
+
+type IdempotencyRepo struct {
+	db *sql.DB
+}
+
+func NewIdempotencyRepo(db *sql.DB) *IdempotencyRepo {
+	return &IdempotencyRepo{db: db}
+}
+
+// reserveAttempts bounds retries of Reserve when the taken key disappears
+// between the upsert and the read of its record
+const reserveAttempts = 3
+
+// Reserve claims the key of tenant for a new request. If the key is already
+// taken by a not expired record, that record is returned and reserved is false
+func (r *IdempotencyRepo) Reserve(tenantID, key, requestHash string, ttl time.Duration) (*model.IdempotencyRecord, bool, error) {
+	var err error
+	for range reserveAttempts {
+		var record *model.IdempotencyRecord
+		var reserved bool
+		record, reserved, err = r.reserve(tenantID, key, requestHash, ttl)
+		// the record expired or was released after the upsert, try again
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		return record, reserved, err
+	}
+	return nil, false, err
+}
+
+func (r *IdempotencyRepo) reserve(tenantID, key, requestHash string, ttl time.Duration) (*model.IdempotencyRecord, bool, error) {
+	query :=
+	`
+		INSERT INTO idempotency_keys (tenant_id, key, request_hash, expires_at)
+		VALUES ($4, $1, $2, now() + $3::float8 * interval '1 second')
+		ON CONFLICT (tenant_id, key) DO UPDATE
+		SET request_hash = EXCLUDED.request_hash,
+			status_code = 0,
+			response_headers = '{}',
+			response_body = NULL,
+			created_at = now(),
+			expires_at = EXCLUDED.expires_at
+		WHERE idempotency_keys.expires_at < now()
+		RETURNING key
+	`
+
+	var reservedKey string
+	err := r.db.QueryRow(query, key, requestHash, ttl.Seconds(), tenantID).Scan(&reservedKey)
+	if err == nil {
+		return nil, true, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, false, err
+	}
+
+	record := model.IdempotencyRecord{Key: key}
+	var headers []byte
+	err = r.db.QueryRow(
+		`SELECT request_hash, status_code, response_headers, response_body FROM idempotency_keys
+		WHERE tenant_id = $2 AND key = $1 AND expires_at >= now()`,
+		key, tenantID).Scan(&record.RequestHash, &record.StatusCode, &headers, &record.Body)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if err := json.Unmarshal(headers, &record.Headers); err != nil {
+		return nil, false, err
+	}
+	return &record, false, nil
+}
+
+func (r *IdempotencyRepo) Complete(tenantID, key string, status int, headers http.Header, body []byte) error {
+	encoded, err := json.Marshal(headers)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.Exec(
+		`UPDATE idempotency_keys SET status_code = $2, response_headers = $3, response_body = $4 WHERE key = $1 AND tenant_id = $5`,
+		key, status, encoded, body, tenantID)
+	return err
+}
+
+// Release frees the key so the request can be retried, used when it failed
+func (r *IdempotencyRepo) Release(tenantID, key string) error {
+	_, err := r.db.Exec(`DELETE FROM idempotency_keys WHERE key = $1 AND tenant_id = $2`, key, tenantID)
+	return err
+}
+
+func (r *IdempotencyRepo) DeleteExpired() (int64, error) {
+	res, err := r.db.Exec(`DELETE FROM idempotency_keys WHERE expires_at < now()`)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
\ No newline at end of file
@@ -0,0 +1,62 @@
+package repo_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+	"github.com/teamcutter/subscriptions-service-task/internal/repo"
+)
+
+func TestIdempotencyReserveAndComplete(t *testing.T) {
+	idempotencyRepo := repo.NewIdempotencyRepo(db)
+
+	_, reserved, err := idempotencyRepo.Reserve(model.DefaultTenant, "key-1", "hash", time.Hour)
+	require.NoError(t, err)
+	assert.True(t, reserved)
+
+	record, reserved, err := idempotencyRepo.Reserve(model.DefaultTenant, "key-1", "hash", time.Hour)
+	require.NoError(t, err)
+	assert.False(t, reserved)
+	assert.Equal(t, 0, record.StatusCode)
+
+	headers := http.Header{"Location": []string{"/subscriptions/1"}}
+	err = idempotencyRepo.Complete(model.DefaultTenant, "key-1", http.StatusCreated, headers, []byte("ok"))
+	require.NoError(t, err)
+
+	record, _, err = idempotencyRepo.Reserve(model.DefaultTenant, "key-1", "hash", time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, record.StatusCode)
+	assert.Equal(t, "/subscriptions/1", record.Headers.Get("Location"))
+	assert.Equal(t, []byte("ok"), record.Body)
+}
+
+func TestIdempotencyExpiredKeyIsReserved(t *testing.T) {
+	idempotencyRepo := repo.NewIdempotencyRepo(db)
+
+	_, reserved, err := idempotencyRepo.Reserve(model.DefaultTenant, "key-2", "hash", -time.Minute)
+	require.NoError(t, err)
+	assert.True(t, reserved)
+
+	_, reserved, err = idempotencyRepo.Reserve(model.DefaultTenant, "key-2", "other-hash", time.Hour)
+	require.NoError(t, err)
+	assert.True(t, reserved)
+}
+
+func TestIdempotencyKeysOfTenants(t *testing.T) {
+	idempotencyRepo := repo.NewIdempotencyRepo(db)
+	_, err := db.Exec(`INSERT INTO tenants (id, name) VALUES ('idem', 'Idem') ON CONFLICT DO NOTHING`)
+	require.NoError(t, err)
+
+	_, reserved, err := idempotencyRepo.Reserve(model.DefaultTenant, "key-3", "hash", time.Hour)
+	require.NoError(t, err)
+	assert.True(t, reserved)
+
+	_, reserved, err = idempotencyRepo.Reserve("idem", "key-3", "hash", time.Hour)
+	require.NoError(t, err)
+	assert.True(t, reserved)
+}
@@ -0,0 +1,72 @@
+package repo
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+)
+
+var ErrImportJobNotFound = errors.New("import job not found")
+
+type ImportJobRepo interface {
+	Create(*model.ImportJob) error
+	Finish(*model.ImportJob) error
+	Get(uuid.UUID) (*model.ImportJob, error)
+}
+
+type PostgresImportJobRepo struct {
+	db *sql.DB
+}
+
+func NewImportJobRepo(db *sql.DB) *PostgresImportJobRepo {
+	return &PostgresImportJobRepo{db: db}
+}
+
+func (r *PostgresImportJobRepo) Create(job *model.ImportJob) error {
+	job.ID = uuid.New()
+	job.Status = model.ImportJobRunning
+
+	return r.db.QueryRow(
+		`INSERT INTO import_jobs (id, tenant_id, user_id, status, format) VALUES ($1, $2, $3, $4, $5) RETURNING created_at`,
+		job.ID, job.TenantID, job.UserID, job.Status, job.Format).Scan(&job.CreatedAt)
+}
+
+// Finish stores job outcome, status is failed if job.Error is set
+func (r *PostgresImportJobRepo) Finish(job *model.ImportJob) error {
+	job.Status = model.ImportJobDone
+	if job.Error != "" {
+		job.Status = model.ImportJobFailed
+	}
+
+	report, err := json.Marshal(job.Report)
+	if err != nil {
+		return err
+	}
+
+	return r.db.QueryRow(
+		`UPDATE import_jobs SET status = $2, report = $3, error = $4, finished_at = now() WHERE id = $1 RETURNING finished_at`,
+		job.ID, job.Status, report, job.Error).Scan(&job.FinishedAt)
+}
+
+func (r *PostgresImportJobRepo) Get(id uuid.UUID) (*model.ImportJob, error) {
+	var job model.ImportJob
+	var report []byte
+
+	err := r.db.QueryRow(
+		`SELECT id, tenant_id, user_id, status, format, report, error, created_at, finished_at FROM import_jobs WHERE id = $1`, id).
+		Scan(&job.ID, &job.TenantID, &job.UserID, &job.Status, &job.Format, &report, &job.Error, &job.CreatedAt, &job.FinishedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrImportJobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(report, &job.Report); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
\ No newline at end of file
@@ -0,0 +1,34 @@
+package repo_test
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+	"github.com/teamcutter/subscriptions-service-task/internal/repo"
+)
+
+func TestImportJobLifecycle(t *testing.T) {
+	jobs := repo.NewImportJobRepo(db)
+
+	job := &model.ImportJob{TenantID: model.DefaultTenant, Format: "csv"}
+	require.NoError(t, jobs.Create(job))
+	assert.Equal(t, model.ImportJobRunning, job.Status)
+
+	job.Report = model.ImportReport{TotalRows: 2, Imported: 1, Failed: 1,
+		Errors: []model.ImportRowError{{Line: 3, Error: "invalid price"}}}
+	require.NoError(t, jobs.Finish(job))
+
+	got, err := jobs.Get(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.ImportJobDone, got.Status)
+	assert.Equal(t, 1, got.Report.Imported)
+	assert.Equal(t, 3, got.Report.Errors[0].Line)
+	assert.NotNil(t, got.FinishedAt)
+
+	_, err = jobs.Get(uuid.New())
+	assert.ErrorIs(t, err, repo.ErrImportJobNotFound)
+}
\ No newline at end of file
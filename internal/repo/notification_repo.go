@@ -0,0 +1,124 @@
+package repo
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+)
+
+// NotificationRepo stores reminders of all tenants, it is used by
+// the background scheduler and is not limited to a tenant
+type NotificationRepo struct {
+	db *sql.DB
+}
+
+func NewNotificationRepo(db *sql.DB) *NotificationRepo {
+	return &NotificationRepo{db: db}
+}
+
+// Schedule creates notifications for reminders whose window opened today
+// in the timezone of the user. Renewals happen on the first day of every
+// paid month, subscriptions end on the month after end date. A reminder
+// is created once per subscription, kind, date and channel
+func (r *NotificationRepo) Schedule(channels []string) (int64, error) {
+	query :=
+	`
+		INSERT INTO notifications (tenant_id, subscription_id, user_id, kind, channel, due_date)
+		SELECT s.tenant_id, s.id, s.user_id, d.kind, c.channel, d.due_date
+		FROM subscriptions s
+		JOIN users u ON u.tenant_id = s.tenant_id AND u.id = s.user_id
+		CROSS JOIN LATERAL (SELECT
+			(now() AT TIME ZONE u.timezone)::date AS today,
+			COALESCE((u.notifications->>'remind_days_before')::int, 0) AS days) t
+		CROSS JOIN LATERAL (VALUES
+			('renewal', (date_trunc('month', t.today) + interval '1 month')::date),
+			('trial_end', s.trial_end_date),
+			('end', (s.end_date + interval '1 month')::date)) AS d(kind, due_date)
+		CROSS JOIN LATERAL (VALUES
+			('email', COALESCE((u.notifications->>'email')::boolean, false) AND u.email IS NOT NULL),
+			('webhook', COALESCE((u.notifications->>'webhook')::boolean, false))) AS c(channel, enabled)
+		WHERE t.days > 0
+		AND c.enabled AND c.channel = ANY($1)
+		AND d.due_date > t.today
+		AND d.due_date - t.days <= t.today
+		AND (d.kind <> 'renewal' OR (
+			d.due_date > COALESCE(s.trial_end_date, s.start_date)
+			AND (s.end_date IS NULL OR d.due_date <= s.end_date)))
+		ON CONFLICT DO NOTHING
+	`
+
+	res, err := r.db.Exec(query, pq.Array(channels))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Claim takes up to limit notifications due for delivery and hides them
+// from other instances for lease. Notifications not marked before the
+// lease expires, e.g. after a crash, are claimed again
+func (r *NotificationRepo) Claim(limit int, lease time.Duration) ([]model.Notification, error) {
+	query :=
+	`
+		WITH claimed AS (
+			UPDATE notifications
+			SET status = 'sending', attempts = attempts + 1, next_attempt_at = now() + $2::float8 * interval '1 second'
+			WHERE id IN (
+				SELECT id FROM notifications
+				WHERE status IN ('pending', 'sending') AND next_attempt_at <= now()
+				ORDER BY next_attempt_at
+				LIMIT $1
+				FOR UPDATE SKIP LOCKED)
+			RETURNING id, tenant_id, subscription_id, user_id, kind, channel, due_date, attempts
+		)
+		SELECT c.id, c.tenant_id, c.kind, c.channel, c.due_date, c.attempts, c.subscription_id,
+			s.service_name, s.price, c.user_id,
+			COALESCE(u.name, ''), COALESCE(u.email, ''), COALESCE(u.currency, $3), COALESCE(u.timezone, $4)
+		FROM claimed c
+		JOIN subscriptions s ON s.id = c.subscription_id
+		LEFT JOIN users u ON u.tenant_id = c.tenant_id AND u.id = c.user_id
+		ORDER BY c.id
+	`
+
+	rows, err := r.db.Query(query, limit, lease.Seconds(), model.DefaultCurrency, model.DefaultTimezone)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var notifications []model.Notification
+	for rows.Next() {
+		var n model.Notification
+		err := rows.Scan(&n.ID, &n.TenantID, &n.Kind, &n.Channel, &n.DueDate, &n.Attempts, &n.SubscriptionID,
+			&n.Subscription.ServiceName, &n.Subscription.Price, &n.User.ID,
+			&n.User.Name, &n.User.Email, &n.User.Currency, &n.User.Timezone)
+		if err != nil {
+			return nil, err
+		}
+		n.Subscription.ID = n.SubscriptionID
+		n.Subscription.UserID = n.User.ID
+		notifications = append(notifications, n)
+	}
+	return notifications, rows.Err()
+}
+
+func (r *NotificationRepo) MarkSent(id int64) error {
+	_, err := r.db.Exec(
+		`UPDATE notifications SET status = 'sent', sent_at = now(), last_error = '' WHERE id = $1`, id)
+	return err
+}
+
+// MarkFailed schedules another attempt after retryAfter, zero retryAfter
+// fails the notification for good
+func (r *NotificationRepo) MarkFailed(id int64, reason string, retryAfter time.Duration) error {
+	_, err := r.db.Exec(
+		`UPDATE notifications
+		SET status = CASE WHEN $3::float8 > 0 THEN 'pending' ELSE 'failed' END,
+			last_error = $2,
+			next_attempt_at = now() + $3::float8 * interval '1 second'
+		WHERE id = $1`,
+		id, reason, retryAfter.Seconds())
+	return err
+}
\ No newline at end of file
@@ -0,0 +1,71 @@
+package repo_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+	"github.com/teamcutter/subscriptions-service-task/internal/repo"
+)
+
+func TestScheduleAndClaimReminders(t *testing.T) {
+	notifications := repo.NewNotificationRepo(db)
+	userID := uuid.New()
+
+	// window longer than a month always covers the next first day of month
+	require.NoError(t, repo.NewUserRepo(db).Save(&model.User{ID: userID, Email: userID.String() + "@example.com", Currency: "RUB", Timezone: "UTC",
+		Notifications: model.NotificationPreferences{Email: true, Webhook: true, RemindDaysBefore: 40}}))
+
+	now := time.Now().UTC()
+	thisMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	nextMonth := thisMonth.AddDate(0, 1, 0)
+
+	for _, sub := range []*model.Subscription{
+		{ServiceName: "Renewing", Price: 100, UserID: userID, StartDate: "01-2024"},
+		{ServiceName: "Trial", Price: 200, UserID: userID, StartDate: thisMonth.Format("01-2006"), TrialEndDate: nextMonth.Format("01-2006")},
+		{ServiceName: "Ending", Price: 300, UserID: userID, StartDate: "01-2024", EndDate: thisMonth.Format("01-2006")},
+	} {
+		require.NoError(t, testRepo.Create(sub))
+	}
+
+	scheduled, err := notifications.Schedule([]string{model.ChannelEmail})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), scheduled)
+
+	scheduled, err = notifications.Schedule([]string{model.ChannelEmail})
+	require.NoError(t, err)
+	assert.Zero(t, scheduled)
+
+	claimed, err := notifications.Claim(10, time.Minute)
+	require.NoError(t, err)
+	kinds := map[string]string{}
+	for _, n := range claimed {
+		kinds[n.Subscription.ServiceName] = n.Kind
+		assert.Equal(t, model.ChannelEmail, n.Channel)
+		assert.Equal(t, 1, n.Attempts)
+		assert.True(t, n.DueDate.Equal(nextMonth))
+	}
+	assert.Equal(t, map[string]string{"Renewing": model.ReminderRenewal, "Trial": model.ReminderTrialEnd, "Ending": model.ReminderEnd}, kinds)
+
+	// leased notifications are not claimed twice
+	again, err := notifications.Claim(10, time.Minute)
+	require.NoError(t, err)
+	assert.Empty(t, again)
+
+	require.NoError(t, notifications.MarkSent(claimed[0].ID))
+	require.NoError(t, notifications.MarkFailed(claimed[1].ID, "unavailable", 0))
+	require.NoError(t, notifications.MarkFailed(claimed[2].ID, "unavailable", time.Millisecond))
+	time.Sleep(10 * time.Millisecond)
+
+	// only the notification scheduled for retry comes back
+	retried, err := notifications.Claim(10, time.Minute)
+	require.NoError(t, err)
+	if assert.Len(t, retried, 1) {
+		assert.Equal(t, claimed[2].ID, retried[0].ID)
+		assert.Equal(t, 2, retried[0].Attempts)
+	}
+}
\ No newline at end of file
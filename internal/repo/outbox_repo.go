@@ -0,0 +1,141 @@
+package repo
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+)
+
+// EventsChannel is notified with every event recorded to the outbox
+// when its transaction commits
+const EventsChannel = "subscription_events"
+
+// maxNotifyPayload is the limit of NOTIFY payload, longer events are only
+// published through the outbox and EventsReset is notified instead
+const maxNotifyPayload = 8000
+
+// EventsReset is notified on EventsChannel in place of an event too long
+// to be sent, listeners missed a change and must make their clients resync
+const EventsReset = "reset"
+
+func newEvent(tenantID, eventType string, id int64, userID uuid.UUID, s *model.Subscription) model.Event {
+	return model.Event{
+		ID: uuid.New(),
+		Type: eventType,
+		TenantID: tenantID,
+		OccurredAt: time.Now().UTC(),
+		SubscriptionID: id,
+		UserID: userID,
+		Subscription: s,
+	}
+}
+
+// recordEvents writes events to the outbox, notifies EventsChannel and
+// keeps price history. It must run in the transaction of the change, so
+// events are published if and only if the change commits
+func recordEvents(q querier, events ...model.Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	placeholders := make([]string, 0, len(events))
+	args := make([]any, 0, len(events)*5)
+	for i, event := range events {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		n := i * 5
+		placeholders = append(placeholders, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4, n+5))
+		args = append(args, event.ID, event.TenantID, event.SubscriptionID, event.Type, payload)
+	}
+
+	_, err := q.Exec(
+		`WITH inserted AS (
+			INSERT INTO outbox (event_id, tenant_id, subscription_id, event_type, payload) VALUES `+
+			strings.Join(placeholders, ", ")+`
+			RETURNING id, payload::text AS payload
+		)
+		SELECT pg_notify('`+EventsChannel+`', payload) FROM (
+			SELECT CASE WHEN octet_length(payload) < `+strconv.Itoa(maxNotifyPayload)+` THEN payload ELSE '`+EventsReset+`' END AS payload
+			FROM inserted ORDER BY id
+		) ordered`,
+		args...)
+	if err != nil {
+		return err
+	}
+	return recordPrices(q, events)
+}
+
+// OutboxRepo reads events of all tenants, it is used by the background
+// relay and is not limited to a tenant
+type OutboxRepo struct {
+	db *sql.DB
+}
+
+func NewOutboxRepo(db *sql.DB) *OutboxRepo {
+	return &OutboxRepo{db: db}
+}
+
+// Claim takes up to limit events due for publishing and hides them from
+// other instances for lease. Only the oldest event of a subscription is
+// taken, the next one waits until it is published, which keeps events of
+// a subscription in order across instances
+func (r *OutboxRepo) Claim(limit int, lease time.Duration) ([]model.OutboxEntry, error) {
+	query :=
+	`
+		WITH claimed AS (
+			UPDATE outbox
+			SET attempts = attempts + 1, next_attempt_at = now() + $2::float8 * interval '1 second'
+			WHERE id IN (
+				SELECT o.id FROM outbox o
+				WHERE o.next_attempt_at <= now()
+				AND NOT EXISTS (SELECT 1 FROM outbox e WHERE e.subscription_id = o.subscription_id AND e.id < o.id)
+				ORDER BY o.id
+				LIMIT $1
+				FOR UPDATE SKIP LOCKED)
+			RETURNING id, attempts, payload
+		)
+		SELECT id, attempts, payload FROM claimed ORDER BY id
+	`
+
+	rows, err := r.db.Query(query, limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []model.OutboxEntry
+	for rows.Next() {
+		var entry model.OutboxEntry
+		var payload []byte
+		if err := rows.Scan(&entry.ID, &entry.Attempts, &payload); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(payload, &entry.Event); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// MarkPublished removes published event from the outbox
+func (r *OutboxRepo) MarkPublished(id int64) error {
+	_, err := r.db.Exec(`DELETE FROM outbox WHERE id = $1`, id)
+	return err
+}
+
+// MarkFailed schedules another attempt after retryAfter
+func (r *OutboxRepo) MarkFailed(id int64, reason string, retryAfter time.Duration) error {
+	_, err := r.db.Exec(
+		`UPDATE outbox SET last_error = $2, next_attempt_at = now() + $3::float8 * interval '1 second' WHERE id = $1`,
+		id, reason, retryAfter.Seconds())
+	return err
+}
\ No newline at end of file
@@ -0,0 +1,169 @@
+package repo_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+	"github.com/teamcutter/subscriptions-service-task/internal/repo"
+)
+
+// publishAll claims and publishes events until none are due and returns
+// their types per subscription in publishing order
+func publishAll(t *testing.T, outbox *repo.OutboxRepo) map[int64][]string {
+	published := map[int64][]string{}
+	for {
+		entries, err := outbox.Claim(100, time.Minute)
+		require.NoError(t, err)
+		if len(entries) == 0 {
+			return published
+		}
+
+		seen := map[int64]bool{}
+		for _, entry := range entries {
+			// only the oldest event of a subscription is claimed
+			assert.False(t, seen[entry.Event.SubscriptionID])
+			seen[entry.Event.SubscriptionID] = true
+
+			published[entry.Event.SubscriptionID] = append(published[entry.Event.SubscriptionID], entry.Event.Type)
+			require.NoError(t, outbox.MarkPublished(entry.ID))
+		}
+	}
+}
+
+func TestOutboxEvents(t *testing.T) {
+	require.NoError(t, repo.NewTenantRepo(db).Create(&model.Tenant{ID: "outbox", Name: "Outbox"}))
+	outbox := repo.NewOutboxRepo(db)
+	publishAll(t, outbox)
+
+	subs := testRepo.ForTenant("outbox")
+	user := uuid.New()
+	sub := &model.Subscription{ServiceName: "Evented", Price: 100, UserID: user, StartDate: "01-2024"}
+	require.NoError(t, subs.Create(sub))
+
+	sub.ServiceName = "Renamed"
+	require.NoError(t, subs.Update(sub, sub.Version))
+	sub.Price = 150
+	require.NoError(t, subs.Update(sub, sub.Version))
+	sub.Price = 200
+	sub.EndDate = "12-2024"
+	require.NoError(t, subs.Update(sub, sub.Version))
+	// failed update records nothing
+	assert.ErrorIs(t, subs.Update(sub, sub.Version-1), repo.ErrVersionMismatch)
+
+	results, err := subs.Batch([]model.BatchOperation{
+		{Op: model.BatchOpCreate, Subscription: &model.Subscription{ServiceName: "Batched", Price: 10, UserID: user, StartDate: "01-2024"}},
+		{Op: model.BatchOpDelete, ID: sub.ID},
+	}, true)
+	require.NoError(t, err)
+	batched := results[0].ID
+
+	// rolled back batch records nothing
+	_, err = subs.Batch([]model.BatchOperation{
+		{Op: model.BatchOpDelete, ID: batched},
+		{Op: model.BatchOpDelete, ID: sub.ID, Version: 1},
+	}, true)
+	require.NoError(t, err)
+
+	published := publishAll(t, outbox)
+	assert.Equal(t, map[int64][]string{
+		sub.ID: {
+			model.EventSubscriptionCreated,
+			model.EventSubscriptionUpdated,
+			model.EventSubscriptionPriceChanged,
+			model.EventSubscriptionPriceChanged,
+			model.EventSubscriptionCancelled,
+			model.EventSubscriptionDeleted,
+		},
+		batched: {model.EventSubscriptionCreated},
+	}, published)
+}
+
+func TestOutboxRetry(t *testing.T) {
+	outbox := repo.NewOutboxRepo(db)
+	publishAll(t, outbox)
+
+	sub := &model.Subscription{ServiceName: "Retried", Price: 100, UserID: uuid.New(), StartDate: "01-2024"}
+	require.NoError(t, testRepo.Create(sub))
+	require.NoError(t, testRepo.Delete(int(sub.ID), 0))
+
+	entries, err := outbox.Claim(100, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, 1, entries[0].Attempts)
+	assert.Equal(t, model.DefaultTenant, entries[0].Event.TenantID)
+	assert.Equal(t, "Retried", entries[0].Event.Subscription.ServiceName)
+
+	// the deleted event waits for the failed created event
+	require.NoError(t, outbox.MarkFailed(entries[0].ID, "unavailable", time.Hour))
+	again, err := outbox.Claim(100, time.Minute)
+	require.NoError(t, err)
+	assert.Empty(t, again)
+
+	require.NoError(t, outbox.MarkFailed(entries[0].ID, "unavailable", 0))
+	assert.Equal(t, map[int64][]string{
+		sub.ID: {model.EventSubscriptionCreated, model.EventSubscriptionDeleted},
+	}, publishAll(t, outbox))
+}
+
+func TestEventsNotified(t *testing.T) {
+	listener := pq.NewListener(dsn, time.Second, time.Second, nil)
+	defer listener.Close()
+	require.NoError(t, listener.Listen(repo.EventsChannel))
+
+	sub := &model.Subscription{ServiceName: "Notified", Price: 100, UserID: uuid.New(), StartDate: "01-2024"}
+	require.NoError(t, testRepo.Create(sub))
+	require.NoError(t, testRepo.Delete(int(sub.ID), 0))
+
+	var types []string
+	for len(types) < 2 {
+		select {
+		case n := <-listener.Notify:
+			var event model.Event
+			require.NoError(t, json.Unmarshal([]byte(n.Extra), &event))
+			if event.SubscriptionID != sub.ID {
+				continue
+			}
+			assert.Equal(t, sub.UserID, event.UserID)
+			types = append(types, event.Type)
+		case <-time.After(5 * time.Second):
+			t.Fatal("no notification")
+		}
+	}
+	assert.Equal(t, []string{model.EventSubscriptionCreated, model.EventSubscriptionDeleted}, types)
+
+	// rolled back changes notify nothing
+	_, err := testRepo.Batch([]model.BatchOperation{
+		{Op: model.BatchOpCreate, Subscription: &model.Subscription{ServiceName: "Rolled back", Price: 1, UserID: sub.UserID, StartDate: "01-2024"}},
+		{Op: model.BatchOpDelete, ID: sub.ID, Version: 1},
+	}, true)
+	require.NoError(t, err)
+	select {
+	case n := <-listener.Notify:
+		t.Fatalf("unexpected notification %s", n.Extra)
+	case <-time.After(500 * time.Millisecond):
+	}
+}
+
+func TestLongEventNotifiedAsReset(t *testing.T) {
+	listener := pq.NewListener(dsn, time.Second, time.Second, nil)
+	defer listener.Close()
+	require.NoError(t, listener.Listen(repo.EventsChannel))
+
+	sub := &model.Subscription{ServiceName: strings.Repeat("x", 9000), Price: 100, UserID: uuid.New(), StartDate: "01-2024"}
+	require.NoError(t, testRepo.Create(sub))
+
+	select {
+	case n := <-listener.Notify:
+		assert.Equal(t, repo.EventsReset, n.Extra)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no notification")
+	}
+}
@@ -0,0 +1,54 @@
+package repo
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+)
+
+// recordPrices keeps price of created and repriced subscriptions, it is
+// called by recordEvents so every change writing events writes the history
+func recordPrices(q querier, events []model.Event) error {
+	placeholders := []string{}
+	args := []any{}
+	for _, event := range events {
+		if event.Subscription == nil ||
+			(event.Type != model.EventSubscriptionCreated && event.Type != model.EventSubscriptionPriceChanged) {
+			continue
+		}
+		n := len(args)
+		placeholders = append(placeholders, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4, n+5))
+		args = append(args, event.TenantID, event.SubscriptionID, event.UserID, event.Subscription.Price, event.OccurredAt)
+	}
+	if len(placeholders) == 0 {
+		return nil
+	}
+
+	_, err := q.Exec(
+		`INSERT INTO price_history (tenant_id, subscription_id, user_id, price, changed_at) VALUES `+
+			strings.Join(placeholders, ", "),
+		args...)
+	return err
+}
+
+func exportPrices(q querier, tenantID string, userID uuid.UUID) ([]model.PriceChange, error) {
+	rows, err := q.Query(
+		`SELECT subscription_id, price, changed_at FROM price_history
+		WHERE tenant_id = $1 AND user_id = $2 ORDER BY subscription_id, changed_at, id`, tenantID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	prices := []model.PriceChange{}
+	for rows.Next() {
+		var price model.PriceChange
+		if err := rows.Scan(&price.SubscriptionID, &price.Price, &price.ChangedAt); err != nil {
+			return nil, err
+		}
+		prices = append(prices, price)
+	}
+	return prices, rows.Err()
+}
@@ -0,0 +1,104 @@
+package repo
+
+import (
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+)
+
+// ScopedRepo restricts Repo to subscriptions of one user. Reads are
+// filtered by user, writes get user id overwritten and rows of other
+// users look as if they do not exist
+type ScopedRepo struct {
+	Repo
+	userID uuid.UUID
+}
+
+func NewScopedRepo(r Repo, userID uuid.UUID) *ScopedRepo {
+	return &ScopedRepo{Repo: r, userID: userID}
+}
+
+// checkOwner returns ErrNotFound for rows of other users
+func (r *ScopedRepo) checkOwner(id int) error {
+	sub, err := r.Repo.GetByID(id)
+	if err != nil {
+		return err
+	}
+	if sub.UserID != r.userID {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *ScopedRepo) Create(s *model.Subscription) error {
+	s.UserID = r.userID
+	return r.Repo.Create(s)
+}
+
+func (r *ScopedRepo) GetAll(filter model.SubscriptionFilter) ([]model.Subscription, error) {
+	filter.UserID = uuid.NullUUID{UUID: r.userID, Valid: true}
+	return r.Repo.GetAll(filter)
+}
+
+func (r *ScopedRepo) Stream(filter model.SubscriptionFilter, fn func(model.Subscription) error) error {
+	filter.UserID = uuid.NullUUID{UUID: r.userID, Valid: true}
+	return r.Repo.Stream(filter, fn)
+}
+
+func (r *ScopedRepo) GetByID(id int) (*model.Subscription, error) {
+	sub, err := r.Repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if sub.UserID != r.userID {
+		return nil, ErrNotFound
+	}
+	return sub, nil
+}
+
+func (r *ScopedRepo) Update(s *model.Subscription, version int) error {
+	if err := r.checkOwner(int(s.ID)); err != nil {
+		return err
+	}
+	s.UserID = r.userID
+	return r.Repo.Update(s, version)
+}
+
+func (r *ScopedRepo) Delete(id int, version int) error {
+	err := r.checkOwner(id)
+	// deleting a missing row without expected version stays a no-op
+	if errors.Is(err, ErrNotFound) && version == 0 {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return r.Repo.Delete(id, version)
+}
+
+func (r *ScopedRepo) ForTenant(tenantID string) Repo {
+	return NewScopedRepo(r.Repo.ForTenant(tenantID), r.userID)
+}
+
+func (r *ScopedRepo) TotalCost(_, serviceName, start, end string) (int, error) {
+	return r.Repo.TotalCost(r.userID.String(), serviceName, start, end)
+}
+
+// Batch passes only operations on own subscriptions to the wrapped repo,
+// the rest fail with ErrNotFound
+func (r *ScopedRepo) Batch(ops []model.BatchOperation, atomic bool) ([]model.BatchResult, error) {
+	return filterBatch(r.Repo, ops, atomic, func(op *model.BatchOperation) error {
+		if op.Op == model.BatchOpUpdate || op.Op == model.BatchOpDelete {
+			if err := r.checkOwner(int(op.ID)); err != nil {
+				return err
+			}
+		}
+		if op.Subscription != nil {
+			sub := *op.Subscription
+			sub.UserID = r.userID
+			op.Subscription = &sub
+		}
+		return nil
+	})
+}
\ No newline at end of file
@@ -0,0 +1,65 @@
+package repo_test
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+	"github.com/teamcutter/subscriptions-service-task/internal/repo"
+	"github.com/teamcutter/subscriptions-service-task/internal/seed"
+)
+
+// loadFixtures reads testdata/seed.json, regenerate it with
+// go run ./cmd/seed -seed 1 -users 5 -until 12-2024 -fixtures internal/repo/testdata/seed.json
+func loadFixtures(t *testing.T) seed.Data {
+	raw, err := os.ReadFile("testdata/seed.json")
+	require.NoError(t, err)
+
+	var data seed.Data
+	require.NoError(t, json.Unmarshal(raw, &data))
+	return data
+}
+
+func TestSeedFixtures(t *testing.T) {
+	data := loadFixtures(t)
+	require.NoError(t, repo.NewUserRepo(db).SaveMany(data.Users))
+
+	ops := make([]model.BatchOperation, len(data.Subscriptions))
+	for i := range data.Subscriptions {
+		ops[i] = model.BatchOperation{Op: model.BatchOpCreate, Subscription: &data.Subscriptions[i]}
+	}
+	results, err := testRepo.Batch(ops, true)
+	require.NoError(t, err)
+	for _, result := range results {
+		require.NoError(t, result.Err)
+	}
+
+	for _, user := range data.Users {
+		got, err := repo.NewUserRepo(db).Get(user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, user.Currency, got.Currency)
+		assert.Equal(t, user.Notifications, got.Notifications)
+
+		var want []model.Subscription
+		for _, sub := range data.Subscriptions {
+			if sub.UserID == user.ID {
+				want = append(want, sub)
+			}
+		}
+		subs, err := testRepo.GetAll(model.SubscriptionFilter{UserID: uuid.NullUUID{UUID: user.ID, Valid: true}})
+		require.NoError(t, err)
+		require.Len(t, subs, len(want))
+		for i, sub := range subs {
+			assert.Equal(t, want[i].ServiceName, sub.ServiceName)
+			assert.Equal(t, want[i].Price, sub.Price)
+			assert.Equal(t, want[i].StartDate, sub.StartDate)
+			assert.Equal(t, want[i].EndDate, sub.EndDate)
+			assert.Equal(t, want[i].TrialEndDate, sub.TrialEndDate)
+		}
+	}
+}
@@ -2,126 +2,387 @@ package repo
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
 
+	"github.com/google/uuid"
+	"github.com/lib/pq"
 	"github.com/teamcutter/subscriptions-service-task/internal/model"
+	"github.com/teamcutter/subscriptions-service-task/internal/split"
 	"github.com/teamcutter/subscriptions-service-task/internal/utils"
 )
 
+var (
+	ErrNotFound = errors.New("subscription not found")
+	ErrVersionMismatch = errors.New("subscription version mismatch")
+	ErrInvalidSubscription = errors.New("invalid subscription")
+)
+
 type Repo interface{
 	Create(*model.Subscription) error
-	GetAll() ([]model.Subscription, error)
-	Delete(int) error
+	GetAll(model.SubscriptionFilter) ([]model.Subscription, error)
+	// Stream calls fn for every subscription matching filter while reading
+	// rows from DB cursor, iteration stops on the first error of fn
+	Stream(model.SubscriptionFilter, func(model.Subscription) error) error
+	GetByID(int) (*model.Subscription, error)
+	// Update and Delete only touch the row if its version equals the given one,
+	// 0 means any version
+	Update(*model.Subscription, int) error
+	Delete(int, int) error
 	TotalCost(string, string, string, string) (int, error)
-} 
+	Batch([]model.BatchOperation, bool) ([]model.BatchResult, error)
+	// ForTenant returns repository working with subscriptions of the tenant
+	ForTenant(string) Repo
+}
 
+// SubscriptionRepo reads and writes subscriptions of a single tenant,
+// every query is filtered by tenant_id
 type SubscriptionRepo struct {
 	db *sql.DB
+	tenantID string
 }
 
 func NewSubscriptionRepo(db *sql.DB) *SubscriptionRepo {
-	return &SubscriptionRepo{db: db}
+	return &SubscriptionRepo{db: db, tenantID: model.DefaultTenant}
+}
+
+func (r *SubscriptionRepo) ForTenant(tenantID string) Repo {
+	return &SubscriptionRepo{db: r.db, tenantID: tenantID}
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// querier is implemented by both *sql.DB and *sql.Tx
+type querier interface {
+	Exec(string, ...any) (sql.Result, error)
+	Query(string, ...any) (*sql.Rows, error)
+	QueryRow(string, ...any) *sql.Row
+}
+
+func scanSubscription(row rowScanner) (model.Subscription, error) {
+	var sub model.Subscription
+	var endDate, trialEndDate sql.NullString
+
+	err := row.Scan(&sub.ID, &sub.ServiceName, &sub.Price, &sub.UserID, &sub.StartDate, &endDate, &trialEndDate, &sub.Version)
+	if err != nil {
+		return sub, err
+	}
+
+	sub.StartDate, err = utils.ParseDateFromDB(sub.StartDate)
+	if err != nil {
+		return sub, err
+	}
+
+	if endDate.Valid {
+		sub.EndDate, err = utils.ParseDateFromDB(endDate.String)
+		if err != nil {
+			return sub, err
+		}
+	}
+
+	if trialEndDate.Valid {
+		sub.TrialEndDate, err = utils.ParseDateFromDB(trialEndDate.String)
+		if err != nil {
+			return sub, err
+		}
+	}
+
+	return sub, nil
+}
+
+// dbDates are dates of subscription in DB format, end and trial end
+// are nil when not set
+type dbDates struct {
+	start string
+	end interface{}
+	trialEnd interface{}
+}
+
+// checkUser fails subscriptions without user, callers without a user of
+// their own like API keys must name it
+func checkUser(s *model.Subscription) error {
+	if s.UserID == uuid.Nil {
+		return fmt.Errorf("%w: user_id is required", ErrInvalidSubscription)
+	}
+	return nil
+}
+
+// parseDates checks user of subscription and converts request dates
+// (MM-YYYY) to DB format
+func parseDates(s *model.Subscription) (dbDates, error) {
+	var dates dbDates
+	var err error
+
+	if err := checkUser(s); err != nil {
+		return dates, err
+	}
+
+	dates.start, err = utils.ParseDateFromRequest(s.StartDate)
+	if err != nil {
+		return dates, fmt.Errorf("%w: %v", ErrInvalidSubscription, err)
+	}
+
+	if s.EndDate != "" {
+		if dates.end, err = utils.ParseDateFromRequest(s.EndDate); err != nil {
+			return dates, fmt.Errorf("%w: %v", ErrInvalidSubscription, err)
+		}
+	}
+
+	if s.TrialEndDate != "" {
+		trialEnd, err := utils.ParseDateFromRequest(s.TrialEndDate)
+		if err != nil {
+			return dates, fmt.Errorf("%w: %v", ErrInvalidSubscription, err)
+		}
+		// ISO dates compare as strings
+		if trialEnd < dates.start {
+			return dates, fmt.Errorf("%w: trial_end_date is before start_date", ErrInvalidSubscription)
+		}
+		dates.trialEnd = trialEnd
+	}
+	return dates, nil
+}
+
+// Validate checks subscription with the same rules as Create
+func Validate(s *model.Subscription) error {
+	_, err := parseDates(s)
+	return err
 }
 
 func (r *SubscriptionRepo) Create(s *model.Subscription) error {
-	query := 
+	query :=
 	`
-		INSERT INTO subscriptions (service_name, price, user_id, start_date, end_date)
-		VALUES ($1, $2, $3, $4, $5)
+		INSERT INTO subscriptions (service_name, price, user_id, start_date, end_date, trial_end_date, tenant_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, version
 	`
 
-	startDate, err := utils.ParseDateFromRequest(s.StartDate)
+	dates, err := parseDates(s)
 	if err != nil {
 		return err
 	}
 
-	var endDate interface{}
-	if s.EndDate != "" {
-		parsedEndDate, err := utils.ParseDateFromRequest(s.EndDate)
-		if err != nil {
-			return err
-		}
-		endDate = parsedEndDate
-	} else {
-		endDate = nil
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
 	}
+	defer tx.Rollback()
 
-	_, err = r.db.Exec(
-		query, 
-		s.ServiceName, 
-		s.Price, 
-		s.UserID, 
-		startDate, 
-		endDate)
+	err = tx.QueryRow(
+		query,
+		s.ServiceName,
+		s.Price,
+		s.UserID,
+		dates.start,
+		dates.end,
+		dates.trialEnd,
+		r.tenantID).Scan(&s.ID, &s.Version)
+	if err != nil {
+		return err
+	}
 
-	return err
+	if err := recordEvents(tx, newEvent(r.tenantID, model.EventSubscriptionCreated, s.ID, s.UserID, s)); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-func (r *SubscriptionRepo) GetAll() ([]model.Subscription, error) {
-	rows, err := r.db.Query(
-		`SELECT id, service_name, price, user_id, start_date, end_date FROM subscriptions`)
+func (r *SubscriptionRepo) GetAll(filter model.SubscriptionFilter) ([]model.Subscription, error) {
+	var subscriptions []model.Subscription
+	err := r.Stream(filter, func(sub model.Subscription) error {
+		subscriptions = append(subscriptions, sub)
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
+	return subscriptions, nil
+}
+
+func (r *SubscriptionRepo) Stream(filter model.SubscriptionFilter, fn func(model.Subscription) error) error {
+	query :=
+	`
+		SELECT id, service_name, price, user_id, start_date, end_date, trial_end_date, version
+		FROM subscriptions
+		WHERE tenant_id = $3
+		AND ($1::uuid IS NULL OR user_id = $1)
+		AND ($2 = '' OR service_name = $2)
+		AND id > $4
+		AND (cardinality($6::uuid[]) = 0 OR user_id = ANY($6))
+		ORDER BY id
+		LIMIT NULLIF($5, 0)
+	`
+
+	rows, err := r.db.Query(query, filter.UserID, filter.ServiceName, r.tenantID, filter.AfterID, filter.Limit, pq.Array(uuidStrings(filter.UserIDs)))
+	if err != nil {
+		return err
+	}
 	defer rows.Close()
 
-	var subscriptions []model.Subscription
 	for rows.Next() {
-		var sub model.Subscription
-		rows.Scan(&sub.ID, &sub.ServiceName, &sub.Price, &sub.UserID, &sub.StartDate, &sub.EndDate)
-		sub.StartDate, err = utils.ParseDateFromDB(sub.StartDate)
+		sub, err := scanSubscription(rows)
 		if err != nil {
-			return nil, err
+			return err
 		}
 
-		if sub.EndDate != "" {
-			sub.EndDate, err = utils.ParseDateFromDB(sub.EndDate)
-			if err != nil {
-				return nil, err
-			}
+		if err := fn(sub); err != nil {
+			return err
 		}
+	}
 
-		subscriptions = append(subscriptions, sub)
+	return rows.Err()
+}
+
+func (r *SubscriptionRepo) GetByID(id int) (*model.Subscription, error) {
+	row := r.db.QueryRow(
+		`SELECT id, service_name, price, user_id, start_date, end_date, trial_end_date, version FROM subscriptions WHERE id = $1 AND tenant_id = $2`,
+		id, r.tenantID)
+
+	sub, err := scanSubscription(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
 	}
+	if err != nil {
+		return nil, err
+	}
+	return &sub, nil
+}
 
-	return subscriptions, nil
+func (r *SubscriptionRepo) Update(s *model.Subscription, version int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := update(tx, r.tenantID, s, version); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-func (r *SubscriptionRepo) Delete(id int) error {
-	_, err := r.db.Exec(`DELETE FROM subscriptions WHERE id = $1`, id)
-	return err
+// updateEvents tells price changes and cancellations, setting end date
+// of open-ended subscription, apart from other updates
+func updateEvents(tenantID string, s *model.Subscription, oldPrice int, wasOpen bool) []model.Event {
+	var events []model.Event
+	if oldPrice != s.Price {
+		events = append(events, newEvent(tenantID, model.EventSubscriptionPriceChanged, s.ID, s.UserID, s))
+	}
+	if wasOpen && s.EndDate != "" {
+		events = append(events, newEvent(tenantID, model.EventSubscriptionCancelled, s.ID, s.UserID, s))
+	}
+	if len(events) == 0 {
+		events = append(events, newEvent(tenantID, model.EventSubscriptionUpdated, s.ID, s.UserID, s))
+	}
+	return events
 }
 
-func (r *SubscriptionRepo) TotalCost(userID, serviceName, start, end string) (int, error) {
-	query := 
+// update must run in a transaction, the row stays locked until the event
+// is recorded so events of a subscription are written in order of changes
+func update(q querier, tenantID string, s *model.Subscription, version int) error {
+	query :=
 	`
-	SELECT
-	SUM(
-    	price * (
-        	DATE_PART('year', AGE(LEAST(COALESCE(end_date, $4), $4), GREATEST(start_date, $3))) * 12 +
-        	DATE_PART('month', AGE(LEAST(COALESCE(end_date, $4), $4), GREATEST(start_date, $3))) + 1
-    	)
-	) as total_price
-	FROM subscriptions
-	WHERE user_id = $1
-	AND ($2 = '' OR service_name = $2)
-	AND start_date <= $4
-	AND (end_date >= $3 OR end_date IS NULL);
+		WITH old AS (
+			SELECT id, price, end_date FROM subscriptions WHERE id = $1 AND tenant_id = $8 FOR UPDATE
+		)
+		UPDATE subscriptions s
+		SET service_name = $2, price = $3, user_id = $4, start_date = $5, end_date = $6, trial_end_date = $9,
+			version = s.version + 1
+		FROM old
+		WHERE s.id = old.id AND ($7 = 0 OR s.version = $7)
+		RETURNING s.version, old.price, old.end_date IS NULL
 	`
-	var sum sql.NullInt64
 
-	startDate, err := utils.ParseDateFromRequest(start)
+	dates, err := parseDates(s)
 	if err != nil {
-		return 0, err
+		return err
+	}
+
+	var oldPrice int
+	var wasOpen bool
+	err = q.QueryRow(
+		query,
+		s.ID,
+		s.ServiceName,
+		s.Price,
+		s.UserID,
+		dates.start,
+		dates.end,
+		version,
+		tenantID,
+		dates.trialEnd).Scan(&s.Version, &oldPrice, &wasOpen)
+	if errors.Is(err, sql.ErrNoRows) {
+		return missingRowError(q, tenantID, s.ID)
+	}
+	if err != nil {
+		return err
+	}
+	return recordEvents(q, updateEvents(tenantID, s, oldPrice, wasOpen)...)
+}
+
+func (r *SubscriptionRepo) Delete(id int, version int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := remove(tx, r.tenantID, id, version); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// remove must run in a transaction, see update
+func remove(q querier, tenantID string, id int, version int) error {
+	var userID uuid.UUID
+	err := q.QueryRow(
+		`DELETE FROM subscriptions WHERE id = $1 AND tenant_id = $3 AND ($2 = 0 OR version = $2) RETURNING user_id`,
+		id, version, tenantID).Scan(&userID)
+	if err == nil {
+		return recordEvents(q, newEvent(tenantID, model.EventSubscriptionDeleted, int64(id), userID, nil))
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	// without expected version deleting a missing row is a no-op
+	if version == 0 {
+		return nil
+	}
+	return missingRowError(q, tenantID, int64(id))
+}
+
+// missingRowError tells apart a deleted row from a row changed by someone else
+func missingRowError(q querier, tenantID string, id int64) error {
+	var exists bool
+	err := q.QueryRow(
+		`SELECT EXISTS(SELECT 1 FROM subscriptions WHERE id = $1 AND tenant_id = $2)`, id, tenantID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return ErrVersionMismatch
 	}
+	return ErrNotFound
+}
 
-	endDate, err := utils.ParseDateFromRequest(end)
+// TotalCost sums what user pays for subscriptions between start and end,
+// for shared subscriptions only the user's share is counted
+func (r *SubscriptionRepo) TotalCost(userID, serviceName, start, end string) (int, error) {
+	user, err := uuid.Parse(userID)
 	if err != nil {
 		return 0, err
 	}
 
-	err = r.db.QueryRow(query, userID, serviceName, startDate, endDate).Scan(&sum)
-	if sum.Valid {
-		return int(sum.Int64), err
+	costs, err := loadCosts(r.db, r.tenantID, start, end,
+		`AND ($5 = '' OR s.service_name = $5)
+		AND (s.user_id = $4 OR EXISTS (
+			SELECT 1 FROM household_members m WHERE m.household_id = sp.household_id AND m.user_id = $4))`,
+		user, serviceName)
+	if err != nil {
+		return 0, err
 	}
-	return 0, err
+	return split.ShareOf(user, costs), nil
 }
\ No newline at end of file
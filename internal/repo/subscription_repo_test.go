@@ -19,6 +19,7 @@ import (
 
 var (
 	db       *sql.DB
+	dsn      string
 	testRepo *repo.SubscriptionRepo
 	mockUUID uuid.UUID = uuid.New()
 )
@@ -49,7 +50,7 @@ func TestMain(m *testing.M) {
 	host, _ := container.Host(ctx)
 	port, _ := container.MappedPort(ctx, "5432")
 
-	dsn := fmt.Sprintf("postgres://postgres:password@%s:%s/testdb?sslmode=disable", host, port.Port())
+	dsn = fmt.Sprintf("postgres://postgres:password@%s:%s/testdb?sslmode=disable", host, port.Port())
 	db, err = sql.Open("postgres", dsn)
 	if err != nil {
 		panic(err)
@@ -61,13 +62,178 @@ func TestMain(m *testing.M) {
 	}
 
 	_, err = db.Exec(`
+		CREATE TABLE tenants (
+			id TEXT PRIMARY KEY,
+			name TEXT NOT NULL,
+			created_at TIMESTAMP NOT NULL DEFAULT now()
+		);
+
+		INSERT INTO tenants (id, name) VALUES ('default', 'Default');
+
 		CREATE TABLE subscriptions (
 			id SERIAL PRIMARY KEY,
+			tenant_id TEXT NOT NULL DEFAULT 'default' REFERENCES tenants(id) ON DELETE CASCADE,
 			service_name TEXT NOT NULL,
 			price INT NOT NULL,
 			user_id UUID NOT NULL,
 			start_date DATE NOT NULL,
-			end_date DATE
+			end_date DATE,
+			trial_end_date DATE,
+			version INT NOT NULL DEFAULT 1
+		);
+
+		CREATE TABLE price_history (
+			id BIGSERIAL PRIMARY KEY,
+			tenant_id TEXT NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+			subscription_id INT NOT NULL,
+			user_id UUID NOT NULL,
+			price INT NOT NULL,
+			changed_at TIMESTAMP NOT NULL DEFAULT now()
+		);
+
+		CREATE TABLE idempotency_keys (
+			tenant_id TEXT NOT NULL DEFAULT 'default' REFERENCES tenants(id) ON DELETE CASCADE,
+			key TEXT NOT NULL,
+			request_hash TEXT NOT NULL,
+			status_code INT NOT NULL DEFAULT 0,
+			response_headers JSONB NOT NULL DEFAULT '{}',
+			response_body BYTEA,
+			created_at TIMESTAMP NOT NULL DEFAULT now(),
+			expires_at TIMESTAMP NOT NULL,
+			PRIMARY KEY (tenant_id, key)
+		);
+
+		CREATE TABLE import_jobs (
+			id UUID PRIMARY KEY,
+			tenant_id TEXT NOT NULL DEFAULT 'default' REFERENCES tenants(id) ON DELETE CASCADE,
+			user_id UUID,
+			status TEXT NOT NULL,
+			format TEXT NOT NULL,
+			report JSONB NOT NULL DEFAULT '{}',
+			error TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMP NOT NULL DEFAULT now(),
+			finished_at TIMESTAMP
+		);
+
+		CREATE TABLE api_keys (
+			id SERIAL PRIMARY KEY,
+			tenant_id TEXT REFERENCES tenants(id) ON DELETE CASCADE,
+			name TEXT NOT NULL,
+			prefix TEXT NOT NULL,
+			key_hash TEXT NOT NULL UNIQUE,
+			scopes TEXT[] NOT NULL,
+			expires_at TIMESTAMP,
+			last_used_at TIMESTAMP,
+			revoked_at TIMESTAMP,
+			created_at TIMESTAMP NOT NULL DEFAULT now()
+		);
+
+		CREATE TABLE audit_log (
+			id BIGSERIAL PRIMARY KEY,
+			tenant_id TEXT REFERENCES tenants(id) ON DELETE SET NULL,
+			actor TEXT NOT NULL,
+			action TEXT NOT NULL,
+			resource TEXT NOT NULL,
+			outcome TEXT NOT NULL,
+			created_at TIMESTAMP NOT NULL DEFAULT now()
+		);
+
+		CREATE TABLE users (
+			id UUID NOT NULL,
+			tenant_id TEXT NOT NULL DEFAULT 'default' REFERENCES tenants(id) ON DELETE CASCADE,
+			name TEXT NOT NULL DEFAULT '',
+			email TEXT,
+			currency TEXT NOT NULL DEFAULT 'RUB',
+			timezone TEXT NOT NULL DEFAULT 'UTC',
+			notifications JSONB NOT NULL DEFAULT '{}',
+			created_at TIMESTAMP NOT NULL DEFAULT now(),
+			updated_at TIMESTAMP NOT NULL DEFAULT now(),
+			PRIMARY KEY (tenant_id, id),
+			UNIQUE (tenant_id, email)
+		);
+
+		CREATE TABLE notifications (
+			id BIGSERIAL PRIMARY KEY,
+			tenant_id TEXT NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+			subscription_id INT NOT NULL REFERENCES subscriptions(id) ON DELETE CASCADE,
+			user_id UUID NOT NULL,
+			kind TEXT NOT NULL,
+			channel TEXT NOT NULL,
+			due_date DATE NOT NULL,
+			status TEXT NOT NULL DEFAULT 'pending',
+			attempts INT NOT NULL DEFAULT 0,
+			last_error TEXT NOT NULL DEFAULT '',
+			next_attempt_at TIMESTAMP NOT NULL DEFAULT now(),
+			sent_at TIMESTAMP,
+			created_at TIMESTAMP NOT NULL DEFAULT now(),
+			UNIQUE (subscription_id, kind, due_date, channel)
+		);
+
+		CREATE TABLE webhooks (
+			id SERIAL PRIMARY KEY,
+			tenant_id TEXT NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+			url TEXT NOT NULL,
+			events TEXT[] NOT NULL DEFAULT '{}',
+			secret TEXT NOT NULL,
+			created_at TIMESTAMP NOT NULL DEFAULT now()
+		);
+
+		CREATE TABLE webhook_deliveries (
+			id BIGSERIAL PRIMARY KEY,
+			webhook_id INT NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+			event_id UUID NOT NULL,
+			event_type TEXT NOT NULL,
+			payload JSONB NOT NULL,
+			status TEXT NOT NULL DEFAULT 'pending',
+			attempts INT NOT NULL DEFAULT 0,
+			response_status INT,
+			last_error TEXT NOT NULL DEFAULT '',
+			next_attempt_at TIMESTAMP NOT NULL DEFAULT now(),
+			delivered_at TIMESTAMP,
+			created_at TIMESTAMP NOT NULL DEFAULT now(),
+			UNIQUE (webhook_id, event_id)
+		);
+
+		CREATE TABLE outbox (
+			id BIGSERIAL PRIMARY KEY,
+			event_id UUID NOT NULL UNIQUE,
+			tenant_id TEXT NOT NULL,
+			subscription_id INT NOT NULL,
+			event_type TEXT NOT NULL,
+			payload JSONB NOT NULL,
+			attempts INT NOT NULL DEFAULT 0,
+			last_error TEXT NOT NULL DEFAULT '',
+			next_attempt_at TIMESTAMP NOT NULL DEFAULT now(),
+			created_at TIMESTAMP NOT NULL DEFAULT now()
+		);
+
+		CREATE TABLE households (
+			id SERIAL PRIMARY KEY,
+			tenant_id TEXT NOT NULL DEFAULT 'default' REFERENCES tenants(id) ON DELETE CASCADE,
+			name TEXT NOT NULL,
+			owner_id UUID NOT NULL,
+			created_at TIMESTAMP NOT NULL DEFAULT now()
+		);
+
+		CREATE TABLE household_members (
+			household_id INT NOT NULL REFERENCES households(id) ON DELETE CASCADE,
+			user_id UUID NOT NULL,
+			PRIMARY KEY (household_id, user_id)
+		);
+
+		CREATE TABLE subscription_splits (
+			subscription_id INT PRIMARY KEY REFERENCES subscriptions(id) ON DELETE CASCADE,
+			household_id INT NOT NULL REFERENCES households(id) ON DELETE CASCADE,
+			rule TEXT NOT NULL
+		);
+
+		CREATE TABLE subscription_split_parts (
+			subscription_id INT NOT NULL REFERENCES subscription_splits(subscription_id) ON DELETE CASCADE,
+			household_id INT NOT NULL,
+			user_id UUID NOT NULL,
+			value INT NOT NULL,
+			PRIMARY KEY (subscription_id, user_id),
+			FOREIGN KEY (household_id, user_id) REFERENCES household_members(household_id, user_id) ON DELETE CASCADE
 		);
 	`)
 	if err != nil {
@@ -97,7 +263,7 @@ func TestCreateAndGetAll(t *testing.T) {
 	err := testRepo.Create(sub)
 	require.NoError(t, err)
 
-	subs, err := testRepo.GetAll()
+	subs, err := testRepo.GetAll(model.SubscriptionFilter{})
 	require.NoError(t, err)
 	assert.Len(t, subs, 1)
 	assert.Equal(t, "Netflix", subs[0].ServiceName)
@@ -119,7 +285,7 @@ func TestDelete(t *testing.T) {
 	err = db.QueryRow(`SELECT id FROM subscriptions WHERE service_name = 'Spotify'`).Scan(&id)
 	require.NoError(t, err)
 
-	err = testRepo.Delete(id)
+	err = testRepo.Delete(id, 0)
 	require.NoError(t, err)
 
 	var count int
@@ -128,6 +294,35 @@ func TestDelete(t *testing.T) {
 	assert.Equal(t, 0, count)
 }
 
+func TestUpdateVersion(t *testing.T) {
+	sub := &model.Subscription{
+		ServiceName: "Disney",
+		Price:       300,
+		UserID:      mockUUID,
+		StartDate:   "01-2024",
+	}
+	err := testRepo.Create(sub)
+	require.NoError(t, err)
+	assert.Equal(t, 1, sub.Version)
+
+	sub.Price = 400
+	err = testRepo.Update(sub, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 2, sub.Version)
+
+	err = testRepo.Update(sub, 1)
+	assert.ErrorIs(t, err, repo.ErrVersionMismatch)
+
+	err = testRepo.Delete(int(sub.ID), 1)
+	assert.ErrorIs(t, err, repo.ErrVersionMismatch)
+
+	err = testRepo.Delete(int(sub.ID), 2)
+	require.NoError(t, err)
+
+	_, err = testRepo.GetByID(int(sub.ID))
+	assert.ErrorIs(t, err, repo.ErrNotFound)
+}
+
 func TestTotalCost(t *testing.T) {
 	sub := &model.Subscription{
 		ServiceName: "HBO",
@@ -143,4 +338,131 @@ func TestTotalCost(t *testing.T) {
 	require.NoError(t, err)
 
 	assert.Equal(t, 300, total)
-}
\ No newline at end of file
+}
+
+// trial end date is for reminders only, totals count from start date
+func TestTotalCostCountsTrial(t *testing.T) {
+	sub := &model.Subscription{
+		ServiceName:  "Max",
+		Price:        100,
+		UserID:       mockUUID,
+		StartDate:    "01-2024",
+		EndDate:      "03-2024",
+		TrialEndDate: "03-2024",
+	}
+	err := testRepo.Create(sub)
+	require.NoError(t, err)
+
+	total, err := testRepo.TotalCost(mockUUID.String(), "Max", "01-2024", "12-2024")
+	require.NoError(t, err)
+	assert.Equal(t, 300, total)
+
+	got, err := testRepo.GetByID(int(sub.ID))
+	require.NoError(t, err)
+	assert.Equal(t, "03-2024", got.TrialEndDate)
+
+	sub.TrialEndDate = "12-2023"
+	assert.ErrorIs(t, testRepo.Update(sub, 0), repo.ErrInvalidSubscription)
+}
+
+func TestBatchAtomic(t *testing.T) {
+	ops := []model.BatchOperation{
+		{Op: model.BatchOpCreate, Subscription: &model.Subscription{
+			ServiceName: "Batch-A", Price: 100, UserID: mockUUID, StartDate: "01-2024",
+		}},
+		{Op: model.BatchOpCreate, Subscription: &model.Subscription{
+			ServiceName: "Batch-B", Price: 100, UserID: mockUUID, StartDate: "bad-date",
+		}},
+	}
+
+	results, err := testRepo.Batch(ops, true)
+	require.NoError(t, err)
+	assert.ErrorIs(t, results[0].Err, repo.ErrBatchAborted)
+	assert.ErrorIs(t, results[1].Err, repo.ErrInvalidSubscription)
+
+	var count int
+	err = db.QueryRow(`SELECT COUNT(*) FROM subscriptions WHERE service_name LIKE 'Batch-%'`).Scan(&count)
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+}
+
+func TestBatchBestEffort(t *testing.T) {
+	existing := &model.Subscription{ServiceName: "Batch-C", Price: 100, UserID: mockUUID, StartDate: "01-2024"}
+	require.NoError(t, testRepo.Create(existing))
+
+	ops := []model.BatchOperation{
+		{Op: model.BatchOpCreate, Subscription: &model.Subscription{
+			ServiceName: "Batch-D", Price: 100, UserID: mockUUID, StartDate: "01-2024",
+		}},
+		{Op: model.BatchOpCreate, Subscription: &model.Subscription{
+			ServiceName: "Batch-E", Price: 200, UserID: mockUUID, StartDate: "02-2024", EndDate: "05-2024",
+		}},
+		{Op: model.BatchOpUpdate, ID: existing.ID, Version: existing.Version + 1, Subscription: &model.Subscription{
+			ServiceName: "Batch-C", Price: 150, UserID: mockUUID, StartDate: "01-2024",
+		}},
+		{Op: model.BatchOpDelete, ID: existing.ID},
+	}
+
+	results, err := testRepo.Batch(ops, false)
+	require.NoError(t, err)
+	assert.NoError(t, results[0].Err)
+	assert.NotZero(t, results[0].ID)
+	assert.NoError(t, results[1].Err)
+	assert.NotEqual(t, results[0].ID, results[1].ID)
+	assert.ErrorIs(t, results[2].Err, repo.ErrVersionMismatch)
+	assert.NoError(t, results[3].Err)
+
+	_, err = testRepo.GetByID(int(existing.ID))
+	assert.ErrorIs(t, err, repo.ErrNotFound)
+
+	sub, err := testRepo.GetByID(int(results[1].ID))
+	require.NoError(t, err)
+	assert.Equal(t, "Batch-E", sub.ServiceName)
+}
+
+func TestStreamFilter(t *testing.T) {
+	otherUser := uuid.New()
+	sub := &model.Subscription{ServiceName: "Stream", Price: 100, UserID: otherUser, StartDate: "01-2024"}
+	require.NoError(t, testRepo.Create(sub))
+
+	var got []model.Subscription
+	filter := model.SubscriptionFilter{UserID: uuid.NullUUID{UUID: otherUser, Valid: true}}
+	err := testRepo.Stream(filter, func(s model.Subscription) error {
+		got = append(got, s)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Len(t, got, 1)
+	assert.Equal(t, "Stream", got[0].ServiceName)
+}
+func TestGetAllPages(t *testing.T) {
+	owner := uuid.New()
+	for _, name := range []string{"A", "B", "C"} {
+		require.NoError(t, testRepo.Create(&model.Subscription{ServiceName: name, Price: 100, UserID: owner, StartDate: "01-2024"}))
+	}
+
+	filter := model.SubscriptionFilter{UserID: uuid.NullUUID{UUID: owner, Valid: true}, Limit: 2}
+	first, err := testRepo.GetAll(filter)
+	require.NoError(t, err)
+	require.Len(t, first, 2)
+	assert.Equal(t, "A", first[0].ServiceName)
+
+	filter.AfterID = first[1].ID
+	rest, err := testRepo.GetAll(filter)
+	require.NoError(t, err)
+	require.Len(t, rest, 1)
+	assert.Equal(t, "C", rest[0].ServiceName)
+}
+
+func TestGetAllOfUsers(t *testing.T) {
+	first, second, other := uuid.New(), uuid.New(), uuid.New()
+	for _, userID := range []uuid.UUID{first, second, other} {
+		require.NoError(t, testRepo.Create(&model.Subscription{ServiceName: "Users", Price: 100, UserID: userID, StartDate: "01-2024"}))
+	}
+
+	subs, err := testRepo.GetAll(model.SubscriptionFilter{UserIDs: []uuid.UUID{first, second}})
+	require.NoError(t, err)
+	require.Len(t, subs, 2)
+	assert.Equal(t, first, subs[0].UserID)
+	assert.Equal(t, second, subs[1].UserID)
+}
@@ -0,0 +1,83 @@
+package repo
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+)
+
+var (
+	ErrTenantNotFound = errors.New("tenant not found")
+	ErrTenantExists = errors.New("tenant already exists")
+)
+
+type TenantRepo interface {
+	Create(*model.Tenant) error
+	List() ([]model.Tenant, error)
+	// Delete removes tenant together with all its data
+	Delete(string) error
+	Exists(string) (bool, error)
+}
+
+type PostgresTenantRepo struct {
+	db *sql.DB
+}
+
+func NewTenantRepo(db *sql.DB) *PostgresTenantRepo {
+	return &PostgresTenantRepo{db: db}
+}
+
+func (r *PostgresTenantRepo) Create(tenant *model.Tenant) error {
+	err := r.db.QueryRow(
+		`INSERT INTO tenants (id, name) VALUES ($1, $2) RETURNING created_at`,
+		tenant.ID, tenant.Name).Scan(&tenant.CreatedAt)
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrTenantExists
+	}
+	return err
+}
+
+func (r *PostgresTenantRepo) List() ([]model.Tenant, error) {
+	rows, err := r.db.Query(`SELECT id, name, created_at FROM tenants ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tenants := []model.Tenant{}
+	for rows.Next() {
+		var tenant model.Tenant
+		if err := rows.Scan(&tenant.ID, &tenant.Name, &tenant.CreatedAt); err != nil {
+			return nil, err
+		}
+		tenants = append(tenants, tenant)
+	}
+	return tenants, rows.Err()
+}
+
+// Delete relies on ON DELETE CASCADE of tenant_id foreign keys
+func (r *PostgresTenantRepo) Delete(id string) error {
+	res, err := r.db.Exec(`DELETE FROM tenants WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrTenantNotFound
+	}
+	return nil
+}
+
+func (r *PostgresTenantRepo) Exists(id string) (bool, error) {
+	var exists bool
+	err := r.db.QueryRow(`SELECT EXISTS(SELECT 1 FROM tenants WHERE id = $1)`, id).Scan(&exists)
+	return exists, err
+}
\ No newline at end of file
@@ -0,0 +1,46 @@
+package repo_test
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+	"github.com/teamcutter/subscriptions-service-task/internal/repo"
+)
+
+func TestTenantIsolation(t *testing.T) {
+	tenants := repo.NewTenantRepo(db)
+	require.NoError(t, tenants.Create(&model.Tenant{ID: "acme", Name: "Acme"}))
+	assert.ErrorIs(t, tenants.Create(&model.Tenant{ID: "acme", Name: "Acme"}), repo.ErrTenantExists)
+
+	acme := testRepo.ForTenant("acme")
+	user := uuid.New()
+	sub := &model.Subscription{ServiceName: "Tenant", Price: 100, UserID: user, StartDate: "01-2024"}
+	require.NoError(t, acme.Create(sub))
+
+	// the default tenant does not see rows of acme
+	_, err := testRepo.GetByID(int(sub.ID))
+	assert.ErrorIs(t, err, repo.ErrNotFound)
+	assert.ErrorIs(t, testRepo.Delete(int(sub.ID), sub.Version), repo.ErrNotFound)
+
+	subs, err := testRepo.GetAll(model.SubscriptionFilter{UserID: uuid.NullUUID{UUID: user, Valid: true}})
+	require.NoError(t, err)
+	assert.Empty(t, subs)
+
+	total, err := testRepo.TotalCost(user.String(), "", "01-2024", "12-2024")
+	require.NoError(t, err)
+	assert.Equal(t, 0, total)
+
+	found, err := acme.GetByID(int(sub.ID))
+	require.NoError(t, err)
+	assert.Equal(t, "Tenant", found.ServiceName)
+
+	// deleting tenant removes its data
+	require.NoError(t, tenants.Delete("acme"))
+	_, err = acme.GetByID(int(sub.ID))
+	assert.ErrorIs(t, err, repo.ErrNotFound)
+	assert.ErrorIs(t, tenants.Delete("acme"), repo.ErrTenantNotFound)
+}
\ No newline at end of file
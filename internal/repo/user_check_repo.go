@@ -0,0 +1,94 @@
+package repo
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/google/uuid"
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+)
+
+// UserCheckRepo makes sure subscriptions are written only for existing
+// users. In permissive mode missing users are created with default
+// profile instead of failing with ErrInvalidSubscription
+type UserCheckRepo struct {
+	Repo
+	users UserRepo
+	permissive bool
+}
+
+func NewUserCheckRepo(r Repo, users UserRepo, permissive bool) *UserCheckRepo {
+	return &UserCheckRepo{Repo: r, users: users, permissive: permissive}
+}
+
+func (r *UserCheckRepo) ForTenant(tenantID string) Repo {
+	return NewUserCheckRepo(r.Repo.ForTenant(tenantID), r.users.ForTenant(tenantID), r.permissive)
+}
+
+// missing returns users that do not exist, in permissive mode it creates them
+func (r *UserCheckRepo) missing(ids []uuid.UUID) ([]uuid.UUID, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	if r.permissive {
+		return nil, r.users.Ensure(ids)
+	}
+	return r.users.Missing(ids)
+}
+
+func (r *UserCheckRepo) check(s *model.Subscription) error {
+	// nil user is never created in permissive mode
+	if err := checkUser(s); err != nil {
+		return err
+	}
+	missing, err := r.missing([]uuid.UUID{s.UserID})
+	if err != nil {
+		return err
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: user %s does not exist", ErrInvalidSubscription, s.UserID)
+	}
+	return nil
+}
+
+func (r *UserCheckRepo) Create(s *model.Subscription) error {
+	if err := r.check(s); err != nil {
+		return err
+	}
+	return r.Repo.Create(s)
+}
+
+func (r *UserCheckRepo) Update(s *model.Subscription, version int) error {
+	if err := r.check(s); err != nil {
+		return err
+	}
+	return r.Repo.Update(s, version)
+}
+
+// Batch fails operations writing subscriptions of missing users
+func (r *UserCheckRepo) Batch(ops []model.BatchOperation, atomic bool) ([]model.BatchResult, error) {
+	var ids []uuid.UUID
+	for _, op := range ops {
+		if op.Subscription != nil && op.Subscription.UserID != uuid.Nil && !slices.Contains(ids, op.Subscription.UserID) {
+			ids = append(ids, op.Subscription.UserID)
+		}
+	}
+
+	missing, err := r.missing(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	return filterBatch(r.Repo, ops, atomic, func(op *model.BatchOperation) error {
+		if op.Subscription == nil {
+			return nil
+		}
+		if err := checkUser(op.Subscription); err != nil {
+			return err
+		}
+		if slices.Contains(missing, op.Subscription.UserID) {
+			return fmt.Errorf("%w: user %s does not exist", ErrInvalidSubscription, op.Subscription.UserID)
+		}
+		return nil
+	})
+}
\ No newline at end of file
@@ -0,0 +1,324 @@
+package repo
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"github.com/google/uuid"
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+)
+
+type UserDataRepo interface {
+	// Export reads all data of user from one snapshot
+	Export(uuid.UUID) (*model.UserData, error)
+	// Erase deletes or anonymises all data of user and records entry
+	// to audit log in the same transaction, it returns affected rows per table
+	Erase(uuid.UUID, *model.AuditEntry) (map[string]int64, error)
+	ForTenant(string) UserDataRepo
+}
+
+type PostgresUserDataRepo struct {
+	db *sql.DB
+	tenantID string
+}
+
+func NewUserDataRepo(db *sql.DB) *PostgresUserDataRepo {
+	return &PostgresUserDataRepo{db: db, tenantID: model.DefaultTenant}
+}
+
+func (r *PostgresUserDataRepo) ForTenant(tenantID string) UserDataRepo {
+	return &PostgresUserDataRepo{db: r.db, tenantID: tenantID}
+}
+
+func actorOf(userID uuid.UUID) string {
+	return "user:" + userID.String()
+}
+
+func (r *PostgresUserDataRepo) Export(userID uuid.UUID) (*model.UserData, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`SET TRANSACTION ISOLATION LEVEL REPEATABLE READ, READ ONLY`); err != nil {
+		return nil, err
+	}
+
+	data := model.UserData{}
+	if data.Profile, err = getUser(tx, r.tenantID, userID); err != nil {
+		return nil, err
+	}
+	if data.Subscriptions, err = exportSubscriptions(tx, r.tenantID, userID); err != nil {
+		return nil, err
+	}
+	if data.Prices, err = exportPrices(tx, r.tenantID, userID); err != nil {
+		return nil, err
+	}
+	if data.Splits, err = exportSplits(tx, r.tenantID, userID); err != nil {
+		return nil, err
+	}
+	if data.Households, err = listHouseholds(tx, r.tenantID, userID); err != nil {
+		return nil, err
+	}
+	if data.ImportJobs, err = exportImportJobs(tx, r.tenantID, userID); err != nil {
+		return nil, err
+	}
+	if data.Notifications, err = exportNotifications(tx, r.tenantID, userID); err != nil {
+		return nil, err
+	}
+	if data.WebhookDeliveries, err = exportDeliveries(tx, r.tenantID, userID); err != nil {
+		return nil, err
+	}
+	if data.Audit, err = exportAudit(tx, r.tenantID, userID); err != nil {
+		return nil, err
+	}
+	return &data, tx.Commit()
+}
+
+func exportSubscriptions(q querier, tenantID string, userID uuid.UUID) ([]model.Subscription, error) {
+	rows, err := q.Query(
+		`SELECT id, service_name, price, user_id, start_date, end_date, trial_end_date, version FROM subscriptions
+		WHERE tenant_id = $1 AND user_id = $2 ORDER BY id`, tenantID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subs := []model.Subscription{}
+	for rows.Next() {
+		sub, err := scanSubscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, sub)
+	}
+	return subs, rows.Err()
+}
+
+func exportSplits(q querier, tenantID string, userID uuid.UUID) ([]model.Split, error) {
+	rows, err := q.Query(
+		`SELECT sp.subscription_id, sp.household_id, sp.rule, `+splitParts+`
+		FROM subscription_splits sp JOIN subscriptions s ON s.id = sp.subscription_id
+		WHERE s.tenant_id = $1
+		AND (s.user_id = $2 OR EXISTS (SELECT 1 FROM subscription_split_parts p WHERE p.subscription_id = s.id AND p.user_id = $2))
+		ORDER BY sp.subscription_id`, tenantID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	splits := []model.Split{}
+	for rows.Next() {
+		var split model.Split
+		var parts []byte
+		if err := rows.Scan(&split.SubscriptionID, &split.HouseholdID, &split.Rule, &parts); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(parts, &split.Parts); err != nil {
+			return nil, err
+		}
+		splits = append(splits, split)
+	}
+	return splits, rows.Err()
+}
+
+func exportImportJobs(q querier, tenantID string, userID uuid.UUID) ([]model.ImportJob, error) {
+	rows, err := q.Query(
+		`SELECT id, status, format, report, error, created_at, finished_at FROM import_jobs
+		WHERE tenant_id = $1 AND user_id = $2 ORDER BY created_at`, tenantID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := []model.ImportJob{}
+	for rows.Next() {
+		job := model.ImportJob{TenantID: tenantID, UserID: uuid.NullUUID{UUID: userID, Valid: true}}
+		var report []byte
+		err := rows.Scan(&job.ID, &job.Status, &job.Format, &report, &job.Error, &job.CreatedAt, &job.FinishedAt)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(report, &job.Report); err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+func exportNotifications(q querier, tenantID string, userID uuid.UUID) ([]model.Notification, error) {
+	rows, err := q.Query(
+		`SELECT n.id, n.tenant_id, n.kind, n.channel, n.due_date, n.attempts, n.subscription_id,
+			s.service_name, s.price, n.user_id
+		FROM notifications n JOIN subscriptions s ON s.id = n.subscription_id
+		WHERE n.tenant_id = $1 AND n.user_id = $2 ORDER BY n.id`, tenantID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notifications := []model.Notification{}
+	for rows.Next() {
+		var n model.Notification
+		err := rows.Scan(&n.ID, &n.TenantID, &n.Kind, &n.Channel, &n.DueDate, &n.Attempts, &n.SubscriptionID,
+			&n.Subscription.ServiceName, &n.Subscription.Price, &n.User.ID)
+		if err != nil {
+			return nil, err
+		}
+		n.Subscription.ID = n.SubscriptionID
+		n.Subscription.UserID = n.User.ID
+		notifications = append(notifications, n)
+	}
+	return notifications, rows.Err()
+}
+
+// exportDeliveries reads deliveries of events about the user, they are
+// matched by the user id in the payload like in erasure
+func exportDeliveries(q querier, tenantID string, userID uuid.UUID) ([]model.WebhookDelivery, error) {
+	rows, err := q.Query(
+		`SELECT `+deliveryColumns+` FROM webhook_deliveries d JOIN webhooks w ON w.id = d.webhook_id
+		WHERE w.tenant_id = $1 AND d.payload->>'user_id' = $2 ORDER BY d.id`, tenantID, userID.String())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []model.WebhookDelivery{}
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, *d)
+	}
+	return deliveries, rows.Err()
+}
+
+func exportAudit(q querier, tenantID string, userID uuid.UUID) ([]model.AuditEntry, error) {
+	rows, err := q.Query(
+		`SELECT id, tenant_id, actor, action, resource, outcome, created_at FROM audit_log
+		WHERE tenant_id = $1 AND actor = $2 ORDER BY id`, tenantID, actorOf(userID))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []model.AuditEntry{}
+	for rows.Next() {
+		var entry model.AuditEntry
+		err := rows.Scan(&entry.ID, &entry.TenantID, &entry.Actor, &entry.Action, &entry.Resource, &entry.Outcome, &entry.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// erasure statements run in order, $1 is tenant and $2 is user id.
+// Households owned by the user pass to another member, those left
+// without members are deleted. Split parts go with memberships and
+// splits with subscriptions through foreign keys. Events of the deleted
+// subscriptions are recorded after all statements, see Erase
+var erasure = []struct {
+	table string
+	query string
+}{
+	{"notifications", `DELETE FROM notifications WHERE tenant_id = $1 AND user_id = $2`},
+	{"subscriptions", `DELETE FROM subscriptions WHERE tenant_id = $1 AND user_id = $2`},
+	{"price_history", `DELETE FROM price_history WHERE tenant_id = $1 AND user_id = $2`},
+	{"households", `UPDATE households h SET owner_id = m.user_id
+		FROM (SELECT DISTINCT ON (household_id) household_id, user_id FROM household_members
+			WHERE user_id <> $2 ORDER BY household_id, user_id) m
+		WHERE m.household_id = h.id AND h.tenant_id = $1 AND h.owner_id = $2`},
+	{"household_members", `DELETE FROM household_members m USING households h
+		WHERE m.household_id = h.id AND h.tenant_id = $1 AND m.user_id = $2`},
+	{"households", `DELETE FROM households WHERE tenant_id = $1 AND owner_id = $2`},
+	{"import_jobs", `DELETE FROM import_jobs WHERE tenant_id = $1 AND user_id = $2`},
+	// stored responses may contain subscriptions of the user
+	{"idempotency_keys", `DELETE FROM idempotency_keys WHERE tenant_id = $1 AND starts_with(key, $2::text || ':')`},
+	// queued and sent events carry the user id and subscription details
+	{"outbox", `DELETE FROM outbox WHERE tenant_id = $1 AND payload->>'user_id' = $2`},
+	{"webhook_deliveries", `DELETE FROM webhook_deliveries d USING webhooks w
+		WHERE d.webhook_id = w.id AND w.tenant_id = $1 AND d.payload->>'user_id' = $2`},
+	{"users", `DELETE FROM users WHERE tenant_id = $1 AND id = $2`},
+}
+
+func (r *PostgresUserDataRepo) Erase(userID uuid.UUID, entry *model.AuditEntry) (map[string]int64, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// ids are read first, the rows stay locked until they are deleted
+	var deleted []int64
+	rows, err := tx.Query(`SELECT id FROM subscriptions WHERE tenant_id = $1 AND user_id = $2 ORDER BY id FOR UPDATE`,
+		r.tenantID, userID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		deleted = append(deleted, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	erased := map[string]int64{}
+	var total int64
+	for _, statement := range erasure {
+		res, err := tx.Exec(statement.query, r.tenantID, userID.String())
+		if err != nil {
+			return nil, err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+		erased[statement.table] += n
+		total += n
+	}
+
+	// subscribers learn about deleted subscriptions like on any delete. The
+	// events do not name the user, so they are not left behind with its id
+	// after outbox and deliveries of the user were erased
+	events := make([]model.Event, 0, len(deleted))
+	for _, id := range deleted {
+		events = append(events, newEvent(r.tenantID, model.EventSubscriptionDeleted, id, uuid.Nil, nil))
+	}
+	if err := recordEvents(tx, events...); err != nil {
+		return nil, err
+	}
+
+	// audit trail is kept, only the actor is anonymised
+	res, err := tx.Exec(`UPDATE audit_log SET actor = $1 WHERE tenant_id = $2 AND actor = $3`,
+		model.ErasedActor, r.tenantID, actorOf(userID))
+	if err != nil {
+		return nil, err
+	}
+	if erased["audit_log"], err = res.RowsAffected(); err != nil {
+		return nil, err
+	}
+
+	if total+erased["audit_log"] == 0 {
+		return nil, ErrUserNotFound
+	}
+
+	entry.TenantID = r.tenantID
+	if entry.Actor == actorOf(userID) {
+		entry.Actor = model.ErasedActor
+	}
+	if err := recordAudit(tx, entry); err != nil {
+		return nil, err
+	}
+	return erased, tx.Commit()
+}
\ No newline at end of file
@@ -0,0 +1,69 @@
+package repo_test
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+	"github.com/teamcutter/subscriptions-service-task/internal/repo"
+)
+
+func TestExportAndEraseUser(t *testing.T) {
+	users := repo.NewUserRepo(db)
+	households := repo.NewHouseholdRepo(db)
+	data := repo.NewUserDataRepo(db)
+	outbox := repo.NewOutboxRepo(db)
+	publishAll(t, outbox)
+	user, member := uuid.New(), uuid.New()
+
+	require.NoError(t, users.Save(&model.User{ID: user, Name: "Ann", Currency: "EUR", Timezone: "UTC"}))
+	sub := &model.Subscription{ServiceName: "Music", Price: 300, UserID: user, StartDate: "01-2024"}
+	require.NoError(t, testRepo.Create(sub))
+	sub.Price = 350
+	require.NoError(t, testRepo.Update(sub, 0))
+
+	household := &model.Household{Name: "Flat", OwnerID: user}
+	require.NoError(t, households.Create(household))
+	require.NoError(t, households.AddMember(household.ID, member))
+	require.NoError(t, households.SetSplit(&model.Split{SubscriptionID: sub.ID, HouseholdID: household.ID, Rule: model.SplitEqual}))
+
+	require.NoError(t, repo.NewAuditRepo(db).Record(&model.AuditEntry{
+		TenantID: model.DefaultTenant, Actor: "user:" + user.String(), Action: "subscriptions:read:any", Resource: "GET /subscriptions", Outcome: model.AuditDenied}))
+
+	exported, err := data.Export(user)
+	require.NoError(t, err)
+	assert.Equal(t, "Ann", exported.Profile.Name)
+	assert.Len(t, exported.Subscriptions, 1)
+	require.Len(t, exported.Prices, 2)
+	assert.Equal(t, 300, exported.Prices[0].Price)
+	assert.Equal(t, 350, exported.Prices[1].Price)
+	assert.Len(t, exported.Splits, 1)
+	assert.Len(t, exported.Households, 1)
+	assert.Len(t, exported.Audit, 1)
+
+	entry := &model.AuditEntry{Actor: "user:" + user.String(), Action: model.AuditErasure, Resource: "erasure:1", Outcome: model.AuditAllowed}
+	erased, err := data.Erase(user, entry)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), erased["subscriptions"])
+	assert.Equal(t, int64(2), erased["price_history"])
+	assert.Equal(t, int64(1), erased["users"])
+	assert.Equal(t, int64(1), erased["audit_log"])
+	assert.Equal(t, model.ErasedActor, entry.Actor)
+
+	// events of the user are erased, deletion is announced without the user
+	assert.Equal(t, map[int64][]string{sub.ID: {model.EventSubscriptionDeleted}}, publishAll(t, outbox))
+
+	// household stays with the other member
+	got, err := households.Get(household.ID)
+	require.NoError(t, err)
+	assert.Equal(t, member, got.OwnerID)
+	assert.Equal(t, []uuid.UUID{member}, got.Members)
+
+	_, err = data.Export(user)
+	assert.ErrorIs(t, err, repo.ErrUserNotFound)
+	_, err = data.Erase(user, &model.AuditEntry{Action: model.AuditErasure, Resource: "erasure:2", Outcome: model.AuditAllowed})
+	assert.ErrorIs(t, err, repo.ErrUserNotFound)
+}
\ No newline at end of file
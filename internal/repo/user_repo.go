@@ -0,0 +1,190 @@
+package repo
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+)
+
+var (
+	ErrUserNotFound = errors.New("user not found")
+	ErrEmailTaken = errors.New("email is already used by another user")
+)
+
+type UserRepo interface {
+	Get(uuid.UUID) (*model.User, error)
+	// GetMany returns users of ids that exist
+	GetMany([]uuid.UUID) ([]model.User, error)
+	// Save creates user or replaces the profile of an existing one
+	Save(*model.User) error
+	// SaveMany saves users with a single statement, for bulk loads
+	SaveMany([]model.User) error
+	// Missing returns ids of users that do not exist
+	Missing([]uuid.UUID) ([]uuid.UUID, error)
+	// Ensure creates users with default profile unless they exist
+	Ensure([]uuid.UUID) error
+	ForTenant(string) UserRepo
+}
+
+type PostgresUserRepo struct {
+	db *sql.DB
+	tenantID string
+}
+
+func NewUserRepo(db *sql.DB) *PostgresUserRepo {
+	return &PostgresUserRepo{db: db, tenantID: model.DefaultTenant}
+}
+
+func (r *PostgresUserRepo) ForTenant(tenantID string) UserRepo {
+	return &PostgresUserRepo{db: r.db, tenantID: tenantID}
+}
+
+func (r *PostgresUserRepo) Get(id uuid.UUID) (*model.User, error) {
+	return getUser(r.db, r.tenantID, id)
+}
+
+func getUser(q querier, tenantID string, id uuid.UUID) (*model.User, error) {
+	user, err := scanUser(q.QueryRow(
+		`SELECT id, name, email, currency, timezone, notifications, created_at, updated_at
+		FROM users WHERE id = $1 AND tenant_id = $2`, id, tenantID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+	return user, err
+}
+
+func scanUser(row rowScanner) (*model.User, error) {
+	var user model.User
+	var email sql.NullString
+	var notifications []byte
+
+	err := row.Scan(&user.ID, &user.Name, &email, &user.Currency, &user.Timezone, &notifications, &user.CreatedAt, &user.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	user.Email = email.String
+	if err := json.Unmarshal(notifications, &user.Notifications); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *PostgresUserRepo) GetMany(ids []uuid.UUID) ([]model.User, error) {
+	rows, err := r.db.Query(
+		`SELECT id, name, email, currency, timezone, notifications, created_at, updated_at
+		FROM users WHERE tenant_id = $1 AND id = ANY($2::uuid[])`,
+		r.tenantID, pq.Array(uuidStrings(ids)))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []model.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, *user)
+	}
+	return users, rows.Err()
+}
+
+func (r *PostgresUserRepo) Save(user *model.User) error {
+	notifications, err := json.Marshal(user.Notifications)
+	if err != nil {
+		return err
+	}
+
+	err = r.db.QueryRow(
+		`INSERT INTO users (id, tenant_id, name, email, currency, timezone, notifications)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6, $7)
+		ON CONFLICT (tenant_id, id) DO UPDATE
+		SET name = $3, email = NULLIF($4, ''), currency = $5, timezone = $6, notifications = $7, updated_at = now()
+		RETURNING created_at, updated_at`,
+		user.ID, r.tenantID, user.Name, user.Email, user.Currency, user.Timezone, notifications).
+		Scan(&user.CreatedAt, &user.UpdatedAt)
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrEmailTaken
+	}
+	return err
+}
+
+func (r *PostgresUserRepo) SaveMany(users []model.User) error {
+	ids := make([]string, len(users))
+	names := make([]string, len(users))
+	emails := make([]string, len(users))
+	currencies := make([]string, len(users))
+	timezones := make([]string, len(users))
+	notifications := make([]string, len(users))
+	for i, user := range users {
+		prefs, err := json.Marshal(user.Notifications)
+		if err != nil {
+			return err
+		}
+		ids[i], names[i], emails[i] = user.ID.String(), user.Name, user.Email
+		currencies[i], timezones[i], notifications[i] = user.Currency, user.Timezone, string(prefs)
+	}
+
+	_, err := r.db.Exec(
+		`INSERT INTO users (id, tenant_id, name, email, currency, timezone, notifications)
+		SELECT u.id, $7, u.name, NULLIF(u.email, ''), u.currency, u.timezone, u.notifications
+		FROM unnest($1::uuid[], $2::text[], $3::text[], $4::text[], $5::text[], $6::jsonb[])
+			AS u(id, name, email, currency, timezone, notifications)
+		ON CONFLICT (tenant_id, id) DO UPDATE
+		SET name = EXCLUDED.name, email = EXCLUDED.email, currency = EXCLUDED.currency,
+			timezone = EXCLUDED.timezone, notifications = EXCLUDED.notifications, updated_at = now()`,
+		pq.Array(ids), pq.Array(names), pq.Array(emails), pq.Array(currencies), pq.Array(timezones), pq.Array(notifications), r.tenantID)
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrEmailTaken
+	}
+	return err
+}
+
+func uuidStrings(ids []uuid.UUID) []string {
+	values := make([]string, len(ids))
+	for i, id := range ids {
+		values[i] = id.String()
+	}
+	return values
+}
+
+func (r *PostgresUserRepo) Missing(ids []uuid.UUID) ([]uuid.UUID, error) {
+	rows, err := r.db.Query(
+		`SELECT DISTINCT ids.id::text FROM unnest($1::uuid[]) AS ids(id)
+		WHERE NOT EXISTS (SELECT 1 FROM users u WHERE u.id = ids.id AND u.tenant_id = $2)`,
+		pq.Array(uuidStrings(ids)), r.tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var missing []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		missing = append(missing, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return parseUUIDs(missing)
+}
+
+func (r *PostgresUserRepo) Ensure(ids []uuid.UUID) error {
+	_, err := r.db.Exec(
+		`INSERT INTO users (id, tenant_id) SELECT DISTINCT unnest($1::uuid[]), $2 ON CONFLICT DO NOTHING`,
+		pq.Array(uuidStrings(ids)), r.tenantID)
+	return err
+}
\ No newline at end of file
@@ -0,0 +1,84 @@
+package repo_test
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+	"github.com/teamcutter/subscriptions-service-task/internal/repo"
+)
+
+func TestUserProfile(t *testing.T) {
+	users := repo.NewUserRepo(db)
+
+	user := &model.User{ID: uuid.New(), Name: "Ann", Email: "ann@example.com", Currency: "EUR", Timezone: "Europe/Berlin",
+		Notifications: model.NotificationPreferences{Email: true, RemindDaysBefore: 3}}
+	require.NoError(t, users.Save(user))
+
+	got, err := users.Get(user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "EUR", got.Currency)
+	assert.Equal(t, 3, got.Notifications.RemindDaysBefore)
+
+	other := &model.User{ID: uuid.New(), Email: "ann@example.com", Currency: "EUR", Timezone: "UTC"}
+	assert.ErrorIs(t, users.Save(other), repo.ErrEmailTaken)
+}
+
+func TestGetManyUsers(t *testing.T) {
+	users := repo.NewUserRepo(db)
+	saved, missing := uuid.New(), uuid.New()
+	require.NoError(t, users.Save(&model.User{ID: saved, Name: "Bob", Currency: "USD", Timezone: "UTC"}))
+
+	got, err := users.GetMany([]uuid.UUID{saved, missing})
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	assert.Equal(t, "Bob", got[0].Name)
+
+	got, err = users.ForTenant("other").GetMany([]uuid.UUID{saved})
+	require.NoError(t, err)
+	assert.Empty(t, got)
+}
+
+func TestUserCheckRepo(t *testing.T) {
+	users := repo.NewUserRepo(db)
+	known, unknown := uuid.New(), uuid.New()
+	require.NoError(t, users.Ensure([]uuid.UUID{known}))
+
+	strict := repo.NewUserCheckRepo(testRepo, users, false)
+	assert.NoError(t, strict.Create(&model.Subscription{ServiceName: "Known", Price: 1, UserID: known, StartDate: "01-2024"}))
+	err := strict.Create(&model.Subscription{ServiceName: "Typo", Price: 1, UserID: unknown, StartDate: "01-2024"})
+	assert.ErrorIs(t, err, repo.ErrInvalidSubscription)
+
+	results, err := strict.Batch([]model.BatchOperation{
+		{Op: model.BatchOpCreate, Subscription: &model.Subscription{ServiceName: "Known", Price: 1, UserID: known, StartDate: "01-2024"}},
+		{Op: model.BatchOpCreate, Subscription: &model.Subscription{ServiceName: "Typo", Price: 1, UserID: unknown, StartDate: "01-2024"}},
+	}, false)
+	require.NoError(t, err)
+	assert.NoError(t, results[0].Err)
+	assert.ErrorIs(t, results[1].Err, repo.ErrInvalidSubscription)
+
+	permissive := repo.NewUserCheckRepo(testRepo, users, true)
+	assert.NoError(t, permissive.Create(&model.Subscription{ServiceName: "Typo", Price: 1, UserID: unknown, StartDate: "01-2024"}))
+	_, err = users.Get(unknown)
+	assert.NoError(t, err)
+}
+func TestSubscriptionWithoutUser(t *testing.T) {
+	users := repo.NewUserRepo(db)
+	permissive := repo.NewUserCheckRepo(testRepo, users, true)
+
+	err := permissive.Create(&model.Subscription{ServiceName: "Nobody", Price: 1, StartDate: "01-2024"})
+	assert.ErrorIs(t, err, repo.ErrInvalidSubscription)
+
+	results, err := permissive.Batch([]model.BatchOperation{
+		{Op: model.BatchOpCreate, Subscription: &model.Subscription{ServiceName: "Nobody", Price: 1, StartDate: "01-2024"}},
+	}, false)
+	require.NoError(t, err)
+	assert.ErrorIs(t, results[0].Err, repo.ErrInvalidSubscription)
+
+	_, err = users.Get(uuid.Nil)
+	assert.ErrorIs(t, err, repo.ErrUserNotFound)
+	assert.ErrorIs(t, repo.Validate(&model.Subscription{ServiceName: "Nobody", Price: 1, StartDate: "01-2024"}), repo.ErrInvalidSubscription)
+}
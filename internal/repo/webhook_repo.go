@@ -0,0 +1,216 @@
+package repo
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+)
+
+var (
+	ErrWebhookNotFound = errors.New("webhook not found")
+	ErrDeliveryNotFound = errors.New("webhook delivery not found")
+)
+
+type WebhookRepo interface {
+	Create(*model.Webhook) error
+	// List returns webhooks without secrets
+	List() ([]model.Webhook, error)
+	Delete(int64) error
+	// Enqueue creates deliveries of event to webhooks subscribed to its type
+	Enqueue(model.Event) error
+	// Deliveries returns up to limit latest deliveries of webhook
+	Deliveries(int64, int) ([]model.WebhookDelivery, error)
+	// Redeliver makes delivery of webhook pending again with no attempts made
+	Redeliver(int64, int64) (*model.WebhookDelivery, error)
+	ForTenant(string) WebhookRepo
+}
+
+type PostgresWebhookRepo struct {
+	db *sql.DB
+	tenantID string
+}
+
+func NewWebhookRepo(db *sql.DB) *PostgresWebhookRepo {
+	return &PostgresWebhookRepo{db: db, tenantID: model.DefaultTenant}
+}
+
+func (r *PostgresWebhookRepo) ForTenant(tenantID string) WebhookRepo {
+	return &PostgresWebhookRepo{db: r.db, tenantID: tenantID}
+}
+
+func (r *PostgresWebhookRepo) Create(webhook *model.Webhook) error {
+	webhook.TenantID = r.tenantID
+	if webhook.Events == nil {
+		webhook.Events = []string{}
+	}
+	return r.db.QueryRow(
+		`INSERT INTO webhooks (tenant_id, url, events, secret) VALUES ($1, $2, $3, $4) RETURNING id, created_at`,
+		r.tenantID, webhook.URL, pq.Array(webhook.Events), webhook.Secret).Scan(&webhook.ID, &webhook.CreatedAt)
+}
+
+func (r *PostgresWebhookRepo) List() ([]model.Webhook, error) {
+	rows, err := r.db.Query(
+		`SELECT id, tenant_id, url, events, created_at FROM webhooks WHERE tenant_id = $1 ORDER BY id`, r.tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := []model.Webhook{}
+	for rows.Next() {
+		var webhook model.Webhook
+		err := rows.Scan(&webhook.ID, &webhook.TenantID, &webhook.URL, pq.Array(&webhook.Events), &webhook.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	return webhooks, rows.Err()
+}
+
+func (r *PostgresWebhookRepo) Delete(id int64) error {
+	res, err := r.db.Exec(`DELETE FROM webhooks WHERE id = $1 AND tenant_id = $2`, id, r.tenantID)
+	if err != nil {
+		return err
+	}
+	return affected(res, ErrWebhookNotFound)
+}
+
+func (r *PostgresWebhookRepo) Enqueue(event model.Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.Exec(
+		`INSERT INTO webhook_deliveries (webhook_id, event_id, event_type, payload)
+		SELECT id, $2, $3, $4 FROM webhooks
+		WHERE tenant_id = $1 AND (cardinality(events) = 0 OR $3 = ANY(events))
+		ON CONFLICT DO NOTHING`,
+		r.tenantID, event.ID, event.Type, payload)
+	return err
+}
+
+const deliveryColumns = `d.id, d.webhook_id, d.event_id, d.event_type, d.payload, d.status, d.attempts,
+	COALESCE(d.response_status, 0), d.last_error, d.next_attempt_at, d.delivered_at, d.created_at`
+
+func scanDelivery(row rowScanner, extra ...any) (*model.WebhookDelivery, error) {
+	var d model.WebhookDelivery
+	var payload []byte
+
+	err := row.Scan(append([]any{&d.ID, &d.WebhookID, &d.EventID, &d.EventType, &payload, &d.Status, &d.Attempts,
+		&d.ResponseStatus, &d.LastError, &d.NextAttemptAt, &d.DeliveredAt, &d.CreatedAt}, extra...)...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrDeliveryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	d.Payload = payload
+	return &d, nil
+}
+
+func (r *PostgresWebhookRepo) Deliveries(webhookID int64, limit int) ([]model.WebhookDelivery, error) {
+	var exists bool
+	err := r.db.QueryRow(
+		`SELECT EXISTS(SELECT 1 FROM webhooks WHERE id = $1 AND tenant_id = $2)`, webhookID, r.tenantID).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrWebhookNotFound
+	}
+
+	rows, err := r.db.Query(
+		`SELECT `+deliveryColumns+` FROM webhook_deliveries d WHERE d.webhook_id = $1 ORDER BY d.id DESC LIMIT $2`,
+		webhookID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []model.WebhookDelivery{}
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, *d)
+	}
+	return deliveries, rows.Err()
+}
+
+func (r *PostgresWebhookRepo) Redeliver(webhookID, deliveryID int64) (*model.WebhookDelivery, error) {
+	return scanDelivery(r.db.QueryRow(
+		`UPDATE webhook_deliveries d
+		SET status = 'pending', attempts = 0, last_error = '', next_attempt_at = now()
+		FROM webhooks w
+		WHERE w.id = d.webhook_id AND d.id = $1 AND d.webhook_id = $2 AND w.tenant_id = $3
+		RETURNING `+deliveryColumns,
+		deliveryID, webhookID, r.tenantID))
+}
+
+// Claim takes up to limit deliveries of all tenants due for sending and
+// hides them from other instances for lease
+func (r *PostgresWebhookRepo) Claim(limit int, lease time.Duration) ([]model.WebhookDelivery, error) {
+	rows, err := r.db.Query(
+		`WITH claimed AS (
+			UPDATE webhook_deliveries
+			SET status = 'sending', attempts = attempts + 1, next_attempt_at = now() + $2::float8 * interval '1 second'
+			WHERE id IN (
+				SELECT id FROM webhook_deliveries
+				WHERE status IN ('pending', 'sending') AND next_attempt_at <= now()
+				ORDER BY next_attempt_at
+				LIMIT $1
+				FOR UPDATE SKIP LOCKED)
+			RETURNING *
+		)
+		SELECT `+deliveryColumns+`, w.url, w.secret
+		FROM claimed d JOIN webhooks w ON w.id = d.webhook_id
+		ORDER BY d.id`,
+		limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []model.WebhookDelivery
+	for rows.Next() {
+		var url, secret string
+		d, err := scanDelivery(rows, &url, &secret)
+		if err != nil {
+			return nil, err
+		}
+		d.URL, d.Secret = url, secret
+		deliveries = append(deliveries, *d)
+	}
+	return deliveries, rows.Err()
+}
+
+func (r *PostgresWebhookRepo) MarkDelivered(id int64, responseStatus int) error {
+	_, err := r.db.Exec(
+		`UPDATE webhook_deliveries
+		SET status = 'delivered', response_status = $2, last_error = '', delivered_at = now()
+		WHERE id = $1`,
+		id, responseStatus)
+	return err
+}
+
+// MarkFailed schedules another attempt after retryAfter, zero retryAfter
+// moves the delivery to dead letters. Response status is zero when
+// the request was not answered
+func (r *PostgresWebhookRepo) MarkFailed(id int64, responseStatus int, reason string, retryAfter time.Duration) error {
+	_, err := r.db.Exec(
+		`UPDATE webhook_deliveries
+		SET status = CASE WHEN $4::float8 > 0 THEN 'pending' ELSE 'dead' END,
+			response_status = NULLIF($2, 0),
+			last_error = $3,
+			next_attempt_at = now() + $4::float8 * interval '1 second'
+		WHERE id = $1`,
+		id, responseStatus, reason, retryAfter.Seconds())
+	return err
+}
\ No newline at end of file
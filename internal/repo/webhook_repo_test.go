@@ -0,0 +1,79 @@
+package repo_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+	"github.com/teamcutter/subscriptions-service-task/internal/repo"
+)
+
+func TestWebhookDeliveries(t *testing.T) {
+	require.NoError(t, repo.NewTenantRepo(db).Create(&model.Tenant{ID: "hooks", Name: "Hooks"}))
+	webhooks := repo.NewWebhookRepo(db)
+	tenant := webhooks.ForTenant("hooks")
+
+	all := &model.Webhook{URL: "https://example.com/all", Secret: "whsec_all"}
+	cancelled := &model.Webhook{URL: "https://example.com/cancelled", Events: []string{model.EventSubscriptionCancelled}, Secret: "whsec_cancelled"}
+	require.NoError(t, tenant.Create(all))
+	require.NoError(t, tenant.Create(cancelled))
+
+	list, err := tenant.List()
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.Empty(t, list[0].Secret)
+
+	for _, eventType := range []string{model.EventSubscriptionCreated, model.EventSubscriptionCancelled, model.EventSubscriptionDeleted} {
+		require.NoError(t, tenant.Enqueue(model.Event{ID: uuid.New(), Type: eventType, TenantID: "hooks", SubscriptionID: 1}))
+	}
+	// events published again do not create deliveries twice
+	event := model.Event{ID: uuid.New(), Type: model.EventSubscriptionUpdated, TenantID: "hooks", SubscriptionID: 1}
+	require.NoError(t, tenant.Enqueue(event))
+	require.NoError(t, tenant.Enqueue(event))
+
+	deliveries, err := tenant.Deliveries(all.ID, 10)
+	require.NoError(t, err)
+	var events []string
+	for _, d := range deliveries {
+		events = append(events, d.EventType)
+	}
+	assert.Equal(t, []string{model.EventSubscriptionUpdated, model.EventSubscriptionDeleted, model.EventSubscriptionCancelled, model.EventSubscriptionCreated}, events)
+
+	deliveries, err = tenant.Deliveries(cancelled.ID, 10)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, model.EventSubscriptionCancelled, deliveries[0].EventType)
+
+	// webhooks of other tenants are not visible
+	_, err = webhooks.Deliveries(all.ID, 10)
+	assert.ErrorIs(t, err, repo.ErrWebhookNotFound)
+
+	claimed, err := webhooks.Claim(10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, claimed, 5)
+	assert.Equal(t, "https://example.com/all", claimed[0].URL)
+	assert.Equal(t, "whsec_all", claimed[0].Secret)
+	assert.Equal(t, 1, claimed[0].Attempts)
+
+	again, err := webhooks.Claim(10, time.Minute)
+	require.NoError(t, err)
+	assert.Empty(t, again)
+
+	require.NoError(t, webhooks.MarkDelivered(claimed[0].ID, 200))
+	require.NoError(t, webhooks.MarkFailed(claimed[1].ID, 500, "webhook responded 500", 0))
+
+	dead, err := tenant.Redeliver(claimed[1].WebhookID, claimed[1].ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.DeliveryPending, dead.Status)
+	assert.Zero(t, dead.Attempts)
+
+	_, err = tenant.Redeliver(cancelled.ID, claimed[0].ID+1000)
+	assert.ErrorIs(t, err, repo.ErrDeliveryNotFound)
+
+	require.NoError(t, tenant.Delete(cancelled.ID))
+	assert.ErrorIs(t, tenant.Delete(cancelled.ID), repo.ErrWebhookNotFound)
+}
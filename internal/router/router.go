@@ -0,0 +1,123 @@
+package router
+
+import (
+	"slices"
+
+	"github.com/labstack/echo/v4"
+	"github.com/teamcutter/subscriptions-service-task/internal/auth"
+	"github.com/teamcutter/subscriptions-service-task/internal/handler"
+	"github.com/teamcutter/subscriptions-service-task/internal/middleware"
+)
+
+// Handlers serve the API routes
+type Handlers struct {
+	Subscriptions *handler.Handler
+	SubscriptionsV2 *handler.HandlerV2
+	Import *handler.ImportHandler
+	Stream *handler.StreamHandler
+	Households *handler.HouseholdHandler
+	Users *handler.UserHandler
+	GraphQL *handler.GraphQLHandler
+	Webhooks *handler.WebhookHandler
+	APIKeys *handler.APIKeyHandler
+	Tenants *handler.TenantHandler
+}
+
+// Middlewares wrap the API routes, nil ones are skipped
+type Middlewares struct {
+	// Auth returns middlewares authenticating callers of a group and
+	// checking scope, admin groups pass nil scope and are authorized by
+	// the policy alone. Requests are validated against operations at
+	// specPrefix followed by their path
+	Auth func(specPrefix string, scope echo.MiddlewareFunc) []echo.MiddlewareFunc
+	// Unversioned run on unversioned routes after the version middleware
+	Unversioned []echo.MiddlewareFunc
+	Idempotency echo.MiddlewareFunc
+	TotalLimit echo.MiddlewareFunc
+	SettleLimit echo.MiddlewareFunc
+	TotalV2Limit echo.MiddlewareFunc
+}
+
+func present(middlewares ...echo.MiddlewareFunc) []echo.MiddlewareFunc {
+	return slices.DeleteFunc(middlewares, func(m echo.MiddlewareFunc) bool { return m == nil })
+}
+
+func (m Middlewares) auth(specPrefix string, scope echo.MiddlewareFunc) []echo.MiddlewareFunc {
+	if m.Auth == nil {
+		return nil
+	}
+	return m.Auth(specPrefix, scope)
+}
+
+// Mount registers API v1 under /v1 and without prefix, and API v2
+func Mount(e *echo.Echo, h Handlers, m Middlewares) {
+	mountV1(e.Group("/v1"), "", h, m, middleware.APIVersion("v1"))
+	// unversioned routes stay until sunset, responses point to /v1
+	mountV1(e.Group(""), "/v1", h, m, append([]echo.MiddlewareFunc{middleware.APIVersion("unversioned")}, m.Unversioned...)...)
+
+	v2 := e.Group("/v2/subscriptions", present(append([]echo.MiddlewareFunc{middleware.APIVersion("v2")}, m.auth("", middleware.MethodScope())...)...)...)
+	v2.POST("", h.SubscriptionsV2.Create, present(m.Idempotency)...)
+	v2.GET("", h.SubscriptionsV2.List)
+	v2.GET("/:id", h.SubscriptionsV2.GetByID)
+	v2.PUT("/:id", h.SubscriptionsV2.Update)
+	v2.DELETE("/:id", h.SubscriptionsV2.Delete)
+	v2.GET("/total", h.SubscriptionsV2.TotalCost, present(m.TotalV2Limit)...)
+}
+
+// mountV1 registers API v1 under api, version middlewares run first
+func mountV1(api *echo.Group, specPrefix string, h Handlers, m Middlewares, version ...echo.MiddlewareFunc) {
+	group := func(prefix string, scope echo.MiddlewareFunc) *echo.Group {
+		return api.Group(prefix, present(append(slices.Clone(version), m.auth(specPrefix, scope)...)...)...)
+	}
+
+	subscriptions := group("/subscriptions", middleware.MethodScope())
+	subscriptions.POST("", h.Subscriptions.Create, present(m.Idempotency)...)
+	subscriptions.GET("", h.Subscriptions.GetAll)
+	subscriptions.GET("/export", h.Subscriptions.Export)
+	subscriptions.GET("/stream", h.Stream.Stream)
+	subscriptions.POST("/batch", h.Subscriptions.Batch)
+	subscriptions.POST("/import", h.Import.Import)
+	subscriptions.GET("/import/:id", h.Import.GetImportJob)
+	subscriptions.GET("/:id", h.Subscriptions.GetByID)
+	subscriptions.PUT("/:id", h.Subscriptions.Update)
+	subscriptions.DELETE("/:id", h.Subscriptions.Delete)
+	subscriptions.GET("/:id/split", h.Households.GetSplit)
+	subscriptions.PUT("/:id/split", h.Households.SetSplit)
+	subscriptions.DELETE("/:id/split", h.Households.DeleteSplit)
+	subscriptions.GET("/total", h.Subscriptions.TotalCost, present(m.TotalLimit)...)
+
+	households := group("/households", middleware.MethodScope())
+	households.POST("", h.Households.Create)
+	households.GET("", h.Households.List)
+	households.GET("/:id", h.Households.Get)
+	households.DELETE("/:id", h.Households.Delete)
+	households.POST("/:id/members", h.Households.AddMember)
+	households.DELETE("/:id/members/:user_id", h.Households.RemoveMember)
+	households.GET("/:id/settle", h.Households.Settle, present(m.SettleLimit)...)
+
+	users := group("/users", middleware.MethodScope())
+	users.GET("/:id", h.Users.Get)
+	users.GET("/:id/export", h.Users.Export)
+	users.PUT("/:id", h.Users.Save)
+	users.DELETE("/:id", h.Users.Delete)
+
+	// queries only read, so read scope is enough for POST
+	graphql := group("/graphql", middleware.RequireScope(auth.ScopeRead))
+	graphql.POST("", h.GraphQL.Query)
+
+	webhooks := group("/webhooks", middleware.MethodScope())
+	webhooks.POST("", h.Webhooks.Create)
+	webhooks.GET("", h.Webhooks.List)
+	webhooks.DELETE("/:id", h.Webhooks.Delete)
+	webhooks.GET("/:id/deliveries", h.Webhooks.Deliveries)
+	webhooks.POST("/:id/deliveries/:delivery_id/redeliver", h.Webhooks.Redeliver)
+
+	admin := group("/admin", nil)
+	admin.POST("/api-keys", h.APIKeys.Create)
+	admin.GET("/api-keys", h.APIKeys.List)
+	admin.POST("/api-keys/:id/rotate", h.APIKeys.Rotate)
+	admin.DELETE("/api-keys/:id", h.APIKeys.Revoke)
+	admin.POST("/tenants", h.Tenants.Create)
+	admin.GET("/tenants", h.Tenants.List)
+	admin.DELETE("/tenants/:id", h.Tenants.Delete)
+}
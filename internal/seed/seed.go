@@ -0,0 +1,221 @@
+package seed
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+)
+
+const monthDate = "01-2006"
+
+// Options of generated data, the same options always give the same data
+type Options struct {
+	Seed uint64
+	Users int
+	// Until is the last month of generated dates, subscriptions start up
+	// to History months before it
+	Until time.Time
+	History int
+}
+
+// Data is generated users and their subscriptions
+type Data struct {
+	Users []model.User `json:"users"`
+	Subscriptions []model.Subscription `json:"subscriptions"`
+}
+
+type service struct {
+	name string
+	// weight is how popular the service is
+	weight int
+	// tiers are monthly prices of plans in RUB, cheap plans are more common
+	tiers []int
+}
+
+var services = []service{
+	{"Yandex Plus", 30, []int{299, 449}},
+	{"Netflix", 25, []int{599, 799, 999}},
+	{"Spotify", 25, []int{169, 269, 349}},
+	{"YouTube Premium", 20, []int{299, 449}},
+	{"Kinopoisk", 15, []int{299, 449}},
+	{"Apple Music", 12, []int{169, 269}},
+	{"iCloud+", 12, []int{59, 149, 599}},
+	{"Google One", 10, []int{139, 279, 699}},
+	{"Amazon Prime", 8, []int{449}},
+	{"Disney+", 8, []int{549, 899}},
+	{"Microsoft 365", 7, []int{499, 699}},
+	{"ChatGPT Plus", 7, []int{1990}},
+	{"Dropbox", 5, []int{899, 1499}},
+	{"Xbox Game Pass", 5, []int{699, 1199}},
+	{"PlayStation Plus", 5, []int{599, 999, 1299}},
+	{"Duolingo", 5, []int{499}},
+	{"Notion", 4, []int{799}},
+	{"GitHub Copilot", 4, []int{899}},
+	{"Adobe Creative Cloud", 3, []int{2499, 5499}},
+	{"Headspace", 2, []int{1099}},
+}
+
+type region struct {
+	currency string
+	timezones []string
+	weight int
+	// rate converts prices of tiers, prices are rounded to whole units
+	rate float64
+	firstNames []string
+	// lastNames do not change with gender
+	lastNames []string
+}
+
+var regions = []region{
+	{"RUB", []string{"Europe/Moscow", "Asia/Yekaterinburg", "Asia/Novosibirsk"}, 70, 1,
+		[]string{"Anna", "Ivan", "Maria", "Alexey", "Olga", "Dmitry", "Elena", "Sergey", "Natalia", "Pavel"},
+		[]string{"Kim", "Chernykh", "Sedykh", "Belykh", "Dolgikh", "Shevchenko", "Kovalenko", "Bondarenko", "Tkachenko", "Moroz"}},
+	{"USD", []string{"America/New_York", "America/Chicago", "America/Los_Angeles"}, 15, 1.0 / 90,
+		[]string{"John", "Emma", "Liam", "Olivia", "Noah", "Ava", "James", "Mia"},
+		[]string{"Smith", "Johnson", "Brown", "Miller", "Garcia", "Davis", "Wilson", "Taylor"}},
+	{"EUR", []string{"Europe/Berlin", "Europe/Paris", "Europe/Madrid"}, 15, 1.0 / 100,
+		[]string{"Lukas", "Sophie", "Felix", "Clara", "Hugo", "Chloe", "Pablo", "Lucia"},
+		[]string{"Schmidt", "Weber", "Fischer", "Martin", "Dubois", "Moreau", "Lopez", "Romero"}},
+}
+
+// Generate returns users with 1-8 subscriptions each. About a fifth of
+// subscriptions are ended, some have trials, and some services are
+// subscribed again or upgraded while the old plan still runs, so date
+// ranges overlap
+func Generate(opts Options) Data {
+	var key [32]byte
+	binary.LittleEndian.PutUint64(key[:], opts.Seed)
+	src := rand.NewChaCha8(key)
+	g := &generator{
+		rnd: rand.New(src),
+		src: src,
+		until: time.Date(opts.Until.Year(), opts.Until.Month(), 1, 0, 0, 0, 0, time.UTC),
+		history: max(opts.History, 1),
+	}
+
+	var data Data
+	for i := range opts.Users {
+		user, reg := g.user(i)
+		data.Users = append(data.Users, user)
+		data.Subscriptions = append(data.Subscriptions, g.subscriptions(user.ID, reg)...)
+	}
+	return data
+}
+
+type generator struct {
+	rnd *rand.Rand
+	// src also feeds UUIDs
+	src *rand.ChaCha8
+	until time.Time
+	history int
+}
+
+func (g *generator) user(i int) (model.User, region) {
+	reg := pick(g.rnd, regions, func(r region) int { return r.weight })
+	first, last := reg.firstNames[g.rnd.IntN(len(reg.firstNames))], reg.lastNames[g.rnd.IntN(len(reg.lastNames))]
+
+	user := model.User{
+		ID: uuid.Must(uuid.NewRandomFromReader(g.src)),
+		Name: first + " " + last,
+		// the index keeps emails unique
+		Email: fmt.Sprintf("%s.%s.%d@example.com", strings.ToLower(first), strings.ToLower(last), i+1),
+		Currency: reg.currency,
+		Timezone: reg.timezones[g.rnd.IntN(len(reg.timezones))],
+	}
+	if g.rnd.IntN(10) < 3 {
+		user.Notifications = model.NotificationPreferences{Email: true, RemindDaysBefore: []int{1, 3, 7}[g.rnd.IntN(3)]}
+	}
+	return user, reg
+}
+
+func (g *generator) subscriptions(userID uuid.UUID, reg region) []model.Subscription {
+	// most users have 2-4 subscriptions, few have many
+	count := pick(g.rnd, []int{1, 2, 3, 4, 5, 6, 7, 8}, func(n int) int { return []int{10, 20, 22, 18, 12, 8, 6, 4}[n-1] })
+
+	var subs []model.Subscription
+	used := map[string]bool{}
+	for len(subs) < count {
+		svc := pick(g.rnd, services, func(s service) int { return s.weight })
+		if used[svc.name] {
+			continue
+		}
+		used[svc.name] = true
+
+		tier := g.tier(svc)
+		start := g.until.AddDate(0, -g.rnd.IntN(g.history), 0)
+		sub := model.Subscription{
+			ServiceName: svc.name,
+			Price: price(svc.tiers[tier], reg.rate),
+			UserID: userID,
+			StartDate: start.Format(monthDate),
+		}
+		if g.rnd.IntN(100) < 15 {
+			sub.TrialEndDate = start.AddDate(0, 1, 0).Format(monthDate)
+		}
+
+		end, ended := g.end(start)
+		if ended {
+			sub.EndDate = end.Format(monthDate)
+		}
+		subs = append(subs, sub)
+
+		// some ended plans are followed by another plan of the service,
+		// started a month or two before the old one ended
+		if ended && g.rnd.IntN(100) < 30 {
+			restart := end.AddDate(0, -g.rnd.IntN(2), 0)
+			if restart.Before(start) {
+				restart = start
+			}
+			subs = append(subs, model.Subscription{
+				ServiceName: svc.name,
+				Price: price(svc.tiers[min(tier+1, len(svc.tiers)-1)], reg.rate),
+				UserID: userID,
+				StartDate: restart.Format(monthDate),
+			})
+		}
+	}
+	return subs
+}
+
+// tier picks plan of service, cheaper plans are more common
+func (g *generator) tier(svc service) int {
+	weights := []int{6, 3, 1}
+	return pick(g.rnd, []int{0, 1, 2}[:len(svc.tiers)], func(i int) int { return weights[i] })
+}
+
+// end returns the last month of subscription started at start, ok is false
+// when it is still running
+func (g *generator) end(start time.Time) (time.Time, bool) {
+	if g.rnd.IntN(100) >= 35 {
+		return time.Time{}, false
+	}
+	end := start.AddDate(0, g.rnd.IntN(24), 0)
+	if end.After(g.until) {
+		return time.Time{}, false
+	}
+	return end, true
+}
+
+func price(rub int, rate float64) int {
+	return max(int(float64(rub)*rate+0.5), 1)
+}
+
+// pick returns a random item, items with more weight are picked more often
+func pick[T any](rnd *rand.Rand, items []T, weight func(T) int) T {
+	total := 0
+	for _, item := range items {
+		total += weight(item)
+	}
+	n := rnd.IntN(total)
+	for _, item := range items {
+		if n -= weight(item); n < 0 {
+			return item
+		}
+	}
+	return items[len(items)-1]
+}
@@ -0,0 +1,75 @@
+package seed_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/teamcutter/subscriptions-service-task/internal/repo"
+	"github.com/teamcutter/subscriptions-service-task/internal/seed"
+)
+
+var opts = seed.Options{Seed: 42, Users: 200, Until: time.Date(2025, time.June, 1, 0, 0, 0, 0, time.UTC), History: 36}
+
+func TestGenerateIsReproducible(t *testing.T) {
+	assert.Equal(t, seed.Generate(opts), seed.Generate(opts))
+
+	other := opts
+	other.Seed = 43
+	assert.NotEqual(t, seed.Generate(opts).Users[0].ID, seed.Generate(other).Users[0].ID)
+}
+
+func TestGenerateIsValid(t *testing.T) {
+	data := seed.Generate(opts)
+	require.Len(t, data.Users, opts.Users)
+
+	currencies := map[string]string{}
+	emails := map[string]bool{}
+	for _, user := range data.Users {
+		currencies[user.ID.String()] = user.Currency
+		assert.False(t, emails[user.Email], "duplicate email %s", user.Email)
+		emails[user.Email] = true
+		_, err := time.LoadLocation(user.Timezone)
+		assert.NoError(t, err)
+	}
+	assert.Len(t, distinct(currencies), 3)
+
+	var ended, trials, renewed int
+	services := map[string]bool{}
+	for _, sub := range data.Subscriptions {
+		key := sub.UserID.String() + sub.ServiceName
+		if services[key] {
+			renewed++
+		}
+		services[key] = true
+
+		require.NoError(t, repo.Validate(&sub), "%+v", sub)
+		assert.Contains(t, currencies, sub.UserID.String())
+		assert.Positive(t, sub.Price)
+
+		start, _ := time.Parse("01-2006", sub.StartDate)
+		assert.False(t, start.After(opts.Until), "%+v", sub)
+		if sub.EndDate != "" {
+			ended++
+			end, _ := time.Parse("01-2006", sub.EndDate)
+			assert.False(t, end.Before(start) || end.After(opts.Until), "%+v", sub)
+		}
+		if sub.TrialEndDate != "" {
+			trials++
+		}
+	}
+	assert.Positive(t, ended)
+	assert.Positive(t, trials)
+	assert.Positive(t, renewed)
+	assert.Less(t, ended, len(data.Subscriptions))
+}
+
+func distinct(m map[string]string) map[string]bool {
+	values := map[string]bool{}
+	for _, v := range m {
+		values[v] = true
+	}
+	return values
+}
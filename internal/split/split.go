@@ -0,0 +1,168 @@
+package split
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/google/uuid"
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+)
+
+var ErrInvalidSplit = errors.New("invalid split")
+
+// Validate checks split of subscription paid by payer among household members
+func Validate(price int, payer uuid.UUID, split *model.Split, members []uuid.UUID) error {
+	if !slices.Contains(members, payer) {
+		return fmt.Errorf("%w: payer is not a household member", ErrInvalidSplit)
+	}
+
+	switch split.Rule {
+	case model.SplitEqual:
+		if len(split.Parts) > 0 {
+			return fmt.Errorf("%w: equal split has no parts", ErrInvalidSplit)
+		}
+		return nil
+	case model.SplitPercentage, model.SplitFixed:
+	default:
+		return fmt.Errorf("%w: unknown rule %q", ErrInvalidSplit, split.Rule)
+	}
+
+	limit := price
+	if split.Rule == model.SplitPercentage {
+		limit = 100
+	}
+
+	sum := 0
+	seen := map[uuid.UUID]bool{}
+	for _, part := range split.Parts {
+		switch {
+		case part.UserID == payer:
+			return fmt.Errorf("%w: payer covers the rest and has no part", ErrInvalidSplit)
+		case !slices.Contains(members, part.UserID):
+			return fmt.Errorf("%w: %s is not a household member", ErrInvalidSplit, part.UserID)
+		case seen[part.UserID]:
+			return fmt.Errorf("%w: %s has several parts", ErrInvalidSplit, part.UserID)
+		case part.Value < 0:
+			return fmt.Errorf("%w: negative part", ErrInvalidSplit)
+		}
+		seen[part.UserID] = true
+		sum += part.Value
+	}
+	if sum > limit {
+		return fmt.Errorf("%w: parts exceed %d", ErrInvalidSplit, limit)
+	}
+	return nil
+}
+
+// Shares returns monthly amounts members pay for subscription, they add up
+// to price. Rounding leftovers and whatever is not given to others is paid
+// by payer. Unshared subscriptions are paid by payer alone
+func Shares(price int, payer uuid.UUID, split *model.Split, members []uuid.UUID) []model.Share {
+	amounts := map[uuid.UUID]int{}
+	given := 0
+
+	if split != nil {
+		switch split.Rule {
+		case model.SplitEqual:
+			for _, member := range members {
+				amounts[member] = price / len(members)
+				given += amounts[member]
+			}
+		case model.SplitPercentage:
+			for _, part := range split.Parts {
+				amounts[part.UserID] = price * part.Value / 100
+				given += amounts[part.UserID]
+			}
+		case model.SplitFixed:
+			for _, part := range split.Parts {
+				amounts[part.UserID] = part.Value
+				given += part.Value
+			}
+		}
+	}
+	amounts[payer] += price - given
+
+	shares := make([]model.Share, 0, len(amounts))
+	for userID, amount := range amounts {
+		shares = append(shares, model.Share{UserID: userID, Amount: amount})
+	}
+	sort.Slice(shares, func(i, j int) bool {
+		return shares[i].UserID.String() < shares[j].UserID.String()
+	})
+	return shares
+}
+
+// ShareOf returns total amount user pays for costs in their period
+func ShareOf(userID uuid.UUID, costs []model.Cost) int {
+	total := 0
+	for _, cost := range costs {
+		for _, share := range Shares(cost.Price, cost.Payer, cost.Split, cost.Members) {
+			if share.UserID == userID {
+				total += share.Amount * cost.Months
+			}
+		}
+	}
+	return total
+}
+
+// Settle sums what members paid and what their shares are, then finds
+// transfers evening balances out, largest debts are paid to largest
+// creditors first
+func Settle(costs []model.Cost) ([]model.MemberBalance, []model.Transfer) {
+	byUser := map[uuid.UUID]*model.MemberBalance{}
+	balance := func(userID uuid.UUID) *model.MemberBalance {
+		if b, ok := byUser[userID]; ok {
+			return b
+		}
+		byUser[userID] = &model.MemberBalance{UserID: userID}
+		return byUser[userID]
+	}
+
+	for _, cost := range costs {
+		balance(cost.Payer).Paid += cost.Price * cost.Months
+		for _, share := range Shares(cost.Price, cost.Payer, cost.Split, cost.Members) {
+			balance(share.UserID).Share += share.Amount * cost.Months
+		}
+	}
+
+	balances := make([]model.MemberBalance, 0, len(byUser))
+	for _, b := range byUser {
+		b.Balance = b.Paid - b.Share
+		balances = append(balances, *b)
+	}
+	sort.Slice(balances, func(i, j int) bool {
+		if balances[i].Balance != balances[j].Balance {
+			return balances[i].Balance > balances[j].Balance
+		}
+		return balances[i].UserID.String() < balances[j].UserID.String()
+	})
+
+	// creditors are at the start and debtors at the end
+	transfers := []model.Transfer{}
+	left := map[uuid.UUID]int{}
+	for _, b := range balances {
+		left[b.UserID] = b.Balance
+	}
+	i, j := 0, len(balances)-1
+	for i < j {
+		creditor, debtor := balances[i].UserID, balances[j].UserID
+		if left[creditor] <= 0 || left[debtor] >= 0 {
+			break
+		}
+
+		amount := min(left[creditor], -left[debtor])
+		transfers = append(transfers, model.Transfer{From: debtor, To: creditor, Amount: amount})
+		left[creditor] -= amount
+		left[debtor] += amount
+
+		if left[creditor] == 0 {
+			i++
+		}
+		if left[debtor] == 0 {
+			j--
+		}
+	}
+	return balances, transfers
+}
\ No newline at end of file
@@ -0,0 +1,85 @@
+package split_test
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+	"github.com/teamcutter/subscriptions-service-task/internal/split"
+)
+
+var (
+	alice = uuid.MustParse("00000000-0000-0000-0000-00000000000a")
+	bob = uuid.MustParse("00000000-0000-0000-0000-00000000000b")
+	carol = uuid.MustParse("00000000-0000-0000-0000-00000000000c")
+	members = []uuid.UUID{alice, bob, carol}
+)
+
+func amounts(shares []model.Share) map[uuid.UUID]int {
+	m := map[uuid.UUID]int{}
+	for _, share := range shares {
+		m[share.UserID] = share.Amount
+	}
+	return m
+}
+
+func TestShares(t *testing.T) {
+	// rounding leftover goes to payer
+	equal := split.Shares(1000, alice, &model.Split{Rule: model.SplitEqual}, members)
+	assert.Equal(t, map[uuid.UUID]int{alice: 334, bob: 333, carol: 333}, amounts(equal))
+
+	percentage := split.Shares(1000, alice, &model.Split{Rule: model.SplitPercentage,
+		Parts: []model.SplitPart{{UserID: bob, Value: 25}, {UserID: carol, Value: 25}}}, members)
+	assert.Equal(t, map[uuid.UUID]int{alice: 500, bob: 250, carol: 250}, amounts(percentage))
+
+	fixed := split.Shares(1000, alice, &model.Split{Rule: model.SplitFixed,
+		Parts: []model.SplitPart{{UserID: bob, Value: 100}}}, members)
+	assert.Equal(t, map[uuid.UUID]int{alice: 900, bob: 100}, amounts(fixed))
+
+	assert.Equal(t, map[uuid.UUID]int{alice: 1000}, amounts(split.Shares(1000, alice, nil, nil)))
+}
+
+func TestValidate(t *testing.T) {
+	valid := &model.Split{Rule: model.SplitPercentage, Parts: []model.SplitPart{{UserID: bob, Value: 50}}}
+	assert.NoError(t, split.Validate(1000, alice, valid, members))
+
+	for name, s := range map[string]*model.Split{
+		"unknown rule": {Rule: "random"},
+		"over 100 percent": {Rule: model.SplitPercentage, Parts: []model.SplitPart{{UserID: bob, Value: 60}, {UserID: carol, Value: 50}}},
+		"over price": {Rule: model.SplitFixed, Parts: []model.SplitPart{{UserID: bob, Value: 1001}}},
+		"payer part": {Rule: model.SplitFixed, Parts: []model.SplitPart{{UserID: alice, Value: 1}}},
+		"not a member": {Rule: model.SplitFixed, Parts: []model.SplitPart{{UserID: uuid.New(), Value: 1}}},
+		"equal with parts": {Rule: model.SplitEqual, Parts: []model.SplitPart{{UserID: bob, Value: 1}}},
+	} {
+		assert.ErrorIs(t, split.Validate(1000, alice, s, members), split.ErrInvalidSplit, name)
+	}
+
+	assert.ErrorIs(t, split.Validate(1000, uuid.New(), valid, members), split.ErrInvalidSplit)
+}
+
+func TestSettle(t *testing.T) {
+	costs := []model.Cost{
+		{Payer: alice, Price: 900, Months: 2, Split: &model.Split{Rule: model.SplitEqual}, Members: members},
+		{Payer: bob, Price: 300, Months: 1, Split: &model.Split{Rule: model.SplitEqual}, Members: members},
+	}
+
+	balances, transfers := split.Settle(costs)
+
+	byUser := map[uuid.UUID]model.MemberBalance{}
+	for _, b := range balances {
+		byUser[b.UserID] = b
+	}
+	// shares are 600 + 100 for everyone
+	assert.Equal(t, model.MemberBalance{UserID: alice, Paid: 1800, Share: 700, Balance: 1100}, byUser[alice])
+	assert.Equal(t, -400, byUser[bob].Balance)
+	assert.Equal(t, -700, byUser[carol].Balance)
+
+	assert.Equal(t, []model.Transfer{
+		{From: carol, To: alice, Amount: 700},
+		{From: bob, To: alice, Amount: 400},
+	}, transfers)
+
+	assert.Equal(t, 700, split.ShareOf(carol, costs))
+}
@@ -0,0 +1,126 @@
+package stream
+
+import (
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+)
+
+// subscriberBuffer is how many events may wait for a slow client,
+// a client falling further behind is disconnected and resumes by replay
+const subscriberBuffer = 64
+
+// Subscription receives events matching its filter
+type Subscription struct {
+	events chan model.Event
+	match func(model.Event) bool
+}
+
+// Events is closed when the subscriber fell behind or the hub lost events
+func (s *Subscription) Events() <-chan model.Event {
+	return s.events
+}
+
+// Hub passes events to subscribers and keeps the latest ones for
+// subscribers resuming after a disconnect
+type Hub struct {
+	mu sync.Mutex
+	// replay is a ring of the latest events, next is where the next one goes
+	replay []model.Event
+	next int
+	full bool
+	subscribers map[*Subscription]struct{}
+}
+
+func NewHub(replaySize int) *Hub {
+	return &Hub{
+		replay: make([]model.Event, replaySize),
+		subscribers: map[*Subscription]struct{}{},
+	}
+}
+
+// buffered returns replay buffer from the oldest event
+func (h *Hub) buffered() []model.Event {
+	if !h.full {
+		return h.replay[:h.next]
+	}
+	return append(append([]model.Event{}, h.replay[h.next:]...), h.replay[:h.next]...)
+}
+
+// Subscribe registers subscriber of events accepted by match. With lastEventID
+// set it also returns the buffered events after that event, resumed is false
+// when the event is no longer buffered and some events may have been missed
+func (h *Hub) Subscribe(lastEventID uuid.UUID, match func(model.Event) bool) (sub *Subscription, missed []model.Event, resumed bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	sub = &Subscription{events: make(chan model.Event, subscriberBuffer), match: match}
+	h.subscribers[sub] = struct{}{}
+
+	if lastEventID == uuid.Nil {
+		return sub, nil, true
+	}
+
+	buffered := h.buffered()
+	for i, event := range buffered {
+		if event.ID != lastEventID {
+			continue
+		}
+		for _, next := range buffered[i+1:] {
+			if match(next) {
+				missed = append(missed, next)
+			}
+		}
+		return sub, missed, true
+	}
+	return sub, nil, false
+}
+
+func (h *Hub) Unsubscribe(sub *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.subscribers[sub]; ok {
+		delete(h.subscribers, sub)
+		close(sub.events)
+	}
+}
+
+// Publish buffers event and passes it to matching subscribers
+func (h *Hub) Publish(event model.Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(h.replay) > 0 {
+		h.replay[h.next] = event
+		h.next = (h.next + 1) % len(h.replay)
+		h.full = h.full || h.next == 0
+	}
+
+	for sub := range h.subscribers {
+		if !sub.match(event) {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			delete(h.subscribers, sub)
+			close(sub.events)
+		}
+	}
+}
+
+// Reset drops buffered events and disconnects all subscribers, it is
+// used when events may have been lost
+func (h *Hub) Reset() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	clear(h.replay)
+	h.next, h.full = 0, false
+	for sub := range h.subscribers {
+		delete(h.subscribers, sub)
+		close(sub.events)
+	}
+}
@@ -0,0 +1,75 @@
+package stream_test
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+	"github.com/teamcutter/subscriptions-service-task/internal/stream"
+)
+
+func all(model.Event) bool { return true }
+
+func events(n int) []model.Event {
+	list := make([]model.Event, n)
+	for i := range list {
+		list[i] = model.Event{ID: uuid.New(), SubscriptionID: int64(i)}
+	}
+	return list
+}
+
+func TestHubReplaysBufferedEvents(t *testing.T) {
+	hub := stream.NewHub(3)
+	published := events(5)
+	for _, event := range published {
+		hub.Publish(event)
+	}
+
+	_, missed, resumed := hub.Subscribe(published[2].ID, all)
+	assert.True(t, resumed)
+	assert.Equal(t, published[3:], missed)
+
+	_, missed, resumed = hub.Subscribe(published[4].ID, all)
+	assert.True(t, resumed)
+	assert.Empty(t, missed)
+
+	// evicted from the buffer
+	_, _, resumed = hub.Subscribe(published[1].ID, all)
+	assert.False(t, resumed)
+
+	odd := func(e model.Event) bool { return e.SubscriptionID%2 == 1 }
+	_, missed, _ = hub.Subscribe(published[2].ID, odd)
+	assert.Equal(t, []model.Event{published[3]}, missed)
+}
+
+func TestHubDropsSlowSubscriber(t *testing.T) {
+	hub := stream.NewHub(0)
+	slow, _, _ := hub.Subscribe(uuid.Nil, all)
+
+	for _, event := range events(100) {
+		hub.Publish(event)
+	}
+
+	received := 0
+	for range slow.Events() {
+		received++
+	}
+	assert.Equal(t, 64, received)
+}
+
+func TestHubReset(t *testing.T) {
+	hub := stream.NewHub(10)
+	published := events(2)
+	hub.Publish(published[0])
+
+	sub, _, _ := hub.Subscribe(uuid.Nil, all)
+	hub.Reset()
+	hub.Publish(published[1])
+
+	_, open := <-sub.Events()
+	assert.False(t, open)
+	_, _, resumed := hub.Subscribe(published[0].ID, all)
+	assert.False(t, resumed)
+}
@@ -0,0 +1,59 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+	"github.com/teamcutter/subscriptions-service-task/internal/repo"
+)
+
+// Listen publishes events notified by Postgres on repo.EventsChannel to hub
+// until ctx is done. Every replica listens, so clients get changes made
+// through any of them. Notifications sent while the connection was down
+// are lost and events too long for NOTIFY are not sent, the hub is reset
+// then and clients resync
+func Listen(ctx context.Context, dsn string, hub *Hub, logger *slog.Logger) error {
+	listener := pq.NewListener(dsn, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			logger.Error("events listener error", "error", err)
+		}
+	})
+	defer listener.Close()
+
+	if err := listener.Listen(repo.EventsChannel); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case n := <-listener.Notify:
+			// nil is sent after reconnect
+			if n == nil {
+				logger.Warn("events listener reconnected, resetting streams")
+				hub.Reset()
+				continue
+			}
+			if n.Extra == repo.EventsReset {
+				logger.Warn("event too long to be notified, resetting streams")
+				hub.Reset()
+				continue
+			}
+
+			var event model.Event
+			if err := json.Unmarshal([]byte(n.Extra), &event); err != nil {
+				logger.Error("decode event notification error", "error", err)
+				continue
+			}
+			hub.Publish(event)
+		case <-time.After(90 * time.Second):
+			// detects broken connections nothing was notified on
+			go listener.Ping()
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+)
+
+const (
+	HeaderEvent = "X-Webhook-Event"
+	HeaderDelivery = "X-Webhook-Delivery"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+const (
+	claimBatch = 20
+	sendTimeout = 10 * time.Second
+	// lease hides claimed deliveries from other instances until they are
+	// sent. Deliveries of a batch are sent one by one, so the lease covers
+	// the whole batch timing out, with a minute left for the store
+	lease = claimBatch*sendTimeout + time.Minute
+	maxAttempts = 8
+	// retryBase is the delay after the first failed attempt, it doubles
+	// with every next one
+	retryBase = 30 * time.Second
+)
+
+var deliveriesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "webhook_deliveries_total",
+	Help: "Total number of webhook delivery attempts",
+}, []string{"event", "outcome"})
+
+// GenerateSecret returns a new random signing secret
+func GenerateSecret() (string, error) {
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(secret), nil
+}
+
+// Sign returns signature of body sent at timestamp (unix seconds), it is
+// HMAC-SHA256 of "<timestamp>.<body>" keyed with the webhook secret
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+type Store interface {
+	Claim(limit int, lease time.Duration) ([]model.WebhookDelivery, error)
+	MarkDelivered(id int64, responseStatus int) error
+	MarkFailed(id int64, responseStatus int, reason string, retryAfter time.Duration) error
+}
+
+// Dispatcher sends pending deliveries to webhooks
+type Dispatcher struct {
+	store Store
+	client *http.Client
+	logger *slog.Logger
+}
+
+func NewDispatcher(store Store, targets Targets, logger *slog.Logger) *Dispatcher {
+	return &Dispatcher{
+		store: store,
+		client: &http.Client{Timeout: sendTimeout, Transport: targets.transport()},
+		logger: logger,
+	}
+}
+
+// Run ticks every interval until ctx is done
+func (d *Dispatcher) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		d.Tick(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Tick sends all deliveries due now
+func (d *Dispatcher) Tick(ctx context.Context) {
+	for ctx.Err() == nil {
+		deliveries, err := d.store.Claim(claimBatch, lease)
+		if err != nil {
+			d.logger.Error("claim webhook deliveries error", "error", err)
+			return
+		}
+		for _, delivery := range deliveries {
+			d.deliver(ctx, delivery)
+		}
+		if len(deliveries) < claimBatch {
+			return
+		}
+	}
+}
+
+// retryAfter returns delay before the next attempt, zero after the last one
+func retryAfter(attempts int) time.Duration {
+	if attempts >= maxAttempts {
+		return 0
+	}
+	return retryBase << (attempts - 1)
+}
+
+// send posts payload and returns response status
+func (d *Dispatcher) send(ctx context.Context, delivery model.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, delivery.EventType)
+	req.Header.Set(HeaderDelivery, strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(delivery.Secret, timestamp, delivery.Payload))
+
+	res, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("webhook responded %s", res.Status)
+	}
+	return res.StatusCode, nil
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, delivery model.WebhookDelivery) {
+	status, err := d.send(ctx, delivery)
+	if err == nil {
+		deliveriesTotal.WithLabelValues(delivery.EventType, model.DeliveryDelivered).Inc()
+		if err := d.store.MarkDelivered(delivery.ID, status); err != nil {
+			d.logger.Error("mark webhook delivered error", "error", err)
+		}
+		return
+	}
+
+	retry := retryAfter(delivery.Attempts)
+	outcome := model.DeliveryPending
+	if retry == 0 {
+		outcome = model.DeliveryDead
+	}
+	deliveriesTotal.WithLabelValues(delivery.EventType, outcome).Inc()
+	d.logger.Warn("webhook delivery failed",
+		"delivery_id", delivery.ID,
+		"webhook_id", delivery.WebhookID,
+		"attempts", delivery.Attempts,
+		"retry_after", retry,
+		"error", err,
+	)
+
+	if err := d.store.MarkFailed(delivery.ID, status, err.Error(), retry); err != nil {
+		d.logger.Error("mark webhook failed error", "error", err)
+	}
+}
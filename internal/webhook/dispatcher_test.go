@@ -0,0 +1,151 @@
+package webhook_test
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/teamcutter/subscriptions-service-task/internal/model"
+	"github.com/teamcutter/subscriptions-service-task/internal/webhook"
+)
+
+type fakeStore struct {
+	pending []model.WebhookDelivery
+	delivered map[int64]int
+	retries map[int64]time.Duration
+}
+
+func (s *fakeStore) Claim(limit int, _ time.Duration) ([]model.WebhookDelivery, error) {
+	n := min(limit, len(s.pending))
+	claimed := s.pending[:n]
+	s.pending = s.pending[n:]
+	return claimed, nil
+}
+
+func (s *fakeStore) MarkDelivered(id int64, responseStatus int) error {
+	s.delivered[id] = responseStatus
+	return nil
+}
+
+func (s *fakeStore) MarkFailed(id int64, _ int, _ string, retryAfter time.Duration) error {
+	s.retries[id] = retryAfter
+	return nil
+}
+
+func TestTickSignsDeliveries(t *testing.T) {
+	payload := []byte(`{"type":"subscription.created"}`)
+
+	var headers http.Header
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = r.Header
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	store := &fakeStore{
+		delivered: map[int64]int{},
+		retries: map[int64]time.Duration{},
+		pending: []model.WebhookDelivery{{
+			ID: 7,
+			EventType: model.EventSubscriptionCreated,
+			Payload: payload,
+			Attempts: 1,
+			URL: server.URL,
+			Secret: "whsec_test",
+		}},
+	}
+	webhook.NewDispatcher(store, webhook.Targets{AllowPrivate: true}, slog.Default()).Tick(context.Background())
+
+	assert.Equal(t, map[int64]int{7: http.StatusNoContent}, store.delivered)
+	assert.Equal(t, payload, body)
+	assert.Equal(t, model.EventSubscriptionCreated, headers.Get(webhook.HeaderEvent))
+	assert.Equal(t, "7", headers.Get(webhook.HeaderDelivery))
+
+	timestamp, err := strconv.ParseInt(headers.Get(webhook.HeaderTimestamp), 10, 64)
+	if assert.NoError(t, err) {
+		assert.Equal(t, webhook.Sign("whsec_test", timestamp, payload), headers.Get(webhook.HeaderSignature))
+	}
+}
+
+func TestTickRetriesFailedDeliveries(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	store := &fakeStore{
+		delivered: map[int64]int{},
+		retries: map[int64]time.Duration{},
+		pending: []model.WebhookDelivery{
+			{ID: 1, Attempts: 1, URL: server.URL},
+			{ID: 2, Attempts: 3, URL: server.URL},
+			{ID: 3, Attempts: 8, URL: server.URL},
+			// nothing listens there
+			{ID: 4, Attempts: 2, URL: "http://127.0.0.1:1"},
+		},
+	}
+	webhook.NewDispatcher(store, webhook.Targets{AllowPrivate: true}, slog.Default()).Tick(context.Background())
+
+	assert.Empty(t, store.delivered)
+	assert.Equal(t, map[int64]time.Duration{
+		1: 30 * time.Second,
+		2: 2 * time.Minute,
+		// the last attempt goes to dead letters
+		3: 0,
+		4: time.Minute,
+	}, store.retries)
+}
+
+func TestSign(t *testing.T) {
+	// echo -n '1700000000.{}' | openssl dgst -sha256 -hmac secret
+	assert.Equal(t,
+		"sha256=b8569b78799ff9e3cbff0fc2d63a33a2b57f3282abd07c37ae5e8e7d79a5f163",
+		webhook.Sign("secret", 1700000000, []byte("{}")))
+}
+
+func TestTickRefusesPrivateTargets(t *testing.T) {
+	called := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer server.Close()
+
+	store := &fakeStore{
+		delivered: map[int64]int{},
+		retries: map[int64]time.Duration{},
+		pending: []model.WebhookDelivery{{ID: 1, Attempts: 1, URL: server.URL}},
+	}
+	webhook.NewDispatcher(store, webhook.Targets{}, slog.Default()).Tick(context.Background())
+
+	assert.False(t, called)
+	assert.Empty(t, store.delivered)
+	assert.Equal(t, map[int64]time.Duration{1: 30 * time.Second}, store.retries)
+}
+
+func TestTargetsCheck(t *testing.T) {
+	for target, allowed := range map[string]bool{
+		"https://203.0.113.10/hook": true,
+		"https://[2001:db8::1]/hook": true,
+		"http://127.0.0.1:8080/hook": false,
+		"http://localhost/hook": false,
+		"http://10.0.0.5/hook": false,
+		"http://192.168.1.1/hook": false,
+		"http://169.254.169.254/latest/meta-data": false,
+		"http://[::1]/hook": false,
+		"http://[::ffff:127.0.0.1]/hook": false,
+		"http://0.0.0.0/hook": false,
+	} {
+		err := webhook.Targets{}.Check(context.Background(), target)
+		assert.Equal(t, allowed, err == nil, target)
+	}
+	assert.NoError(t, webhook.Targets{AllowPrivate: true}.Check(context.Background(), "http://127.0.0.1:8080/hook"))
+}
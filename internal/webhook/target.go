@@ -0,0 +1,84 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+var ErrTargetNotAllowed = errors.New("webhook url resolves to a loopback, private or link-local address")
+
+// Targets decides which addresses webhooks may be sent to. Webhook URLs come
+// from tenants, so by default they can not reach the network of the service:
+// loopback, private, link-local (cloud metadata), unspecified and multicast
+// addresses are refused
+type Targets struct {
+	// AllowPrivate turns the check off, for local development
+	AllowPrivate bool
+}
+
+// Allowed reports whether deliveries may be sent to addr
+func (t Targets) Allowed(addr netip.Addr) bool {
+	if t.AllowPrivate {
+		return true
+	}
+	addr = addr.Unmap()
+	return addr.IsValid() &&
+		!addr.IsLoopback() &&
+		!addr.IsPrivate() &&
+		!addr.IsLinkLocalUnicast() &&
+		!addr.IsMulticast() &&
+		!addr.IsUnspecified()
+}
+
+// Check resolves host of rawURL and fails when any of its addresses is not
+// allowed
+func (t Targets) Check(ctx context.Context, rawURL string) error {
+	if t.AllowPrivate {
+		return nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", u.Hostname())
+	if err != nil {
+		return fmt.Errorf("resolve webhook host: %w", err)
+	}
+	for _, addr := range addrs {
+		if !t.Allowed(addr) {
+			return ErrTargetNotAllowed
+		}
+	}
+	return nil
+}
+
+// control checks the address actually dialed, after DNS resolution, so a
+// host resolving to another address than at Check is refused too
+func (t Targets) control(_, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if !t.Allowed(addrPort.Addr()) {
+		return ErrTargetNotAllowed
+	}
+	return nil
+}
+
+// transport dials only allowed addresses. Proxies from the environment are
+// not used, they would be dialed instead of the webhook
+func (t Targets) transport() *http.Transport {
+	dialer := &net.Dialer{Timeout: 5 * time.Second, Control: t.control}
+	return &http.Transport{
+		DialContext: dialer.DialContext,
+		TLSHandshakeTimeout: 5 * time.Second,
+		MaxIdleConnsPerHost: 2,
+	}
+}
@@ -0,0 +1,66 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// CreateAPIKey creates API key, the key itself is only in the response
+func (c *Client) CreateAPIKey(ctx context.Context, key CreateAPIKeyRequest) (*NewAPIKey, error) {
+	r, err := newRequest(http.MethodPost, "/v1/admin/api-keys").json(key)
+	if err != nil {
+		return nil, err
+	}
+
+	var created NewAPIKey
+	if _, err := c.do(ctx, r, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+func (c *Client) ListAPIKeys(ctx context.Context) ([]APIKey, error) {
+	var keys []APIKey
+	_, err := c.do(ctx, newRequest(http.MethodGet, "/v1/admin/api-keys"), &keys)
+	return keys, err
+}
+
+// RotateAPIKey replaces the key keeping its name and scopes
+func (c *Client) RotateAPIKey(ctx context.Context, id int64) (*NewAPIKey, error) {
+	var rotated NewAPIKey
+	if _, err := c.do(ctx, newRequest(http.MethodPost, fmt.Sprintf("/v1/admin/api-keys/%d/rotate", id)), &rotated); err != nil {
+		return nil, err
+	}
+	return &rotated, nil
+}
+
+func (c *Client) RevokeAPIKey(ctx context.Context, id int64) error {
+	_, err := c.do(ctx, newRequest(http.MethodDelete, fmt.Sprintf("/v1/admin/api-keys/%d", id)), nil)
+	return err
+}
+
+func (c *Client) CreateTenant(ctx context.Context, id, name string) (*Tenant, error) {
+	r, err := newRequest(http.MethodPost, "/v1/admin/tenants").json(Tenant{ID: id, Name: name})
+	if err != nil {
+		return nil, err
+	}
+
+	var tenant Tenant
+	if _, err := c.do(ctx, r, &tenant); err != nil {
+		return nil, err
+	}
+	return &tenant, nil
+}
+
+func (c *Client) ListTenants(ctx context.Context) ([]Tenant, error) {
+	var tenants []Tenant
+	_, err := c.do(ctx, newRequest(http.MethodGet, "/v1/admin/tenants"), &tenants)
+	return tenants, err
+}
+
+func (c *Client) DeleteTenant(ctx context.Context, id string) error {
+	_, err := c.do(ctx, newRequest(http.MethodDelete, "/v1/admin/tenants/"+url.PathEscape(id)), nil)
+	return err
+}
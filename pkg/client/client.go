@@ -0,0 +1,276 @@
+// Package client is a typed Go client of the subscriptions service REST API.
+//
+// Requests are retried with exponential backoff on 429 and, when repeating
+// them is safe, on 5xx responses and network errors. Error responses are
+// returned as *APIError, use errors.Is with ErrNotFound and the other
+// errors of this package to check them.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultMaxRetries = 3
+	defaultMinBackoff = 100 * time.Millisecond
+	defaultMaxBackoff = 5 * time.Second
+)
+
+// Client calls the API, it is safe for concurrent use
+type Client struct {
+	baseURL string
+	httpClient *http.Client
+	token string
+	apiKey string
+	tenantID string
+	maxRetries int
+	minBackoff time.Duration
+	maxBackoff time.Duration
+}
+
+type Option func(*Client)
+
+// WithHTTPClient sets the HTTP client, http.DefaultClient is used otherwise
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithToken authenticates requests with JWT sent as bearer token
+func WithToken(token string) Option {
+	return func(c *Client) {
+		c.token = token
+	}
+}
+
+// WithAPIKey authenticates requests with API key
+func WithAPIKey(key string) Option {
+	return func(c *Client) {
+		c.apiKey = key
+	}
+}
+
+// WithTenant sends requests to tenant, only platform callers not bound to
+// a tenant may choose it
+func WithTenant(tenantID string) Option {
+	return func(c *Client) {
+		c.tenantID = tenantID
+	}
+}
+
+// WithRetries sets how many times failed requests are repeated and the
+// bounds of backoff between attempts, 0 retries disables them
+func WithRetries(maxRetries int, minBackoff, maxBackoff time.Duration) Option {
+	return func(c *Client) {
+		c.maxRetries = maxRetries
+		c.minBackoff = minBackoff
+		c.maxBackoff = maxBackoff
+	}
+}
+
+// New returns client of the API at baseURL, e.g. http://localhost:8080
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		httpClient: http.DefaultClient,
+		maxRetries: defaultMaxRetries,
+		minBackoff: defaultMinBackoff,
+		maxBackoff: defaultMaxBackoff,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// request is a call of the API, body is kept in memory so that it can be
+// sent again on retry
+type request struct {
+	method string
+	path string
+	query url.Values
+	header http.Header
+	body []byte
+	contentType string
+	// readOnly POST requests are repeated like GET
+	readOnly bool
+}
+
+func newRequest(method, path string) *request {
+	return &request{method: method, path: path, query: url.Values{}, header: http.Header{}}
+}
+
+func (r *request) json(body any) (*request, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	r.body = data
+	r.contentType = "application/json"
+	return r, nil
+}
+
+// idempotent requests may be repeated after server errors, POST is
+// repeated only with Idempotency-Key or when it only reads
+func (r *request) idempotent() bool {
+	if r.readOnly {
+		return true
+	}
+	switch r.method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return r.header.Get("Idempotency-Key") != ""
+}
+
+func (c *Client) newHTTPRequest(ctx context.Context, r *request) (*http.Request, error) {
+	target := c.baseURL + r.path
+	if len(r.query) > 0 {
+		target += "?" + r.query.Encode()
+	}
+
+	var body io.Reader
+	if r.body != nil {
+		body = bytes.NewReader(r.body)
+	}
+	req, err := http.NewRequestWithContext(ctx, r.method, target, body)
+	if err != nil {
+		return nil, err
+	}
+
+	for key, values := range r.header {
+		req.Header[key] = values
+	}
+	if r.contentType != "" {
+		req.Header.Set("Content-Type", r.contentType)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	if c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
+	}
+	if c.tenantID != "" {
+		req.Header.Set("X-Tenant-ID", c.tenantID)
+	}
+	return req, nil
+}
+
+// send makes the request retrying failed attempts, responses with status
+// below 400 are returned with open body, others as *APIError
+func (c *Client) send(ctx context.Context, r *request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		req, err := c.newHTTPRequest(ctx, r)
+		if err != nil {
+			return nil, err
+		}
+
+		res, err := c.httpClient.Do(req)
+		if err != nil {
+			if ctx.Err() != nil || !r.idempotent() || attempt >= c.maxRetries {
+				return nil, err
+			}
+			if err := c.wait(ctx, attempt, 0); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if res.StatusCode < http.StatusBadRequest {
+			return res, nil
+		}
+
+		apiErr := readError(res)
+		// rejected by rate limit before the request was handled
+		retry := res.StatusCode == http.StatusTooManyRequests ||
+			res.StatusCode >= http.StatusInternalServerError && r.idempotent()
+		if !retry || attempt >= c.maxRetries {
+			return nil, apiErr
+		}
+		if err := c.wait(ctx, attempt, apiErr.RetryAfter); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// wait sleeps before next attempt, Retry-After of the server wins over
+// backoff with jitter
+func (c *Client) wait(ctx context.Context, attempt int, retryAfter time.Duration) error {
+	delay := retryAfter
+	if delay <= 0 {
+		backoff := min(c.minBackoff<<attempt, c.maxBackoff)
+		delay = backoff/2 + rand.N(backoff/2+1)
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// do makes the request and decodes JSON response into out unless it is nil
+func (c *Client) do(ctx context.Context, r *request, out any) (*http.Response, error) {
+	res, err := c.send(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if out == nil || res.StatusCode == http.StatusNoContent {
+		_, _ = io.Copy(io.Discard, res.Body)
+		return res, nil
+	}
+	if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+		return res, fmt.Errorf("decode %s %s response: %w", r.method, r.path, err)
+	}
+	return res, nil
+}
+
+// versionOf reads version from ETag header, 0 when there is none
+func versionOf(res *http.Response) int {
+	etag := strings.Trim(strings.TrimPrefix(res.Header.Get("ETag"), "W/"), `"`)
+	version, _ := strconv.Atoi(etag)
+	return version
+}
+
+// idOf reads id from the last segment of Location header
+func idOf(res *http.Response) (int64, error) {
+	location := res.Header.Get("Location")
+	id, err := strconv.ParseInt(location[strings.LastIndex(location, "/")+1:], 10, 64)
+	if err != nil {
+		return 0, errors.New("invalid Location header " + strconv.Quote(location))
+	}
+	return id, nil
+}
+
+// ifMatch sets If-Match to version when it is known
+func (r *request) ifMatch(version int) *request {
+	if version > 0 {
+		r.header.Set("If-Match", fmt.Sprintf(`"%d"`, version))
+	}
+	return r
+}
+
+// set adds query param unless value is empty
+func (r *request) set(key, value string) *request {
+	if value != "" {
+		r.query.Set(key, value)
+	}
+	return r
+}
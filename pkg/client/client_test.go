@@ -0,0 +1,567 @@
+package client_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/teamcutter/subscriptions-service-task/pkg/client"
+)
+
+// spec is the part of docs/swagger.json the tests check the client against
+type spec struct {
+	Paths map[string]map[string]operation `json:"paths"`
+	Definitions map[string]schema `json:"definitions"`
+}
+
+type operation struct {
+	Parameters []struct {
+		Name string `json:"name"`
+		In string `json:"in"`
+		Schema schema `json:"schema"`
+	} `json:"parameters"`
+	Responses map[string]struct {
+		Schema *schema `json:"schema"`
+	} `json:"responses"`
+}
+
+type schema struct {
+	Ref string `json:"$ref"`
+	Type string `json:"type"`
+	Properties map[string]json.RawMessage `json:"properties"`
+}
+
+func loadSpec(t *testing.T) spec {
+	data, err := os.ReadFile("../../docs/swagger.json")
+	require.NoError(t, err)
+
+	var s spec
+	require.NoError(t, json.Unmarshal(data, &s))
+	return s
+}
+
+var pathParam = regexp.MustCompile(`\{[^/]+\}`)
+
+// match returns the operation of request as "METHOD /path/{param}",
+// static paths win over templates like in the router
+func (s spec) match(r *http.Request) (string, operation, bool) {
+	if op, ok := s.Paths[r.URL.Path][strings.ToLower(r.Method)]; ok {
+		return r.Method + " " + r.URL.Path, op, true
+	}
+	for path, ops := range s.Paths {
+		pattern := "^" + pathParam.ReplaceAllString(path, "[^/]+") + "$"
+		if !regexp.MustCompile(pattern).MatchString(r.URL.Path) {
+			continue
+		}
+		if op, ok := ops[strings.ToLower(r.Method)]; ok {
+			return r.Method + " " + path, op, true
+		}
+	}
+	return "", operation{}, false
+}
+
+// specServer answers every operation of the spec with an empty value of
+// its success response and records the operations called. Query params
+// and body fields that the spec does not declare fail the test
+func specServer(t *testing.T, s spec) (*httptest.Server, func() []string) {
+	var mu sync.Mutex
+	var called []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key, op, ok := s.match(r)
+		if !assert.True(t, ok, "%s %s is not in the spec", r.Method, r.URL.Path) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		mu.Lock()
+		called = append(called, key)
+		mu.Unlock()
+
+		declared := map[string]schema{}
+		for _, param := range op.Parameters {
+			declared[param.In+":"+param.Name] = param.Schema
+		}
+		for name := range r.URL.Query() {
+			assert.Contains(t, declared, "query:"+name, "%s sends undeclared query param", key)
+		}
+		if body, ok := declared["body:"+paramName(op)]; ok && body.Ref != "" {
+			var fields map[string]json.RawMessage
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&fields))
+			properties := s.Definitions[strings.TrimPrefix(body.Ref, "#/definitions/")].Properties
+			for field := range fields {
+				assert.Contains(t, properties, field, "%s sends undeclared body field", key)
+			}
+		}
+
+		w.Header().Set("Location", "/v1/subscriptions/1")
+		w.Header().Set("ETag", `"1"`)
+		for _, code := range []string{"200", "201", "202", "204"} {
+			res, ok := op.Responses[code]
+			if !ok {
+				continue
+			}
+			status, _ := strconv.Atoi(code)
+			if res.Schema == nil {
+				w.WriteHeader(status)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			if res.Schema.Type == "array" {
+				io.WriteString(w, "[]")
+			} else {
+				io.WriteString(w, "{}")
+			}
+			return
+		}
+		t.Errorf("%s has no success response", key)
+	}))
+	t.Cleanup(server.Close)
+
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), called...)
+	}
+}
+
+// paramName returns the name of the body param of op
+func paramName(op operation) string {
+	for _, param := range op.Parameters {
+		if param.In == "body" {
+			return param.Name
+		}
+	}
+	return ""
+}
+
+func TestEveryOperationIsCovered(t *testing.T) {
+	s := loadSpec(t)
+	server, called := specServer(t, s)
+	c := client.New(server.URL)
+	ctx := context.Background()
+	id := uuid.New()
+
+	closeBody := func(body io.ReadCloser, err error) error {
+		if err == nil {
+			body.Close()
+		}
+		return err
+	}
+	calls := map[string]func() error{
+		"GET /v1/subscriptions": func() error {
+			_, err := c.ListSubscriptions(ctx, client.SubscriptionFilter{UserID: id, Service: "Netflix"})
+			return err
+		},
+		"POST /v1/subscriptions": func() error {
+			return c.CreateSubscription(ctx, &client.Subscription{ServiceName: "Netflix", Price: 500, StartDate: "07-2025"})
+		},
+		"GET /v1/subscriptions/{id}": func() error {
+			_, err := c.GetSubscription(ctx, 1)
+			return err
+		},
+		"PUT /v1/subscriptions/{id}": func() error {
+			return c.UpdateSubscription(ctx, &client.Subscription{ID: 1, Version: 1, StartDate: "07-2025", EndDate: "12-2025"})
+		},
+		"DELETE /v1/subscriptions/{id}": func() error {
+			return c.DeleteSubscription(ctx, 1, 1)
+		},
+		"GET /v1/subscriptions/total": func() error {
+			_, err := c.TotalCost(ctx, client.TotalQuery{UserID: id, Service: "Netflix", Start: "01-2025", End: "12-2025"})
+			return err
+		},
+		"POST /v1/subscriptions/batch": func() error {
+			_, err := c.Batch(ctx, client.BatchRequest{Atomic: true, Operations: []client.BatchOperation{{Op: client.BatchOpDelete, ID: 1}}})
+			return err
+		},
+		"GET /v1/subscriptions/export": func() error {
+			return closeBody(c.ExportSubscriptions(ctx, "csv", client.SubscriptionFilter{UserID: id, Service: "Netflix"}))
+		},
+		"POST /v1/subscriptions/import": func() error {
+			_, err := c.ImportSubscriptions(ctx, strings.NewReader("service_name,price\n"),
+				client.ImportOptions{Format: "csv", Columns: "price=Cost", DryRun: true, Async: true})
+			return err
+		},
+		"GET /v1/subscriptions/import/{id}": func() error {
+			_, err := c.GetImportJob(ctx, id)
+			return err
+		},
+		"GET /v1/subscriptions/stream": func() error {
+			stream, err := c.StreamSubscriptions(ctx, id, "")
+			if err == nil {
+				stream.Close()
+			}
+			return err
+		},
+		"GET /v1/subscriptions/{id}/split": func() error {
+			_, err := c.GetSplit(ctx, 1)
+			return err
+		},
+		"PUT /v1/subscriptions/{id}/split": func() error {
+			_, err := c.SetSplit(ctx, 1, client.Split{HouseholdID: 1, Rule: client.SplitEqual})
+			return err
+		},
+		"DELETE /v1/subscriptions/{id}/split": func() error {
+			return c.DeleteSplit(ctx, 1)
+		},
+		"GET /v2/subscriptions": func() error {
+			_, err := c.ListSubscriptionsV2(ctx, client.ListOptions{SubscriptionFilter: client.SubscriptionFilter{UserID: id, Service: "Netflix"}, Limit: 10, Cursor: "5"})
+			return err
+		},
+		"POST /v2/subscriptions": func() error {
+			return c.CreateSubscriptionV2(ctx, &client.SubscriptionV2{ServiceName: "Netflix", StartDate: "2025-07-01"})
+		},
+		"GET /v2/subscriptions/{id}": func() error {
+			_, err := c.GetSubscriptionV2(ctx, 1)
+			return err
+		},
+		"PUT /v2/subscriptions/{id}": func() error {
+			return c.UpdateSubscriptionV2(ctx, &client.SubscriptionV2{ID: 1, Version: 1, StartDate: "2025-07-01"})
+		},
+		"DELETE /v2/subscriptions/{id}": func() error {
+			return c.DeleteSubscriptionV2(ctx, 1, 0)
+		},
+		"GET /v2/subscriptions/total": func() error {
+			_, err := c.TotalCostV2(ctx, client.TotalQuery{Start: "2025-01-01", End: "2025-12-31"})
+			return err
+		},
+		"POST /v1/households": func() error {
+			_, err := c.CreateHousehold(ctx, "Home", id)
+			return err
+		},
+		"GET /v1/households": func() error {
+			_, err := c.ListHouseholds(ctx, id)
+			return err
+		},
+		"GET /v1/households/{id}": func() error {
+			_, err := c.GetHousehold(ctx, 1)
+			return err
+		},
+		"DELETE /v1/households/{id}": func() error {
+			return c.DeleteHousehold(ctx, 1)
+		},
+		"POST /v1/households/{id}/members": func() error {
+			return c.AddHouseholdMember(ctx, 1, id)
+		},
+		"DELETE /v1/households/{id}/members/{user_id}": func() error {
+			return c.RemoveHouseholdMember(ctx, 1, id)
+		},
+		"GET /v1/households/{id}/settle": func() error {
+			_, err := c.Settle(ctx, 1, "01-2025", "12-2025")
+			return err
+		},
+		"GET /v1/users/{id}": func() error {
+			_, err := c.GetUser(ctx, client.Me)
+			return err
+		},
+		"PUT /v1/users/{id}": func() error {
+			_, err := c.SaveUser(ctx, id.String(), client.User{Name: "Ann", Currency: "EUR"})
+			return err
+		},
+		"DELETE /v1/users/{id}": func() error {
+			_, err := c.EraseUser(ctx, client.Me)
+			return err
+		},
+		"GET /v1/users/{id}/export": func() error {
+			return closeBody(c.ExportUser(ctx, client.Me))
+		},
+		"POST /v1/graphql": func() error {
+			return c.GraphQL(ctx, `{ me { id } }`, map[string]any{"first": 1}, nil)
+		},
+		"POST /v1/webhooks": func() error {
+			_, err := c.CreateWebhook(ctx, "https://example.com/hook", []string{client.EventSubscriptionCreated})
+			return err
+		},
+		"GET /v1/webhooks": func() error {
+			_, err := c.ListWebhooks(ctx)
+			return err
+		},
+		"DELETE /v1/webhooks/{id}": func() error {
+			return c.DeleteWebhook(ctx, 1)
+		},
+		"GET /v1/webhooks/{id}/deliveries": func() error {
+			_, err := c.ListDeliveries(ctx, 1)
+			return err
+		},
+		"POST /v1/webhooks/{id}/deliveries/{delivery_id}/redeliver": func() error {
+			_, err := c.Redeliver(ctx, 1, 2)
+			return err
+		},
+		"POST /v1/admin/api-keys": func() error {
+			_, err := c.CreateAPIKey(ctx, client.CreateAPIKeyRequest{Name: "ci", Scopes: []string{client.ScopeRead}})
+			return err
+		},
+		"GET /v1/admin/api-keys": func() error {
+			_, err := c.ListAPIKeys(ctx)
+			return err
+		},
+		"POST /v1/admin/api-keys/{id}/rotate": func() error {
+			_, err := c.RotateAPIKey(ctx, 1)
+			return err
+		},
+		"DELETE /v1/admin/api-keys/{id}": func() error {
+			return c.RevokeAPIKey(ctx, 1)
+		},
+		"POST /v1/admin/tenants": func() error {
+			_, err := c.CreateTenant(ctx, "acme", "Acme")
+			return err
+		},
+		"GET /v1/admin/tenants": func() error {
+			_, err := c.ListTenants(ctx)
+			return err
+		},
+		"DELETE /v1/admin/tenants/{id}": func() error {
+			return c.DeleteTenant(ctx, "acme")
+		},
+	}
+
+	for path, ops := range s.Paths {
+		for method := range ops {
+			key := strings.ToUpper(method) + " " + path
+			call, ok := calls[key]
+			if !assert.True(t, ok, "%s has no client method", key) {
+				continue
+			}
+
+			before := len(called())
+			assert.NoError(t, call(), key)
+			assert.Equal(t, []string{key}, called()[before:], "client method of %s", key)
+		}
+	}
+	assert.Len(t, called(), len(calls), "client calls operations missing from the spec")
+}
+
+func TestTypesMatchSpec(t *testing.T) {
+	s := loadSpec(t)
+
+	types := map[string]any{
+		"model.APIKey": client.APIKey{},
+		"model.NewAPIKey": client.NewAPIKey{},
+		"handler.createAPIKeyRequest": client.CreateAPIKeyRequest{},
+		"model.BatchOperation": client.BatchOperation{},
+		"model.BatchRequest": client.BatchRequest{},
+		"model.BatchResult": client.BatchResult{},
+		"model.ErasureReceipt": client.ErasureReceipt{},
+		"model.Household": client.Household{},
+		"model.ImportJob": client.ImportJob{},
+		"model.ImportReport": client.ImportReport{},
+		"model.ImportRowError": client.ImportRowError{},
+		"model.MemberBalance": client.MemberBalance{},
+		"model.Money": client.Money{},
+		"model.NotificationPreferences": client.NotificationPreferences{},
+		"model.Settlement": client.Settlement{},
+		"model.Share": client.Share{},
+		"model.Split": client.Split{},
+		"model.SplitPart": client.SplitPart{},
+		"model.Subscription": client.Subscription{},
+		"model.SubscriptionPageV2": client.SubscriptionPage{},
+		"model.SubscriptionV2": client.SubscriptionV2{},
+		"model.Tenant": client.Tenant{},
+		"model.Total": client.Total{},
+		"model.TotalV2": client.TotalV2{},
+		"model.Transfer": client.Transfer{},
+		"model.User": client.User{},
+		"model.Webhook": client.Webhook{},
+		"model.WebhookDelivery": client.WebhookDelivery{},
+	}
+
+	for name, def := range s.Definitions {
+		typ, ok := types[name]
+		if !ok {
+			// request bodies of handlers are checked by specServer
+			assert.False(t, strings.HasPrefix(name, "model."), "%s has no client type", name)
+			continue
+		}
+
+		var properties []string
+		for property := range def.Properties {
+			properties = append(properties, property)
+		}
+		assert.ElementsMatch(t, properties, jsonFields(reflect.TypeOf(typ)), name)
+	}
+}
+
+// jsonFields returns JSON names of fields of struct type, fields of
+// embedded structs are inlined like encoding/json does
+func jsonFields(typ reflect.Type) []string {
+	var fields []string
+	for i := range typ.NumField() {
+		field := typ.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if field.Anonymous && name == "" {
+			fields = append(fields, jsonFields(field.Type)...)
+			continue
+		}
+		if name != "-" {
+			fields = append(fields, name)
+		}
+	}
+	return fields
+}
+
+func TestCreateReadsLocationAndETag(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NotEmpty(t, r.Header.Get("Idempotency-Key"))
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		w.Header().Set("Location", "/v1/subscriptions/42")
+		w.Header().Set("ETag", `"3"`)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	sub := &client.Subscription{ServiceName: "Netflix", StartDate: "07-2025"}
+	require.NoError(t, client.New(server.URL, client.WithToken("token")).CreateSubscription(context.Background(), sub))
+	assert.Equal(t, int64(42), sub.ID)
+	assert.Equal(t, 3, sub.Version)
+}
+
+func TestRetries(t *testing.T) {
+	var mu sync.Mutex
+	attempts := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		attempts[r.Method]++
+		n := attempts[r.Method]
+		mu.Unlock()
+
+		switch {
+		case r.Method == http.MethodGet && n < 3:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case r.Method == http.MethodGet:
+			io.WriteString(w, `{"total":100,"currency":"RUB"}`)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+			io.WriteString(w, `"boom"`)
+		}
+	}))
+	defer server.Close()
+
+	c := client.New(server.URL, client.WithRetries(3, time.Millisecond, 10*time.Millisecond))
+
+	total, err := c.TotalCost(context.Background(), client.TotalQuery{})
+	require.NoError(t, err)
+	assert.Equal(t, 100, total.Total)
+	assert.Equal(t, 3, attempts[http.MethodGet])
+
+	// batches are not repeated after server errors
+	_, err = c.Batch(context.Background(), client.BatchRequest{})
+	assert.ErrorIs(t, err, client.ErrServer)
+	assert.Equal(t, 1, attempts[http.MethodPost])
+}
+
+func TestRateLimitedRequestsAreRetried(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			io.WriteString(w, `"rate limit exceeded"`)
+			return
+		}
+		io.WriteString(w, `[]`)
+	}))
+	defer server.Close()
+
+	c := client.New(server.URL, client.WithRetries(1, time.Millisecond, time.Millisecond))
+	_, err := c.Batch(context.Background(), client.BatchRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, 2, attempts)
+}
+
+func TestErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/subscriptions/1":
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, `"subscription not found"`)
+		case "/v1/graphql":
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, `{"errors":[{"message":"query depth 8 exceeds 7"}]}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, `{"message":"Not Found"}`)
+		}
+	}))
+	defer server.Close()
+
+	c := client.New(server.URL)
+	ctx := context.Background()
+
+	_, err := c.GetSubscription(ctx, 1)
+	assert.ErrorIs(t, err, client.ErrNotFound)
+	var apiErr *client.APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, "subscription not found", apiErr.Message)
+
+	err = c.GraphQL(ctx, `{ me { id } }`, nil, nil)
+	assert.ErrorIs(t, err, client.ErrBadRequest)
+	assert.ErrorContains(t, err, "query depth 8")
+
+	err = c.DeleteWebhook(ctx, 1)
+	assert.ErrorIs(t, err, client.ErrNotFound)
+	assert.ErrorContains(t, err, "Not Found")
+}
+
+func TestSubscriptionsV2Iterator(t *testing.T) {
+	pages := map[string]string{
+		"": `{"items":[{"id":1},{"id":2}],"next":"2"}`,
+		"2": `{"items":[{"id":3}]}`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "2", r.URL.Query().Get("limit"))
+		io.WriteString(w, pages[r.URL.Query().Get("cursor")])
+	}))
+	defer server.Close()
+
+	var ids []int64
+	for sub, err := range client.New(server.URL).SubscriptionsV2(context.Background(), client.ListOptions{Limit: 2}) {
+		require.NoError(t, err)
+		ids = append(ids, sub.ID)
+	}
+	assert.Equal(t, []int64{1, 2, 3}, ids)
+}
+
+func TestEventStream(t *testing.T) {
+	id := uuid.New()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "41", r.Header.Get("Last-Event-ID"))
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, "retry: 3000\n\n: heartbeat\n\n")
+		io.WriteString(w, "id: "+id.String()+"\nevent: subscription.created\ndata: {\"id\":\""+id.String()+"\",\"type\":\"subscription.created\",\"subscription_id\":7}\n\n")
+		io.WriteString(w, "event: reset\ndata: {}\n\n")
+	}))
+	defer server.Close()
+
+	stream, err := client.New(server.URL).StreamSubscriptions(context.Background(), uuid.Nil, "41")
+	require.NoError(t, err)
+	defer stream.Close()
+
+	event, err := stream.Next()
+	require.NoError(t, err)
+	assert.Equal(t, client.EventSubscriptionCreated, event.Type)
+	assert.Equal(t, int64(7), event.SubscriptionID)
+	assert.Equal(t, id.String(), stream.LastEventID)
+
+	event, err = stream.Next()
+	require.NoError(t, err)
+	assert.Equal(t, client.EventReset, event.Type)
+
+	_, err = stream.Next()
+	assert.ErrorIs(t, err, io.EOF)
+}
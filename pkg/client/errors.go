@@ -0,0 +1,112 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxErrorBody limits how much of an error response is read
+const maxErrorBody = 64 << 10
+
+var (
+	ErrBadRequest = errors.New("bad request")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden = errors.New("forbidden")
+	ErrNotFound = errors.New("not found")
+	ErrConflict = errors.New("conflict")
+	// ErrPreconditionFailed is returned when If-Match version is not the current one
+	ErrPreconditionFailed = errors.New("precondition failed")
+	ErrUnsupportedMediaType = errors.New("unsupported media type")
+	// ErrUnprocessable is returned when Idempotency-Key was used for a different request
+	ErrUnprocessable = errors.New("unprocessable entity")
+	ErrRateLimited = errors.New("rate limited")
+	ErrServer = errors.New("server error")
+)
+
+var statusErrors = map[int]error{
+	http.StatusBadRequest: ErrBadRequest,
+	http.StatusUnauthorized: ErrUnauthorized,
+	http.StatusForbidden: ErrForbidden,
+	http.StatusNotFound: ErrNotFound,
+	http.StatusConflict: ErrConflict,
+	http.StatusPreconditionFailed: ErrPreconditionFailed,
+	http.StatusUnsupportedMediaType: ErrUnsupportedMediaType,
+	http.StatusUnprocessableEntity: ErrUnprocessable,
+	http.StatusTooManyRequests: ErrRateLimited,
+}
+
+// APIError is an error response, Message is the message of the server
+type APIError struct {
+	StatusCode int
+	Message string
+	// RetryAfter is when the server asks to repeat the request, 0 when it does not
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("%d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("%d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// Is matches errors of this package by status code, any 5xx is ErrServer
+func (e *APIError) Is(target error) bool {
+	if target == ErrServer {
+		return e.StatusCode >= http.StatusInternalServerError
+	}
+	return statusErrors[e.StatusCode] == target
+}
+
+// readError reads error response and closes its body. Handlers respond
+// with the message as JSON string, the router with {"message": ...} and
+// GraphQL with {"errors": [{"message": ...}]}
+func readError(res *http.Response) *APIError {
+	defer res.Body.Close()
+
+	apiErr := &APIError{StatusCode: res.StatusCode, RetryAfter: retryAfter(res.Header.Get("Retry-After"))}
+	body, _ := io.ReadAll(io.LimitReader(res.Body, maxErrorBody))
+
+	var message string
+	var object struct {
+		Message string `json:"message"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	switch {
+	case json.Unmarshal(body, &message) == nil:
+		apiErr.Message = message
+	case json.Unmarshal(body, &object) == nil && object.Message != "":
+		apiErr.Message = object.Message
+	case json.Unmarshal(body, &object) == nil && len(object.Errors) > 0:
+		messages := make([]string, len(object.Errors))
+		for i, e := range object.Errors {
+			messages[i] = e.Message
+		}
+		apiErr.Message = strings.Join(messages, "; ")
+	default:
+		apiErr.Message = strings.TrimSpace(string(body))
+	}
+	return apiErr
+}
+
+// retryAfter parses Retry-After given in seconds or as HTTP date
+func retryAfter(header string) time.Duration {
+	if header == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(header); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(header); err == nil {
+		return max(time.Until(at), 0)
+	}
+	return 0
+}
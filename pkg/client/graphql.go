@@ -0,0 +1,58 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// GraphQLError is an error of a field, data of other fields is still returned
+type GraphQLError struct {
+	Message string `json:"message"`
+	Path []any `json:"path,omitempty"`
+}
+
+// GraphQLErrors are field errors of a query that was run
+type GraphQLErrors []GraphQLError
+
+func (e GraphQLErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Message
+	}
+	return "graphql: " + strings.Join(messages, "; ")
+}
+
+// GraphQL runs query and decodes its data into out. Invalid or too
+// complex queries fail with ErrBadRequest, errors of fields are returned
+// as GraphQLErrors after out is filled with the rest of data
+func (c *Client) GraphQL(ctx context.Context, query string, variables map[string]any, out any) error {
+	body := struct {
+		Query string `json:"query"`
+		Variables map[string]any `json:"variables,omitempty"`
+	}{query, variables}
+	r, err := newRequest(http.MethodPost, "/v1/graphql").json(body)
+	if err != nil {
+		return err
+	}
+	r.readOnly = true
+
+	var result struct {
+		Data json.RawMessage `json:"data"`
+		Errors GraphQLErrors `json:"errors"`
+	}
+	if _, err := c.do(ctx, r, &result); err != nil {
+		return err
+	}
+
+	if out != nil && len(result.Data) > 0 {
+		if err := json.Unmarshal(result.Data, out); err != nil {
+			return err
+		}
+	}
+	if len(result.Errors) > 0 {
+		return result.Errors
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// CreateHousehold creates household owned by owner, the authenticated
+// user when owner is not set
+func (c *Client) CreateHousehold(ctx context.Context, name string, owner uuid.UUID) (*Household, error) {
+	body := struct {
+		Name string `json:"name"`
+		OwnerID uuid.UUID `json:"owner_id"`
+	}{name, owner}
+	r, err := newRequest(http.MethodPost, "/v1/households").json(body)
+	if err != nil {
+		return nil, err
+	}
+
+	var household Household
+	if _, err := c.do(ctx, r, &household); err != nil {
+		return nil, err
+	}
+	return &household, nil
+}
+
+// ListHouseholds returns households of user, the authenticated user when
+// user is not set
+func (c *Client) ListHouseholds(ctx context.Context, userID uuid.UUID) ([]Household, error) {
+	r := newRequest(http.MethodGet, "/v1/households")
+	if userID != uuid.Nil {
+		r.set("user", userID.String())
+	}
+
+	var households []Household
+	_, err := c.do(ctx, r, &households)
+	return households, err
+}
+
+func (c *Client) GetHousehold(ctx context.Context, id int64) (*Household, error) {
+	var household Household
+	if _, err := c.do(ctx, newRequest(http.MethodGet, fmt.Sprintf("/v1/households/%d", id)), &household); err != nil {
+		return nil, err
+	}
+	return &household, nil
+}
+
+func (c *Client) DeleteHousehold(ctx context.Context, id int64) error {
+	_, err := c.do(ctx, newRequest(http.MethodDelete, fmt.Sprintf("/v1/households/%d", id)), nil)
+	return err
+}
+
+func (c *Client) AddHouseholdMember(ctx context.Context, id int64, userID uuid.UUID) error {
+	body := struct {
+		UserID uuid.UUID `json:"user_id"`
+	}{userID}
+	r, err := newRequest(http.MethodPost, fmt.Sprintf("/v1/households/%d/members", id)).json(body)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.do(ctx, r, nil)
+	return err
+}
+
+func (c *Client) RemoveHouseholdMember(ctx context.Context, id int64, userID uuid.UUID) error {
+	_, err := c.do(ctx, newRequest(http.MethodDelete, fmt.Sprintf("/v1/households/%d/members/%s", id, userID)), nil)
+	return err
+}
+
+// Settle returns balances of members between months (MM-YYYY)
+func (c *Client) Settle(ctx context.Context, id int64, start, end string) (*Settlement, error) {
+	r := newRequest(http.MethodGet, fmt.Sprintf("/v1/households/%d/settle", id)).set("start", start).set("end", end)
+
+	var settlement Settlement
+	if _, err := c.do(ctx, r, &settlement); err != nil {
+		return nil, err
+	}
+	return &settlement, nil
+}
@@ -0,0 +1,306 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// SubscriptionFilter narrows lists, zero fields match everything
+type SubscriptionFilter struct {
+	UserID uuid.UUID
+	Service string
+}
+
+func (f SubscriptionFilter) apply(r *request) *request {
+	if f.UserID != uuid.Nil {
+		r.set("user", f.UserID.String())
+	}
+	return r.set("service", f.Service)
+}
+
+// TotalQuery selects subscriptions to sum up, UserID defaults to the
+// authenticated user and End to the current month
+type TotalQuery struct {
+	UserID uuid.UUID
+	Service string
+	Start string
+	End string
+}
+
+func (q TotalQuery) apply(r *request) *request {
+	if q.UserID != uuid.Nil {
+		r.set("user", q.UserID.String())
+	}
+	return r.set("service", q.Service).set("start", q.Start).set("end", q.End)
+}
+
+// ListSubscriptions returns subscriptions matching filter, v1 lists carry
+// no ids, use ListSubscriptionsV2 to get them
+func (c *Client) ListSubscriptions(ctx context.Context, filter SubscriptionFilter) ([]Subscription, error) {
+	var subs []Subscription
+	_, err := c.do(ctx, filter.apply(newRequest(http.MethodGet, "/v1/subscriptions")), &subs)
+	return subs, err
+}
+
+func (c *Client) GetSubscription(ctx context.Context, id int64) (*Subscription, error) {
+	var sub Subscription
+	res, err := c.do(ctx, newRequest(http.MethodGet, fmt.Sprintf("/v1/subscriptions/%d", id)), &sub)
+	if err != nil {
+		return nil, err
+	}
+	sub.ID = id
+	sub.Version = versionOf(res)
+	return &sub, nil
+}
+
+// CreateSubscription creates sub and sets its ID and Version. Requests
+// get Idempotency-Key so that retries do not create it twice
+func (c *Client) CreateSubscription(ctx context.Context, sub *Subscription) error {
+	r, err := newRequest(http.MethodPost, "/v1/subscriptions").json(sub)
+	if err != nil {
+		return err
+	}
+	r.header.Set("Idempotency-Key", uuid.NewString())
+
+	res, err := c.do(ctx, r, nil)
+	if err != nil {
+		return err
+	}
+	if sub.ID, err = idOf(res); err != nil {
+		return err
+	}
+	sub.Version = versionOf(res)
+	return nil
+}
+
+// UpdateSubscription replaces subscription sub.ID, when sub.Version is set
+// it fails with ErrPreconditionFailed if the subscription changed since
+func (c *Client) UpdateSubscription(ctx context.Context, sub *Subscription) error {
+	r, err := newRequest(http.MethodPut, fmt.Sprintf("/v1/subscriptions/%d", sub.ID)).json(sub)
+	if err != nil {
+		return err
+	}
+
+	res, err := c.do(ctx, r.ifMatch(sub.Version), sub)
+	if err != nil {
+		return err
+	}
+	sub.Version = versionOf(res)
+	return nil
+}
+
+// DeleteSubscription deletes subscription, version 0 deletes any version
+func (c *Client) DeleteSubscription(ctx context.Context, id int64, version int) error {
+	_, err := c.do(ctx, newRequest(http.MethodDelete, fmt.Sprintf("/v1/subscriptions/%d", id)).ifMatch(version), nil)
+	return err
+}
+
+// TotalCost returns the cost of subscriptions between months (MM-YYYY)
+func (c *Client) TotalCost(ctx context.Context, query TotalQuery) (*Total, error) {
+	var total Total
+	if _, err := c.do(ctx, query.apply(newRequest(http.MethodGet, "/v1/subscriptions/total")), &total); err != nil {
+		return nil, err
+	}
+	return &total, nil
+}
+
+// Batch applies operations and returns their results, failed operations
+// of non-atomic batches are reported in results rather than as error
+func (c *Client) Batch(ctx context.Context, batch BatchRequest) ([]BatchResult, error) {
+	r, err := newRequest(http.MethodPost, "/v1/subscriptions/batch").json(batch)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []BatchResult
+	_, err = c.do(ctx, r, &results)
+	return results, err
+}
+
+// ExportSubscriptions streams subscriptions in format csv or ndjson, the
+// caller closes the returned reader
+func (c *Client) ExportSubscriptions(ctx context.Context, format string, filter SubscriptionFilter) (io.ReadCloser, error) {
+	res, err := c.send(ctx, filter.apply(newRequest(http.MethodGet, "/v1/subscriptions/export").set("format", format)))
+	if err != nil {
+		return nil, err
+	}
+	return res.Body, nil
+}
+
+// ImportOptions of import, Format is csv or ndjson
+type ImportOptions struct {
+	Format string
+	// Columns maps CSV columns, e.g. service_name=Service,price=Cost
+	Columns string
+	DryRun bool
+	// Async runs the import as a job, large bodies always do
+	Async bool
+}
+
+// ImportResult is Report of imports finished in the request or Job of
+// those running in background
+type ImportResult struct {
+	Report *ImportReport
+	Job *ImportJob
+}
+
+// ImportSubscriptions imports rows read from body
+func (c *Client) ImportSubscriptions(ctx context.Context, body io.Reader, opts ImportOptions) (*ImportResult, error) {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+
+	r := newRequest(http.MethodPost, "/v1/subscriptions/import").set("format", opts.Format).set("columns", opts.Columns)
+	if opts.DryRun {
+		r.set("dry_run", "true")
+	}
+	if opts.Async {
+		r.set("async", "true")
+	}
+	r.body = data
+	r.contentType = "text/csv"
+	if opts.Format == "ndjson" {
+		r.contentType = "application/x-ndjson"
+	}
+
+	var raw json.RawMessage
+	res, err := c.do(ctx, r, &raw)
+	if err != nil {
+		return nil, err
+	}
+
+	var result ImportResult
+	if res.StatusCode == http.StatusAccepted {
+		err = json.Unmarshal(raw, &result.Job)
+	} else {
+		err = json.Unmarshal(raw, &result.Report)
+	}
+	return &result, err
+}
+
+func (c *Client) GetImportJob(ctx context.Context, id uuid.UUID) (*ImportJob, error) {
+	var job ImportJob
+	if _, err := c.do(ctx, newRequest(http.MethodGet, "/v1/subscriptions/import/"+id.String()), &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// EventReset is sent when events after Last-Event-ID are no longer kept,
+// subscriptions have to be fetched again
+const EventReset = "reset"
+
+// EventStream reads Server-Sent Events of subscription changes
+type EventStream struct {
+	body io.ReadCloser
+	scanner *bufio.Scanner
+	// LastEventID is the id of the last received event, pass it to
+	// StreamSubscriptions to resume after reconnect
+	LastEventID string
+}
+
+// StreamSubscriptions subscribes to changes of subscriptions of user,
+// own subscriptions when user is not set. Events after lastEventID are
+// sent first
+func (c *Client) StreamSubscriptions(ctx context.Context, userID uuid.UUID, lastEventID string) (*EventStream, error) {
+	r := newRequest(http.MethodGet, "/v1/subscriptions/stream")
+	if userID != uuid.Nil {
+		r.set("user", userID.String())
+	}
+	r.header.Set("Accept", "text/event-stream")
+	if lastEventID != "" {
+		r.header.Set("Last-Event-ID", lastEventID)
+	}
+
+	res, err := c.send(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	return &EventStream{body: res.Body, scanner: bufio.NewScanner(res.Body), LastEventID: lastEventID}, nil
+}
+
+// Next blocks until the next event, the reset event has only Type set.
+// It returns io.EOF when the server closes the stream
+func (s *EventStream) Next() (*Event, error) {
+	var id, typ string
+	var data strings.Builder
+	for s.scanner.Scan() {
+		line := s.scanner.Text()
+		if line == "" {
+			if typ == "" && data.Len() == 0 {
+				continue
+			}
+			if id != "" {
+				s.LastEventID = id
+			}
+			if typ == EventReset {
+				return &Event{Type: EventReset}, nil
+			}
+
+			var event Event
+			if err := json.Unmarshal([]byte(data.String()), &event); err != nil {
+				return nil, fmt.Errorf("decode event %s: %w", id, err)
+			}
+			return &event, nil
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "id":
+			id = value
+		case "event":
+			typ = value
+		case "data":
+			data.WriteString(value)
+		}
+	}
+	if err := s.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+func (s *EventStream) Close() error {
+	return s.body.Close()
+}
+
+func (c *Client) GetSplit(ctx context.Context, subscriptionID int64) (*Split, error) {
+	var split Split
+	if _, err := c.do(ctx, newRequest(http.MethodGet, splitPath(subscriptionID)), &split); err != nil {
+		return nil, err
+	}
+	return &split, nil
+}
+
+// SetSplit shares subscription in household of split
+func (c *Client) SetSplit(ctx context.Context, subscriptionID int64, split Split) (*Split, error) {
+	r, err := newRequest(http.MethodPut, splitPath(subscriptionID)).json(split)
+	if err != nil {
+		return nil, err
+	}
+
+	var saved Split
+	if _, err := c.do(ctx, r, &saved); err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
+
+func (c *Client) DeleteSplit(ctx context.Context, subscriptionID int64) error {
+	_, err := c.do(ctx, newRequest(http.MethodDelete, splitPath(subscriptionID)), nil)
+	return err
+}
+
+func splitPath(subscriptionID int64) string {
+	return "/v1/subscriptions/" + strconv.FormatInt(subscriptionID, 10) + "/split"
+}
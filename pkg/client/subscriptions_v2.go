@@ -0,0 +1,117 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+)
+
+// ListOptions selects a page of v2 subscriptions
+type ListOptions struct {
+	SubscriptionFilter
+	// Limit is the page size, the server uses 100 when it is 0 and at most 1000
+	Limit int
+	// Cursor is Next of the previous page
+	Cursor string
+}
+
+// ListSubscriptionsV2 returns one page of subscriptions ordered by id
+func (c *Client) ListSubscriptionsV2(ctx context.Context, opts ListOptions) (*SubscriptionPage, error) {
+	r := opts.apply(newRequest(http.MethodGet, "/v2/subscriptions")).set("cursor", opts.Cursor)
+	if opts.Limit > 0 {
+		r.set("limit", strconv.Itoa(opts.Limit))
+	}
+
+	var page SubscriptionPage
+	if _, err := c.do(ctx, r, &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// SubscriptionsV2 iterates over all subscriptions starting at opts.Cursor,
+// pages are fetched as the iteration goes. Iteration stops after the
+// first error
+func (c *Client) SubscriptionsV2(ctx context.Context, opts ListOptions) iter.Seq2[SubscriptionV2, error] {
+	return func(yield func(SubscriptionV2, error) bool) {
+		for {
+			page, err := c.ListSubscriptionsV2(ctx, opts)
+			if err != nil {
+				yield(SubscriptionV2{}, err)
+				return
+			}
+			for _, sub := range page.Items {
+				if !yield(sub, nil) {
+					return
+				}
+			}
+			if page.Next == "" {
+				return
+			}
+			opts.Cursor = page.Next
+		}
+	}
+}
+
+func (c *Client) GetSubscriptionV2(ctx context.Context, id int64) (*SubscriptionV2, error) {
+	var sub SubscriptionV2
+	res, err := c.do(ctx, newRequest(http.MethodGet, fmt.Sprintf("/v2/subscriptions/%d", id)), &sub)
+	if err != nil {
+		return nil, err
+	}
+	sub.Version = versionOf(res)
+	return &sub, nil
+}
+
+// CreateSubscriptionV2 creates sub and updates it with the stored one.
+// Requests get Idempotency-Key so that retries do not create it twice
+func (c *Client) CreateSubscriptionV2(ctx context.Context, sub *SubscriptionV2) error {
+	r, err := newRequest(http.MethodPost, "/v2/subscriptions").json(sub)
+	if err != nil {
+		return err
+	}
+	r.header.Set("Idempotency-Key", uuid.NewString())
+
+	res, err := c.do(ctx, r, sub)
+	if err != nil {
+		return err
+	}
+	sub.Version = versionOf(res)
+	return nil
+}
+
+// UpdateSubscriptionV2 replaces subscription sub.ID, when sub.Version is
+// set it fails with ErrPreconditionFailed if the subscription changed since
+func (c *Client) UpdateSubscriptionV2(ctx context.Context, sub *SubscriptionV2) error {
+	r, err := newRequest(http.MethodPut, fmt.Sprintf("/v2/subscriptions/%d", sub.ID)).json(sub)
+	if err != nil {
+		return err
+	}
+
+	res, err := c.do(ctx, r.ifMatch(sub.Version), sub)
+	if err != nil {
+		return err
+	}
+	sub.Version = versionOf(res)
+	return nil
+}
+
+// DeleteSubscriptionV2 deletes subscription, version 0 deletes any version
+func (c *Client) DeleteSubscriptionV2(ctx context.Context, id int64, version int) error {
+	_, err := c.do(ctx, newRequest(http.MethodDelete, fmt.Sprintf("/v2/subscriptions/%d", id)).ifMatch(version), nil)
+	return err
+}
+
+// TotalCostV2 returns the cost of subscriptions in whole months between
+// ISO dates (YYYY-MM-DD)
+func (c *Client) TotalCostV2(ctx context.Context, query TotalQuery) (*TotalV2, error) {
+	var total TotalV2
+	if _, err := c.do(ctx, query.apply(newRequest(http.MethodGet, "/v2/subscriptions/total")), &total); err != nil {
+		return nil, err
+	}
+	return &total, nil
+}
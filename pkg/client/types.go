@@ -0,0 +1,304 @@
+package client
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Subscription is a subscription of v1 API, dates are months (MM-YYYY).
+// v1 bodies carry neither id nor version, the client reads them from
+// Location and ETag headers where the server sends them
+type Subscription struct {
+	ID int64 `json:"-"`
+	Version int `json:"-"`
+	ServiceName string `json:"service_name"`
+	Price int `json:"price"`
+	UserID uuid.UUID `json:"user_id"`
+	StartDate string `json:"start_date"`
+	EndDate string `json:"end_date,omitempty"`
+	// TrialEndDate is the first paid month, users are reminded before it.
+	// Totals count the subscription from start date all the same
+	TrialEndDate string `json:"trial_end_date,omitempty"`
+}
+
+// Total is the cost of subscriptions in currency of the user
+type Total struct {
+	Total int `json:"total"`
+	Currency string `json:"currency"`
+}
+
+// Money is an amount in whole units of currency
+type Money struct {
+	Amount int `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+// SubscriptionV2 is a subscription of v2 API, dates are ISO dates and
+// price is in currency of the user. Version is read from ETag header
+type SubscriptionV2 struct {
+	ID int64 `json:"id"`
+	Version int `json:"-"`
+	ServiceName string `json:"service_name"`
+	// Price currency may be left empty in requests
+	Price Money `json:"price"`
+	UserID uuid.UUID `json:"user_id"`
+	// StartDate is the first day of the first month
+	StartDate string `json:"start_date"`
+	// EndDate is the last day of the last month
+	EndDate string `json:"end_date,omitempty"`
+	// TrialEndDate is the first day of the first paid month
+	TrialEndDate string `json:"trial_end_date,omitempty"`
+}
+
+// SubscriptionPage is a page of v2 subscriptions, Next is empty on the last page
+type SubscriptionPage struct {
+	Items []SubscriptionV2 `json:"items"`
+	Next string `json:"next,omitempty"`
+}
+
+// TotalV2 is the cost of subscriptions between ISO dates Start and End
+type TotalV2 struct {
+	Total Money `json:"total"`
+	Start string `json:"start"`
+	End string `json:"end"`
+}
+
+const (
+	BatchOpCreate = "create"
+	BatchOpUpdate = "update"
+	BatchOpDelete = "delete"
+)
+
+// BatchOperation is one item of a batch, ID and Version are used by
+// update and delete, Subscription by create and update
+type BatchOperation struct {
+	Op string `json:"op"`
+	ID int64 `json:"id,omitempty"`
+	Version int `json:"version,omitempty"`
+	Subscription *Subscription `json:"subscription,omitempty"`
+}
+
+type BatchRequest struct {
+	// Atomic applies all operations or none of them
+	Atomic bool `json:"atomic"`
+	Operations []BatchOperation `json:"operations"`
+}
+
+// BatchResult is the outcome of operation Index with its HTTP status
+type BatchResult struct {
+	Index int `json:"index"`
+	Status int `json:"status"`
+	ID int64 `json:"id,omitempty"`
+	Version int `json:"version,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+const (
+	ImportJobRunning = "running"
+	ImportJobDone = "done"
+	ImportJobFailed = "failed"
+)
+
+type ImportRowError struct {
+	Line int `json:"line"`
+	Error string `json:"error"`
+}
+
+// ImportReport is the outcome of an import, in dry-run mode Imported
+// counts rows that passed validation
+type ImportReport struct {
+	DryRun bool `json:"dry_run"`
+	TotalRows int `json:"total_rows"`
+	Imported int `json:"imported"`
+	Failed int `json:"failed"`
+	Errors []ImportRowError `json:"errors,omitempty"`
+}
+
+type ImportJob struct {
+	ID uuid.UUID `json:"id"`
+	Status string `json:"status"`
+	Format string `json:"format"`
+	Report ImportReport `json:"report"`
+	Error string `json:"error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+const (
+	SplitEqual = "equal"
+	SplitPercentage = "percentage"
+	SplitFixed = "fixed"
+)
+
+type Household struct {
+	ID int64 `json:"id"`
+	Name string `json:"name"`
+	OwnerID uuid.UUID `json:"owner_id"`
+	Members []uuid.UUID `json:"members"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type SplitPart struct {
+	UserID uuid.UUID `json:"user_id"`
+	Value int `json:"value"`
+}
+
+// Share is the monthly amount a member pays for a subscription
+type Share struct {
+	UserID uuid.UUID `json:"user_id"`
+	Amount int `json:"amount"`
+}
+
+// Split shares subscription among household members, Parts are given to
+// members other than the payer and Shares are computed by the server
+type Split struct {
+	SubscriptionID int64 `json:"subscription_id"`
+	HouseholdID int64 `json:"household_id"`
+	Rule string `json:"rule"`
+	Parts []SplitPart `json:"parts,omitempty"`
+	Shares []Share `json:"shares,omitempty"`
+}
+
+type MemberBalance struct {
+	UserID uuid.UUID `json:"user_id"`
+	Paid int `json:"paid"`
+	Share int `json:"share"`
+	// Balance is positive for members who are owed money
+	Balance int `json:"balance"`
+}
+
+type Transfer struct {
+	From uuid.UUID `json:"from"`
+	To uuid.UUID `json:"to"`
+	Amount int `json:"amount"`
+}
+
+type Settlement struct {
+	HouseholdID int64 `json:"household_id"`
+	Start string `json:"start"`
+	End string `json:"end"`
+	Members []MemberBalance `json:"members"`
+	Transfers []Transfer `json:"transfers"`
+}
+
+type NotificationPreferences struct {
+	Email bool `json:"email"`
+	// RemindDaysBefore is how many days before renewal to remind, 0 disables reminders
+	RemindDaysBefore int `json:"remind_days_before"`
+	Webhook bool `json:"webhook"`
+}
+
+type User struct {
+	ID uuid.UUID `json:"id"`
+	Name string `json:"name"`
+	Email string `json:"email,omitempty"`
+	Currency string `json:"currency"`
+	Timezone string `json:"timezone"`
+	Notifications NotificationPreferences `json:"notifications"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ErasureReceipt confirms erasure of user data
+type ErasureReceipt struct {
+	ID uuid.UUID `json:"id"`
+	TenantID string `json:"tenant_id"`
+	Subject string `json:"subject"`
+	// Erased counts removed or anonymised rows per table
+	Erased map[string]int64 `json:"erased"`
+	ErasedAt time.Time `json:"erased_at"`
+	Signature string `json:"signature"`
+}
+
+const (
+	EventSubscriptionCreated = "subscription.created"
+	EventSubscriptionUpdated = "subscription.updated"
+	EventSubscriptionPriceChanged = "subscription.price_changed"
+	EventSubscriptionCancelled = "subscription.cancelled"
+	EventSubscriptionDeleted = "subscription.deleted"
+)
+
+// Event is a change of subscription, Subscription is not set for deleted ones
+type Event struct {
+	ID uuid.UUID `json:"id"`
+	Type string `json:"type"`
+	TenantID string `json:"tenant_id"`
+	OccurredAt time.Time `json:"occurred_at"`
+	SubscriptionID int64 `json:"subscription_id"`
+	UserID uuid.UUID `json:"user_id"`
+	Subscription *Subscription `json:"subscription,omitempty"`
+}
+
+type Webhook struct {
+	ID int64 `json:"id"`
+	TenantID string `json:"tenant_id"`
+	URL string `json:"url"`
+	// Events filters sent events, empty means all of them
+	Events []string `json:"events"`
+	// Secret signs payloads, it is returned only on create
+	Secret string `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+const (
+	DeliveryPending = "pending"
+	DeliverySending = "sending"
+	DeliveryDelivered = "delivered"
+	DeliveryDead = "dead"
+)
+
+type WebhookDelivery struct {
+	ID int64 `json:"id"`
+	WebhookID int64 `json:"webhook_id"`
+	EventID uuid.UUID `json:"event_id"`
+	EventType string `json:"event_type"`
+	Payload json.RawMessage `json:"payload"`
+	Status string `json:"status"`
+	Attempts int `json:"attempts"`
+	ResponseStatus int `json:"response_status,omitempty"`
+	LastError string `json:"last_error,omitempty"`
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+	DeliveredAt *time.Time `json:"delivered_at,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+const (
+	ScopeRead = "read"
+	ScopeWrite = "write"
+	ScopeAdmin = "admin"
+)
+
+type APIKey struct {
+	ID int64 `json:"id"`
+	// TenantID is empty for platform keys not bound to a tenant
+	TenantID string `json:"tenant_id,omitempty"`
+	Name string `json:"name"`
+	Prefix string `json:"prefix"`
+	Scopes []string `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NewAPIKey is returned on create and rotate, Key is not shown again
+type NewAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+type CreateAPIKeyRequest struct {
+	// TenantID binds the key to a tenant, only platform callers may set it
+	TenantID string `json:"tenant_id,omitempty"`
+	Name string `json:"name"`
+	Scopes []string `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+type Tenant struct {
+	ID string `json:"id"`
+	Name string `json:"name"`
+	CreatedAt time.Time `json:"created_at,omitzero"`
+}
@@ -0,0 +1,57 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// Me stands for the authenticated user in place of user id
+const Me = "me"
+
+func userPath(id string) string {
+	return "/v1/users/" + url.PathEscape(id)
+}
+
+// GetUser returns profile of user id or Me
+func (c *Client) GetUser(ctx context.Context, id string) (*User, error) {
+	var user User
+	if _, err := c.do(ctx, newRequest(http.MethodGet, userPath(id)), &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// SaveUser creates or replaces profile of user id or Me
+func (c *Client) SaveUser(ctx context.Context, id string, user User) (*User, error) {
+	r, err := newRequest(http.MethodPut, userPath(id)).json(user)
+	if err != nil {
+		return nil, err
+	}
+
+	var saved User
+	if _, err := c.do(ctx, r, &saved); err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
+
+// EraseUser erases all data of user and returns the signed receipt
+func (c *Client) EraseUser(ctx context.Context, id string) (*ErasureReceipt, error) {
+	var receipt ErasureReceipt
+	if _, err := c.do(ctx, newRequest(http.MethodDelete, userPath(id)), &receipt); err != nil {
+		return nil, err
+	}
+	return &receipt, nil
+}
+
+// ExportUser streams ZIP archive with all data of user, the caller
+// closes the returned reader
+func (c *Client) ExportUser(ctx context.Context, id string) (io.ReadCloser, error) {
+	res, err := c.send(ctx, newRequest(http.MethodGet, userPath(id)+"/export"))
+	if err != nil {
+		return nil, err
+	}
+	return res.Body, nil
+}
@@ -0,0 +1,54 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// CreateWebhook registers url for events, all events when none are given.
+// The returned webhook has Secret signing payloads, it is not shown again
+func (c *Client) CreateWebhook(ctx context.Context, url string, events []string) (*Webhook, error) {
+	body := struct {
+		URL string `json:"url"`
+		Events []string `json:"events"`
+	}{url, events}
+	r, err := newRequest(http.MethodPost, "/v1/webhooks").json(body)
+	if err != nil {
+		return nil, err
+	}
+
+	var webhook Webhook
+	if _, err := c.do(ctx, r, &webhook); err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+func (c *Client) ListWebhooks(ctx context.Context) ([]Webhook, error) {
+	var webhooks []Webhook
+	_, err := c.do(ctx, newRequest(http.MethodGet, "/v1/webhooks"), &webhooks)
+	return webhooks, err
+}
+
+func (c *Client) DeleteWebhook(ctx context.Context, id int64) error {
+	_, err := c.do(ctx, newRequest(http.MethodDelete, fmt.Sprintf("/v1/webhooks/%d", id)), nil)
+	return err
+}
+
+func (c *Client) ListDeliveries(ctx context.Context, webhookID int64) ([]WebhookDelivery, error) {
+	var deliveries []WebhookDelivery
+	_, err := c.do(ctx, newRequest(http.MethodGet, fmt.Sprintf("/v1/webhooks/%d/deliveries", webhookID)), &deliveries)
+	return deliveries, err
+}
+
+// Redeliver queues the delivery again with a fresh set of attempts
+func (c *Client) Redeliver(ctx context.Context, webhookID, deliveryID int64) (*WebhookDelivery, error) {
+	r := newRequest(http.MethodPost, fmt.Sprintf("/v1/webhooks/%d/deliveries/%d/redeliver", webhookID, deliveryID))
+
+	var delivery WebhookDelivery
+	if _, err := c.do(ctx, r, &delivery); err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
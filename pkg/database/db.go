@@ -9,8 +9,9 @@ import (
 )
 
 
-func Connect() (*sql.DB, error) {
-	dsn := fmt.Sprintf(
+// DSN returns connection string built from DB_* env
+func DSN() string {
+	return fmt.Sprintf(
 		"host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
 		os.Getenv("DB_HOST"),
 		os.Getenv("DB_PORT"),
@@ -19,6 +20,8 @@ func Connect() (*sql.DB, error) {
 		os.Getenv("DB_NAME"),
 		os.Getenv("DB_SSLMODE"),
 	)
-	
-	return sql.Open("postgres", dsn)
+}
+
+func Connect() (*sql.DB, error) {
+	return sql.Open("postgres", DSN())
 }
\ No newline at end of file